@@ -0,0 +1,20 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/viper"
+	"gocloud.dev/blob"
+	_ "gocloud.dev/blob/fileblob"
+)
+
+// initBucket opens the media bucket set in the loaded config.
+func initBucket(ctx context.Context) (*blob.Bucket, error) {
+	bucket, err := blob.OpenBucket(ctx, viper.GetString("bucket.url"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open bucket: %w", err)
+	}
+
+	return bucket, nil
+}
@@ -0,0 +1,95 @@
+package cmd
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/database/postgres"
+	_ "github.com/golang-migrate/migrate/v4/source/file"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+	_ "github.com/lib/pq"
+	"github.com/spf13/viper"
+
+	"github.com/charlieegan3/photos/internal/pkg/database"
+)
+
+// initDatabase opens a connection to the database set in the loaded config.
+func initDatabase(ctx context.Context) (*sql.DB, error) {
+	params := viper.GetStringMapString("database.params")
+	connectionString := viper.GetString("database.connectionString")
+
+	db, err := database.Init(
+		ctx,
+		connectionString,
+		params,
+		params["dbname"],
+		viper.GetBool("database.createDatabase"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to init DB: %w", err)
+	}
+
+	return db, nil
+}
+
+// newMigrator returns a migrate instance for the embedded migrations. The
+// caller must close it when done.
+func newMigrator(ctx context.Context, db *sql.DB) (*migrate.Migrate, error) {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get DB connection: %w", err)
+	}
+
+	// if the schema migrations table has the old name, move it
+	_, err = conn.ExecContext(
+		ctx,
+		"ALTER TABLE IF EXISTS schema_migrations RENAME TO "+viper.GetString("database.migrationsTable"),
+	)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to rename migrations table: %w", err)
+	}
+
+	driver, err := postgres.WithConnection(ctx, conn, &postgres.Config{
+		MigrationsTable: viper.GetString("database.migrationsTable"),
+	})
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to init DB driver: %w", err)
+	}
+
+	source, err := iofs.New(database.Migrations, "migrations")
+	if err != nil {
+		driver.Close()
+		return nil, fmt.Errorf("failed to create migration source: %w", err)
+	}
+	m, err := migrate.NewWithInstance("iofs", source, "postgres", driver)
+	if err != nil {
+		driver.Close()
+		return nil, fmt.Errorf("failed to load migrations: %w", err)
+	}
+
+	return m, nil
+}
+
+// migrateDatabase runs all pending migrations.
+func migrateDatabase(ctx context.Context, db *sql.DB) error {
+	m, err := newMigrator(ctx, db)
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	err = m.Up()
+	if err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return fmt.Errorf("failed to migrate up: %w", err)
+	}
+
+	log.Println("migrated up")
+
+	return nil
+}
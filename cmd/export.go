@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"context"
+	"log"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/charlieegan3/photos/internal/pkg/archive"
+)
+
+// exportCmd writes the whole library to a portable archive.
+var exportCmd = &cobra.Command{
+	Use:   "export <archive.tar.gz>",
+	Short: "export the library to an archive",
+	Long: `Export writes every database record as JSON and the original media and icon
+files from the bucket to a single gzipped tarball. Thumbnails are not included,
+they can be rebuilt with 'jobs thumbnails regenerate' after a restore.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(_ *cobra.Command, args []string) {
+		ctx := context.Background()
+
+		db, err := initDatabase(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer db.Close()
+
+		bucket, err := initBucket(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer bucket.Close()
+
+		// write to a temporary file so that a failed export does not leave a
+		// partial archive at the destination
+		tmpPath := args[0] + ".tmp"
+		f, err := os.Create(tmpPath)
+		if err != nil {
+			log.Fatalf("failed to create archive: %s", err)
+		}
+
+		err = archive.Export(ctx, db, bucket, f)
+		if err != nil {
+			f.Close()
+			os.Remove(tmpPath)
+			log.Fatalf("failed to export library: %s", err)
+		}
+
+		err = f.Close()
+		if err != nil {
+			log.Fatalf("failed to close archive: %s", err)
+		}
+
+		err = os.Rename(tmpPath, args[0])
+		if err != nil {
+			log.Fatalf("failed to move archive into place: %s", err)
+		}
+
+		log.Printf("exported library to %s", args[0])
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(exportCmd)
+}
@@ -0,0 +1,165 @@
+package cmd
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/charlieegan3/photos/internal/pkg/activity"
+	"github.com/charlieegan3/photos/internal/pkg/database"
+	"github.com/charlieegan3/photos/internal/pkg/models"
+)
+
+// activityFileSuffixes are the file types which can be parsed by
+// activity.ParseActivity, gzipped variants are found in Strava exports.
+var activityFileSuffixes = []string{".fit", ".gpx", ".tcx", ".fit.gz", ".gpx.gz", ".tcx.gz"}
+
+var jobsActivitiesCmd = &cobra.Command{
+	Use:   "activities",
+	Short: "Commands for managing recorded GPS activities",
+}
+
+// jobsActivitiesImportCmd loads FIT, GPX and TCX files into the database so
+// that the library has a record of where we were and when.
+var jobsActivitiesImportCmd = &cobra.Command{
+	Use:   "import <files or directories...>",
+	Short: "import FIT, GPX and TCX activity files",
+	Long: `Import parses each activity file and stores the activity and its points.
+Directories are walked for activity files. Activities which have already been
+imported, based on their start and end times, are skipped.`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(_ *cobra.Command, args []string) {
+		ctx := context.Background()
+
+		db, err := initDatabase(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer db.Close()
+
+		files, err := findActivityFiles(args)
+		if err != nil {
+			log.Fatalf("failed to list activity files: %s", err)
+		}
+
+		repo := database.NewActivityRepository(db)
+
+		var imported, skipped, failed int
+		for _, file := range files {
+			created, err := importActivityFile(ctx, repo, file)
+			if err != nil {
+				log.Printf("failed to import %s: %s", file, err)
+				failed++
+				continue
+			}
+
+			if created {
+				imported++
+			} else {
+				skipped++
+			}
+		}
+
+		log.Printf("imported %d activities, skipped %d, failed %d", imported, skipped, failed)
+
+		if failed > 0 {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	jobsActivitiesCmd.AddCommand(jobsActivitiesImportCmd)
+	jobsCmd.AddCommand(jobsActivitiesCmd)
+}
+
+// importActivityFile parses and stores a single activity file, returning false
+// when the activity was skipped.
+func importActivityFile(ctx context.Context, repo *database.ActivityRepository, file string) (bool, error) {
+	parsedActivity, parsedPoints, err := activity.ParseActivity(file)
+	if err != nil {
+		return false, err
+	}
+
+	if len(parsedPoints) == 0 {
+		log.Printf("skipping %s, it has no recorded points", file)
+		return false, nil
+	}
+
+	existing, err := repo.FindByTimes(ctx, parsedActivity.StartTime, parsedActivity.EndTime)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return false, err
+	}
+	if existing != nil {
+		log.Printf("skipping %s, already imported as activity %d", file, existing.ID)
+		return false, nil
+	}
+
+	points := make([]models.Point, 0, len(parsedPoints))
+	for _, p := range parsedPoints {
+		points = append(points, p.ToModel())
+	}
+
+	created, err := repo.CreateWithPoints(ctx, parsedActivity, points)
+	if err != nil {
+		return false, err
+	}
+
+	log.Printf("imported %s as activity %d with %d points", file, created.ID, len(points))
+
+	return true, nil
+}
+
+// findActivityFiles expands any directories in paths into the activity files
+// they contain.
+func findActivityFiles(paths []string) ([]string, error) {
+	var files []string
+
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to stat %s: %w", path, err)
+		}
+
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+
+		err = filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+
+			if !d.IsDir() && isActivityFile(p) {
+				files = append(files, p)
+			}
+
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to walk %s: %w", path, err)
+		}
+	}
+
+	return files, nil
+}
+
+func isActivityFile(path string) bool {
+	lowerPath := strings.ToLower(path)
+	for _, suffix := range activityFileSuffixes {
+		if strings.HasSuffix(lowerPath, suffix) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,143 @@
+package cmd
+
+import (
+	"context"
+	"log"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/charlieegan3/photos/internal/pkg/activity"
+	"github.com/charlieegan3/photos/internal/pkg/database"
+	"github.com/charlieegan3/photos/internal/pkg/geotag"
+	"github.com/charlieegan3/photos/internal/pkg/models"
+)
+
+var (
+	geotagMaxGap time.Duration
+	geotagDryRun bool
+)
+
+// jobsGeotagCmd locates existing media which have no GPS data using the
+// activity points recorded around the time they were taken.
+var jobsGeotagCmd = &cobra.Command{
+	Use:   "geotag [activity files or directories...]",
+	Short: "set locations for media without GPS data from activity tracks",
+	Long: `Geotag finds media without a location and interpolates one from the
+activity points recorded around the time they were taken. By default, points
+imported with 'jobs activities import' are used. When files or directories are
+given, points are read from those activity files instead.
+
+Only media with UTC correct timestamps are updated, the times on older media
+are not reliable enough to match against activity points.`,
+	Run: func(_ *cobra.Command, args []string) {
+		ctx := context.Background()
+
+		db, err := initDatabase(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer db.Close()
+
+		maxGap := geotagMaxGap
+		if maxGap == 0 {
+			maxGap = viper.GetDuration("geotag.maxGap")
+		}
+
+		finder := database.NewActivityRepository(db).PointsBetween
+		if len(args) > 0 {
+			points, err := loadActivityPoints(args)
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			finder = geotag.SlicePointFinder(points)
+		}
+
+		geotagger := geotag.NewGeotagger(finder, maxGap)
+
+		medias, err := database.AllMedias(ctx, db, false)
+		if err != nil {
+			log.Fatalf("failed to list medias: %s", err)
+		}
+
+		var located, missed, failed int
+		for i := range medias {
+			media := medias[i]
+			if media.Latitude != 0 || media.Longitude != 0 || !media.UTCCorrect || media.TakenAt.IsZero() {
+				continue
+			}
+
+			point, ok, err := geotagger.Locate(ctx, media.TakenAt)
+			if err != nil {
+				log.Printf("failed to geotag media %d: %s", media.ID, err)
+				failed++
+				continue
+			}
+			if !ok {
+				missed++
+				continue
+			}
+
+			media.Latitude = point.Latitude
+			media.Longitude = point.Longitude
+			media.Altitude = point.Altitude
+
+			log.Printf("media %d taken at %s located at %f,%f", media.ID, media.TakenAt, media.Latitude, media.Longitude)
+			located++
+
+			if geotagDryRun {
+				continue
+			}
+
+			_, err = database.UpdateMedias(ctx, db, []models.Media{media})
+			if err != nil {
+				log.Printf("failed to update media %d: %s", media.ID, err)
+				failed++
+			}
+		}
+
+		log.Printf("located %d medias, no points for %d, failed %d", located, missed, failed)
+
+		if failed > 0 {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	jobsGeotagCmd.Flags().DurationVar(
+		&geotagMaxGap,
+		"max-gap",
+		0,
+		"max time between a photo and the points used to locate it (default from geotag.maxGap or 15m)",
+	)
+	jobsGeotagCmd.Flags().BoolVar(&geotagDryRun, "dry-run", false, "log the locations found without saving them")
+
+	jobsCmd.AddCommand(jobsGeotagCmd)
+}
+
+// loadActivityPoints parses all the activity files in paths and returns their
+// points.
+func loadActivityPoints(paths []string) ([]models.Point, error) {
+	files, err := findActivityFiles(paths)
+	if err != nil {
+		return nil, err
+	}
+
+	var points []models.Point
+	for _, file := range files {
+		_, parsedPoints, err := activity.ParseActivity(file)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, p := range parsedPoints {
+			points = append(points, p.ToModel())
+		}
+	}
+
+	return points, nil
+}
@@ -0,0 +1,221 @@
+package cmd
+
+import (
+	"context"
+	"log"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/charlieegan3/photos/internal/pkg/database"
+	"github.com/charlieegan3/photos/internal/pkg/ingest"
+	"github.com/charlieegan3/photos/internal/pkg/models"
+	"github.com/charlieegan3/photos/internal/pkg/thumbnails"
+)
+
+var (
+	hashesDryRun bool
+	hashesFromID int
+)
+
+var jobsHashesCmd = &cobra.Command{
+	Use:   "hashes",
+	Short: "Commands for managing media content hashes",
+}
+
+// jobsHashesBackfillCmd sets the checksum of media uploaded before checksums
+// were recorded, these are used to reject duplicate uploads.
+var jobsHashesBackfillCmd = &cobra.Command{
+	Use:   "backfill",
+	Short: "record the checksums of media original files",
+	Long: `Backfill hashes the original file of each media without a checksum and saves
+it on the media. Media which are duplicates of another media are reported and
+left without a checksum, since checksums must be unique. These should be
+merged or deleted by hand, then the job can be run again.`,
+	Run: func(_ *cobra.Command, _ []string) {
+		ctx := context.Background()
+
+		db, err := initDatabase(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer db.Close()
+
+		bucket, err := initBucket(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer bucket.Close()
+
+		repo := database.NewMediaRepository(db)
+
+		// seen maps checksums to the media they belong to, so that duplicates
+		// within the media being backfilled are found
+		seen := make(map[string]int)
+
+		var hashed, duplicates, failed int
+		afterID := hashesFromID - 1
+		for {
+			medias, err := repo.AfterID(ctx, afterID, thumbnailsPageSize)
+			if err != nil {
+				log.Fatalf("failed to list medias: %s", err)
+			}
+
+			if len(medias) == 0 {
+				break
+			}
+
+			for _, media := range medias {
+				if media.SHA256 != "" {
+					seen[media.SHA256] = media.ID
+					continue
+				}
+
+				original, err := bucket.ReadAll(ctx, thumbnails.OriginalPath(media))
+				if err != nil {
+					log.Printf("failed to read original of media %d: %s", media.ID, err)
+					failed++
+					continue
+				}
+				sum := ingest.SHA256(original)
+
+				duplicateID, ok := seen[sum]
+				if !ok {
+					existing, err := repo.FindBySHA256(ctx, sum)
+					if err != nil {
+						log.Printf("failed to find duplicates of media %d: %s", media.ID, err)
+						failed++
+						continue
+					}
+					if len(existing) > 0 {
+						duplicateID, ok = existing[0].ID, true
+					}
+				}
+				if ok {
+					log.Printf("media %d is a duplicate of media %d", media.ID, duplicateID)
+					duplicates++
+					continue
+				}
+
+				seen[sum] = media.ID
+				hashed++
+
+				if hashesDryRun {
+					continue
+				}
+
+				media.SHA256 = sum
+				_, err = database.UpdateMedias(ctx, db, []models.Media{media})
+				if err != nil {
+					log.Printf("failed to update media %d: %s", media.ID, err)
+					failed++
+				}
+			}
+
+			afterID = medias[len(medias)-1].ID
+			log.Printf("completed medias up to %d, resume with --from-id=%d", afterID, afterID+1)
+		}
+
+		log.Printf("hashed %d medias, found %d duplicates, failed %d", hashed, duplicates, failed)
+
+		if failed > 0 {
+			os.Exit(1)
+		}
+	},
+}
+
+// jobsHashesPerceptualCmd sets the perceptual hash of media uploaded before
+// perceptual hashes were recorded, these are used to find near duplicates.
+var jobsHashesPerceptualCmd = &cobra.Command{
+	Use:   "perceptual",
+	Short: "record the perceptual hashes of media",
+	Long: `Perceptual hashes a small render of each media without a perceptual hash and
+saves it on the media. The render is made from the original without the
+watermark. Similar media are listed at /admin/medias/similar.`,
+	Run: func(_ *cobra.Command, _ []string) {
+		ctx := context.Background()
+
+		db, err := initDatabase(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer db.Close()
+
+		bucket, err := initBucket(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer bucket.Close()
+
+		repo := database.NewMediaRepository(db)
+
+		var hashed, failed int
+		afterID := hashesFromID - 1
+		for {
+			medias, err := repo.AfterID(ctx, afterID, thumbnailsPageSize)
+			if err != nil {
+				log.Fatalf("failed to list medias: %s", err)
+			}
+
+			if len(medias) == 0 {
+				break
+			}
+
+			for _, media := range medias {
+				if media.PerceptualHash != "" {
+					continue
+				}
+
+				hash, err := thumbnails.PerceptualHash(ctx, bucket, media)
+				if err != nil {
+					log.Printf("failed to hash media %d: %s", media.ID, err)
+					failed++
+					continue
+				}
+
+				hashed++
+
+				if hashesDryRun {
+					continue
+				}
+
+				err = repo.SetPerceptualHash(ctx, media.ID, hash)
+				if err != nil {
+					log.Printf("failed to update media %d: %s", media.ID, err)
+					failed++
+				}
+			}
+
+			afterID = medias[len(medias)-1].ID
+			log.Printf("completed medias up to %d, resume with --from-id=%d", afterID, afterID+1)
+		}
+
+		log.Printf("hashed %d medias, failed %d", hashed, failed)
+
+		if failed > 0 {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	jobsHashesBackfillCmd.Flags().BoolVar(
+		&hashesDryRun,
+		"dry-run",
+		false,
+		"report duplicates without saving any checksums",
+	)
+	jobsHashesBackfillCmd.Flags().IntVar(&hashesFromID, "from-id", 0, "start from the media with this ID")
+
+	jobsHashesPerceptualCmd.Flags().BoolVar(
+		&hashesDryRun,
+		"dry-run",
+		false,
+		"hash media without saving the hashes",
+	)
+	jobsHashesPerceptualCmd.Flags().IntVar(&hashesFromID, "from-id", 0, "start from the media with this ID")
+
+	jobsHashesCmd.AddCommand(jobsHashesBackfillCmd)
+	jobsHashesCmd.AddCommand(jobsHashesPerceptualCmd)
+	jobsCmd.AddCommand(jobsHashesCmd)
+}
@@ -0,0 +1,331 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	"log"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"gocloud.dev/blob"
+
+	"github.com/charlieegan3/photos/internal/pkg/database"
+	"github.com/charlieegan3/photos/internal/pkg/imageproxy"
+	"github.com/charlieegan3/photos/internal/pkg/ingest"
+	"github.com/charlieegan3/photos/internal/pkg/instagram"
+	"github.com/charlieegan3/photos/internal/pkg/jobs"
+	"github.com/charlieegan3/photos/internal/pkg/mediametadata"
+	"github.com/charlieegan3/photos/internal/pkg/models"
+	"github.com/charlieegan3/photos/internal/pkg/thumbnails"
+)
+
+var (
+	instagramDeviceName   string
+	instagramLocationName string
+)
+
+var jobsImportCmd = &cobra.Command{
+	Use:   "import",
+	Short: "Commands for importing media from other services",
+}
+
+// jobsImportInstagramCmd loads the posts from an Instagram data export into
+// the library.
+var jobsImportInstagramCmd = &cobra.Command{
+	Use:   "instagram <export.zip>",
+	Short: "import posts from an Instagram data export",
+	Long: `Import creates a media and post for each photo in an Instagram data export.
+Hashtags in captions are added as tags. Photos with a location in their
+metadata are added to the nearest existing location, or a new location is
+created. Other photos use the fallback location.
+
+The export does not contain post shortcodes, the media file name is stored as
+the Instagram code instead. Items which have already been imported are
+skipped, so the command can be run again with newer exports.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(_ *cobra.Command, args []string) {
+		ctx := context.Background()
+
+		db, err := initDatabase(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer db.Close()
+
+		bucket, err := initBucket(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer bucket.Close()
+
+		export, err := instagram.Open(args[0])
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer export.Close()
+
+		posts, err := export.Posts()
+		if err != nil {
+			log.Fatalf("failed to read posts from export: %s", err)
+		}
+
+		importer := instagramImporter{
+			db:     db,
+			bucket: bucket,
+			export: export,
+		}
+
+		var imported, skipped, failed int
+		for _, post := range posts {
+			for _, media := range post.Media {
+				created, err := importer.importMedia(ctx, post, media)
+				if err != nil {
+					log.Printf("failed to import %s: %s", media.URI, err)
+					failed++
+					continue
+				}
+
+				if created {
+					imported++
+				} else {
+					skipped++
+				}
+			}
+		}
+
+		log.Printf("imported %d posts, skipped %d, failed %d", imported, skipped, failed)
+
+		if failed > 0 {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	jobsImportInstagramCmd.Flags().StringVar(
+		&instagramDeviceName,
+		"device",
+		"Instagram",
+		"name of the device used for media which do not match a known device",
+	)
+	jobsImportInstagramCmd.Flags().StringVar(
+		&instagramLocationName,
+		"location",
+		"Unknown",
+		"name of the location used for media without location data",
+	)
+
+	jobsImportCmd.AddCommand(jobsImportInstagramCmd)
+	jobsCmd.AddCommand(jobsImportCmd)
+}
+
+type instagramImporter struct {
+	db     *sql.DB
+	bucket *blob.Bucket
+	export *instagram.Export
+	ir     imageproxy.Resizer
+}
+
+// importMedia creates the media and post for a single item in the export,
+// returning false when it has already been imported.
+func (i *instagramImporter) importMedia(ctx context.Context, post instagram.Post, media instagram.Media) (bool, error) {
+	existingPosts, err := database.FindPostsByInstagramCode(ctx, i.db, media.Code)
+	if err != nil {
+		return false, err
+	}
+	if len(existingPosts) > 0 {
+		return false, nil
+	}
+
+	kind := strings.TrimPrefix(strings.ToLower(path.Ext(media.URI)), ".")
+	if kind == "jpeg" {
+		kind = "jpg"
+	}
+	if kind != "jpg" {
+		log.Printf("skipping %s, only jpg media are supported", media.URI)
+		return false, nil
+	}
+
+	// the media may exist without a post if a previous import was interrupted
+	existingMedias, err := database.FindMediasByInstagramCode(ctx, i.db, media.Code)
+	if err != nil {
+		return false, err
+	}
+
+	var persistedMedia models.Media
+	if len(existingMedias) > 0 {
+		persistedMedia = existingMedias[0]
+	} else {
+		persistedMedia, err = i.createMedia(ctx, media, kind)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	location, err := i.findLocation(ctx, persistedMedia.Latitude, persistedMedia.Longitude)
+	if err != nil {
+		return false, err
+	}
+
+	persistedPosts, err := database.CreatePosts(ctx, i.db, []models.Post{
+		{
+			Description:   post.Caption,
+			InstagramCode: media.Code,
+			PublishDate:   post.Timestamp,
+			MediaID:       persistedMedia.ID,
+			LocationID:    location.ID,
+		},
+	})
+	if err != nil {
+		return false, err
+	}
+
+	err = database.SetPostTags(ctx, i.db, persistedPosts[0], post.Tags)
+	if err != nil {
+		return false, err
+	}
+
+	log.Printf("imported %s as post %d", media.URI, persistedPosts[0].ID)
+
+	return true, nil
+}
+
+func (i *instagramImporter) createMedia(ctx context.Context, media instagram.Media, kind string) (models.Media, error) {
+	fileBytes, err := i.export.ReadMedia(media.URI)
+	if err != nil {
+		return models.Media{}, err
+	}
+
+	newMedia := models.Media{
+		Kind:          kind,
+		InstagramCode: media.Code,
+		TakenAt:       media.Timestamp,
+		Latitude:      media.Latitude,
+		Longitude:     media.Longitude,
+		Orientation:   1,
+		SHA256:        ingest.SHA256(fileBytes),
+	}
+
+	// photos uploaded directly may also have been posted to Instagram
+	existingMedias, err := database.NewMediaRepository(i.db).FindBySHA256(ctx, newMedia.SHA256)
+	if err != nil {
+		return models.Media{}, err
+	}
+	if len(existingMedias) > 0 {
+		return existingMedias[0], nil
+	}
+
+	// Instagram removes most metadata, but use what remains
+	exifData, err := mediametadata.ExtractMetadata(fileBytes)
+	if err == nil {
+		newMedia.Make = exifData.Make
+		newMedia.Model = exifData.Model
+		if !exifData.DateTime.IsZero() {
+			newMedia.TakenAt = exifData.DateTime
+		}
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(fileBytes))
+	if err != nil {
+		return models.Media{}, fmt.Errorf("failed to decode image: %w", err)
+	}
+	newMedia.Width = config.Width
+	newMedia.Height = config.Height
+
+	device, err := i.findDevice(ctx, newMedia.Model)
+	if err != nil {
+		return models.Media{}, err
+	}
+	newMedia.DeviceID = device.ID
+
+	persistedMedias, err := database.CreateMedias(ctx, i.db, []models.Media{newMedia})
+	if err != nil {
+		return models.Media{}, err
+	}
+	persistedMedia := persistedMedias[0]
+
+	err = i.bucket.WriteAll(ctx, thumbnails.OriginalPath(persistedMedia), fileBytes, nil)
+	if err != nil {
+		return models.Media{}, fmt.Errorf("failed to save media: %w", err)
+	}
+
+	// the placeholder and perceptual hash are made with the thumbnails
+	err = (&jobs.Handlers{DB: i.db, Bucket: i.bucket, Resizer: i.ir}).GenerateThumbnails(ctx, persistedMedia.ID)
+	if err != nil {
+		return models.Media{}, err
+	}
+
+	return persistedMedia, nil
+}
+
+// findDevice returns the device matching the model, or the fallback device
+// which is created if needed.
+func (i *instagramImporter) findDevice(ctx context.Context, model string) (models.Device, error) {
+	if model != "" {
+		device, err := database.NewDeviceRepository(i.db).FindByModelMatches(ctx, model)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return models.Device{}, err
+		}
+		if device != nil {
+			return *device, nil
+		}
+	}
+
+	devices, err := database.FindDevicesByName(ctx, i.db, instagramDeviceName)
+	if err != nil {
+		return models.Device{}, err
+	}
+	if len(devices) > 0 {
+		return devices[0], nil
+	}
+
+	devices, err = database.CreateDevices(ctx, i.db, []models.Device{{Name: instagramDeviceName}})
+	if err != nil {
+		return models.Device{}, err
+	}
+
+	return devices[0], nil
+}
+
+// findLocation returns the nearest location to the coordinates, creating one
+// if there are none nearby. Media without coordinates use the fallback
+// location.
+func (i *instagramImporter) findLocation(ctx context.Context, latitude, longitude float64) (models.Location, error) {
+	name := instagramLocationName
+
+	if latitude != 0 || longitude != 0 {
+		nearby, err := database.NearbyLocations(i.db, latitude, longitude)
+		if err != nil {
+			return models.Location{}, err
+		}
+		if len(nearby) > 0 {
+			return nearby[0], nil
+		}
+
+		name = fmt.Sprintf("%.4f, %.4f", latitude, longitude)
+	}
+
+	locations, err := database.FindLocationsByName(ctx, i.db, name)
+	if err != nil {
+		return models.Location{}, err
+	}
+	if len(locations) > 0 {
+		return locations[0], nil
+	}
+
+	locations, err = database.CreateLocations(ctx, i.db, []models.Location{
+		{Name: name, Latitude: latitude, Longitude: longitude},
+	})
+	if err != nil {
+		return models.Location{}, err
+	}
+
+	return locations[0], nil
+}
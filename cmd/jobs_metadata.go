@@ -0,0 +1,280 @@
+package cmd
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"gocloud.dev/blob"
+
+	"github.com/charlieegan3/photos/internal/pkg/backfill"
+	"github.com/charlieegan3/photos/internal/pkg/database"
+	"github.com/charlieegan3/photos/internal/pkg/ingest"
+	"github.com/charlieegan3/photos/internal/pkg/mediakind"
+	"github.com/charlieegan3/photos/internal/pkg/models"
+	"github.com/charlieegan3/photos/internal/pkg/thumbnails"
+)
+
+var (
+	metadataDryRun bool
+	metadataFromID int
+)
+
+var jobsMetadataCmd = &cobra.Command{
+	Use:   "metadata",
+	Short: "Commands for managing media metadata",
+}
+
+// jobsMetadataBackfillCmd fills in the metadata missing from legacy media,
+// mostly those imported from Instagram before dimensions were recorded.
+var jobsMetadataBackfillCmd = &cobra.Command{
+	Use:   "backfill",
+	Short: "fill in missing media metadata from the original files",
+	Long: `Backfill re-reads the original file of each media missing dimensions,
+orientation, make, model, lens, focal length or exposure details and sets those
+fields from the file's EXIF data and image size. Fields which are already set
+are not changed. The exposure details include the camera direction and GPS
+time.
+The device and lens are then matched again using the updated metadata.
+
+Media which gain dimensions use fit thumbnails rather than the legacy width
+only thumbnails. These are created on request, or can be built ahead of time
+with 'jobs thumbnails regenerate'.`,
+	Run: func(_ *cobra.Command, _ []string) {
+		ctx := context.Background()
+
+		db, err := initDatabase(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer db.Close()
+
+		bucket, err := initBucket(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer bucket.Close()
+
+		repo := database.NewMediaRepository(db)
+
+		fieldCounts := make(map[string]int)
+		var checked, updated, failed int
+		afterID := metadataFromID - 1
+		for {
+			medias, err := repo.AfterID(ctx, afterID, thumbnailsPageSize)
+			if err != nil {
+				log.Fatalf("failed to list medias: %s", err)
+			}
+
+			if len(medias) == 0 {
+				break
+			}
+
+			for _, media := range medias {
+				if !backfill.NeedsMetadata(media) {
+					continue
+				}
+				checked++
+
+				changes, err := backfillMediaMetadata(ctx, db, bucket, media)
+				if err != nil {
+					log.Printf("failed to backfill media %d: %s", media.ID, err)
+					failed++
+					continue
+				}
+
+				if len(changes) == 0 {
+					continue
+				}
+				updated++
+
+				descriptions := make([]string, 0, len(changes))
+				for _, change := range changes {
+					fieldCounts[change.Field]++
+					descriptions = append(descriptions, change.String())
+				}
+				log.Printf("media %d: %s", media.ID, strings.Join(descriptions, ", "))
+			}
+
+			afterID = medias[len(medias)-1].ID
+			log.Printf("completed medias up to %d, resume with --from-id=%d", afterID, afterID+1)
+		}
+
+		fields := make([]string, 0, len(fieldCounts))
+		for field := range fieldCounts {
+			fields = append(fields, field)
+		}
+		sort.Strings(fields)
+
+		for _, field := range fields {
+			log.Printf("%s: %d changed", field, fieldCounts[field])
+		}
+
+		action := "updated"
+		if metadataDryRun {
+			action = "would update"
+		}
+		log.Printf("checked %d medias, %s %d, failed %d", checked, action, updated, failed)
+
+		if failed > 0 {
+			os.Exit(1)
+		}
+	},
+}
+
+// jobsMetadataTagsCmd reads the EXIF and XMP tags of media uploaded before
+// tags were recorded, or again after tag extraction has been improved.
+var jobsMetadataTagsCmd = &cobra.Command{
+	Use:   "tags",
+	Short: "extract the metadata tags of media from the original files",
+	Long: `Tags reads all the EXIF and XMP tags from the original file of each media and
+saves them on the media, replacing any saved before. Other media fields are not
+changed. The tags of a single media can also be extracted again from its admin
+page.`,
+	Run: func(_ *cobra.Command, _ []string) {
+		ctx := context.Background()
+
+		db, err := initDatabase(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer db.Close()
+
+		bucket, err := initBucket(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer bucket.Close()
+
+		repo := database.NewMediaRepository(db)
+
+		var extracted, failed int
+		afterID := metadataFromID - 1
+		for {
+			medias, err := repo.AfterID(ctx, afterID, thumbnailsPageSize)
+			if err != nil {
+				log.Fatalf("failed to list medias: %s", err)
+			}
+
+			if len(medias) == 0 {
+				break
+			}
+
+			for _, media := range medias {
+				original, err := bucket.ReadAll(ctx, thumbnails.OriginalPath(media))
+				if err != nil {
+					log.Printf("failed to read original of media %d: %s", media.ID, err)
+					failed++
+					continue
+				}
+
+				tags, err := ingest.ExtractMetadataTags(media.Kind, original)
+				if err != nil {
+					log.Printf("failed to extract tags of media %d: %s", media.ID, err)
+					failed++
+					continue
+				}
+
+				extracted++
+
+				if metadataDryRun {
+					continue
+				}
+
+				err = repo.SetMetadataTags(ctx, media.ID, tags)
+				if err != nil {
+					log.Printf("failed to update media %d: %s", media.ID, err)
+					failed++
+				}
+			}
+
+			afterID = medias[len(medias)-1].ID
+			log.Printf("completed medias up to %d, resume with --from-id=%d", afterID, afterID+1)
+		}
+
+		log.Printf("extracted tags of %d medias, failed %d", extracted, failed)
+
+		if failed > 0 {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	jobsMetadataBackfillCmd.Flags().BoolVar(
+		&metadataDryRun,
+		"dry-run",
+		false,
+		"log the changes which would be made without saving them",
+	)
+	jobsMetadataBackfillCmd.Flags().IntVar(&metadataFromID, "from-id", 0, "start from the media with this ID")
+
+	jobsMetadataTagsCmd.Flags().BoolVar(
+		&metadataDryRun,
+		"dry-run",
+		false,
+		"extract the tags without saving them",
+	)
+	jobsMetadataTagsCmd.Flags().IntVar(&metadataFromID, "from-id", 0, "start from the media with this ID")
+
+	jobsMetadataCmd.AddCommand(jobsMetadataBackfillCmd)
+	jobsMetadataCmd.AddCommand(jobsMetadataTagsCmd)
+	jobsCmd.AddCommand(jobsMetadataCmd)
+}
+
+// backfillMediaMetadata updates a single media from its original file and
+// returns the fields which changed.
+func backfillMediaMetadata(
+	ctx context.Context,
+	db *sql.DB,
+	bucket *blob.Bucket,
+	media models.Media,
+) ([]backfill.Change, error) {
+	// the metadata of videos is read when they are uploaded
+	if !mediakind.IsImage(media.Kind) {
+		return nil, nil
+	}
+
+	original, err := bucket.ReadAll(ctx, thumbnails.OriginalPath(media))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read original: %w", err)
+	}
+
+	updatedMedia, changes, err := backfill.Metadata(media, original)
+	if err != nil {
+		return nil, err
+	}
+
+	database.MatchDeviceAndLens(ctx, db, &updatedMedia)
+	if updatedMedia.DeviceID != media.DeviceID {
+		changes = append(changes, backfill.Change{
+			Field: "DeviceID",
+			From:  strconv.FormatInt(media.DeviceID, 10),
+			To:    strconv.FormatInt(updatedMedia.DeviceID, 10),
+		})
+	}
+	if updatedMedia.LensID != media.LensID {
+		changes = append(changes, backfill.Change{
+			Field: "LensID",
+			From:  strconv.FormatInt(media.LensID, 10),
+			To:    strconv.FormatInt(updatedMedia.LensID, 10),
+		})
+	}
+
+	if len(changes) == 0 || metadataDryRun {
+		return changes, nil
+	}
+
+	_, err = database.UpdateMedias(ctx, db, []models.Media{updatedMedia})
+	if err != nil {
+		return nil, err
+	}
+
+	return changes, nil
+}
@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"context"
+	"log"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/charlieegan3/photos/internal/pkg/database"
+	"github.com/charlieegan3/photos/internal/pkg/thumbnails"
+)
+
+var (
+	placeholdersForce  bool
+	placeholdersFromID int
+)
+
+var jobsPlaceholdersCmd = &cobra.Command{
+	Use:   "placeholders",
+	Short: "Commands for managing the placeholders shown while thumbnails load",
+}
+
+// jobsPlaceholdersBackfillCmd sets the BlurHash and dominant colour of media
+// uploaded before placeholders were made.
+var jobsPlaceholdersBackfillCmd = &cobra.Command{
+	Use:   "backfill",
+	Short: "record the placeholders of media",
+	Long: `Backfill makes the BlurHash and dominant colour of each media without them
+from a small render of the original, without the watermark. Use --force to
+remake all placeholders.
+
+Progress is logged after each batch of medias, an interrupted job can be
+resumed with --from-id.`,
+	Run: func(_ *cobra.Command, _ []string) {
+		ctx := context.Background()
+
+		db, err := initDatabase(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer db.Close()
+
+		bucket, err := initBucket(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer bucket.Close()
+
+		repo := database.NewMediaRepository(db)
+
+		var made, failed int
+		afterID := placeholdersFromID - 1
+		for {
+			medias, err := repo.AfterID(ctx, afterID, thumbnailsPageSize)
+			if err != nil {
+				log.Fatalf("failed to list medias: %s", err)
+			}
+
+			if len(medias) == 0 {
+				break
+			}
+
+			for _, media := range medias {
+				if media.BlurHash != "" && !placeholdersForce {
+					continue
+				}
+
+				blurHash, dominantColour, err := thumbnails.Placeholder(ctx, bucket, media)
+				if err != nil {
+					log.Printf("failed to make placeholder of media %d: %s", media.ID, err)
+					failed++
+					continue
+				}
+
+				err = repo.SetPlaceholder(ctx, media.ID, blurHash, dominantColour)
+				if err != nil {
+					log.Printf("failed to update media %d: %s", media.ID, err)
+					failed++
+					continue
+				}
+
+				made++
+			}
+
+			afterID = medias[len(medias)-1].ID
+			log.Printf("completed medias up to %d, resume with --from-id=%d", afterID, afterID+1)
+		}
+
+		log.Printf("made %d placeholders, failed %d", made, failed)
+
+		if failed > 0 {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	jobsPlaceholdersBackfillCmd.Flags().BoolVar(
+		&placeholdersForce,
+		"force",
+		false,
+		"remake all placeholders, even those which are set",
+	)
+	jobsPlaceholdersBackfillCmd.Flags().IntVar(&placeholdersFromID, "from-id", 0, "start from the media with this ID")
+
+	jobsPlaceholdersCmd.AddCommand(jobsPlaceholdersBackfillCmd)
+	jobsCmd.AddCommand(jobsPlaceholdersCmd)
+}
@@ -0,0 +1,238 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"sync/atomic"
+
+	"github.com/spf13/cobra"
+	"gocloud.dev/blob"
+
+	"github.com/charlieegan3/photos/internal/pkg/database"
+	"github.com/charlieegan3/photos/internal/pkg/imageproxy"
+	"github.com/charlieegan3/photos/internal/pkg/mediakind"
+	"github.com/charlieegan3/photos/internal/pkg/models"
+	"github.com/charlieegan3/photos/internal/pkg/thumbnails"
+)
+
+// thumbnailsPageSize is the number of medias loaded from the database at a
+// time, progress is logged after each page so that jobs can be resumed.
+const thumbnailsPageSize = 200
+
+var (
+	thumbnailsConcurrency int
+	thumbnailsDryRun      bool
+	thumbnailsForce       bool
+	thumbnailsFromID      int
+)
+
+var jobsThumbnailsCmd = &cobra.Command{
+	Use:   "thumbnails",
+	Short: "Commands for managing media thumbnails",
+}
+
+// jobsThumbnailsRegenerateCmd rebuilds thumbnails which are missing or older
+// than the original media, for example after the thumbnail profiles change.
+var jobsThumbnailsRegenerateCmd = &cobra.Command{
+	Use:   "regenerate",
+	Short: "rebuild missing or stale media thumbnails",
+	Long: `Regenerate checks the thumbnails of every media in ID order and rebuilds any
+which are missing, empty or older than the original media file. Use --force to
+rebuild all thumbnails, for example after a change to the resizer.
+
+Progress is logged after each batch of medias, an interrupted job can be
+resumed with --from-id.`,
+	Run: func(_ *cobra.Command, _ []string) {
+		ctx := context.Background()
+
+		if thumbnailsConcurrency < 1 {
+			log.Fatal("concurrency must be at least 1")
+		}
+
+		db, err := initDatabase(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer db.Close()
+
+		bucket, err := initBucket(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer bucket.Close()
+
+		repo := database.NewMediaRepository(db)
+
+		var stats thumbnailStats
+		afterID := thumbnailsFromID - 1
+		for {
+			medias, err := repo.AfterID(ctx, afterID, thumbnailsPageSize)
+			if err != nil {
+				log.Fatalf("failed to list medias: %s", err)
+			}
+
+			if len(medias) == 0 {
+				break
+			}
+
+			regenerateThumbnailsPage(ctx, bucket, medias, &stats)
+
+			afterID = medias[len(medias)-1].ID
+			log.Printf("completed medias up to %d, resume with --from-id=%d", afterID, afterID+1)
+		}
+
+		log.Printf(
+			"checked %d medias, regenerated %d thumbnails, skipped %d medias, failed %d medias",
+			stats.checked.Load(),
+			stats.regenerated.Load(),
+			stats.skipped.Load(),
+			stats.failed.Load(),
+		)
+
+		if stats.failed.Load() > 0 {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	jobsThumbnailsRegenerateCmd.Flags().IntVar(
+		&thumbnailsConcurrency,
+		"concurrency",
+		2,
+		"number of medias to process at once, each holds an original image in memory",
+	)
+	jobsThumbnailsRegenerateCmd.Flags().BoolVar(
+		&thumbnailsDryRun,
+		"dry-run",
+		false,
+		"log the thumbnails which would be rebuilt without changing them",
+	)
+	jobsThumbnailsRegenerateCmd.Flags().BoolVar(
+		&thumbnailsForce,
+		"force",
+		false,
+		"rebuild all thumbnails, even those which are up to date",
+	)
+	jobsThumbnailsRegenerateCmd.Flags().IntVar(&thumbnailsFromID, "from-id", 0, "start from the media with this ID")
+
+	jobsThumbnailsCmd.AddCommand(jobsThumbnailsRegenerateCmd)
+	jobsCmd.AddCommand(jobsThumbnailsCmd)
+}
+
+type thumbnailStats struct {
+	checked     atomic.Int64
+	regenerated atomic.Int64
+	skipped     atomic.Int64
+	failed      atomic.Int64
+}
+
+// regenerateThumbnailsPage processes a page of medias with a pool of workers
+// and returns once they are all complete.
+func regenerateThumbnailsPage(
+	ctx context.Context,
+	bucket *blob.Bucket,
+	medias []models.Media,
+	stats *thumbnailStats,
+) {
+	work := make(chan models.Media)
+
+	// renders are limited by the resizer's pool, concurrency sets how many
+	// medias are read and queued for rendering at once
+	ir := imageproxy.Resizer{}
+
+	var wg sync.WaitGroup
+	for range thumbnailsConcurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for media := range work {
+				count, err := regenerateMediaThumbnails(ctx, bucket, &ir, media)
+				if err != nil {
+					log.Printf("failed to regenerate thumbnails for media %d: %s", media.ID, err)
+					stats.failed.Add(1)
+					continue
+				}
+
+				if count < 0 {
+					stats.skipped.Add(1)
+					continue
+				}
+
+				stats.checked.Add(1)
+				stats.regenerated.Add(int64(count))
+			}
+		}()
+	}
+
+	for i := range medias {
+		work <- medias[i]
+	}
+	close(work)
+
+	wg.Wait()
+}
+
+// regenerateMediaThumbnails rebuilds the thumbnails for a single media and
+// returns the number rebuilt, or -1 if the media has no thumbnails.
+func regenerateMediaThumbnails(
+	ctx context.Context,
+	bucket *blob.Bucket,
+	ir *imageproxy.Resizer,
+	media models.Media,
+) (int, error) {
+	// video thumbnails are made from the poster, older videos were uploaded
+	// without one and have no thumbnails
+	if mediakind.IsVideo(media.Kind) {
+		exists, err := bucket.Exists(ctx, thumbnails.PosterPath(media))
+		if err != nil {
+			return 0, fmt.Errorf("failed to check for poster: %w", err)
+		}
+		if !exists {
+			return -1, nil
+		}
+	}
+
+	profiles := thumbnails.Profiles
+	if !thumbnailsForce {
+		var err error
+		profiles, err = thumbnails.Stale(ctx, bucket, media)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if len(profiles) == 0 {
+		return 0, nil
+	}
+
+	if thumbnailsDryRun {
+		log.Printf("would regenerate media %d thumbnails %v", media.ID, thumbnails.Names(profiles))
+		return len(profiles), nil
+	}
+
+	br, err := bucket.NewReader(ctx, thumbnails.SourcePath(media), nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open original media: %w", err)
+	}
+	defer br.Close()
+
+	source, err := io.ReadAll(br)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read original media: %w", err)
+	}
+
+	err = thumbnails.Generate(ctx, bucket, ir, media, source, profiles)
+	if err != nil {
+		return 0, err
+	}
+
+	log.Printf("regenerated media %d thumbnails %v", media.ID, thumbnails.Names(profiles))
+
+	return len(profiles), nil
+}
@@ -0,0 +1,123 @@
+package cmd
+
+import (
+	"context"
+	"log"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/charlieegan3/photos/internal/pkg/database"
+	"github.com/charlieegan3/photos/internal/pkg/imageproxy"
+	"github.com/charlieegan3/photos/internal/pkg/verify"
+)
+
+var (
+	verifyRepair    bool
+	verifyChecksums bool
+)
+
+// jobsVerifyCmd checks that the database and bucket agree.
+var jobsVerifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "check the database and bucket are consistent",
+	Long: `Verify reports medias without original files, missing thumbnails, devices
+and lenses without icons, posts referencing missing medias or locations and
+objects in the bucket which do not belong to any record.
+
+With --repair, missing thumbnails are regenerated and orphaned objects are
+deleted. Other problems need to be fixed by hand.
+
+Checksums of original files are recorded in the database, later runs report
+files whose content has changed without being replaced.`,
+	Run: func(_ *cobra.Command, _ []string) {
+		ctx := context.Background()
+
+		db, err := initDatabase(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer db.Close()
+
+		bucket, err := initBucket(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer bucket.Close()
+
+		var state verify.State
+
+		state.Medias, err = database.NewMediaRepository(db).All(ctx)
+		if err != nil {
+			log.Fatalf("failed to list medias: %s", err)
+		}
+		state.Devices, err = database.NewDeviceRepository(db).All(ctx)
+		if err != nil {
+			log.Fatalf("failed to list devices: %s", err)
+		}
+		state.Lenses, err = database.NewLensRepository(db).All(ctx)
+		if err != nil {
+			log.Fatalf("failed to list lenses: %s", err)
+		}
+		state.BrokenPosts, err = database.NewPostRepository(db).WithMissingReferences(ctx)
+		if err != nil {
+			log.Fatalf("failed to list posts: %s", err)
+		}
+
+		checksumRepo := database.NewObjectChecksumRepository(db)
+		state.Checksums, err = checksumRepo.AllByKey(ctx)
+		if err != nil {
+			log.Fatalf("failed to list checksums: %s", err)
+		}
+
+		result, err := verify.Check(ctx, bucket, &imageproxy.Resizer{}, state, verify.Options{
+			Repair:    verifyRepair,
+			Checksums: verifyChecksums,
+		})
+		if err != nil {
+			log.Fatalf("failed to verify: %s", err)
+		}
+
+		err = checksumRepo.Upsert(ctx, result.Checksums)
+		if err != nil {
+			log.Fatalf("failed to save checksums: %s", err)
+		}
+		err = checksumRepo.DeleteByKeys(ctx, result.RemovedChecksums)
+		if err != nil {
+			log.Fatalf("failed to remove checksums: %s", err)
+		}
+
+		for _, problem := range result.Problems {
+			log.Println(problem)
+		}
+
+		unrepaired := result.Unrepaired()
+		log.Printf(
+			"found %d problems, %d repaired, recorded %d checksums",
+			len(result.Problems),
+			len(result.Problems)-unrepaired,
+			len(result.Checksums),
+		)
+
+		if unrepaired > 0 {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	jobsVerifyCmd.Flags().BoolVar(
+		&verifyRepair,
+		"repair",
+		false,
+		"regenerate missing thumbnails and delete orphaned objects",
+	)
+	jobsVerifyCmd.Flags().BoolVar(
+		&verifyChecksums,
+		"checksums",
+		true,
+		"hash original files and compare them to the recorded checksums",
+	)
+
+	jobsCmd.AddCommand(jobsVerifyCmd)
+}
@@ -0,0 +1,187 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/spf13/cobra"
+
+	"github.com/charlieegan3/photos/internal/pkg/database"
+)
+
+var migrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Commands for managing database migrations",
+	Long: `Migrate applies and rolls back the database migrations built into the binary.
+The server migrates up when it starts unless database.skipMigrations is set.`,
+}
+
+var migrateUpCmd = &cobra.Command{
+	Use:   "up",
+	Short: "apply all pending migrations",
+	Args:  cobra.NoArgs,
+	Run: func(_ *cobra.Command, _ []string) {
+		runMigration(func(m *migrate.Migrate) error {
+			return m.Up()
+		})
+	},
+}
+
+var migrateDownCmd = &cobra.Command{
+	Use:   "down <n>",
+	Short: "roll back the last n migrations",
+	Args:  cobra.ExactArgs(1),
+	Run: func(_ *cobra.Command, args []string) {
+		n, err := strconv.Atoi(args[0])
+		if err != nil || n < 1 {
+			log.Fatalf("n must be a positive number, got %q", args[0])
+		}
+
+		runMigration(func(m *migrate.Migrate) error {
+			return m.Steps(-n)
+		})
+	},
+}
+
+var migrateGotoCmd = &cobra.Command{
+	Use:   "goto <version>",
+	Short: "migrate up or down to a version",
+	Args:  cobra.ExactArgs(1),
+	Run: func(_ *cobra.Command, args []string) {
+		version := parseMigrationVersion(args[0])
+
+		runMigration(func(m *migrate.Migrate) error {
+			return m.Migrate(version)
+		})
+	},
+}
+
+var migrateForceCmd = &cobra.Command{
+	Use:   "force <version>",
+	Short: "set the migration version without running migrations",
+	Long: `Force records version as applied and clears the dirty flag without running
+any migrations. Use it after fixing the database by hand when a migration
+failed part way through.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(_ *cobra.Command, args []string) {
+		version := parseMigrationVersion(args[0])
+
+		runMigration(func(m *migrate.Migrate) error {
+			return m.Force(int(version))
+		})
+	},
+}
+
+var migrateStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "show the applied and pending migrations",
+	Args:  cobra.NoArgs,
+	Run: func(_ *cobra.Command, _ []string) {
+		ctx := context.Background()
+
+		migrations, err := database.ListMigrations()
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		db, err := initDatabase(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer db.Close()
+
+		m, err := newMigrator(ctx, db)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer m.Close()
+
+		version, dirty, err := m.Version()
+		applied := true
+		if errors.Is(err, migrate.ErrNilVersion) {
+			applied = false
+		} else if err != nil {
+			log.Fatalf("failed to get migration version: %s", err)
+		}
+
+		for _, migration := range migrations {
+			status := "pending"
+			if applied && migration.Version <= version {
+				status = "applied"
+				if dirty && migration.Version == version {
+					status = "dirty"
+				}
+			}
+
+			fmt.Printf("%-8s %d %s\n", status, migration.Version, migration.Name)
+		}
+
+		switch {
+		case !applied:
+			log.Println("no migrations applied")
+		case dirty:
+			log.Printf("version %d is dirty, fix the database and run 'migrate force'", version)
+		default:
+			log.Printf("at version %d", version)
+		}
+	},
+}
+
+func init() {
+	migrateCmd.AddCommand(migrateUpCmd)
+	migrateCmd.AddCommand(migrateDownCmd)
+	migrateCmd.AddCommand(migrateGotoCmd)
+	migrateCmd.AddCommand(migrateForceCmd)
+	migrateCmd.AddCommand(migrateStatusCmd)
+
+	rootCmd.AddCommand(migrateCmd)
+}
+
+// runMigration runs fn with a migrator for the configured database and logs
+// the resulting version.
+func runMigration(fn func(m *migrate.Migrate) error) {
+	ctx := context.Background()
+
+	db, err := initDatabase(ctx)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+
+	m, err := newMigrator(ctx, db)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer m.Close()
+
+	err = fn(m)
+	if errors.Is(err, migrate.ErrNoChange) {
+		log.Println("no change")
+	} else if err != nil {
+		log.Fatalf("failed to migrate: %s", err)
+	}
+
+	version, dirty, err := m.Version()
+	if errors.Is(err, migrate.ErrNilVersion) {
+		log.Println("no migrations applied")
+		return
+	}
+	if err != nil {
+		log.Fatalf("failed to get migration version: %s", err)
+	}
+
+	log.Printf("at version %d, dirty: %t", version, dirty)
+}
+
+func parseMigrationVersion(s string) uint {
+	version, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		log.Fatalf("invalid version %q: %s", s, err)
+	}
+
+	return uint(version)
+}
@@ -0,0 +1,31 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/viper"
+
+	"github.com/charlieegan3/photos/internal/pkg/privacy"
+)
+
+// initPrivacy loads the privacy zones set in the config, if any.
+func initPrivacy() error {
+	if !viper.IsSet("privacy.zones") {
+		return nil
+	}
+
+	var configured []privacy.Zone
+	err := viper.UnmarshalKey("privacy.zones", &configured)
+	if err != nil {
+		return fmt.Errorf("failed to read privacy zones: %w", err)
+	}
+
+	zones, err := privacy.ParseZones(configured)
+	if err != nil {
+		return fmt.Errorf("invalid privacy zones: %w", err)
+	}
+
+	privacy.Zones = zones
+
+	return nil
+}
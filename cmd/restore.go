@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"context"
+	"log"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/charlieegan3/photos/internal/pkg/archive"
+)
+
+// restoreCmd loads an archive created by the export command.
+var restoreCmd = &cobra.Command{
+	Use:   "restore <archive.tar.gz>",
+	Short: "restore the library from an archive",
+	Long: `Restore migrates the database and loads an archive created with 'export'.
+The database and bucket must be empty. Records are given new IDs and the media
+and icon files are renamed in the bucket to match.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(_ *cobra.Command, args []string) {
+		ctx := context.Background()
+
+		db, err := initDatabase(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer db.Close()
+
+		err = migrateDatabase(ctx, db)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		bucket, err := initBucket(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer bucket.Close()
+
+		f, err := os.Open(args[0])
+		if err != nil {
+			log.Fatalf("failed to open archive: %s", err)
+		}
+		defer f.Close()
+
+		err = archive.Restore(ctx, db, bucket, f)
+		if err != nil {
+			log.Fatalf("failed to restore library: %s", err)
+		}
+
+		log.Printf("restored library from %s", args[0])
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(restoreCmd)
+}
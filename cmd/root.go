@@ -54,4 +54,14 @@ func initConfig() {
 	}
 
 	log.Printf("Using config file: %s", viper.ConfigFileUsed())
+
+	err = initThumbnails()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	err = initPrivacy()
+	if err != nil {
+		log.Fatal(err)
+	}
 }
@@ -2,20 +2,12 @@ package cmd
 
 import (
 	"context"
-	"errors"
 	"log"
 	"os"
 
-	"github.com/golang-migrate/migrate/v4"
-	"github.com/golang-migrate/migrate/v4/database/postgres"
-	_ "github.com/golang-migrate/migrate/v4/source/file"
-	"github.com/golang-migrate/migrate/v4/source/iofs"
-	_ "github.com/lib/pq"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
-	"gocloud.dev/blob"
 
-	"github.com/charlieegan3/photos/internal/pkg/database"
 	"github.com/charlieegan3/photos/internal/pkg/server"
 )
 
@@ -31,56 +23,19 @@ var serverCmd = &cobra.Command{
 			log.Fatalf("unknown environment %q", environment)
 		}
 
-		params := viper.GetStringMapString("database.params")
-		connectionString := viper.GetString("database.connectionString")
-		db, err := database.Init(
-			ctx,
-			connectionString,
-			params,
-			params["dbname"],
-			viper.GetBool("database.createDatabase"),
-		)
-		if err != nil {
-			log.Fatalf("failed to init DB: %s", err)
-		}
-
-		conn, err := db.Conn(ctx)
-		if err != nil {
-			log.Fatalf("failed to get DB connection: %s", err)
-		}
-
-		// if the schema migrations table has the old name, move it
-		_, err = conn.ExecContext(
-			ctx,
-			"ALTER TABLE IF EXISTS schema_migrations RENAME TO "+viper.GetString("database.migrationsTable"),
-		)
-		if err != nil {
-			log.Fatalf("failed to rename migrations table: %s", err)
-		}
-
-		migrations := database.Migrations
-
-		driver, err := postgres.WithConnection(ctx, conn, &postgres.Config{
-			MigrationsTable: viper.GetString("database.migrationsTable"),
-		})
-		if err != nil {
-			log.Fatalf("failed to init DB driver: %s", err)
-		}
-
-		source, err := iofs.New(migrations, "migrations")
-		if err != nil {
-			log.Fatalf("failed to create migration source: %s", err)
-		}
-		m, err := migrate.NewWithInstance("iofs", source, "postgres", driver)
+		db, err := initDatabase(ctx)
 		if err != nil {
-			log.Fatalf("failed to load migrations: %s", err)
+			log.Fatal(err)
 		}
 
-		err = m.Up()
-		if err != nil && !errors.Is(err, migrate.ErrNoChange) {
-			log.Fatalf("failed to migrate up: %s", err)
+		// deploys which run 'migrate up' as a separate step can disable this
+		if viper.GetBool("database.skipMigrations") {
+			log.Println("skipping migrations")
 		} else {
-			log.Println("migrated up")
+			err = migrateDatabase(ctx, db)
+			if err != nil {
+				log.Fatal(err)
+			}
 		}
 
 		port := viper.GetString("server.port")
@@ -89,9 +44,9 @@ var serverCmd = &cobra.Command{
 			port = p
 		}
 
-		bucket, err := blob.OpenBucket(ctx, viper.GetString("bucket.url"))
+		bucket, err := initBucket(ctx)
 		if err != nil {
-			log.Fatalf("failed to open bucket: %s", err)
+			log.Fatal(err)
 		}
 
 		log.Printf("starting server on http://%s:%s", viper.GetString("hostname"), port)
@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"fmt"
+	"runtime"
+
+	"github.com/spf13/viper"
+
+	"github.com/charlieegan3/photos/internal/pkg/imageproxy"
+	"github.com/charlieegan3/photos/internal/pkg/thumbnails"
+)
+
+// initThumbnails replaces the default thumbnail profiles, render pool and
+// watermark with those set in the loaded config, if any.
+func initThumbnails() error {
+	if viper.IsSet("thumbnails.workers") || viper.IsSet("thumbnails.pixelBudget") {
+		workers := runtime.NumCPU()
+		if viper.IsSet("thumbnails.workers") {
+			workers = viper.GetInt("thumbnails.workers")
+		}
+
+		imageproxy.DefaultPool = imageproxy.NewPool(workers, viper.GetInt64("thumbnails.pixelBudget"))
+	}
+
+	if viper.IsSet("thumbnails.watermark") {
+		var configured imageproxy.Watermark
+		err := viper.UnmarshalKey("thumbnails.watermark", &configured)
+		if err != nil {
+			return fmt.Errorf("failed to read thumbnail watermark: %w", err)
+		}
+
+		imageproxy.DefaultWatermark, err = imageproxy.ParseWatermark(configured)
+		if err != nil {
+			return fmt.Errorf("invalid thumbnail watermark: %w", err)
+		}
+	}
+
+	if !viper.IsSet("thumbnails.profiles") {
+		return nil
+	}
+
+	var configured []thumbnails.Profile
+	err := viper.UnmarshalKey("thumbnails.profiles", &configured)
+	if err != nil {
+		return fmt.Errorf("failed to read thumbnail profiles: %w", err)
+	}
+
+	profiles, err := thumbnails.ParseProfiles(configured)
+	if err != nil {
+		return fmt.Errorf("invalid thumbnail profiles: %w", err)
+	}
+
+	thumbnails.Profiles = profiles
+
+	return nil
+}
@@ -0,0 +1,301 @@
+package cmd
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"gocloud.dev/blob"
+
+	"github.com/charlieegan3/photos/internal/pkg/database"
+	"github.com/charlieegan3/photos/internal/pkg/geotag"
+	"github.com/charlieegan3/photos/internal/pkg/imageproxy"
+	"github.com/charlieegan3/photos/internal/pkg/ingest"
+	"github.com/charlieegan3/photos/internal/pkg/jobs"
+	"github.com/charlieegan3/photos/internal/pkg/mediakind"
+	"github.com/charlieegan3/photos/internal/pkg/mediametadata"
+	"github.com/charlieegan3/photos/internal/pkg/models"
+	"github.com/charlieegan3/photos/internal/pkg/thumbnails"
+)
+
+// uploadCmd creates media and draft posts for the photos in a local directory.
+var uploadCmd = &cobra.Command{
+	Use:   "upload <dir>",
+	Short: "upload the photos in a directory as draft posts",
+	Long: `Upload walks a directory and creates a media for each image file, in the same way
+as uploading through the admin interface. Each media gets a draft post at the
+nearest existing location, ready to be edited and published.
+
+The title, caption, keywords and rating written to XMP by photo editors are
+read from the file and from a .xmp sidecar file next to it, such as IMG_1.xmp
+or IMG_1.jpg.xmp. Sidecar values are used over those in the file. The caption,
+or title when there is no caption, becomes the post description, the keywords
+become tags, and posts rated above upload.favouriteRatingAbove stars (4 by
+default) are marked as favourites.
+
+RAW files are uploaded as they are, their thumbnails are made from the JPEG
+preview embedded in them.
+
+Files are skipped if a media with the same content has already been uploaded.
+Older media are only found once their checksums have been set with
+'jobs hashes backfill'.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(_ *cobra.Command, args []string) {
+		ctx := context.Background()
+
+		files, err := findUploadFiles(args[0])
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		db, err := initDatabase(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer db.Close()
+
+		bucket, err := initBucket(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer bucket.Close()
+
+		favouriteRatingAbove := ingest.DefaultFavouriteRatingAbove
+		if viper.IsSet("upload.favouriteRatingAbove") {
+			favouriteRatingAbove = viper.GetInt("upload.favouriteRatingAbove")
+		}
+
+		u := uploader{
+			db:                   db,
+			bucket:               bucket,
+			favouriteRatingAbove: favouriteRatingAbove,
+			geotagger: geotag.NewGeotagger(
+				database.NewActivityRepository(db).PointsBetween,
+				viper.GetDuration("geotag.maxGap"),
+			),
+		}
+
+		var uploaded, skipped, failed int
+		for _, file := range files {
+			created, err := u.upload(ctx, file)
+			if err != nil {
+				log.Printf("failed to upload %s: %s", file, err)
+				failed++
+				continue
+			}
+
+			if created {
+				uploaded++
+			} else {
+				skipped++
+			}
+		}
+
+		log.Printf("uploaded %d files, skipped %d, failed %d", uploaded, skipped, failed)
+
+		if failed > 0 {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(uploadCmd)
+}
+
+// findUploadFiles returns the paths of the image files in dir and its
+// subdirectories.
+func findUploadFiles(dir string) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() {
+			return nil
+		}
+
+		kind, err := mediakind.FromFilename(path)
+		if err == nil && mediakind.IsImage(kind) {
+			files = append(files, path)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list files in %s: %w", dir, err)
+	}
+
+	return files, nil
+}
+
+type uploader struct {
+	db        *sql.DB
+	bucket    *blob.Bucket
+	geotagger *geotag.Geotagger
+	ir        imageproxy.Resizer
+
+	favouriteRatingAbove int
+}
+
+// upload creates the media and draft post for a single file, returning false
+// when the file has already been uploaded.
+func (u *uploader) upload(ctx context.Context, path string) (bool, error) {
+	fileBytes, err := os.ReadFile(path)
+	if err != nil {
+		return false, fmt.Errorf("failed to read file: %w", err)
+	}
+
+	media := models.Media{
+		SHA256: ingest.SHA256(fileBytes),
+	}
+
+	existing, err := database.NewMediaRepository(u.db).FindBySHA256(ctx, media.SHA256)
+	if err != nil {
+		return false, err
+	}
+	if len(existing) > 0 {
+		log.Printf("skipping %s, it has already been uploaded as media %d", path, existing[0].ID)
+		return false, nil
+	}
+
+	err = ingest.EnrichFromMetadata(&media, fileBytes, filepath.Base(path))
+	if err != nil {
+		return false, err
+	}
+
+	xmp, err := readXMP(path, fileBytes)
+	if err != nil {
+		return false, err
+	}
+
+	if media.Latitude == 0 && media.Longitude == 0 {
+		located, err := ingest.Geotag(ctx, u.geotagger, &media)
+		if err != nil {
+			return false, err
+		}
+		if !located {
+			return false, errors.New("images must have a location set or be taken during an imported activity")
+		}
+	}
+
+	// the admin form defaults to the most recently used device, which is also
+	// used here when the model does not match a device
+	device, err := database.MostRecentlyUsedDevice(ctx, u.db)
+	if err != nil {
+		return false, err
+	}
+	media.DeviceID = device.ID
+
+	database.MatchDeviceAndLens(ctx, u.db, &media)
+	if media.DeviceID == 0 {
+		return false, fmt.Errorf("no device matches model %q", media.Model)
+	}
+
+	location, err := database.NewLocationRepository(u.db).Nearest(ctx, media.Latitude, media.Longitude)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, errors.New("there are no locations to add the post to")
+	}
+	if err != nil {
+		return false, err
+	}
+
+	persistedMedias, err := database.CreateMedias(ctx, u.db, []models.Media{media})
+	if err != nil {
+		return false, err
+	}
+	persistedMedia := persistedMedias[0]
+
+	err = ingest.SaveOriginal(ctx, u.bucket, persistedMedia, fileBytes)
+	if err != nil {
+		return false, err
+	}
+
+	// uploads are run by hand so thumbnails, the placeholder and perceptual
+	// hash are made here rather than queued
+	err = (&jobs.Handlers{DB: u.db, Bucket: u.bucket, Resizer: u.ir}).GenerateThumbnails(ctx, persistedMedia.ID)
+	if err != nil {
+		return false, fmt.Errorf("failed to create thumbnails: %w", err)
+	}
+
+	key := thumbnails.OriginalPath(persistedMedia)
+	attrs, err := u.bucket.Attributes(ctx, key)
+	if err != nil {
+		return false, fmt.Errorf("failed to get attributes of %s: %w", key, err)
+	}
+
+	err = database.NewObjectChecksumRepository(u.db).Upsert(ctx, []models.ObjectChecksum{
+		{Key: key, SHA256: media.SHA256, Size: attrs.Size, ModTime: attrs.ModTime},
+	})
+	if err != nil {
+		return false, err
+	}
+
+	post := models.Post{
+		PublishDate: persistedMedia.TakenAt,
+		IsDraft:     true,
+		MediaID:     persistedMedia.ID,
+		LocationID:  location.ID,
+	}
+	tags := ingest.DraftFromXMP(&post, xmp, u.favouriteRatingAbove)
+
+	persistedPosts, err := database.CreatePosts(ctx, u.db, []models.Post{post})
+	if err != nil {
+		return false, err
+	}
+
+	if len(tags) > 0 {
+		err = database.SetPostTags(ctx, u.db, persistedPosts[0], tags)
+		if err != nil {
+			return false, fmt.Errorf("failed to set tags: %w", err)
+		}
+	}
+
+	log.Printf(
+		"uploaded %s as media %d, draft post %d at %s",
+		path,
+		persistedMedia.ID,
+		persistedPosts[0].ID,
+		location.Name,
+	)
+
+	return true, nil
+}
+
+// readXMP returns the XMP embedded in a file merged with that of its
+// sidecar, if it has one.
+func readXMP(path string, fileBytes []byte) (mediametadata.XMP, error) {
+	embedded, err := mediametadata.ParseXMP(fileBytes)
+	if err != nil && !errors.Is(err, mediametadata.ErrNoXMP) {
+		return mediametadata.XMP{}, fmt.Errorf("failed to parse xmp: %w", err)
+	}
+
+	base := strings.TrimSuffix(path, filepath.Ext(path))
+	for _, sidecarPath := range []string{base + ".xmp", base + ".XMP", path + ".xmp", path + ".XMP"} {
+		sidecarBytes, err := os.ReadFile(sidecarPath)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return mediametadata.XMP{}, fmt.Errorf("failed to read sidecar: %w", err)
+		}
+
+		sidecar, err := mediametadata.ParseXMP(sidecarBytes)
+		if err != nil {
+			return mediametadata.XMP{}, fmt.Errorf("failed to parse sidecar %s: %w", sidecarPath, err)
+		}
+
+		return mediametadata.MergeXMP(embedded, sidecar), nil
+	}
+
+	return embedded, nil
+}
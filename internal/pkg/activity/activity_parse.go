@@ -2,8 +2,10 @@ package activity
 
 import (
 	"bytes"
+	"compress/gzip"
 	"encoding/xml"
 	"fmt"
+	"io"
 	"os"
 	"strings"
 
@@ -16,6 +18,8 @@ import (
 	"github.com/tormoder/fit"
 )
 
+// ParseActivity reads a FIT, GPX or TCX file and returns the activity and any
+// points recorded. Files may also be gzipped, as they are in Strava exports.
 func ParseActivity(inputFile string) (models.Activity, []Point, error) {
 	points := []Point{}
 
@@ -24,9 +28,24 @@ func ParseActivity(inputFile string) (models.Activity, []Point, error) {
 		return models.Activity{}, points, fmt.Errorf("failed to read file: %w", err)
 	}
 
-	parts := strings.Split(inputFile, ".")
+	parts := strings.Split(strings.ToLower(inputFile), ".")
 	fileType := parts[len(parts)-1]
 
+	if fileType == "gz" && len(parts) > 2 {
+		gr, err := gzip.NewReader(bytes.NewReader(rawData))
+		if err != nil {
+			return models.Activity{}, points, fmt.Errorf("failed to open gzipped file: %w", err)
+		}
+		defer gr.Close()
+
+		rawData, err = io.ReadAll(gr)
+		if err != nil {
+			return models.Activity{}, points, fmt.Errorf("failed to read gzipped file: %w", err)
+		}
+
+		fileType = parts[len(parts)-2]
+	}
+
 	switch fileType {
 	case "fit":
 		return parseFit(rawData)
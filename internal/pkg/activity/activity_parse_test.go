@@ -1,8 +1,10 @@
 package activity
 
 import (
+	"compress/gzip"
 	"encoding/json"
 	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -185,3 +187,29 @@ func TestParseActivity(t *testing.T) {
 		})
 	}
 }
+
+func TestParseActivityGzipped(t *testing.T) {
+	t.Parallel()
+
+	rawData, err := os.ReadFile("./fixtures/strava_export.gpx")
+	require.NoError(t, err)
+
+	gzippedFile := filepath.Join(t.TempDir(), "strava_export.GPX.gz")
+	f, err := os.Create(gzippedFile)
+	require.NoError(t, err)
+
+	gw := gzip.NewWriter(f)
+	_, err = gw.Write(rawData)
+	require.NoError(t, err)
+	require.NoError(t, gw.Close())
+	require.NoError(t, f.Close())
+
+	expectedActivity, expectedPoints, err := ParseActivity("./fixtures/strava_export.gpx")
+	require.NoError(t, err)
+
+	resultActivity, resultPoints, err := ParseActivity(gzippedFile)
+	require.NoError(t, err)
+
+	td.Cmp(t, resultActivity, expectedActivity)
+	td.Cmp(t, resultPoints, expectedPoints)
+}
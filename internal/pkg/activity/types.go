@@ -1,6 +1,10 @@
 package activity
 
-import "time"
+import (
+	"time"
+
+	"github.com/charlieegan3/photos/internal/pkg/models"
+)
 
 type Point struct {
 	Timestamp time.Time `json:"timestamp"`
@@ -14,3 +18,17 @@ type Point struct {
 
 	Velocity float64 `json:"velocity"`
 }
+
+// ToModel converts a parsed point into a point which can be stored against an
+// activity.
+func (p Point) ToModel() models.Point {
+	return models.Point{
+		Timestamp:        p.Timestamp,
+		Latitude:         p.Latitude,
+		Longitude:        p.Longitude,
+		Altitude:         p.Altitude,
+		Accuracy:         p.Accuracy,
+		VerticalAccuracy: p.VerticalAccuracy,
+		Velocity:         p.Velocity,
+	}
+}
@@ -0,0 +1,370 @@
+package archive
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"gocloud.dev/blob"
+
+	"github.com/charlieegan3/photos/internal/pkg/database"
+)
+
+// Version is incremented when the archive layout changes.
+const Version = 1
+
+const (
+	manifestName = "manifest.json"
+	dataDir      = "data/"
+	objectsDir   = "objects/"
+)
+
+// objectPrefixes are the bucket prefixes holding original files, thumbnails
+// are not included since they can be regenerated.
+var objectPrefixes = []string{"media/", "device_icons/", "lens_icons/"}
+
+var (
+	// media keys also have suffixes such as the .poster.jpg of videos
+	mediaKeyPattern    = regexp.MustCompile(`^media/(\d+)\.(.+)$`)
+	lensIconKeyPattern = regexp.MustCompile(`^lens_icons/(\d+)\.(\w+)$`)
+)
+
+// Manifest describes the contents of an archive.
+type Manifest struct {
+	Version   int
+	CreatedAt time.Time
+}
+
+type dataFile struct {
+	name  string
+	value any
+}
+
+// dataFiles lists the JSON files in an archive for each table of the library.
+func dataFiles(library *database.Library) []dataFile {
+	return []dataFile{
+		{name: "devices.json", value: &library.Devices},
+		{name: "lenses.json", value: &library.Lenses},
+		{name: "locations.json", value: &library.Locations},
+		{name: "tags.json", value: &library.Tags},
+		{name: "medias.json", value: &library.Medias},
+		{name: "posts.json", value: &library.Posts},
+		{name: "taggings.json", value: &library.Taggings},
+		{name: "trips.json", value: &library.Trips},
+		{name: "collections.json", value: &library.Collections},
+		{name: "post_collections.json", value: &library.PostCollections},
+		{name: "activities.json", value: &library.Activities},
+		{name: "points.json", value: &library.Points},
+	}
+}
+
+// Export writes the whole library and original bucket objects to w as a
+// gzipped tarball.
+func Export(ctx context.Context, db *sql.DB, bucket *blob.Bucket, w io.Writer) error {
+	library, err := database.ExportLibrary(ctx, db)
+	if err != nil {
+		return fmt.Errorf("failed to export database: %w", err)
+	}
+
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+
+	err = writeJSON(tw, manifestName, Manifest{Version: Version, CreatedAt: time.Now().UTC()})
+	if err != nil {
+		return err
+	}
+
+	// data is written before objects so that restores can remap object keys
+	// as they are read
+	for _, f := range dataFiles(&library) {
+		err = writeJSON(tw, dataDir+f.name, f.value)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, prefix := range objectPrefixes {
+		err = writeObjects(ctx, tw, bucket, prefix)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = tw.Close()
+	if err != nil {
+		return fmt.Errorf("failed to close tar writer: %w", err)
+	}
+
+	err = gw.Close()
+	if err != nil {
+		return fmt.Errorf("failed to close gzip writer: %w", err)
+	}
+
+	return nil
+}
+
+// Restore reads an archive created by Export into an empty database and
+// bucket. Records are given new IDs and objects named by ID are renamed to
+// match.
+func Restore(ctx context.Context, db *sql.DB, bucket *blob.Bucket, r io.Reader) error {
+	// restored objects are renamed to new IDs which could clash with those
+	// already in the bucket
+	err := checkBucketEmpty(ctx, bucket)
+	if err != nil {
+		return err
+	}
+
+	gr, err := gzip.NewReader(r)
+	if err != nil {
+		return fmt.Errorf("failed to open archive: %w", err)
+	}
+	defer gr.Close()
+
+	tr := tar.NewReader(gr)
+
+	var library database.Library
+	files := make(map[string]any)
+	for _, f := range dataFiles(&library) {
+		files[dataDir+f.name] = f.value
+	}
+
+	var manifest *Manifest
+	var ids *database.LibraryIDs
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read archive: %w", err)
+		}
+
+		switch {
+		case header.Name == manifestName:
+			manifest = &Manifest{}
+			err = json.NewDecoder(tr).Decode(manifest)
+			if err != nil {
+				return fmt.Errorf("failed to parse manifest: %w", err)
+			}
+			if manifest.Version != Version {
+				return fmt.Errorf("unsupported archive version %d", manifest.Version)
+			}
+
+		case strings.HasPrefix(header.Name, dataDir):
+			value, ok := files[header.Name]
+			if !ok {
+				return fmt.Errorf("unexpected data file %s", header.Name)
+			}
+
+			err = json.NewDecoder(tr).Decode(value)
+			if err != nil {
+				return fmt.Errorf("failed to parse %s: %w", header.Name, err)
+			}
+
+		case strings.HasPrefix(header.Name, objectsDir):
+			if ids == nil {
+				ids, err = restoreLibrary(ctx, db, manifest, library)
+				if err != nil {
+					return err
+				}
+			}
+
+			err = restoreObject(ctx, bucket, tr, strings.TrimPrefix(header.Name, objectsDir), *ids)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	// archives of libraries without any objects
+	if ids == nil {
+		_, err = restoreLibrary(ctx, db, manifest, library)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func restoreLibrary(
+	ctx context.Context,
+	db *sql.DB,
+	manifest *Manifest,
+	library database.Library,
+) (*database.LibraryIDs, error) {
+	if manifest == nil {
+		return nil, errors.New("archive has no manifest")
+	}
+
+	ids, err := database.RestoreLibrary(ctx, db, library)
+	if err != nil {
+		return nil, fmt.Errorf("failed to restore database: %w", err)
+	}
+
+	log.Printf(
+		"restored %d medias, %d posts, %d locations, %d tags and %d activities",
+		len(library.Medias),
+		len(library.Posts),
+		len(library.Locations),
+		len(library.Tags),
+		len(library.Activities),
+	)
+
+	return &ids, nil
+}
+
+func checkBucketEmpty(ctx context.Context, bucket *blob.Bucket) error {
+	for _, prefix := range objectPrefixes {
+		iter := bucket.List(&blob.ListOptions{Prefix: prefix})
+		obj, err := iter.Next(ctx)
+		if errors.Is(err, io.EOF) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to list %s: %w", prefix, err)
+		}
+
+		return fmt.Errorf("bucket must be empty to restore, found %s", obj.Key)
+	}
+
+	return nil
+}
+
+func restoreObject(ctx context.Context, bucket *blob.Bucket, r io.Reader, key string, ids database.LibraryIDs) error {
+	newKey, ok := remapObjectKey(key, ids)
+	if !ok {
+		log.Printf("skipping %s, it does not belong to a restored record", key)
+		return nil
+	}
+
+	bw, err := bucket.NewWriter(ctx, newKey, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create writer for %s: %w", newKey, err)
+	}
+
+	_, err = io.Copy(bw, r)
+	if err != nil {
+		bw.Close()
+		return fmt.Errorf("failed to write %s: %w", newKey, err)
+	}
+
+	err = bw.Close()
+	if err != nil {
+		return fmt.Errorf("failed to close writer for %s: %w", newKey, err)
+	}
+
+	return nil
+}
+
+// remapObjectKey returns the key an object should have in the restored
+// bucket. False is returned for objects of records which were not restored.
+func remapObjectKey(key string, ids database.LibraryIDs) (string, bool) {
+	if match := mediaKeyPattern.FindStringSubmatch(key); match != nil {
+		oldID, _ := strconv.Atoi(match[1])
+		newID, ok := ids.Medias[oldID]
+		if !ok {
+			return "", false
+		}
+
+		return fmt.Sprintf("media/%d.%s", newID, match[2]), true
+	}
+
+	if match := lensIconKeyPattern.FindStringSubmatch(key); match != nil {
+		oldID, _ := strconv.ParseInt(match[1], 10, 64)
+		newID, ok := ids.Lenses[oldID]
+		if !ok {
+			return "", false
+		}
+
+		return fmt.Sprintf("lens_icons/%d.%s", newID, match[2]), true
+	}
+
+	// device icons are named by slug, which is unchanged
+	if strings.HasPrefix(key, "device_icons/") {
+		return key, true
+	}
+
+	return "", false
+}
+
+func writeJSON(tw *tar.Writer, name string, value any) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s: %w", name, err)
+	}
+
+	err = tw.WriteHeader(&tar.Header{
+		Name:    name,
+		Mode:    0o644,
+		Size:    int64(len(data)),
+		ModTime: time.Now(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write header for %s: %w", name, err)
+	}
+
+	_, err = tw.Write(data)
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+
+	return nil
+}
+
+func writeObjects(ctx context.Context, tw *tar.Writer, bucket *blob.Bucket, prefix string) error {
+	iter := bucket.List(&blob.ListOptions{Prefix: prefix})
+	for {
+		obj, err := iter.Next(ctx)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to list %s: %w", prefix, err)
+		}
+
+		if obj.IsDir {
+			continue
+		}
+
+		err = writeObject(ctx, tw, bucket, obj)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func writeObject(ctx context.Context, tw *tar.Writer, bucket *blob.Bucket, obj *blob.ListObject) error {
+	br, err := bucket.NewReader(ctx, obj.Key, nil)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", obj.Key, err)
+	}
+	defer br.Close()
+
+	err = tw.WriteHeader(&tar.Header{
+		Name:    objectsDir + obj.Key,
+		Mode:    0o644,
+		Size:    br.Size(),
+		ModTime: br.ModTime(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write header for %s: %w", obj.Key, err)
+	}
+
+	_, err = io.Copy(tw, br)
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", obj.Key, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,116 @@
+package archive
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gocloud.dev/blob/memblob"
+
+	"github.com/charlieegan3/photos/internal/pkg/database"
+)
+
+func TestRemapObjectKey(t *testing.T) {
+	t.Parallel()
+
+	ids := database.LibraryIDs{
+		Medias: map[int]int{1: 10},
+		Lenses: map[int64]int64{2: 20},
+	}
+
+	testCases := map[string]struct {
+		Key         string
+		ExpectedKey string
+		ExpectedOK  bool
+	}{
+		"media":                {Key: "media/1.jpg", ExpectedKey: "media/10.jpg", ExpectedOK: true},
+		"media not restored":   {Key: "media/2.jpg"},
+		"video poster":         {Key: "media/1.poster.jpg", ExpectedKey: "media/10.poster.jpg", ExpectedOK: true},
+		"lens icon":            {Key: "lens_icons/2.png", ExpectedKey: "lens_icons/20.png", ExpectedOK: true},
+		"device icon":          {Key: "device_icons/x100f.png", ExpectedKey: "device_icons/x100f.png", ExpectedOK: true},
+		"unknown object":       {Key: "thumbs/media/1-200x.jpg"},
+		"media with bad name":  {Key: "media/example.jpg"},
+		"lens icon not stored": {Key: "lens_icons/3.png"},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			key, ok := remapObjectKey(testCase.Key, ids)
+			require.Equal(t, testCase.ExpectedOK, ok)
+			require.Equal(t, testCase.ExpectedKey, key)
+		})
+	}
+}
+
+func TestWriteAndRestoreObjects(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+
+	source := memblob.OpenBucket(nil)
+	defer source.Close()
+
+	require.NoError(t, source.WriteAll(ctx, "media/1.jpg", []byte("image"), nil))
+	require.NoError(t, source.WriteAll(ctx, "media/2.mp4", []byte("video"), nil))
+	require.NoError(t, source.WriteAll(ctx, "media/2.poster.jpg", []byte("poster"), nil))
+	require.NoError(t, source.WriteAll(ctx, "thumbs/media/1-200x.jpg", []byte("thumb"), nil))
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, prefix := range objectPrefixes {
+		require.NoError(t, writeObjects(ctx, tw, source, prefix))
+	}
+	require.NoError(t, tw.Close())
+
+	destination := memblob.OpenBucket(nil)
+	defer destination.Close()
+
+	ids := database.LibraryIDs{Medias: map[int]int{1: 5, 2: 6}}
+
+	tr := tar.NewReader(&buf)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+
+		require.NoError(t, restoreObject(ctx, destination, tr, header.Name[len(objectsDir):], ids))
+	}
+
+	data, err := destination.ReadAll(ctx, "media/5.jpg")
+	require.NoError(t, err)
+	require.Equal(t, "image", string(data))
+
+	data, err = destination.ReadAll(ctx, "media/6.mp4")
+	require.NoError(t, err)
+	require.Equal(t, "video", string(data))
+
+	data, err = destination.ReadAll(ctx, "media/6.poster.jpg")
+	require.NoError(t, err)
+	require.Equal(t, "poster", string(data))
+
+	exists, err := destination.Exists(ctx, "thumbs/media/5-200x.jpg")
+	require.NoError(t, err)
+	require.False(t, exists)
+}
+
+func TestRestoreIntoNonEmptyBucket(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+
+	bucket := memblob.OpenBucket(nil)
+	defer bucket.Close()
+
+	require.NoError(t, bucket.WriteAll(ctx, "media/1.jpg", []byte("image"), nil))
+
+	// the bucket is checked before the archive or database are used
+	err := Restore(ctx, nil, bucket, bytes.NewReader(nil))
+	require.ErrorContains(t, err, "bucket must be empty")
+}
@@ -0,0 +1,132 @@
+package backfill
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	"strconv"
+	"time"
+
+	"github.com/charlieegan3/photos/internal/pkg/ingest"
+	"github.com/charlieegan3/photos/internal/pkg/mediametadata"
+	"github.com/charlieegan3/photos/internal/pkg/models"
+)
+
+// Change is a field of a media updated by a backfill.
+type Change struct {
+	Field string
+	From  string
+	To    string
+}
+
+func (c Change) String() string {
+	return fmt.Sprintf("%s %q -> %q", c.Field, c.From, c.To)
+}
+
+// NeedsMetadata returns true when media is missing any of the fields which
+// Metadata can fill in.
+func NeedsMetadata(media models.Media) bool {
+	return media.Width == 0 ||
+		media.Height == 0 ||
+		media.Orientation == 0 ||
+		media.Make == "" ||
+		media.Model == "" ||
+		media.Lens == "" ||
+		media.FocalLength == "" ||
+		media.ExposureProgram == ""
+}
+
+// Metadata fills in the fields of media which are missing, typically on media
+// imported from Instagram or uploaded before a field was extracted, from the
+// EXIF data and dimensions of the original file. Fields which are already set
+// are left unchanged.
+func Metadata(media models.Media, original []byte) (models.Media, []Change, error) {
+	config, _, err := image.DecodeConfig(bytes.NewReader(original))
+	if err != nil {
+		return media, nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	// older exports have missing or broken EXIF data, the dimensions can
+	// still be set in that case
+	exifData, err := mediametadata.ExtractMetadata(original)
+	if err != nil {
+		exifData = mediametadata.Metadata{}
+	}
+
+	var changes []Change
+
+	if media.Width == 0 || media.Height == 0 {
+		changes = append(changes,
+			Change{Field: "Width", From: strconv.Itoa(media.Width), To: strconv.Itoa(config.Width)},
+			Change{Field: "Height", From: strconv.Itoa(media.Height), To: strconv.Itoa(config.Height)},
+		)
+		media.Width = config.Width
+		media.Height = config.Height
+	}
+
+	if media.Orientation == 0 {
+		orientation := int(exifData.Orientation)
+		if orientation == 0 {
+			orientation = 1
+		}
+
+		changes = append(changes, Change{Field: "Orientation", From: "0", To: strconv.Itoa(orientation)})
+		media.Orientation = orientation
+	}
+
+	// make and model are needed to match the media to a device
+	if media.Make == "" && exifData.Make != "" {
+		changes = append(changes, Change{Field: "Make", To: exifData.Make})
+		media.Make = exifData.Make
+	}
+
+	if media.Model == "" && exifData.Model != "" {
+		changes = append(changes, Change{Field: "Model", To: exifData.Model})
+		media.Model = exifData.Model
+	}
+
+	if media.Lens == "" && exifData.Lens != "" {
+		changes = append(changes, Change{Field: "Lens", To: exifData.Lens})
+		media.Lens = exifData.Lens
+	}
+
+	if media.FocalLength == "" && exifData.FocalLength != "" {
+		changes = append(changes, Change{Field: "FocalLength", To: exifData.FocalLength})
+		media.FocalLength = exifData.FocalLength
+	}
+
+	// media uploaded before the exposure and GPS details were extracted
+	// have none of them set
+	if media.ExposureProgram == "" && exifData.ExposureProgram != "" {
+		updated := media
+		ingest.SetExposureAndGPS(&updated, exifData)
+
+		for _, change := range []Change{
+			{Field: "ExposureCompensation", To: strconv.FormatFloat(updated.ExposureCompensation, 'f', -1, 64)},
+			{Field: "ExposureProgram", To: updated.ExposureProgram},
+			{Field: "MeteringMode", To: updated.MeteringMode},
+			{Field: "Flash", To: updated.Flash},
+			{Field: "WhiteBalance", To: updated.WhiteBalance},
+			{Field: "SubjectDistance", To: strconv.FormatFloat(updated.SubjectDistance, 'f', -1, 64)},
+			{Field: "AltitudeRef", To: strconv.Itoa(updated.AltitudeRef)},
+		} {
+			if change.To != "" && change.To != "0" {
+				changes = append(changes, change)
+			}
+		}
+		if updated.ImageDirectionRef != "" {
+			changes = append(changes, Change{
+				Field: "ImageDirection",
+				To:    strconv.FormatFloat(updated.ImageDirection, 'f', -1, 64) + " " + updated.ImageDirectionRef,
+			})
+		}
+		if !updated.GPSTime.IsZero() {
+			changes = append(changes, Change{Field: "GPSTime", To: updated.GPSTime.Format(time.RFC3339)})
+		}
+
+		media = updated
+	}
+
+	return media, changes, nil
+}
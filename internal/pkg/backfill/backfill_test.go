@@ -0,0 +1,71 @@
+package backfill
+
+import (
+	"bytes"
+	"image"
+	"image/jpeg"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/charlieegan3/photos/internal/pkg/models"
+)
+
+func TestMetadata(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	err := jpeg.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 300, 200)), nil)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name            string
+		media           models.Media
+		expectedMedia   models.Media
+		expectedChanges []Change
+	}{
+		{
+			name:  "legacy media without dimensions",
+			media: models.Media{ID: 1, Make: "Apple", Model: "iPhone", Lens: "Back", FocalLength: "4mm"},
+			expectedMedia: models.Media{
+				ID: 1, Make: "Apple", Model: "iPhone", Lens: "Back", FocalLength: "4mm",
+				Width: 300, Height: 200, Orientation: 1,
+			},
+			expectedChanges: []Change{
+				{Field: "Width", From: "0", To: "300"},
+				{Field: "Height", From: "0", To: "200"},
+				{Field: "Orientation", From: "0", To: "1"},
+			},
+		},
+		{
+			name: "complete media",
+			media: models.Media{
+				ID: 2, Make: "Apple", Model: "iPhone", Lens: "Back", FocalLength: "4mm",
+				Width: 100, Height: 50, Orientation: 6,
+			},
+			expectedMedia: models.Media{
+				ID: 2, Make: "Apple", Model: "iPhone", Lens: "Back", FocalLength: "4mm",
+				Width: 100, Height: 50, Orientation: 6,
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			media, changes, err := Metadata(tc.media, buf.Bytes())
+			require.NoError(t, err)
+
+			require.Equal(t, tc.expectedMedia, media)
+			require.Equal(t, tc.expectedChanges, changes)
+		})
+	}
+}
+
+func TestMetadataInvalidImage(t *testing.T) {
+	t.Parallel()
+
+	_, _, err := Metadata(models.Media{}, []byte("not an image"))
+	require.Error(t, err)
+}
@@ -0,0 +1,294 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/doug-martin/goqu/v9"
+	"github.com/pkg/errors"
+
+	"github.com/charlieegan3/photos/internal/pkg/models"
+)
+
+// pointInsertBatchSize is the number of points inserted per statement, long
+// activities can have tens of thousands of points.
+const pointInsertBatchSize = 1000
+
+type dbActivity struct {
+	ID int64 `db:"id"`
+
+	Title       string `db:"title"`
+	Description string `db:"description"`
+
+	StartTime time.Time `db:"start_time"`
+	EndTime   time.Time `db:"end_time"`
+
+	CreatedAt time.Time `db:"created_at"`
+	UpdatedAt time.Time `db:"updated_at"`
+}
+
+func (d dbActivity) ToRecord(includeID bool) goqu.Record {
+	record := goqu.Record{
+		"title":       d.Title,
+		"description": d.Description,
+		"start_time":  d.StartTime.UTC(),
+		"end_time":    d.EndTime.UTC(),
+	}
+
+	if includeID {
+		record["id"] = d.ID
+	}
+
+	return record
+}
+
+func (d dbActivity) ToModel() models.Activity {
+	return models.Activity{
+		ID: d.ID,
+
+		Title:       d.Title,
+		Description: d.Description,
+
+		StartTime: d.StartTime.UTC(),
+		EndTime:   d.EndTime.UTC(),
+
+		CreatedAt: d.CreatedAt,
+		UpdatedAt: d.UpdatedAt,
+	}
+}
+
+func newActivity(activity dbActivity) models.Activity {
+	return activity.ToModel()
+}
+
+func newDBActivity(activity models.Activity) dbActivity {
+	return dbActivity{
+		ID:          activity.ID,
+		Title:       activity.Title,
+		Description: activity.Description,
+		StartTime:   activity.StartTime.UTC(),
+		EndTime:     activity.EndTime.UTC(),
+		CreatedAt:   activity.CreatedAt,
+		UpdatedAt:   activity.UpdatedAt,
+	}
+}
+
+type dbPoint struct {
+	ID int64 `db:"id"`
+
+	ActivityID int64 `db:"activity_id"`
+
+	RecordedAt time.Time `db:"recorded_at"`
+
+	Latitude  float64 `db:"latitude"`
+	Longitude float64 `db:"longitude"`
+	Altitude  float64 `db:"altitude"`
+
+	Accuracy         float64 `db:"accuracy"`
+	VerticalAccuracy float64 `db:"vertical_accuracy"`
+
+	Velocity float64 `db:"velocity"`
+
+	CreatedAt time.Time `db:"created_at"`
+}
+
+func (d dbPoint) ToRecord(includeID bool) goqu.Record {
+	record := goqu.Record{
+		"activity_id":       d.ActivityID,
+		"recorded_at":       d.RecordedAt.UTC(),
+		"latitude":          d.Latitude,
+		"longitude":         d.Longitude,
+		"altitude":          d.Altitude,
+		"accuracy":          d.Accuracy,
+		"vertical_accuracy": d.VerticalAccuracy,
+		"velocity":          d.Velocity,
+	}
+
+	if includeID {
+		record["id"] = d.ID
+	}
+
+	return record
+}
+
+func (d dbPoint) ToModel() models.Point {
+	return models.Point{
+		ID: d.ID,
+
+		ActivityID: d.ActivityID,
+
+		Timestamp: d.RecordedAt.UTC(),
+
+		Latitude:  d.Latitude,
+		Longitude: d.Longitude,
+		Altitude:  d.Altitude,
+
+		Accuracy:         d.Accuracy,
+		VerticalAccuracy: d.VerticalAccuracy,
+
+		Velocity: d.Velocity,
+
+		CreatedAt: d.CreatedAt,
+	}
+}
+
+func newDBPoint(point models.Point) dbPoint {
+	return dbPoint{
+		ID:               point.ID,
+		ActivityID:       point.ActivityID,
+		RecordedAt:       point.Timestamp.UTC(),
+		Latitude:         point.Latitude,
+		Longitude:        point.Longitude,
+		Altitude:         point.Altitude,
+		Accuracy:         point.Accuracy,
+		VerticalAccuracy: point.VerticalAccuracy,
+		Velocity:         point.Velocity,
+		CreatedAt:        point.CreatedAt,
+	}
+}
+
+// ActivityRepository provides activity-specific database operations.
+type ActivityRepository struct {
+	*BaseRepository[models.Activity, dbActivity]
+}
+
+// NewActivityRepository creates a new activity repository instance.
+func NewActivityRepository(db *sql.DB) *ActivityRepository {
+	return &ActivityRepository{
+		BaseRepository: NewBaseRepository(db, "activities", newActivity, newDBActivity, "start_time"),
+	}
+}
+
+// FindByTimes finds an activity with the exact start and end time, this is
+// used to detect activities which have already been imported.
+func (r *ActivityRepository) FindByTimes(ctx context.Context, startTime, endTime time.Time) (*models.Activity, error) {
+	var dbActivities []dbActivity
+
+	goquDB := goqu.New("postgres", r.db)
+	query := goquDB.From(goqu.T(r.tableName).Schema(r.schema)).
+		Select("*").
+		Where(goqu.Ex{
+			"start_time": startTime.UTC(),
+			"end_time":   endTime.UTC(),
+		}).
+		Limit(1).
+		Executor()
+
+	err := query.ScanStructsContext(ctx, &dbActivities)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to select activity by times")
+	}
+
+	if len(dbActivities) == 0 {
+		return nil, sql.ErrNoRows
+	}
+
+	activity := newActivity(dbActivities[0])
+	return &activity, nil
+}
+
+// CreateWithPoints inserts an activity and all of its points in a single
+// transaction so that partially imported activities are not left behind.
+func (r *ActivityRepository) CreateWithPoints(
+	ctx context.Context,
+	activity models.Activity,
+	points []models.Point,
+) (models.Activity, error) {
+	goquDB := goqu.New("postgres", r.db)
+	tx, err := goquDB.Begin()
+	if err != nil {
+		return models.Activity{}, errors.Wrap(err, "failed to open tx for creating activity")
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	var created dbActivity
+	_, err = tx.Insert(goqu.T(r.tableName).Schema(r.schema)).
+		Returning(goqu.Star()).
+		Rows(newDBActivity(activity).ToRecord(false)).
+		Executor().
+		ScanStructContext(ctx, &created)
+	if err != nil {
+		return models.Activity{}, errors.Wrap(err, "failed to insert activity")
+	}
+
+	for start := 0; start < len(points); start += pointInsertBatchSize {
+		end := min(start+pointInsertBatchSize, len(points))
+
+		records := make([]goqu.Record, 0, end-start)
+		for i := start; i < end; i++ {
+			point := points[i]
+			point.ActivityID = created.ID
+			records = append(records, newDBPoint(point).ToRecord(false))
+		}
+
+		_, err = tx.Insert(goqu.T("points").Schema(r.schema)).
+			Rows(records).
+			Executor().
+			ExecContext(ctx)
+		if err != nil {
+			return models.Activity{}, errors.Wrap(err, "failed to insert activity points")
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return models.Activity{}, errors.Wrap(err, "failed to commit activity create transaction")
+	}
+
+	return newActivity(created), nil
+}
+
+// Points returns all the points recorded for an activity in time order.
+func (r *ActivityRepository) Points(ctx context.Context, activityID int64) ([]models.Point, error) {
+	var dbPoints []dbPoint
+
+	goquDB := goqu.New("postgres", r.db)
+	query := goquDB.From(goqu.T("points").Schema(r.schema)).
+		Select("*").
+		Where(goqu.Ex{"activity_id": activityID}).
+		Order(goqu.I("recorded_at").Asc()).
+		Executor()
+
+	err := query.ScanStructsContext(ctx, &dbPoints)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to select activity points")
+	}
+
+	results := make([]models.Point, 0, len(dbPoints))
+	for i := range dbPoints {
+		results = append(results, dbPoints[i].ToModel())
+	}
+
+	return results, nil
+}
+
+// PointsBetween returns the points from all activities recorded between from
+// and to inclusive, in time order.
+func (r *ActivityRepository) PointsBetween(ctx context.Context, from, to time.Time) ([]models.Point, error) {
+	var dbPoints []dbPoint
+
+	goquDB := goqu.New("postgres", r.db)
+	query := goquDB.From(goqu.T("points").Schema(r.schema)).
+		Select("*").
+		Where(
+			goqu.I("recorded_at").Gte(from.UTC()),
+			goqu.I("recorded_at").Lte(to.UTC()),
+		).
+		Order(goqu.I("recorded_at").Asc()).
+		Executor()
+
+	err := query.ScanStructsContext(ctx, &dbPoints)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to select points between times")
+	}
+
+	results := make([]models.Point, 0, len(dbPoints))
+	for i := range dbPoints {
+		results = append(results, dbPoints[i].ToModel())
+	}
+
+	return results, nil
+}
@@ -0,0 +1,111 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/maxatome/go-testdeep/td"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/charlieegan3/photos/internal/pkg/models"
+)
+
+// ActivitiesSuite is a number of tests to define the database integration for
+// storing activities and their points.
+type ActivitiesSuite struct {
+	suite.Suite
+
+	DB *sql.DB
+}
+
+func (s *ActivitiesSuite) SetupTest() {
+	err := Truncate(s.T().Context(), s.DB, "photos.activities")
+	if err != nil {
+		s.T().Fatalf("failed to truncate table: %s", err)
+	}
+}
+
+func (s *ActivitiesSuite) TestCreateWithPoints() {
+	repo := NewActivityRepository(s.DB)
+
+	startTime := time.Date(2022, 4, 24, 5, 6, 39, 0, time.UTC)
+
+	points := make([]models.Point, 0, pointInsertBatchSize+1)
+	for i := range pointInsertBatchSize + 1 {
+		points = append(points, models.Point{
+			Timestamp: startTime.Add(time.Duration(i) * time.Second),
+			Latitude:  57.46,
+			Longitude: -4.23,
+		})
+	}
+
+	activity, err := repo.CreateWithPoints(s.T().Context(), models.Activity{
+		Title:     "Cycling",
+		StartTime: startTime,
+		EndTime:   startTime.Add(time.Duration(pointInsertBatchSize) * time.Second),
+	}, points)
+	s.Require().NoError(err)
+
+	td.Cmp(s.T(), activity, td.SStruct(
+		models.Activity{
+			Title:     "Cycling",
+			StartTime: startTime,
+			EndTime:   startTime.Add(time.Duration(pointInsertBatchSize) * time.Second),
+		},
+		td.StructFields{
+			"=*": td.Ignore(),
+		}))
+
+	returnedPoints, err := repo.Points(s.T().Context(), activity.ID)
+	s.Require().NoError(err)
+
+	s.Len(returnedPoints, pointInsertBatchSize+1)
+	s.Equal(activity.ID, returnedPoints[0].ActivityID)
+	s.Equal(startTime, returnedPoints[0].Timestamp)
+}
+
+func (s *ActivitiesSuite) TestFindByTimes() {
+	repo := NewActivityRepository(s.DB)
+
+	startTime := time.Date(2022, 4, 30, 8, 1, 16, 0, time.UTC)
+	endTime := time.Date(2022, 4, 30, 8, 21, 12, 0, time.UTC)
+
+	_, err := repo.FindByTimes(s.T().Context(), startTime, endTime)
+	s.Require().ErrorIs(err, sql.ErrNoRows)
+
+	activity, err := repo.CreateWithPoints(s.T().Context(), models.Activity{
+		Title:     "Running",
+		StartTime: startTime,
+		EndTime:   endTime,
+	}, []models.Point{})
+	s.Require().NoError(err)
+
+	found, err := repo.FindByTimes(s.T().Context(), startTime, endTime)
+	s.Require().NoError(err)
+
+	s.Equal(activity.ID, found.ID)
+}
+
+func (s *ActivitiesSuite) TestPointsBetween() {
+	repo := NewActivityRepository(s.DB)
+
+	startTime := time.Date(2022, 5, 10, 16, 48, 28, 0, time.UTC)
+
+	_, err := repo.CreateWithPoints(s.T().Context(), models.Activity{
+		Title:     "Run",
+		StartTime: startTime,
+		EndTime:   startTime.Add(2 * time.Minute),
+	}, []models.Point{
+		{Timestamp: startTime, Latitude: 1},
+		{Timestamp: startTime.Add(time.Minute), Latitude: 2},
+		{Timestamp: startTime.Add(2 * time.Minute), Latitude: 3},
+	})
+	s.Require().NoError(err)
+
+	points, err := repo.PointsBetween(s.T().Context(), startTime.Add(30*time.Second), startTime.Add(2*time.Minute))
+	s.Require().NoError(err)
+
+	s.Require().Len(points, 2)
+	s.InDelta(2.0, points[0].Latitude, 0.000001)
+	s.InDelta(3.0, points[1].Latitude, 0.000001)
+}
@@ -15,8 +15,10 @@ import (
 	"gocloud.dev/blob"
 
 	"github.com/charlieegan3/photos/internal/pkg/database"
+	"github.com/charlieegan3/photos/internal/pkg/jobs"
 	"github.com/charlieegan3/photos/internal/pkg/server/handlers/admin/collections"
 	"github.com/charlieegan3/photos/internal/pkg/server/handlers/admin/devices"
+	adminjobs "github.com/charlieegan3/photos/internal/pkg/server/handlers/admin/jobs"
 	"github.com/charlieegan3/photos/internal/pkg/server/handlers/admin/lenses"
 	"github.com/charlieegan3/photos/internal/pkg/server/handlers/admin/locations"
 	"github.com/charlieegan3/photos/internal/pkg/server/handlers/admin/medias"
@@ -175,6 +177,10 @@ func (s *DatabaseSuite) TestPostsSuite() {
 	suite.Run(s.T(), &database.PostsSuite{DB: s.DB})
 }
 
+func (s *DatabaseSuite) TestLibrarySuite() {
+	suite.Run(s.T(), &database.LibrarySuite{DB: s.DB})
+}
+
 func (s *DatabaseSuite) TestTaggingsSuite() {
 	suite.Run(s.T(), &database.TaggingsSuite{DB: s.DB})
 }
@@ -191,6 +197,26 @@ func (s *DatabaseSuite) TestPostCollectionsSuite() {
 	suite.Run(s.T(), &database.PostCollectionsSuite{DB: s.DB})
 }
 
+func (s *DatabaseSuite) TestActivitiesSuite() {
+	suite.Run(s.T(), &database.ActivitiesSuite{DB: s.DB})
+}
+
+func (s *DatabaseSuite) TestObjectChecksumsSuite() {
+	suite.Run(s.T(), &database.ObjectChecksumsSuite{DB: s.DB})
+}
+
+func (s *DatabaseSuite) TestJobsSuite() {
+	suite.Run(s.T(), &database.JobsSuite{DB: s.DB})
+}
+
+func (s *DatabaseSuite) TestJobsRunnerSuite() {
+	suite.Run(s.T(), &jobs.JobsSuite{DB: s.DB})
+}
+
+func (s *DatabaseSuite) TestEndpointsJobsSuite() {
+	suite.Run(s.T(), &adminjobs.EndpointsJobsSuite{DB: s.DB})
+}
+
 func (s *DatabaseSuite) TestEndpointsDevicesSuite() {
 	// TODO move to suite to be shared
 	bucketBaseURL := "mem://test_bucket/"
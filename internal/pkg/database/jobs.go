@@ -0,0 +1,363 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/doug-martin/goqu/v9"
+	"github.com/doug-martin/goqu/v9/exp"
+	"github.com/pkg/errors"
+
+	"github.com/charlieegan3/photos/internal/pkg/models"
+)
+
+type dbJob struct {
+	ID int `db:"id"`
+
+	Kind    string `db:"kind"`
+	Payload string `db:"payload"`
+
+	State       string `db:"state"`
+	Attempts    int    `db:"attempts"`
+	MaxAttempts int    `db:"max_attempts"`
+	LastError   string `db:"last_error"`
+
+	RunAt    time.Time    `db:"run_at"`
+	LockedAt sql.NullTime `db:"locked_at"`
+
+	CreatedAt time.Time `db:"created_at"`
+	UpdatedAt time.Time `db:"updated_at"`
+}
+
+func (d dbJob) ToRecord(includeID bool) goqu.Record {
+	record := goqu.Record{
+		"kind":       d.Kind,
+		"payload":    d.Payload,
+		"state":      d.State,
+		"attempts":   d.Attempts,
+		"last_error": d.LastError,
+		"run_at":     d.RunAt.UTC(),
+	}
+
+	// use the column default unless set
+	if d.MaxAttempts > 0 {
+		record["max_attempts"] = d.MaxAttempts
+	}
+
+	if includeID {
+		record["id"] = d.ID
+	}
+
+	return record
+}
+
+func (d dbJob) ToModel() models.Job {
+	return models.Job{
+		ID:          d.ID,
+		Kind:        d.Kind,
+		Payload:     json.RawMessage(d.Payload),
+		State:       d.State,
+		Attempts:    d.Attempts,
+		MaxAttempts: d.MaxAttempts,
+		LastError:   d.LastError,
+		RunAt:       d.RunAt.UTC(),
+		LockedAt:    d.LockedAt.Time.UTC(),
+		CreatedAt:   d.CreatedAt,
+		UpdatedAt:   d.UpdatedAt,
+	}
+}
+
+func newJob(job dbJob) models.Job {
+	return job.ToModel()
+}
+
+func newDBJob(job models.Job) dbJob {
+	payload := string(job.Payload)
+	if payload == "" {
+		payload = "{}"
+	}
+
+	state := job.State
+	if state == "" {
+		state = models.JobStatePending
+	}
+
+	runAt := job.RunAt
+	if runAt.IsZero() {
+		runAt = time.Now()
+	}
+
+	return dbJob{
+		ID:          job.ID,
+		Kind:        job.Kind,
+		Payload:     payload,
+		State:       state,
+		Attempts:    job.Attempts,
+		MaxAttempts: job.MaxAttempts,
+		LastError:   job.LastError,
+		RunAt:       runAt.UTC(),
+		LockedAt:    sql.NullTime{Time: job.LockedAt.UTC(), Valid: !job.LockedAt.IsZero()},
+		CreatedAt:   job.CreatedAt,
+		UpdatedAt:   job.UpdatedAt,
+	}
+}
+
+// JobRepository provides operations for the queue of background jobs.
+type JobRepository struct {
+	*BaseRepository[models.Job, dbJob]
+}
+
+// NewJobRepository creates a new job repository instance.
+func NewJobRepository(db *sql.DB) *JobRepository {
+	return &JobRepository{
+		BaseRepository: NewBaseRepository(db, "jobs", newJob, newDBJob, "updated_at"),
+	}
+}
+
+// Enqueue adds jobs to the queue. Jobs with the same kind and payload as a job
+// which is already pending are not added again. They are added while the same
+// work is running since the running job may have read the state from before
+// the change which queued it.
+func (r *JobRepository) Enqueue(ctx context.Context, jobs []models.Job) error {
+	if len(jobs) == 0 {
+		return nil
+	}
+
+	records := make([]goqu.Record, 0, len(jobs))
+	for _, job := range jobs {
+		records = append(records, newDBJob(job).ToRecord(false))
+	}
+
+	goquDB := goqu.New("postgres", r.db)
+	_, err := goquDB.Insert(goqu.T(r.tableName).Schema(r.schema)).
+		Rows(records).
+		OnConflict(goqu.DoNothing()).
+		Executor().
+		ExecContext(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to enqueue jobs")
+	}
+
+	return nil
+}
+
+// Dequeue claims the next pending job which is due to run and marks it as
+// running. Jobs locked by other workers are skipped rather than waited for.
+// ok is false when there are no jobs to run.
+func (r *JobRepository) Dequeue(ctx context.Context) (job models.Job, ok bool, err error) {
+	table := goqu.T(r.tableName).Schema(r.schema)
+
+	goquDB := goqu.New("postgres", r.db)
+	next := goquDB.From(table).
+		Select("id").
+		Where(
+			goqu.C("state").Eq(models.JobStatePending),
+			goqu.C("run_at").Lte(goqu.L("NOW()")),
+		).
+		Order(goqu.I("run_at").Asc()).
+		Limit(1).
+		ForUpdate(exp.SkipLocked)
+
+	var result dbJob
+	ok, err = goquDB.Update(table).
+		Set(goqu.Record{
+			"state":     models.JobStateRunning,
+			"attempts":  goqu.L("attempts + 1"),
+			"locked_at": goqu.L("NOW()"),
+		}).
+		Where(goqu.C("id").Eq(next)).
+		Returning(goqu.Star()).
+		Executor().
+		ScanStructContext(ctx, &result)
+	if err != nil {
+		return models.Job{}, false, errors.Wrap(err, "failed to dequeue job")
+	}
+
+	return result.ToModel(), ok, nil
+}
+
+// Complete removes a job which has run successfully.
+func (r *JobRepository) Complete(ctx context.Context, id int) error {
+	goquDB := goqu.New("postgres", r.db)
+	_, err := goquDB.Delete(goqu.T(r.tableName).Schema(r.schema)).
+		Where(goqu.Ex{"id": id}).
+		Executor().
+		ExecContext(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to complete job")
+	}
+
+	return nil
+}
+
+// Reschedule returns a job which has failed to the queue to be run again at
+// runAt.
+func (r *JobRepository) Reschedule(ctx context.Context, id int, runAt time.Time, lastError string) error {
+	return r.returnToQueue(ctx, id, goqu.Record{
+		"state":      models.JobStatePending,
+		"run_at":     runAt.UTC(),
+		"locked_at":  nil,
+		"last_error": lastError,
+	})
+}
+
+// Fail marks a job as failed, it will not be run again unless retried.
+func (r *JobRepository) Fail(ctx context.Context, id int, lastError string) error {
+	return r.setState(ctx, id, goqu.Record{
+		"state":      models.JobStateFailed,
+		"locked_at":  nil,
+		"last_error": lastError,
+	})
+}
+
+// returnToQueue sets a running job back to pending with record. When the same
+// work was queued again while the job was running, the job is removed instead
+// and the queued job does the work.
+func (r *JobRepository) returnToQueue(ctx context.Context, id int, record goqu.Record) error {
+	table := goqu.T(r.tableName).Schema(r.schema)
+
+	goquDB := goqu.New("postgres", r.db)
+	result, err := goquDB.Update(table).
+		Set(record).
+		Where(
+			goqu.C("id").Eq(id),
+			goqu.Func("NOT EXISTS", r.pendingDuplicates(goquDB, table)),
+		).
+		Executor().
+		ExecContext(ctx)
+	if err != nil {
+		return errors.Wrapf(err, "failed to return job %d to the queue", id)
+	}
+
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return errors.Wrapf(err, "failed to check return of job %d to the queue", id)
+	}
+	if updated > 0 {
+		return nil
+	}
+
+	_, err = goquDB.Delete(table).
+		Where(goqu.Ex{"id": id, "state": models.JobStateRunning}).
+		Executor().
+		ExecContext(ctx)
+	if err != nil {
+		return errors.Wrapf(err, "failed to remove job %d which is already queued", id)
+	}
+
+	return nil
+}
+
+// pendingDuplicates selects the pending jobs with the same kind and payload
+// as the row of table being updated.
+func (r *JobRepository) pendingDuplicates(goquDB *goqu.Database, table exp.IdentifierExpression) *goqu.SelectDataset {
+	pending := goqu.T(r.tableName).Schema(r.schema).As("pending")
+
+	return goquDB.From(pending).
+		Select(goqu.L("1")).
+		Where(
+			pending.Col("kind").Eq(table.Col("kind")),
+			pending.Col("payload").Eq(table.Col("payload")),
+			pending.Col("state").Eq(models.JobStatePending),
+		)
+}
+
+func (r *JobRepository) setState(ctx context.Context, id int, record goqu.Record) error {
+	goquDB := goqu.New("postgres", r.db)
+	_, err := goquDB.Update(goqu.T(r.tableName).Schema(r.schema)).
+		Set(record).
+		Where(goqu.Ex{"id": id}).
+		Executor().
+		ExecContext(ctx)
+	if err != nil {
+		return errors.Wrapf(err, "failed to set state of job %d", id)
+	}
+
+	return nil
+}
+
+// Failed returns the jobs which have used all their attempts, most recently
+// failed first.
+func (r *JobRepository) Failed(ctx context.Context) ([]models.Job, error) {
+	return r.FindByField(ctx, "state", models.JobStateFailed)
+}
+
+// Retry returns a failed job to the queue with its attempts reset. When the
+// same work has been queued again since the job failed, the failed job is
+// removed instead.
+func (r *JobRepository) Retry(ctx context.Context, id int) error {
+	table := goqu.T(r.tableName).Schema(r.schema)
+
+	goquDB := goqu.New("postgres", r.db)
+	result, err := goquDB.Update(table).
+		Set(goqu.Record{
+			"state":      models.JobStatePending,
+			"attempts":   0,
+			"run_at":     goqu.L("NOW()"),
+			"last_error": "",
+		}).
+		Where(
+			goqu.C("id").Eq(id),
+			goqu.C("state").Eq(models.JobStateFailed),
+			goqu.Func("NOT EXISTS", r.pendingDuplicates(goquDB, table)),
+		).
+		Executor().
+		ExecContext(ctx)
+	if err != nil {
+		return errors.Wrapf(err, "failed to retry job %d", id)
+	}
+
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return errors.Wrapf(err, "failed to check retry of job %d", id)
+	}
+	if updated > 0 {
+		return nil
+	}
+
+	_, err = goquDB.Delete(table).
+		Where(goqu.Ex{"id": id, "state": models.JobStateFailed}).
+		Executor().
+		ExecContext(ctx)
+	if err != nil {
+		return errors.Wrapf(err, "failed to remove job %d which is already queued", id)
+	}
+
+	return nil
+}
+
+// RequeueStale returns running jobs which were locked before lockedBefore to
+// the queue, these were claimed by workers which stopped before finishing.
+func (r *JobRepository) RequeueStale(ctx context.Context, lockedBefore time.Time) (int64, error) {
+	var ids []int
+
+	goquDB := goqu.New("postgres", r.db)
+	err := goquDB.From(goqu.T(r.tableName).Schema(r.schema)).
+		Select("id").
+		Where(
+			goqu.C("state").Eq(models.JobStateRunning),
+			goqu.C("locked_at").Lt(lockedBefore.UTC()),
+		).
+		Order(goqu.I("id").Asc()).
+		Executor().
+		ScanValsContext(ctx, &ids)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to select stale jobs")
+	}
+
+	// jobs are returned one at a time since stale jobs can have the same
+	// work, only one of them is kept
+	for _, id := range ids {
+		err = r.returnToQueue(ctx, id, goqu.Record{
+			"state":     models.JobStatePending,
+			"locked_at": nil,
+		})
+		if err != nil {
+			return 0, errors.Wrap(err, "failed to requeue stale jobs")
+		}
+	}
+
+	return int64(len(ids)), nil
+}
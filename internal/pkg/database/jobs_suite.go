@@ -0,0 +1,237 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/charlieegan3/photos/internal/pkg/models"
+)
+
+// JobsSuite is a number of tests to define the database integration for the
+// queue of background jobs.
+type JobsSuite struct {
+	suite.Suite
+
+	DB *sql.DB
+}
+
+func (s *JobsSuite) SetupTest() {
+	err := Truncate(s.T().Context(), s.DB, "photos.jobs")
+	s.Require().NoError(err)
+}
+
+func (s *JobsSuite) TestEnqueueSkipsWaitingDuplicates() {
+	repo := NewJobRepository(s.DB)
+
+	jobs := []models.Job{
+		{Kind: "thumbnails", Payload: json.RawMessage(`{"media_id": 1}`)},
+		{Kind: "thumbnails", Payload: json.RawMessage(`{"media_id": 2}`)},
+	}
+
+	err := repo.Enqueue(s.T().Context(), jobs)
+	s.Require().NoError(err)
+
+	err = repo.Enqueue(s.T().Context(), jobs[:1])
+	s.Require().NoError(err)
+
+	count, err := repo.Count(s.T().Context())
+	s.Require().NoError(err)
+	s.Equal(int64(2), count)
+}
+
+func (s *JobsSuite) TestEnqueueWhileRunning() {
+	repo := NewJobRepository(s.DB)
+
+	jobs := []models.Job{{Kind: "thumbnails", Payload: json.RawMessage(`{"media_id": 1}`)}}
+
+	err := repo.Enqueue(s.T().Context(), jobs)
+	s.Require().NoError(err)
+
+	running, ok, err := repo.Dequeue(s.T().Context())
+	s.Require().NoError(err)
+	s.Require().True(ok)
+
+	// the running job may have read the old state, so the work is queued again
+	err = repo.Enqueue(s.T().Context(), jobs)
+	s.Require().NoError(err)
+
+	err = repo.Enqueue(s.T().Context(), jobs)
+	s.Require().NoError(err)
+
+	count, err := repo.Count(s.T().Context())
+	s.Require().NoError(err)
+	s.Equal(int64(2), count)
+
+	// when the running job fails, the queued job does the work instead
+	err = repo.Reschedule(s.T().Context(), running.ID, time.Now(), "try again")
+	s.Require().NoError(err)
+
+	all, err := repo.All(s.T().Context())
+	s.Require().NoError(err)
+	s.Require().Len(all, 1)
+	s.NotEqual(running.ID, all[0].ID)
+	s.Equal(models.JobStatePending, all[0].State)
+}
+
+func (s *JobsSuite) TestDequeue() {
+	repo := NewJobRepository(s.DB)
+
+	err := repo.Enqueue(s.T().Context(), []models.Job{
+		{Kind: "later", RunAt: time.Now().Add(time.Hour)},
+		{Kind: "now", Payload: json.RawMessage(`{"id": 1}`)},
+	})
+	s.Require().NoError(err)
+
+	job, ok, err := repo.Dequeue(s.T().Context())
+	s.Require().NoError(err)
+	s.Require().True(ok)
+
+	s.Equal("now", job.Kind)
+	s.JSONEq(`{"id": 1}`, string(job.Payload))
+	s.Equal(models.JobStateRunning, job.State)
+	s.Equal(1, job.Attempts)
+	s.Equal(5, job.MaxAttempts)
+	s.False(job.LockedAt.IsZero())
+
+	// the other job is not due yet
+	_, ok, err = repo.Dequeue(s.T().Context())
+	s.Require().NoError(err)
+	s.False(ok)
+}
+
+func (s *JobsSuite) TestDequeueSkipsLockedJobs() {
+	repo := NewJobRepository(s.DB)
+
+	err := repo.Enqueue(s.T().Context(), []models.Job{{Kind: "one"}, {Kind: "two"}})
+	s.Require().NoError(err)
+
+	tx, err := s.DB.BeginTx(s.T().Context(), nil)
+	s.Require().NoError(err)
+	defer func() { _ = tx.Rollback() }()
+
+	var lockedID int
+	err = tx.QueryRowContext(
+		s.T().Context(),
+		`SELECT id FROM photos.jobs WHERE kind = 'one' FOR UPDATE`,
+	).Scan(&lockedID)
+	s.Require().NoError(err)
+
+	job, ok, err := repo.Dequeue(s.T().Context())
+	s.Require().NoError(err)
+	s.Require().True(ok)
+	s.Equal("two", job.Kind)
+
+	_, ok, err = repo.Dequeue(s.T().Context())
+	s.Require().NoError(err)
+	s.False(ok)
+}
+
+func (s *JobsSuite) TestCompleteRescheduleAndFail() {
+	repo := NewJobRepository(s.DB)
+
+	err := repo.Enqueue(s.T().Context(), []models.Job{{Kind: "one"}, {Kind: "two"}, {Kind: "three"}})
+	s.Require().NoError(err)
+
+	var jobs []models.Job
+	for range 3 {
+		job, ok, err := repo.Dequeue(s.T().Context())
+		s.Require().NoError(err)
+		s.Require().True(ok)
+		jobs = append(jobs, job)
+	}
+
+	err = repo.Complete(s.T().Context(), jobs[0].ID)
+	s.Require().NoError(err)
+
+	runAt := time.Now().Add(time.Minute).UTC().Truncate(time.Second)
+	err = repo.Reschedule(s.T().Context(), jobs[1].ID, runAt, "try again")
+	s.Require().NoError(err)
+
+	err = repo.Fail(s.T().Context(), jobs[2].ID, "broken")
+	s.Require().NoError(err)
+
+	exists, err := repo.Exists(s.T().Context(), int64(jobs[0].ID))
+	s.Require().NoError(err)
+	s.False(exists)
+
+	rescheduled, err := repo.FindByID(s.T().Context(), int64(jobs[1].ID))
+	s.Require().NoError(err)
+	s.Equal(models.JobStatePending, rescheduled.State)
+	s.Equal("try again", rescheduled.LastError)
+	s.True(runAt.Equal(rescheduled.RunAt))
+	s.True(rescheduled.LockedAt.IsZero())
+
+	failed, err := repo.Failed(s.T().Context())
+	s.Require().NoError(err)
+	s.Require().Len(failed, 1)
+	s.Equal("three", failed[0].Kind)
+	s.Equal("broken", failed[0].LastError)
+}
+
+func (s *JobsSuite) TestRetry() {
+	repo := NewJobRepository(s.DB)
+
+	err := repo.Enqueue(s.T().Context(), []models.Job{{Kind: "one"}, {Kind: "two"}})
+	s.Require().NoError(err)
+
+	for range 2 {
+		job, ok, err := repo.Dequeue(s.T().Context())
+		s.Require().NoError(err)
+		s.Require().True(ok)
+
+		err = repo.Fail(s.T().Context(), job.ID, "broken")
+		s.Require().NoError(err)
+	}
+
+	// the same work as the second job is queued again
+	err = repo.Enqueue(s.T().Context(), []models.Job{{Kind: "two"}})
+	s.Require().NoError(err)
+
+	failed, err := repo.Failed(s.T().Context())
+	s.Require().NoError(err)
+	s.Require().Len(failed, 2)
+
+	for _, job := range failed {
+		err = repo.Retry(s.T().Context(), job.ID)
+		s.Require().NoError(err)
+	}
+
+	failed, err = repo.Failed(s.T().Context())
+	s.Require().NoError(err)
+	s.Empty(failed)
+
+	jobs, err := repo.All(s.T().Context())
+	s.Require().NoError(err)
+	s.Require().Len(jobs, 2)
+	for _, job := range jobs {
+		s.Equal(models.JobStatePending, job.State)
+		s.Equal(0, job.Attempts)
+	}
+}
+
+func (s *JobsSuite) TestRequeueStale() {
+	repo := NewJobRepository(s.DB)
+
+	err := repo.Enqueue(s.T().Context(), []models.Job{{Kind: "one"}})
+	s.Require().NoError(err)
+
+	_, ok, err := repo.Dequeue(s.T().Context())
+	s.Require().NoError(err)
+	s.Require().True(ok)
+
+	count, err := repo.RequeueStale(s.T().Context(), time.Now().Add(-time.Hour))
+	s.Require().NoError(err)
+	s.Equal(int64(0), count)
+
+	count, err = repo.RequeueStale(s.T().Context(), time.Now().Add(time.Hour))
+	s.Require().NoError(err)
+	s.Equal(int64(1), count)
+
+	job, ok, err := repo.Dequeue(s.T().Context())
+	s.Require().NoError(err)
+	s.Require().True(ok)
+	s.Equal(2, job.Attempts)
+}
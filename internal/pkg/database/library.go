@@ -0,0 +1,335 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/doug-martin/goqu/v9"
+	"github.com/pkg/errors"
+
+	"github.com/charlieegan3/photos/internal/pkg/models"
+)
+
+// libraryTables are the tables held in a Library, in the order they must be
+// restored to satisfy foreign keys.
+var libraryTables = []string{
+	"devices",
+	"lenses",
+	"locations",
+	"tags",
+	"medias",
+	"posts",
+	"taggings",
+	"trips",
+	"collections",
+	"post_collections",
+	"activities",
+	"points",
+}
+
+// Library holds every record in the database, it is used to export a whole
+// instance and restore it elsewhere.
+type Library struct {
+	Devices         []models.Device
+	Lenses          []models.Lens
+	Locations       []models.Location
+	Tags            []models.Tag
+	Medias          []models.Media
+	Posts           []models.Post
+	Taggings        []models.Tagging
+	Trips           []models.Trip
+	Collections     []models.Collection
+	PostCollections []models.PostCollection
+	Activities      []models.Activity
+	Points          []models.Point
+}
+
+// LibraryIDs maps the IDs in a restored Library to the IDs they were given in
+// the database. These are needed to move the objects in the bucket which are
+// named by ID.
+type LibraryIDs struct {
+	Devices     map[int64]int64
+	Lenses      map[int64]int64
+	Locations   map[int]int
+	Tags        map[int]int
+	Medias      map[int]int
+	Posts       map[int]int
+	Collections map[int]int
+	Activities  map[int64]int64
+}
+
+type selecter interface {
+	From(from ...interface{}) *goqu.SelectDataset
+}
+
+// ExportLibrary loads every record from the database. The records are read in
+// a single read only transaction so that the library is consistent.
+func ExportLibrary(ctx context.Context, db *sql.DB) (Library, error) {
+	var library Library
+
+	goquDB := goqu.New("postgres", db)
+	tx, err := goquDB.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return library, errors.Wrap(err, "failed to begin export transaction")
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	library.Devices, err = selectAllByID[models.Device, dbDevice](ctx, tx, "devices")
+	if err != nil {
+		return library, err
+	}
+	library.Lenses, err = selectAllByID[models.Lens, dbLens](ctx, tx, "lenses")
+	if err != nil {
+		return library, err
+	}
+	library.Locations, err = selectAllByID[models.Location, dbLocation](ctx, tx, "locations")
+	if err != nil {
+		return library, err
+	}
+	library.Tags, err = selectAllByID[models.Tag, dbTag](ctx, tx, "tags")
+	if err != nil {
+		return library, err
+	}
+	library.Medias, err = selectAllByID[models.Media, dbMedia](ctx, tx, "medias")
+	if err != nil {
+		return library, err
+	}
+	library.Posts, err = selectAllByID[models.Post, dbPost](ctx, tx, "posts")
+	if err != nil {
+		return library, err
+	}
+	library.Taggings, err = selectAllByID[models.Tagging, dbTagging](ctx, tx, "taggings")
+	if err != nil {
+		return library, err
+	}
+	library.Trips, err = selectAllByID[models.Trip, dbTrip](ctx, tx, "trips")
+	if err != nil {
+		return library, err
+	}
+	library.Collections, err = selectAllByID[models.Collection, dbCollection](ctx, tx, "collections")
+	if err != nil {
+		return library, err
+	}
+	library.PostCollections, err = selectAllByID[models.PostCollection, dbPostCollection](
+		ctx, tx, "post_collections",
+	)
+	if err != nil {
+		return library, err
+	}
+	library.Activities, err = selectAllByID[models.Activity, dbActivity](ctx, tx, "activities")
+	if err != nil {
+		return library, err
+	}
+	library.Points, err = selectAllByID[models.Point, dbPoint](ctx, tx, "points")
+	if err != nil {
+		return library, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return library, errors.Wrap(err, "failed to commit export transaction")
+	}
+
+	return library, nil
+}
+
+// RestoreLibrary inserts all the records in library into an empty database.
+// Records are given new IDs and references between them are updated to
+// match. Nothing is written if any record fails to insert.
+func RestoreLibrary(ctx context.Context, db *sql.DB, library Library) (LibraryIDs, error) {
+	ids := LibraryIDs{
+		Devices:     make(map[int64]int64, len(library.Devices)),
+		Lenses:      make(map[int64]int64, len(library.Lenses)),
+		Locations:   make(map[int]int, len(library.Locations)),
+		Tags:        make(map[int]int, len(library.Tags)),
+		Medias:      make(map[int]int, len(library.Medias)),
+		Posts:       make(map[int]int, len(library.Posts)),
+		Collections: make(map[int]int, len(library.Collections)),
+		Activities:  make(map[int64]int64, len(library.Activities)),
+	}
+
+	goquDB := goqu.New("postgres", db)
+	tx, err := goquDB.Begin()
+	if err != nil {
+		return ids, errors.Wrap(err, "failed to begin restore transaction")
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	for _, table := range libraryTables {
+		count, err := tx.From(goqu.T(table).Schema("photos")).CountContext(ctx)
+		if err != nil {
+			return ids, errors.Wrapf(err, "failed to count %s", table)
+		}
+		if count > 0 {
+			return ids, errors.Errorf("database must be empty to restore, %s has %d records", table, count)
+		}
+	}
+
+	for _, device := range library.Devices {
+		id, err := insertForID(ctx, tx, "devices", newDBDevice(device).ToRecord(false))
+		if err != nil {
+			return ids, err
+		}
+		ids.Devices[device.ID] = id
+	}
+
+	for _, lens := range library.Lenses {
+		id, err := insertForID(ctx, tx, "lenses", newDBLens(lens).ToRecord(false))
+		if err != nil {
+			return ids, err
+		}
+		ids.Lenses[lens.ID] = id
+	}
+
+	for _, location := range library.Locations {
+		id, err := insertForID(ctx, tx, "locations", newDBLocation(location).ToRecord(false))
+		if err != nil {
+			return ids, err
+		}
+		ids.Locations[location.ID] = int(id)
+	}
+
+	for _, tag := range library.Tags {
+		id, err := insertForID(ctx, tx, "tags", newDBTag(tag).ToRecord(false))
+		if err != nil {
+			return ids, err
+		}
+		ids.Tags[tag.ID] = int(id)
+	}
+
+	for _, media := range library.Medias {
+		media.DeviceID = ids.Devices[media.DeviceID]
+		if media.LensID != 0 {
+			media.LensID = ids.Lenses[media.LensID]
+		}
+
+		id, err := insertForID(ctx, tx, "medias", newDBMedia(media).ToRecord(false))
+		if err != nil {
+			return ids, err
+		}
+		ids.Medias[media.ID] = int(id)
+	}
+
+	for _, post := range library.Posts {
+		post.MediaID = ids.Medias[post.MediaID]
+		post.LocationID = ids.Locations[post.LocationID]
+
+		id, err := insertForID(ctx, tx, "posts", newDBPost(post).ToRecord(false))
+		if err != nil {
+			return ids, err
+		}
+		ids.Posts[post.ID] = int(id)
+	}
+
+	for _, tagging := range library.Taggings {
+		tagging.PostID = ids.Posts[tagging.PostID]
+		tagging.TagID = ids.Tags[tagging.TagID]
+
+		_, err := insertForID(ctx, tx, "taggings", newDBTagging(tagging).ToRecord(false))
+		if err != nil {
+			return ids, err
+		}
+	}
+
+	for _, trip := range library.Trips {
+		_, err := insertForID(ctx, tx, "trips", newDBTrip(trip).ToRecord(false))
+		if err != nil {
+			return ids, err
+		}
+	}
+
+	for _, collection := range library.Collections {
+		id, err := insertForID(ctx, tx, "collections", newDBCollection(collection).ToRecord(false))
+		if err != nil {
+			return ids, err
+		}
+		ids.Collections[collection.ID] = int(id)
+	}
+
+	for _, postCollection := range library.PostCollections {
+		postCollection.PostID = ids.Posts[postCollection.PostID]
+		postCollection.CollectionID = ids.Collections[postCollection.CollectionID]
+
+		_, err := insertForID(ctx, tx, "post_collections", newDBPostCollection(postCollection).ToRecord(false))
+		if err != nil {
+			return ids, err
+		}
+	}
+
+	for _, activity := range library.Activities {
+		id, err := insertForID(ctx, tx, "activities", newDBActivity(activity).ToRecord(false))
+		if err != nil {
+			return ids, err
+		}
+		ids.Activities[activity.ID] = id
+	}
+
+	// there can be many points, they are inserted in batches without
+	// returning their IDs since nothing references them
+	for start := 0; start < len(library.Points); start += pointInsertBatchSize {
+		end := min(start+pointInsertBatchSize, len(library.Points))
+
+		records := make([]goqu.Record, 0, end-start)
+		for _, point := range library.Points[start:end] {
+			point.ActivityID = ids.Activities[point.ActivityID]
+			records = append(records, newDBPoint(point).ToRecord(false))
+		}
+
+		_, err = tx.Insert(goqu.T("points").Schema("photos")).
+			Rows(records).
+			Executor().
+			ExecContext(ctx)
+		if err != nil {
+			return ids, errors.Wrap(err, "failed to insert into points")
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return ids, errors.Wrap(err, "failed to commit restore transaction")
+	}
+
+	return ids, nil
+}
+
+func selectAllByID[T any, D DBConverter[T]](ctx context.Context, s selecter, table string) ([]T, error) {
+	var dbEntities []D
+
+	err := s.From(goqu.T(table).Schema("photos")).
+		Select("*").
+		Order(goqu.I("id").Asc()).
+		Executor().
+		ScanStructsContext(ctx, &dbEntities)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to select %s", table)
+	}
+
+	results := make([]T, 0, len(dbEntities))
+	for i := range dbEntities {
+		results = append(results, dbEntities[i].ToModel())
+	}
+
+	return results, nil
+}
+
+func insertForID(ctx context.Context, tx *goqu.TxDatabase, table string, record goqu.Record) (int64, error) {
+	var id int64
+
+	found, err := tx.Insert(goqu.T(table).Schema("photos")).
+		Rows(record).
+		Returning("id").
+		Executor().
+		ScanValContext(ctx, &id)
+	if err != nil {
+		return 0, errors.Wrapf(err, "failed to insert into %s", table)
+	}
+	if !found {
+		return 0, errors.Errorf("no id returned when inserting into %s", table)
+	}
+
+	return id, nil
+}
@@ -0,0 +1,150 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/charlieegan3/photos/internal/pkg/models"
+)
+
+// LibrarySuite tests exporting and restoring the whole database.
+type LibrarySuite struct {
+	suite.Suite
+
+	DB *sql.DB
+}
+
+func (s *LibrarySuite) SetupTest() {
+	s.truncateAll()
+}
+
+func (s *LibrarySuite) truncateAll() {
+	for _, table := range libraryTables {
+		err := Truncate(s.T().Context(), s.DB, "photos."+table)
+		s.Require().NoError(err)
+	}
+}
+
+func (s *LibrarySuite) TestExportAndRestore() {
+	ctx := s.T().Context()
+
+	devices, err := CreateDevices(ctx, s.DB, []models.Device{{Name: "X100F", ModelMatches: "X100F"}})
+	s.Require().NoError(err)
+
+	lenses, err := CreateLenses(ctx, s.DB, []models.Lens{{Name: "Fixed"}})
+	s.Require().NoError(err)
+
+	locations, err := CreateLocations(ctx, s.DB, []models.Location{{Name: "London", Latitude: 51.5, Longitude: -0.1}})
+	s.Require().NoError(err)
+
+	medias, err := CreateMedias(ctx, s.DB, []models.Media{
+		{
+			DeviceID:    devices[0].ID,
+			LensID:      lenses[0].ID,
+			Kind:        "jpg",
+			TakenAt:     time.Date(2021, time.November, 23, 19, 56, 0, 0, time.UTC),
+			Orientation: 1,
+		},
+	})
+	s.Require().NoError(err)
+
+	posts, err := CreatePosts(ctx, s.DB, []models.Post{
+		{
+			Description: "post",
+			PublishDate: time.Date(2021, time.November, 24, 19, 56, 0, 0, time.UTC),
+			MediaID:     medias[0].ID,
+			LocationID:  locations[0].ID,
+		},
+	})
+	s.Require().NoError(err)
+
+	err = SetPostTags(ctx, s.DB, posts[0], []string{"london"})
+	s.Require().NoError(err)
+
+	_, err = CreateTrips(ctx, s.DB, []models.Trip{
+		{
+			Title:     "London",
+			StartDate: time.Date(2021, time.November, 23, 0, 0, 0, 0, time.UTC),
+			EndDate:   time.Date(2021, time.November, 24, 0, 0, 0, 0, time.UTC),
+		},
+	})
+	s.Require().NoError(err)
+
+	collections, err := NewCollectionRepository(s.DB).Create(ctx, []models.Collection{{Title: "Best"}})
+	s.Require().NoError(err)
+
+	_, err = NewPostCollectionRepository(s.DB).Create(ctx, []models.PostCollection{
+		{PostID: posts[0].ID, CollectionID: collections[0].ID},
+	})
+	s.Require().NoError(err)
+
+	activity, err := NewActivityRepository(s.DB).CreateWithPoints(
+		ctx,
+		models.Activity{
+			Title:     "Walk",
+			StartTime: time.Date(2021, time.November, 23, 19, 0, 0, 0, time.UTC),
+			EndTime:   time.Date(2021, time.November, 23, 20, 0, 0, 0, time.UTC),
+		},
+		[]models.Point{
+			{Timestamp: time.Date(2021, time.November, 23, 19, 0, 0, 0, time.UTC), Latitude: 51.5, Longitude: -0.1},
+			{Timestamp: time.Date(2021, time.November, 23, 20, 0, 0, 0, time.UTC), Latitude: 51.6, Longitude: -0.2},
+		},
+	)
+	s.Require().NoError(err)
+
+	library, err := ExportLibrary(ctx, s.DB)
+	s.Require().NoError(err)
+
+	s.Len(library.Medias, 1)
+	s.Len(library.Taggings, 1)
+	s.Len(library.PostCollections, 1)
+	s.Len(library.Activities, 1)
+	s.Len(library.Points, 2)
+
+	// restoring into a database with records must fail
+	_, err = RestoreLibrary(ctx, s.DB, library)
+	s.Require().Error(err)
+
+	s.truncateAll()
+
+	ids, err := RestoreLibrary(ctx, s.DB, library)
+	s.Require().NoError(err)
+
+	restored, err := ExportLibrary(ctx, s.DB)
+	s.Require().NoError(err)
+
+	s.Require().Len(restored.Medias, 1)
+	s.Equal(ids.Medias[medias[0].ID], restored.Medias[0].ID)
+	s.Equal(ids.Devices[devices[0].ID], restored.Medias[0].DeviceID)
+	s.Equal(ids.Lenses[lenses[0].ID], restored.Medias[0].LensID)
+	s.Equal(medias[0].TakenAt, restored.Medias[0].TakenAt)
+
+	s.Require().Len(restored.Posts, 1)
+	s.Equal("post", restored.Posts[0].Description)
+	s.Equal(restored.Medias[0].ID, restored.Posts[0].MediaID)
+	s.Equal(ids.Locations[locations[0].ID], restored.Posts[0].LocationID)
+
+	s.Require().Len(restored.Taggings, 1)
+	s.Equal(restored.Posts[0].ID, restored.Taggings[0].PostID)
+	s.Equal(restored.Tags[0].ID, restored.Taggings[0].TagID)
+
+	s.Require().Len(restored.PostCollections, 1)
+	s.Equal(restored.Posts[0].ID, restored.PostCollections[0].PostID)
+	s.Equal(restored.Collections[0].ID, restored.PostCollections[0].CollectionID)
+
+	s.Len(restored.Trips, 1)
+
+	s.Require().Len(restored.Activities, 1)
+	s.Equal(ids.Activities[activity.ID], restored.Activities[0].ID)
+	s.Equal("Walk", restored.Activities[0].Title)
+	s.Equal(activity.StartTime, restored.Activities[0].StartTime)
+
+	s.Require().Len(restored.Points, 2)
+	for i, point := range restored.Points {
+		s.Equal(restored.Activities[0].ID, point.ActivityID)
+		s.Equal(library.Points[i].Timestamp, point.Timestamp)
+		s.InDelta(library.Points[i].Latitude, point.Latitude, 0.000001)
+	}
+}
@@ -104,6 +104,32 @@ func (r *LocationRepository) Posts(ctx context.Context, locationID int64) ([]mod
 	return entityPosts(ctx, r.db, r.tableName, "posts.location_id", "locations.id", locationID)
 }
 
+// Nearest returns the closest location to the coordinates, however far away
+// it is. sql.ErrNoRows is returned when there are no locations.
+func (r *LocationRepository) Nearest(ctx context.Context, lat, lon float64) (*models.Location, error) {
+	var dbLocations []dbLocation
+
+	goquDB := goqu.New("postgres", r.db)
+	query := goquDB.From(goqu.T(r.tableName).Schema(r.schema)).
+		Select("*").
+		Order(goqu.L("calculate_distance(?, ?, locations.latitude, locations.longitude, 'K')", lat, lon).Asc()).
+		Limit(1).
+		Executor()
+
+	err := query.ScanStructsContext(ctx, &dbLocations)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to select nearest location")
+	}
+
+	if len(dbLocations) == 0 {
+		return nil, sql.ErrNoRows
+	}
+
+	location := newLocation(dbLocations[0])
+
+	return &location, nil
+}
+
 // Legacy function wrappers for backward compatibility with test files.
 // These should be removed after all tests are updated.
 
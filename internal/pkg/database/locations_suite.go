@@ -456,3 +456,30 @@ func (s *LocationsSuite) TestNearbyLocations() {
 
 	td.Cmp(s.T(), nearbyLocations, expectedResult)
 }
+
+func (s *LocationsSuite) TestNearest() {
+	repo := NewLocationRepository(s.DB)
+
+	_, err := repo.Nearest(s.T().Context(), 51.56748, -0.138666)
+	s.Require().ErrorIs(err, sql.ErrNoRows)
+
+	_, err = CreateLocations(s.T().Context(), s.DB, []models.Location{
+		{
+			Name:      "Tokyo National Museum",
+			Latitude:  35.718889,
+			Longitude: 139.775833,
+		},
+		{
+			Name:      "Archway Station",
+			Latitude:  51.565462952567,
+			Longitude: -0.13486676038084,
+		},
+	})
+	s.Require().NoError(err)
+
+	// the nearest location is returned even when it is not nearby
+	location, err := repo.Nearest(s.T().Context(), 48.8566, 2.3522)
+	s.Require().NoError(err)
+	s.Require().NotNil(location)
+	s.Equal("Archway Station", location.Name)
+}
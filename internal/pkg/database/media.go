@@ -3,6 +3,7 @@ package database
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"time"
 
 	"github.com/doug-martin/goqu/v9"
@@ -29,9 +30,21 @@ type dbMedia struct {
 	ExposureTimeDenominator uint32  `db:"exposure_time_denominator"`
 	ISOSpeed                int     `db:"iso_speed"`
 
-	Latitude  float64 `db:"latitude"`
-	Longitude float64 `db:"longitude"`
-	Altitude  float64 `db:"altitude"`
+	ExposureCompensation float64 `db:"exposure_compensation"`
+	ExposureProgram      string  `db:"exposure_program"`
+	MeteringMode         string  `db:"metering_mode"`
+	Flash                string  `db:"flash"`
+	WhiteBalance         string  `db:"white_balance"`
+	SubjectDistance      float64 `db:"subject_distance"`
+
+	Latitude    float64 `db:"latitude"`
+	Longitude   float64 `db:"longitude"`
+	Altitude    float64 `db:"altitude"`
+	AltitudeRef int     `db:"altitude_ref"`
+
+	ImageDirection    float64      `db:"image_direction"`
+	ImageDirectionRef string       `db:"image_direction_ref"`
+	GPSTime           sql.NullTime `db:"gps_time"`
 
 	CreatedAt time.Time `db:"created_at"`
 	UpdatedAt time.Time `db:"updated_at"`
@@ -50,6 +63,24 @@ type dbMedia struct {
 	Orientation int `db:"orientation"`
 
 	DisplayOffset int `db:"display_offset"`
+
+	SHA256 sql.NullString `db:"sha256"`
+
+	DurationMS int64 `db:"duration_ms"`
+
+	CropX      float64 `db:"crop_x"`
+	CropY      float64 `db:"crop_y"`
+	CropWidth  float64 `db:"crop_width"`
+	CropHeight float64 `db:"crop_height"`
+	Rotation   int     `db:"rotation"`
+	Straighten float64 `db:"straighten"`
+
+	BlurHash       string `db:"blur_hash"`
+	DominantColour string `db:"dominant_colour"`
+
+	PerceptualHash string `db:"perceptual_hash"`
+
+	MetadataTags string `db:"metadata_tags"`
 }
 
 func (d dbMedia) ToRecord(includeID bool) goqu.Record {
@@ -67,6 +98,15 @@ func (d dbMedia) ToRecord(includeID bool) goqu.Record {
 		"latitude":                  d.Latitude,
 		"longitude":                 d.Longitude,
 		"altitude":                  d.Altitude,
+		"altitude_ref":              d.AltitudeRef,
+		"exposure_compensation":     d.ExposureCompensation,
+		"exposure_program":          d.ExposureProgram,
+		"metering_mode":             d.MeteringMode,
+		"flash":                     d.Flash,
+		"white_balance":             d.WhiteBalance,
+		"subject_distance":          d.SubjectDistance,
+		"image_direction":           d.ImageDirection,
+		"image_direction_ref":       d.ImageDirectionRef,
 		"device_id":                 d.DeviceID,
 		"instagram_code":            d.InstagramCode,
 		"utc_correct":               d.UTCCorrect,
@@ -74,6 +114,17 @@ func (d dbMedia) ToRecord(includeID bool) goqu.Record {
 		"height":                    d.Height,
 		"orientation":               d.Orientation,
 		"display_offset":            d.DisplayOffset,
+		"duration_ms":               d.DurationMS,
+		"crop_x":                    d.CropX,
+		"crop_y":                    d.CropY,
+		"crop_width":                d.CropWidth,
+		"crop_height":               d.CropHeight,
+		"rotation":                  d.Rotation,
+		"straighten":                d.Straighten,
+		"blur_hash":                 d.BlurHash,
+		"dominant_colour":           d.DominantColour,
+		"perceptual_hash":           d.PerceptualHash,
+		"metadata_tags":             d.MetadataTags,
 	}
 
 	record["lens_id"] = nil
@@ -81,6 +132,16 @@ func (d dbMedia) ToRecord(includeID bool) goqu.Record {
 		record["lens_id"] = d.LensID.Int64
 	}
 
+	record["sha256"] = nil
+	if d.SHA256.Valid {
+		record["sha256"] = d.SHA256.String
+	}
+
+	record["gps_time"] = nil
+	if d.GPSTime.Valid {
+		record["gps_time"] = d.GPSTime.Time
+	}
+
 	if includeID {
 		record["id"] = d.ID
 	}
@@ -106,9 +167,18 @@ func (d dbMedia) ToModel() models.Media {
 		ExposureTimeNumerator:   d.ExposureTimeNumerator,
 		ExposureTimeDenominator: d.ExposureTimeDenominator,
 		ISOSpeed:                d.ISOSpeed,
+		ExposureCompensation:    d.ExposureCompensation,
+		ExposureProgram:         d.ExposureProgram,
+		MeteringMode:            d.MeteringMode,
+		Flash:                   d.Flash,
+		WhiteBalance:            d.WhiteBalance,
+		SubjectDistance:         d.SubjectDistance,
 		Latitude:                d.Latitude,
 		Longitude:               d.Longitude,
 		Altitude:                d.Altitude,
+		AltitudeRef:             d.AltitudeRef,
+		ImageDirection:          d.ImageDirection,
+		ImageDirectionRef:       d.ImageDirectionRef,
 
 		CreatedAt: d.CreatedAt,
 		UpdatedAt: d.UpdatedAt,
@@ -125,12 +195,37 @@ func (d dbMedia) ToModel() models.Media {
 		Orientation: d.Orientation,
 
 		DisplayOffset: d.DisplayOffset,
+
+		Duration: time.Duration(d.DurationMS) * time.Millisecond,
+
+		CropX:      d.CropX,
+		CropY:      d.CropY,
+		CropWidth:  d.CropWidth,
+		CropHeight: d.CropHeight,
+		Rotation:   d.Rotation,
+		Straighten: d.Straighten,
+
+		BlurHash:       d.BlurHash,
+		DominantColour: d.DominantColour,
+
+		PerceptualHash: d.PerceptualHash,
 	}
 
 	if d.LensID.Valid {
 		media.LensID = d.LensID.Int64
 	}
 
+	if d.SHA256.Valid {
+		media.SHA256 = d.SHA256.String
+	}
+
+	if d.GPSTime.Valid {
+		media.GPSTime = d.GPSTime.Time.UTC()
+	}
+
+	// the column only holds JSON written by newDBMedia
+	_ = json.Unmarshal([]byte(d.MetadataTags), &media.MetadataTags)
+
 	return media
 }
 
@@ -152,9 +247,18 @@ func newDBMedia(media models.Media) dbMedia {
 		ExposureTimeNumerator:   media.ExposureTimeNumerator,
 		ExposureTimeDenominator: media.ExposureTimeDenominator,
 		ISOSpeed:                media.ISOSpeed,
+		ExposureCompensation:    media.ExposureCompensation,
+		ExposureProgram:         media.ExposureProgram,
+		MeteringMode:            media.MeteringMode,
+		Flash:                   media.Flash,
+		WhiteBalance:            media.WhiteBalance,
+		SubjectDistance:         media.SubjectDistance,
 		Latitude:                media.Latitude,
 		Longitude:               media.Longitude,
 		Altitude:                media.Altitude,
+		AltitudeRef:             media.AltitudeRef,
+		ImageDirection:          media.ImageDirection,
+		ImageDirectionRef:       media.ImageDirectionRef,
 
 		CreatedAt: media.CreatedAt,
 		UpdatedAt: media.UpdatedAt,
@@ -171,6 +275,22 @@ func newDBMedia(media models.Media) dbMedia {
 		Orientation: media.Orientation,
 
 		DisplayOffset: media.DisplayOffset,
+
+		DurationMS: media.Duration.Milliseconds(),
+
+		CropX:      media.CropX,
+		CropY:      media.CropY,
+		CropWidth:  media.CropWidth,
+		CropHeight: media.CropHeight,
+		Rotation:   media.Rotation,
+		Straighten: media.Straighten,
+
+		BlurHash:       media.BlurHash,
+		DominantColour: media.DominantColour,
+
+		PerceptualHash: media.PerceptualHash,
+
+		MetadataTags: metadataTagsJSON(media.MetadataTags),
 	}
 
 	m.LensID = sql.NullInt64{
@@ -184,12 +304,42 @@ func newDBMedia(media models.Media) dbMedia {
 		}
 	}
 
+	// null rather than empty so that the unique index allows many
+	if media.SHA256 != "" {
+		m.SHA256 = sql.NullString{
+			Valid:  true,
+			String: media.SHA256,
+		}
+	}
+
+	if !media.GPSTime.IsZero() {
+		m.GPSTime = sql.NullTime{
+			Valid: true,
+			Time:  media.GPSTime.UTC(),
+		}
+	}
+
 	return m
 }
 
 // UpdateMedias is not implemented as a single SQL query since update many in
 // place is not supported by goqu and it wasn't worth the work (TODO).
 // MediaRepository provides media-specific database operations.
+// metadataTagsJSON returns the tags for the metadata_tags column. The values
+// are only strings, numbers and lists of them so always encode.
+func metadataTagsJSON(tags []models.MetadataTag) string {
+	if len(tags) == 0 {
+		return "[]"
+	}
+
+	b, err := json.Marshal(tags)
+	if err != nil {
+		return "[]"
+	}
+
+	return string(b)
+}
+
 type MediaRepository struct {
 	*BaseRepository[models.Media, dbMedia]
 }
@@ -231,6 +381,130 @@ func (r *MediaRepository) AllDescending(ctx context.Context) ([]models.Media, er
 	return results, nil
 }
 
+// AfterID retrieves up to limit medias with an ID greater than afterID in ID
+// order. This is used to page through the whole library in batch jobs.
+func (r *MediaRepository) AfterID(ctx context.Context, afterID int, limit uint) ([]models.Media, error) {
+	var dbMedias []dbMedia
+
+	goquDB := goqu.New("postgres", r.db)
+	query := goquDB.From(goqu.T(r.tableName).Schema(r.schema)).
+		Select("*").
+		Where(goqu.I("id").Gt(afterID)).
+		Order(goqu.I("id").Asc()).
+		Limit(limit).
+		Executor()
+
+	err := query.ScanStructsContext(ctx, &dbMedias)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to select medias after id")
+	}
+
+	results := make([]models.Media, 0, len(dbMedias))
+	for i := range dbMedias {
+		results = append(results, newMedia(dbMedias[i]))
+	}
+
+	return results, nil
+}
+
+// FindBySHA256 finds the medias with the given original file checksum.
+func (r *MediaRepository) FindBySHA256(ctx context.Context, sum string) ([]models.Media, error) {
+	return r.FindByField(ctx, "sha256", sum)
+}
+
+// SetPlaceholder saves the placeholder of a media. Only those columns are
+// updated so that edits made while thumbnails are being made are kept.
+func (r *MediaRepository) SetPlaceholder(ctx context.Context, id int, blurHash, dominantColour string) error {
+	goquDB := goqu.New("postgres", r.db)
+	_, err := goquDB.Update(goqu.T(r.tableName).Schema(r.schema)).
+		Set(goqu.Record{
+			"blur_hash":       blurHash,
+			"dominant_colour": dominantColour,
+		}).
+		Where(goqu.Ex{"id": id}).
+		Executor().
+		ExecContext(ctx)
+	if err != nil {
+		return errors.Wrapf(err, "failed to set placeholder of media %d", id)
+	}
+
+	return nil
+}
+
+// SetPerceptualHash saves the perceptual hash of a media, like SetPlaceholder
+// only the one column is updated.
+func (r *MediaRepository) SetPerceptualHash(ctx context.Context, id int, hash string) error {
+	goquDB := goqu.New("postgres", r.db)
+	_, err := goquDB.Update(goqu.T(r.tableName).Schema(r.schema)).
+		Set(goqu.Record{"perceptual_hash": hash}).
+		Where(goqu.Ex{"id": id}).
+		Executor().
+		ExecContext(ctx)
+	if err != nil {
+		return errors.Wrapf(err, "failed to set perceptual hash of media %d", id)
+	}
+
+	return nil
+}
+
+// PerceptualHashes returns the perceptual hash of each media which has one,
+// keyed by media ID. Only the two columns are read so that all media can be
+// compared cheaply.
+func (r *MediaRepository) PerceptualHashes(ctx context.Context) (map[int]string, error) {
+	var rows []struct {
+		ID             int    `db:"id"`
+		PerceptualHash string `db:"perceptual_hash"`
+	}
+
+	goquDB := goqu.New("postgres", r.db)
+	err := goquDB.From(goqu.T(r.tableName).Schema(r.schema)).
+		Select("id", "perceptual_hash").
+		Where(goqu.C("perceptual_hash").Neq("")).
+		Executor().
+		ScanStructsContext(ctx, &rows)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to select perceptual hashes")
+	}
+
+	hashes := make(map[int]string, len(rows))
+	for _, row := range rows {
+		hashes[row.ID] = row.PerceptualHash
+	}
+
+	return hashes, nil
+}
+
+// SetMetadataTags replaces the EXIF and XMP tags of a media, used when the
+// metadata is extracted again from the original file.
+func (r *MediaRepository) SetMetadataTags(ctx context.Context, id int, tags []models.MetadataTag) error {
+	goquDB := goqu.New("postgres", r.db)
+	_, err := goquDB.Update(goqu.T(r.tableName).Schema(r.schema)).
+		Set(goqu.Record{"metadata_tags": metadataTagsJSON(tags)}).
+		Where(goqu.Ex{"id": id}).
+		Executor().
+		ExecContext(ctx)
+	if err != nil {
+		return errors.Wrapf(err, "failed to set metadata tags of media %d", id)
+	}
+
+	return nil
+}
+
+// MatchDeviceAndLens sets the device and lens of media to those matching its
+// EXIF model and lens names. The existing IDs are kept when nothing matches.
+func MatchDeviceAndLens(ctx context.Context, db *sql.DB, media *models.Media) {
+	deviceRepo := NewDeviceRepository(db)
+	modelMatchedDevice, err := deviceRepo.FindByModelMatches(ctx, media.Model)
+	if err == nil && modelMatchedDevice != nil {
+		media.DeviceID = modelMatchedDevice.ID
+	}
+
+	lensMatchLens, err := FindLensByLensMatches(ctx, db, media.Lens)
+	if err == nil && lensMatchLens != nil {
+		media.LensID = lensMatchLens.ID
+	}
+}
+
 // Legacy function wrappers for backward compatibility with test files.
 // These should be removed after all tests are updated.
 
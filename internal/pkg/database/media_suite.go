@@ -587,3 +587,205 @@ func (s *MediasSuite) TestUpdateMedias() {
 
 	td.Cmp(s.T(), returnedMedias, expectedResult)
 }
+
+func (s *MediasSuite) TestMediasAfterID() {
+	returnedDevices, err := CreateDevices(s.T().Context(), s.DB, []models.Device{{Name: "Example Device"}})
+	s.Require().NoError(err)
+
+	medias := []models.Media{
+		{DeviceID: returnedDevices[0].ID, Orientation: 1},
+		{DeviceID: returnedDevices[0].ID, Orientation: 1},
+		{DeviceID: returnedDevices[0].ID, Orientation: 1},
+	}
+
+	returnedMedias, err := CreateMedias(s.T().Context(), s.DB, medias)
+	s.Require().NoError(err)
+
+	repo := NewMediaRepository(s.DB)
+
+	page, err := repo.AfterID(s.T().Context(), returnedMedias[0].ID, 1)
+	s.Require().NoError(err)
+	s.Require().Len(page, 1)
+	s.Equal(returnedMedias[1].ID, page[0].ID)
+
+	page, err = repo.AfterID(s.T().Context(), returnedMedias[1].ID, 10)
+	s.Require().NoError(err)
+	s.Require().Len(page, 1)
+	s.Equal(returnedMedias[2].ID, page[0].ID)
+
+	page, err = repo.AfterID(s.T().Context(), returnedMedias[2].ID, 10)
+	s.Require().NoError(err)
+	s.Empty(page)
+}
+
+func (s *MediasSuite) TestFindMediasBySHA256() {
+	returnedDevices, err := CreateDevices(s.T().Context(), s.DB, []models.Device{{Name: "Example Device"}})
+	s.Require().NoError(err)
+
+	// medias without checksums are stored as null so the unique index allows
+	// more than one
+	medias := []models.Media{
+		{DeviceID: returnedDevices[0].ID, Orientation: 1, SHA256: "aaa"},
+		{DeviceID: returnedDevices[0].ID, Orientation: 1},
+		{DeviceID: returnedDevices[0].ID, Orientation: 1},
+	}
+
+	returnedMedias, err := CreateMedias(s.T().Context(), s.DB, medias)
+	s.Require().NoError(err)
+
+	repo := NewMediaRepository(s.DB)
+
+	found, err := repo.FindBySHA256(s.T().Context(), "aaa")
+	s.Require().NoError(err)
+	s.Require().Len(found, 1)
+	s.Equal(returnedMedias[0].ID, found[0].ID)
+	s.Equal("aaa", found[0].SHA256)
+
+	_, err = CreateMedias(s.T().Context(), s.DB, []models.Media{
+		{DeviceID: returnedDevices[0].ID, Orientation: 1, SHA256: "aaa"},
+	})
+	s.Require().Error(err)
+}
+
+func (s *MediasSuite) TestMediasEdits() {
+	returnedDevices, err := CreateDevices(s.T().Context(), s.DB, []models.Device{{Name: "Example Device"}})
+	s.Require().NoError(err)
+
+	returnedMedias, err := CreateMedias(s.T().Context(), s.DB, []models.Media{
+		{DeviceID: returnedDevices[0].ID, Orientation: 1},
+	})
+	s.Require().NoError(err)
+	s.Zero(returnedMedias[0].Rotation)
+	s.Zero(returnedMedias[0].CropWidth)
+
+	returnedMedias[0].CropX = 0.1
+	returnedMedias[0].CropY = 0.2
+	returnedMedias[0].CropWidth = 0.5
+	returnedMedias[0].CropHeight = 0.6
+	returnedMedias[0].Rotation = 270
+	returnedMedias[0].Straighten = -1.5
+
+	_, err = UpdateMedias(s.T().Context(), s.DB, returnedMedias)
+	s.Require().NoError(err)
+
+	found, err := FindMediasByID(s.T().Context(), s.DB, []int{returnedMedias[0].ID})
+	s.Require().NoError(err)
+	s.Require().Len(found, 1)
+	s.InDelta(0.1, found[0].CropX, 0.0001)
+	s.InDelta(0.2, found[0].CropY, 0.0001)
+	s.InDelta(0.5, found[0].CropWidth, 0.0001)
+	s.InDelta(0.6, found[0].CropHeight, 0.0001)
+	s.Equal(270, found[0].Rotation)
+	s.InDelta(-1.5, found[0].Straighten, 0.0001)
+}
+
+func (s *MediasSuite) TestSetPlaceholder() {
+	returnedDevices, err := CreateDevices(s.T().Context(), s.DB, []models.Device{{Name: "Example Device"}})
+	s.Require().NoError(err)
+
+	returnedMedias, err := CreateMedias(s.T().Context(), s.DB, []models.Media{
+		{DeviceID: returnedDevices[0].ID, Orientation: 1, Make: "Fuji"},
+	})
+	s.Require().NoError(err)
+
+	repo := NewMediaRepository(s.DB)
+	err = repo.SetPlaceholder(s.T().Context(), returnedMedias[0].ID, "LEHV6nWB2yk8pyo0adR*.7kCMdnj", "#a0b1c2")
+	s.Require().NoError(err)
+
+	found, err := FindMediasByID(s.T().Context(), s.DB, []int{returnedMedias[0].ID})
+	s.Require().NoError(err)
+	s.Require().Len(found, 1)
+	s.Equal("LEHV6nWB2yk8pyo0adR*.7kCMdnj", found[0].BlurHash)
+	s.Equal("#a0b1c2", found[0].DominantColour)
+	s.Equal("Fuji", found[0].Make)
+}
+
+func (s *MediasSuite) TestPerceptualHashes() {
+	returnedDevices, err := CreateDevices(s.T().Context(), s.DB, []models.Device{{Name: "Example Device"}})
+	s.Require().NoError(err)
+
+	returnedMedias, err := CreateMedias(s.T().Context(), s.DB, []models.Media{
+		{DeviceID: returnedDevices[0].ID, Orientation: 1, PerceptualHash: "0000000000000003"},
+		{DeviceID: returnedDevices[0].ID, Orientation: 1},
+	})
+	s.Require().NoError(err)
+
+	hashes, err := NewMediaRepository(s.DB).PerceptualHashes(s.T().Context())
+	s.Require().NoError(err)
+	s.Equal(map[int]string{returnedMedias[0].ID: "0000000000000003"}, hashes)
+}
+
+func (s *MediasSuite) TestMetadataTags() {
+	returnedDevices, err := CreateDevices(s.T().Context(), s.DB, []models.Device{{Name: "Example Device"}})
+	s.Require().NoError(err)
+
+	returnedMedias, err := CreateMedias(s.T().Context(), s.DB, []models.Media{
+		{
+			DeviceID:    returnedDevices[0].ID,
+			Orientation: 1,
+			MetadataTags: []models.MetadataTag{
+				{IFD: "IFD", Name: "Make", Type: "ASCII", Value: "Fuji"},
+			},
+		},
+		{DeviceID: returnedDevices[0].ID, Orientation: 1},
+	})
+	s.Require().NoError(err)
+
+	s.Equal([]models.MetadataTag{{IFD: "IFD", Name: "Make", Type: "ASCII", Value: "Fuji"}}, returnedMedias[0].MetadataTags)
+	s.Empty(returnedMedias[1].MetadataTags)
+
+	// numbers are decoded as float64 from JSON
+	tags := []models.MetadataTag{
+		{IFD: "IFD", Name: "Orientation", Type: "SHORT", Value: float64(6)},
+		{IFD: "XMP", Name: "dc:subject", Type: "Bag", Value: []any{"sea", "boat"}},
+	}
+
+	repo := NewMediaRepository(s.DB)
+	err = repo.SetMetadataTags(s.T().Context(), returnedMedias[1].ID, tags)
+	s.Require().NoError(err)
+
+	found, err := FindMediasByID(s.T().Context(), s.DB, []int{returnedMedias[1].ID})
+	s.Require().NoError(err)
+	s.Require().Len(found, 1)
+	s.Equal(tags, found[0].MetadataTags)
+}
+
+func (s *MediasSuite) TestExposureAndGPSDetails() {
+	returnedDevices, err := CreateDevices(s.T().Context(), s.DB, []models.Device{{Name: "Example Device"}})
+	s.Require().NoError(err)
+
+	media := models.Media{
+		DeviceID:             returnedDevices[0].ID,
+		Orientation:          1,
+		ExposureCompensation: -0.67,
+		ExposureProgram:      "Aperture priority",
+		MeteringMode:         "Spot",
+		Flash:                "Fired",
+		WhiteBalance:         "Manual",
+		SubjectDistance:      models.SubjectDistanceInfinity,
+		Altitude:             -3,
+		AltitudeRef:          1,
+		ImageDirection:       270.5,
+		ImageDirectionRef:    "M",
+		GPSTime:              time.Date(2024, time.May, 6, 13, 4, 30, 0, time.UTC),
+	}
+
+	returnedMedias, err := CreateMedias(s.T().Context(), s.DB, []models.Media{
+		media,
+		{DeviceID: returnedDevices[0].ID, Orientation: 1},
+	})
+	s.Require().NoError(err)
+
+	found, err := FindMediasByID(s.T().Context(), s.DB, []int{returnedMedias[0].ID, returnedMedias[1].ID})
+	s.Require().NoError(err)
+	s.Require().Len(found, 2)
+
+	td.Cmp(s.T(), found[0], td.SStruct(media, td.StructFields{
+		"ID":           td.Ignore(),
+		"CreatedAt":    td.Ignore(),
+		"UpdatedAt":    td.Ignore(),
+		"MetadataTags": td.Empty(),
+	}))
+	s.True(found[1].GPSTime.IsZero())
+	s.Empty(found[1].ImageDirectionRef)
+}
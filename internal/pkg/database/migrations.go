@@ -2,7 +2,50 @@ package database
 
 import (
 	"embed"
+	"io/fs"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
 )
 
 //go:embed migrations
 var Migrations embed.FS
+
+// Migration is a single embedded migration.
+type Migration struct {
+	Version uint
+	Name    string
+}
+
+// ListMigrations returns the embedded migrations in the order they are
+// applied.
+func ListMigrations() ([]Migration, error) {
+	entries, err := fs.ReadDir(Migrations, "migrations")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read migrations")
+	}
+
+	var migrations []Migration
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), ".up.sql")
+		if !ok {
+			continue
+		}
+
+		rawVersion, name, _ := strings.Cut(name, "_")
+		version, err := strconv.ParseUint(rawVersion, 10, 64)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid migration version in %s", entry.Name())
+		}
+
+		migrations = append(migrations, Migration{Version: uint(version), Name: name})
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
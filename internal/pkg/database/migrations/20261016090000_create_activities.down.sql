@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS photos.points;
+DROP TABLE IF EXISTS photos.activities;
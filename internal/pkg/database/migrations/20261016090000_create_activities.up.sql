@@ -0,0 +1,47 @@
+-- activities are events spanning a period of time, such as a run or a ride
+CREATE TABLE photos.activities (
+  id SERIAL NOT NULL PRIMARY KEY,
+
+  title text NOT NULL DEFAULT '',
+  description text NOT NULL DEFAULT '',
+
+  start_time TIMESTAMPTZ NOT NULL,
+  end_time TIMESTAMPTZ NOT NULL,
+
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+  -- the same activity is often present in exports from more than one service
+  UNIQUE (start_time, end_time)
+);
+
+CREATE TRIGGER set_timestamp_update
+    BEFORE UPDATE ON photos.activities
+    FOR EACH ROW
+EXECUTE PROCEDURE trigger_set_timestamp();
+
+-- points are recorded locations for a given time during an activity
+CREATE TABLE photos.points (
+  id BIGSERIAL NOT NULL PRIMARY KEY,
+
+  activity_id INT NOT NULL,
+
+  recorded_at TIMESTAMPTZ NOT NULL,
+
+  latitude float NOT NULL DEFAULT 0,
+  longitude float NOT NULL DEFAULT 0,
+  altitude float NOT NULL DEFAULT 0,
+
+  accuracy float NOT NULL DEFAULT 0,
+  vertical_accuracy float NOT NULL DEFAULT 0,
+
+  velocity float NOT NULL DEFAULT 0,
+
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+  CONSTRAINT fk_activity_id FOREIGN KEY(activity_id) REFERENCES photos.activities(id) ON DELETE CASCADE
+);
+
+-- points are looked up by time when working out where media was taken
+CREATE INDEX points_recorded_at_idx ON photos.points (recorded_at);
+CREATE INDEX points_activity_id_idx ON photos.points (activity_id);
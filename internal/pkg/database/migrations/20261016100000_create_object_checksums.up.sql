@@ -0,0 +1,23 @@
+-- object_checksums record the contents of original files in the bucket so
+-- that later verification runs can detect corruption
+CREATE TABLE photos.object_checksums (
+  id SERIAL NOT NULL PRIMARY KEY,
+
+  key text NOT NULL,
+  sha256 text NOT NULL,
+  size BIGINT NOT NULL DEFAULT 0,
+
+  -- mod_time is the modification time of the object when it was checksummed,
+  -- objects replaced after this time are expected to have a new checksum
+  mod_time TIMESTAMPTZ NOT NULL,
+
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+  UNIQUE (key)
+);
+
+CREATE TRIGGER set_timestamp_update
+    BEFORE UPDATE ON photos.object_checksums
+    FOR EACH ROW
+EXECUTE PROCEDURE trigger_set_timestamp();
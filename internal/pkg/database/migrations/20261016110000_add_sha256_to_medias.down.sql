@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS photos.medias_sha256_key;
+
+ALTER TABLE photos.medias DROP COLUMN IF EXISTS sha256;
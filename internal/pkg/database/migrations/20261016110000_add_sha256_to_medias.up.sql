@@ -0,0 +1,5 @@
+-- Add sha256 column to medias table, used to reject duplicate uploads. Older
+-- medias are null until backfilled.
+ALTER TABLE photos.medias ADD COLUMN IF NOT EXISTS sha256 TEXT;
+
+CREATE UNIQUE INDEX IF NOT EXISTS medias_sha256_key ON photos.medias (sha256);
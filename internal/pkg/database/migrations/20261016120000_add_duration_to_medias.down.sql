@@ -0,0 +1 @@
+ALTER TABLE photos.medias DROP COLUMN IF EXISTS duration_ms;
@@ -0,0 +1,2 @@
+-- Add duration column to medias table, set for videos and zero for images.
+ALTER TABLE photos.medias ADD COLUMN IF NOT EXISTS duration_ms BIGINT NOT NULL DEFAULT 0;
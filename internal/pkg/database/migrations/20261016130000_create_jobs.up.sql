@@ -0,0 +1,32 @@
+-- jobs is the queue of background work, such as making thumbnails, which is
+-- run by workers rather than in the HTTP handlers which create it
+CREATE TABLE photos.jobs (
+  id SERIAL NOT NULL PRIMARY KEY,
+
+  kind text NOT NULL,
+  payload JSONB NOT NULL DEFAULT '{}',
+
+  -- state is pending, running or failed. Jobs are deleted when they succeed
+  state text NOT NULL DEFAULT 'pending',
+  attempts INTEGER NOT NULL DEFAULT 0,
+  max_attempts INTEGER NOT NULL DEFAULT 5,
+  last_error text NOT NULL DEFAULT '',
+
+  run_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  locked_at TIMESTAMPTZ,
+
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- the same work is only queued once at a time
+CREATE UNIQUE INDEX jobs_waiting_kind_payload ON photos.jobs (kind, payload)
+  WHERE state IN ('pending', 'running');
+
+CREATE INDEX jobs_pending_run_at ON photos.jobs (run_at)
+  WHERE state = 'pending';
+
+CREATE TRIGGER set_timestamp_update
+    BEFORE UPDATE ON photos.jobs
+    FOR EACH ROW
+EXECUTE PROCEDURE trigger_set_timestamp();
@@ -0,0 +1,7 @@
+ALTER TABLE photos.medias
+    DROP COLUMN IF EXISTS crop_x,
+    DROP COLUMN IF EXISTS crop_y,
+    DROP COLUMN IF EXISTS crop_width,
+    DROP COLUMN IF EXISTS crop_height,
+    DROP COLUMN IF EXISTS rotation,
+    DROP COLUMN IF EXISTS straighten;
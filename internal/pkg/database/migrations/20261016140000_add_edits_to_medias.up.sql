@@ -0,0 +1,9 @@
+-- Add crop, rotation and straighten edits, these are applied to thumbnails
+-- and leave the original unchanged.
+ALTER TABLE photos.medias
+    ADD COLUMN IF NOT EXISTS crop_x float NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS crop_y float NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS crop_width float NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS crop_height float NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS rotation INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS straighten float NOT NULL DEFAULT 0;
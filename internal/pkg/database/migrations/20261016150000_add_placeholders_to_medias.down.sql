@@ -0,0 +1,3 @@
+ALTER TABLE photos.medias
+    DROP COLUMN IF EXISTS blur_hash,
+    DROP COLUMN IF EXISTS dominant_colour;
@@ -0,0 +1,5 @@
+-- BlurHash and dominant colour placeholders shown while thumbnails load, both
+-- are empty until the thumbnails of a media have been made.
+ALTER TABLE photos.medias
+    ADD COLUMN IF NOT EXISTS blur_hash text NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS dominant_colour text NOT NULL DEFAULT '';
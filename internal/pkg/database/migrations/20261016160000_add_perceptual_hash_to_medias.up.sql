@@ -0,0 +1,4 @@
+-- A difference hash of each media used to find near duplicates, empty until
+-- the thumbnails of a media have been made.
+ALTER TABLE photos.medias
+    ADD COLUMN IF NOT EXISTS perceptual_hash text NOT NULL DEFAULT '';
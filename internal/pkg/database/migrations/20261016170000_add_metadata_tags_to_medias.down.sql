@@ -0,0 +1,2 @@
+ALTER TABLE photos.medias
+    DROP COLUMN IF EXISTS metadata_tags;
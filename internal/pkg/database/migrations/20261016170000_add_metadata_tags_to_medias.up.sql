@@ -0,0 +1,4 @@
+-- Every EXIF and XMP tag read from the original file, kept so that fields
+-- can be derived later without uploading the media again.
+ALTER TABLE photos.medias
+    ADD COLUMN IF NOT EXISTS metadata_tags JSONB NOT NULL DEFAULT '[]';
@@ -0,0 +1,7 @@
+ALTER TABLE photos.medias
+    DROP COLUMN IF EXISTS exposure_compensation,
+    DROP COLUMN IF EXISTS exposure_program,
+    DROP COLUMN IF EXISTS metering_mode,
+    DROP COLUMN IF EXISTS flash,
+    DROP COLUMN IF EXISTS white_balance,
+    DROP COLUMN IF EXISTS subject_distance;
@@ -0,0 +1,9 @@
+-- Exposure details from EXIF, the modes are stored by name and are empty
+-- when unknown. Subject distance is in metres and -1 at infinity.
+ALTER TABLE photos.medias
+    ADD COLUMN IF NOT EXISTS exposure_compensation float NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS exposure_program text NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS metering_mode text NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS flash text NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS white_balance text NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS subject_distance float NOT NULL DEFAULT 0;
@@ -0,0 +1,5 @@
+ALTER TABLE photos.medias
+    DROP COLUMN IF EXISTS image_direction,
+    DROP COLUMN IF EXISTS image_direction_ref,
+    DROP COLUMN IF EXISTS gps_time,
+    DROP COLUMN IF EXISTS altitude_ref;
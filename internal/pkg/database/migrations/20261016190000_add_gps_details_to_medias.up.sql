@@ -0,0 +1,7 @@
+-- The direction of the camera, the time of the GPS fix in UTC and if the
+-- altitude is below sea level. The direction ref is empty when unknown.
+ALTER TABLE photos.medias
+    ADD COLUMN IF NOT EXISTS image_direction float NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS image_direction_ref text NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS gps_time TIMESTAMP,
+    ADD COLUMN IF NOT EXISTS altitude_ref INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,12 @@
+DROP INDEX IF EXISTS photos.jobs_pending_kind_payload;
+
+-- pending jobs queued while the same work was running can't be kept
+DELETE FROM photos.jobs AS pending
+  USING photos.jobs AS running
+  WHERE pending.state = 'pending'
+    AND running.state = 'running'
+    AND pending.kind = running.kind
+    AND pending.payload = running.payload;
+
+CREATE UNIQUE INDEX jobs_waiting_kind_payload ON photos.jobs (kind, payload)
+  WHERE state IN ('pending', 'running');
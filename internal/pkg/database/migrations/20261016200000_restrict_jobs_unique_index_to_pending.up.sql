@@ -0,0 +1,6 @@
+-- work queued while a job is running is added again, the running job may
+-- have read the state from before the change which queued it
+DROP INDEX IF EXISTS photos.jobs_waiting_kind_payload;
+
+CREATE UNIQUE INDEX jobs_pending_kind_payload ON photos.jobs (kind, payload)
+  WHERE state = 'pending';
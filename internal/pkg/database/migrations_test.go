@@ -0,0 +1,21 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestListMigrations(t *testing.T) {
+	t.Parallel()
+
+	migrations, err := ListMigrations()
+	require.NoError(t, err)
+
+	require.NotEmpty(t, migrations)
+	require.Equal(t, Migration{Version: 0, Name: "create_function_set_ts"}, migrations[0])
+
+	for i := 1; i < len(migrations); i++ {
+		require.Greater(t, migrations[i].Version, migrations[i-1].Version)
+	}
+}
@@ -0,0 +1,147 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/doug-martin/goqu/v9"
+	"github.com/pkg/errors"
+
+	"github.com/charlieegan3/photos/internal/pkg/models"
+)
+
+type dbObjectChecksum struct {
+	ID int `db:"id"`
+
+	Key    string `db:"key"`
+	SHA256 string `db:"sha256"`
+	Size   int64  `db:"size"`
+
+	ModTime time.Time `db:"mod_time"`
+
+	CreatedAt time.Time `db:"created_at"`
+	UpdatedAt time.Time `db:"updated_at"`
+}
+
+func (d dbObjectChecksum) ToRecord(includeID bool) goqu.Record {
+	record := goqu.Record{
+		"key":      d.Key,
+		"sha256":   d.SHA256,
+		"size":     d.Size,
+		"mod_time": d.ModTime.UTC(),
+	}
+
+	if includeID {
+		record["id"] = d.ID
+	}
+
+	return record
+}
+
+func (d dbObjectChecksum) ToModel() models.ObjectChecksum {
+	return models.ObjectChecksum{
+		ID:        d.ID,
+		Key:       d.Key,
+		SHA256:    d.SHA256,
+		Size:      d.Size,
+		ModTime:   d.ModTime.UTC(),
+		CreatedAt: d.CreatedAt,
+		UpdatedAt: d.UpdatedAt,
+	}
+}
+
+func newObjectChecksum(checksum dbObjectChecksum) models.ObjectChecksum {
+	return checksum.ToModel()
+}
+
+func newDBObjectChecksum(checksum models.ObjectChecksum) dbObjectChecksum {
+	return dbObjectChecksum{
+		ID:        checksum.ID,
+		Key:       checksum.Key,
+		SHA256:    checksum.SHA256,
+		Size:      checksum.Size,
+		ModTime:   checksum.ModTime.UTC(),
+		CreatedAt: checksum.CreatedAt,
+		UpdatedAt: checksum.UpdatedAt,
+	}
+}
+
+// ObjectChecksumRepository provides operations for bucket object checksums.
+type ObjectChecksumRepository struct {
+	*BaseRepository[models.ObjectChecksum, dbObjectChecksum]
+}
+
+// NewObjectChecksumRepository creates a new object checksum repository instance.
+func NewObjectChecksumRepository(db *sql.DB) *ObjectChecksumRepository {
+	return &ObjectChecksumRepository{
+		BaseRepository: NewBaseRepository(db, "object_checksums", newObjectChecksum, newDBObjectChecksum, "key"),
+	}
+}
+
+// AllByKey returns all the recorded checksums keyed by object key.
+func (r *ObjectChecksumRepository) AllByKey(ctx context.Context) (map[string]models.ObjectChecksum, error) {
+	checksums, err := r.All(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make(map[string]models.ObjectChecksum, len(checksums))
+	for _, checksum := range checksums {
+		results[checksum.Key] = checksum
+	}
+
+	return results, nil
+}
+
+// FindBySHA256 finds the objects with the given checksum.
+func (r *ObjectChecksumRepository) FindBySHA256(ctx context.Context, sum string) ([]models.ObjectChecksum, error) {
+	return r.FindByField(ctx, "sha256", sum)
+}
+
+// Upsert records the checksums, replacing any existing checksums for the same
+// keys.
+func (r *ObjectChecksumRepository) Upsert(ctx context.Context, checksums []models.ObjectChecksum) error {
+	if len(checksums) == 0 {
+		return nil
+	}
+
+	records := make([]goqu.Record, 0, len(checksums))
+	for _, checksum := range checksums {
+		records = append(records, newDBObjectChecksum(checksum).ToRecord(false))
+	}
+
+	goquDB := goqu.New("postgres", r.db)
+	_, err := goquDB.Insert(goqu.T(r.tableName).Schema(r.schema)).
+		Rows(records).
+		OnConflict(goqu.DoUpdate("key", goqu.Record{
+			"sha256":   goqu.L("EXCLUDED.sha256"),
+			"size":     goqu.L("EXCLUDED.size"),
+			"mod_time": goqu.L("EXCLUDED.mod_time"),
+		})).
+		Executor().
+		ExecContext(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to upsert object checksums")
+	}
+
+	return nil
+}
+
+// DeleteByKeys removes the checksums for objects which no longer exist.
+func (r *ObjectChecksumRepository) DeleteByKeys(ctx context.Context, keys []string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	goquDB := goqu.New("postgres", r.db)
+	_, err := goquDB.Delete(goqu.T(r.tableName).Schema(r.schema)).
+		Where(goqu.Ex{"key": keys}).
+		Executor().
+		ExecContext(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to delete object checksums")
+	}
+
+	return nil
+}
@@ -0,0 +1,94 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/maxatome/go-testdeep/td"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/charlieegan3/photos/internal/pkg/models"
+)
+
+// ObjectChecksumsSuite is a number of tests to define the database
+// integration for storing bucket object checksums.
+type ObjectChecksumsSuite struct {
+	suite.Suite
+
+	DB *sql.DB
+}
+
+func (s *ObjectChecksumsSuite) SetupTest() {
+	err := Truncate(s.T().Context(), s.DB, "photos.object_checksums")
+	s.Require().NoError(err)
+}
+
+func (s *ObjectChecksumsSuite) TestUpsert() {
+	repo := NewObjectChecksumRepository(s.DB)
+
+	modTime := time.Date(2021, time.November, 23, 19, 56, 0, 0, time.UTC)
+
+	err := repo.Upsert(s.T().Context(), []models.ObjectChecksum{
+		{Key: "media/1.jpg", SHA256: "aaa", Size: 3, ModTime: modTime},
+		{Key: "media/2.jpg", SHA256: "bbb", Size: 3, ModTime: modTime},
+	})
+	s.Require().NoError(err)
+
+	err = repo.Upsert(s.T().Context(), []models.ObjectChecksum{
+		{Key: "media/2.jpg", SHA256: "ccc", Size: 4, ModTime: modTime.Add(time.Hour)},
+	})
+	s.Require().NoError(err)
+
+	checksums, err := repo.AllByKey(s.T().Context())
+	s.Require().NoError(err)
+
+	td.Cmp(s.T(), checksums, td.Map(map[string]models.ObjectChecksum{}, td.MapEntries{
+		"media/1.jpg": td.SStruct(
+			models.ObjectChecksum{Key: "media/1.jpg", SHA256: "aaa", Size: 3, ModTime: modTime},
+			td.StructFields{"=*": td.Ignore()},
+		),
+		"media/2.jpg": td.SStruct(
+			models.ObjectChecksum{Key: "media/2.jpg", SHA256: "ccc", Size: 4, ModTime: modTime.Add(time.Hour)},
+			td.StructFields{"=*": td.Ignore()},
+		),
+	}))
+}
+
+func (s *ObjectChecksumsSuite) TestDeleteByKeys() {
+	repo := NewObjectChecksumRepository(s.DB)
+
+	modTime := time.Date(2021, time.November, 23, 19, 56, 0, 0, time.UTC)
+
+	err := repo.Upsert(s.T().Context(), []models.ObjectChecksum{
+		{Key: "media/1.jpg", SHA256: "aaa", Size: 3, ModTime: modTime},
+		{Key: "media/2.jpg", SHA256: "bbb", Size: 3, ModTime: modTime},
+	})
+	s.Require().NoError(err)
+
+	err = repo.DeleteByKeys(s.T().Context(), []string{"media/1.jpg"})
+	s.Require().NoError(err)
+
+	checksums, err := repo.AllByKey(s.T().Context())
+	s.Require().NoError(err)
+
+	s.Require().Len(checksums, 1)
+	s.Contains(checksums, "media/2.jpg")
+}
+
+func (s *ObjectChecksumsSuite) TestFindBySHA256() {
+	repo := NewObjectChecksumRepository(s.DB)
+
+	modTime := time.Date(2021, time.November, 23, 19, 56, 0, 0, time.UTC)
+
+	err := repo.Upsert(s.T().Context(), []models.ObjectChecksum{
+		{Key: "media/1.jpg", SHA256: "aaa", Size: 3, ModTime: modTime},
+		{Key: "media/2.jpg", SHA256: "bbb", Size: 3, ModTime: modTime},
+	})
+	s.Require().NoError(err)
+
+	checksums, err := repo.FindBySHA256(s.T().Context(), "bbb")
+	s.Require().NoError(err)
+
+	s.Require().Len(checksums, 1)
+	s.Equal("media/2.jpg", checksums[0].Key)
+}
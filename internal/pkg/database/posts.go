@@ -262,6 +262,15 @@ func (r *PostRepository) FindByMediaID(ctx context.Context, mediaID int) ([]mode
 	return r.FindByField(ctx, "media_id", mediaID)
 }
 
+// FindByMediaIDs finds the posts of any of the given medias.
+func (r *PostRepository) FindByMediaIDs(ctx context.Context, mediaIDs []int) ([]models.Post, error) {
+	if len(mediaIDs) == 0 {
+		return []models.Post{}, nil
+	}
+
+	return r.FindByField(ctx, "media_id", mediaIDs)
+}
+
 // FindNextPost finds the next or previous post relative to a given post.
 func (r *PostRepository) FindNextPost(post models.Post, previous bool) ([]models.Post, error) {
 	var dbPosts []dbPost
@@ -535,6 +544,37 @@ func (r *PostRepository) OnThisDay(ctx context.Context, month time.Month, day in
 	return results, nil
 }
 
+// WithMissingReferences finds posts whose media or location does not exist.
+// The foreign keys should prevent this, but it can happen after restoring
+// data with the constraints disabled.
+func (r *PostRepository) WithMissingReferences(ctx context.Context) ([]models.Post, error) {
+	var dbPosts []dbPost
+
+	goquDB := goqu.New("postgres", r.db)
+	query := goquDB.From(goqu.T(r.tableName).Schema(r.schema)).
+		Select(goqu.T(r.tableName).All()).
+		LeftJoin(goqu.T("medias").Schema("photos"), goqu.On(goqu.Ex{"medias.id": goqu.I("posts.media_id")})).
+		LeftJoin(goqu.T("locations").Schema("photos"), goqu.On(goqu.Ex{"locations.id": goqu.I("posts.location_id")})).
+		Where(goqu.Or(
+			goqu.I("medias.id").IsNull(),
+			goqu.I("locations.id").IsNull(),
+		)).
+		Order(goqu.I("posts.id").Asc()).
+		Executor()
+
+	err := query.ScanStructsContext(ctx, &dbPosts)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to select posts with missing references")
+	}
+
+	results := make([]models.Post, 0, len(dbPosts))
+	for i := range dbPosts {
+		results = append(results, newPost(dbPosts[i]))
+	}
+
+	return results, nil
+}
+
 // buildBaseQueryWithJoins creates the base query with all common joins.
 func (r *PostRepository) buildBaseQueryWithJoins(goquDB *goqu.Database) *goqu.SelectDataset {
 	return goquDB.From(goqu.T(r.tableName).Schema(r.schema)).
@@ -594,6 +634,12 @@ func FindPostsByMediaID(ctx context.Context, db *sql.DB, mediaID int) ([]models.
 	return repo.FindByMediaID(ctx, mediaID)
 }
 
+// FindPostsByMediaIDs finds the posts of any of the given medias.
+func FindPostsByMediaIDs(ctx context.Context, db *sql.DB, mediaIDs []int) ([]models.Post, error) {
+	repo := NewPostRepository(db)
+	return repo.FindByMediaIDs(ctx, mediaIDs)
+}
+
 // FindNextPost finds the next or previous post.
 func FindNextPost(db *sql.DB, post models.Post, previous bool) ([]models.Post, error) {
 	repo := NewPostRepository(db)
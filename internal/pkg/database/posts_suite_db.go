@@ -1345,3 +1345,37 @@ func (s *PostsSuite) TestFavouritePosts() {
 		s.Require().False(favourites[i].IsDraft, "All returned posts should not be drafts")
 	}
 }
+
+func (s *PostsSuite) TestWithMissingReferences() {
+	returnedDevices, err := CreateDevices(s.T().Context(), s.DB, []models.Device{{Name: "Example Device"}})
+	s.Require().NoError(err)
+
+	returnedMedias, err := CreateMedias(s.T().Context(), s.DB, []models.Media{
+		{
+			DeviceID:    returnedDevices[0].ID,
+			TakenAt:     time.Date(2021, time.November, 23, 19, 56, 0, 0, time.UTC),
+			Orientation: 1,
+		},
+	})
+	s.Require().NoError(err)
+
+	returnedLocations, err := CreateLocations(s.T().Context(), s.DB, []models.Location{
+		{Name: "London", Latitude: 1.1, Longitude: 1.2},
+	})
+	s.Require().NoError(err)
+
+	_, err = CreatePosts(s.T().Context(), s.DB, []models.Post{
+		{
+			Description: "Here is a photo I took",
+			PublishDate: time.Date(2021, time.November, 24, 19, 56, 0, 0, time.UTC),
+			MediaID:     returnedMedias[0].ID,
+			LocationID:  returnedLocations[0].ID,
+		},
+	})
+	s.Require().NoError(err)
+
+	posts, err := NewPostRepository(s.DB).WithMissingReferences(s.T().Context())
+	s.Require().NoError(err)
+
+	s.Empty(posts)
+}
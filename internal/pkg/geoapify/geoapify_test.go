@@ -3,6 +3,7 @@ package geoapify
 import (
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"os"
 	"testing"
 
@@ -35,3 +36,33 @@ func TestGeoapifyGeocoding(t *testing.T) {
 	require.Equal(t, "Highgate Hill, London, N6 5XG, United Kingdom", features[0].Properties.Formatted)
 	require.Equal(t, "street", features[0].Properties.ResultType)
 }
+
+func TestStaticMap(t *testing.T) {
+	t.Parallel()
+
+	var query url.Values
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query()
+		_, _ = w.Write([]byte("map"))
+	}))
+	defer ts.Close()
+
+	body, err := StaticMap(t.Context(), ts.URL, "api_key", 51.5, -0.1)
+	require.NoError(t, err)
+
+	require.Equal(t, "map", string(body))
+	require.Equal(t, "lonlat:-0.100000,51.500000", query.Get("center"))
+	require.Equal(t, "api_key", query.Get("apiKey"))
+}
+
+func TestStaticMapUpstreamError(t *testing.T) {
+	t.Parallel()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer ts.Close()
+
+	_, err := StaticMap(t.Context(), ts.URL, "api_key", 51.5, -0.1)
+	require.ErrorContains(t, err, "429")
+}
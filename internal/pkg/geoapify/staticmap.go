@@ -0,0 +1,63 @@
+package geoapify
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+// StaticMapURL is the URL of a map image of the area around a point, with the
+// point marked.
+func StaticMapURL(serverURL, apiKey string, latitude, longitude float64) (string, error) {
+	mapURL, err := url.Parse(serverURL)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse map server url: %w", err)
+	}
+	values := url.Values{
+		"style":       []string{"osm-bright-smooth"},
+		"center":      []string{fmt.Sprintf("lonlat:%f,%f", longitude, latitude)},
+		"zoom":        []string{"10.3497"},
+		"width":       []string{"400"},
+		"height":      []string{"400"},
+		"scaleFactor": []string{"2"},
+		"marker":      []string{fmt.Sprintf("lonlat:%f,%f;type:awesome;color:#e01401", longitude, latitude)},
+		"apiKey":      []string{apiKey},
+	}
+	mapURL.RawQuery = values.Encode()
+
+	return mapURL.String(), nil
+}
+
+// StaticMap downloads the map image of the area around a point from the
+// map server.
+func StaticMap(ctx context.Context, serverURL, apiKey string, latitude, longitude float64) ([]byte, error) {
+	mapURL, err := StaticMapURL(serverURL, apiKey, latitude, longitude)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, mapURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request to upstream map server: %w", err)
+	}
+
+	client := &http.Client{}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("upstream map server request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("upstream map server request failed: %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read map image: %w", err)
+	}
+
+	return body, nil
+}
@@ -0,0 +1,115 @@
+package geotag
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/charlieegan3/photos/internal/pkg/models"
+)
+
+// DefaultMaxGap is used when no max gap has been configured. Points further
+// than this from the time a photo was taken are not used to locate it.
+const DefaultMaxGap = 15 * time.Minute
+
+// PointFinder returns the points recorded between from and to in time order.
+type PointFinder func(ctx context.Context, from, to time.Time) ([]models.Point, error)
+
+// Geotagger finds the location of media from recorded activity points.
+type Geotagger struct {
+	findPoints PointFinder
+	maxGap     time.Duration
+}
+
+// NewGeotagger creates a Geotagger which uses finder to load points.
+func NewGeotagger(finder PointFinder, maxGap time.Duration) *Geotagger {
+	if maxGap <= 0 {
+		maxGap = DefaultMaxGap
+	}
+
+	return &Geotagger{
+		findPoints: finder,
+		maxGap:     maxGap,
+	}
+}
+
+// Locate returns the interpolated location at the time given, false is
+// returned when there are no points close enough to that time.
+func (g *Geotagger) Locate(ctx context.Context, at time.Time) (models.Point, bool, error) {
+	points, err := g.findPoints(ctx, at.Add(-g.maxGap), at.Add(g.maxGap))
+	if err != nil {
+		return models.Point{}, false, fmt.Errorf("failed to find points: %w", err)
+	}
+
+	point, ok := Interpolate(points, at, g.maxGap)
+
+	return point, ok, nil
+}
+
+// SlicePointFinder returns a PointFinder for points already held in memory,
+// for example those parsed from activity files rather than the database.
+func SlicePointFinder(points []models.Point) PointFinder {
+	sorted := make([]models.Point, len(points))
+	copy(sorted, points)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Timestamp.Before(sorted[j].Timestamp)
+	})
+
+	return func(_ context.Context, from, to time.Time) ([]models.Point, error) {
+		start := sort.Search(len(sorted), func(i int) bool {
+			return !sorted[i].Timestamp.Before(from)
+		})
+		end := sort.Search(len(sorted), func(i int) bool {
+			return sorted[i].Timestamp.After(to)
+		})
+
+		return sorted[start:end], nil
+	}
+}
+
+// Interpolate estimates the location at a given time from a list of points in
+// time order. The closest points before and after are used, and each must be
+// within maxGap of the time. When only one side has a point in range, that
+// point's location is used as is.
+func Interpolate(points []models.Point, at time.Time, maxGap time.Duration) (models.Point, bool) {
+	var before, after *models.Point
+
+	for i := range points {
+		if points[i].Timestamp.After(at) {
+			after = &points[i]
+			break
+		}
+		before = &points[i]
+	}
+
+	if before != nil && at.Sub(before.Timestamp) > maxGap {
+		before = nil
+	}
+	if after != nil && after.Timestamp.Sub(at) > maxGap {
+		after = nil
+	}
+
+	switch {
+	case before == nil && after == nil:
+		return models.Point{}, false
+	case after == nil:
+		return *before, true
+	case before == nil:
+		return *after, true
+	}
+
+	span := after.Timestamp.Sub(before.Timestamp)
+	if span == 0 {
+		return *before, true
+	}
+
+	fraction := float64(at.Sub(before.Timestamp)) / float64(span)
+
+	return models.Point{
+		Timestamp: at,
+		Latitude:  before.Latitude + (after.Latitude-before.Latitude)*fraction,
+		Longitude: before.Longitude + (after.Longitude-before.Longitude)*fraction,
+		Altitude:  before.Altitude + (after.Altitude-before.Altitude)*fraction,
+	}, true
+}
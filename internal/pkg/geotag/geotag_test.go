@@ -0,0 +1,111 @@
+package geotag
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/charlieegan3/photos/internal/pkg/models"
+)
+
+func TestInterpolate(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2022, 4, 24, 5, 0, 0, 0, time.UTC)
+
+	points := []models.Point{
+		{Timestamp: start, Latitude: 57.0, Longitude: -4.0, Altitude: 100},
+		{Timestamp: start.Add(10 * time.Second), Latitude: 58.0, Longitude: -5.0, Altitude: 200},
+		{Timestamp: start.Add(time.Hour), Latitude: 60.0, Longitude: -6.0, Altitude: 300},
+	}
+
+	testCases := map[string]struct {
+		At                time.Time
+		ExpectedOK        bool
+		ExpectedLatitude  float64
+		ExpectedLongitude float64
+		ExpectedAltitude  float64
+	}{
+		"between two points": {
+			At:                start.Add(5 * time.Second),
+			ExpectedOK:        true,
+			ExpectedLatitude:  57.5,
+			ExpectedLongitude: -4.5,
+			ExpectedAltitude:  150,
+		},
+		"exactly on a point": {
+			At:                start.Add(10 * time.Second),
+			ExpectedOK:        true,
+			ExpectedLatitude:  58.0,
+			ExpectedLongitude: -5.0,
+			ExpectedAltitude:  200,
+		},
+		"after the last point within gap": {
+			At:                start.Add(time.Hour + time.Minute),
+			ExpectedOK:        true,
+			ExpectedLatitude:  60.0,
+			ExpectedLongitude: -6.0,
+			ExpectedAltitude:  300,
+		},
+		"before the first point within gap": {
+			At:                start.Add(-time.Minute),
+			ExpectedOK:        true,
+			ExpectedLatitude:  57.0,
+			ExpectedLongitude: -4.0,
+			ExpectedAltitude:  100,
+		},
+		"in a long pause uses the closest side": {
+			At:                start.Add(2 * time.Minute),
+			ExpectedOK:        true,
+			ExpectedLatitude:  58.0,
+			ExpectedLongitude: -5.0,
+			ExpectedAltitude:  200,
+		},
+		"too far from any point": {
+			At:         start.Add(30 * time.Minute),
+			ExpectedOK: false,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			result, ok := Interpolate(points, tc.At, 5*time.Minute)
+			require.Equal(t, tc.ExpectedOK, ok)
+
+			if !tc.ExpectedOK {
+				return
+			}
+
+			require.InDelta(t, tc.ExpectedLatitude, result.Latitude, 0.000001)
+			require.InDelta(t, tc.ExpectedLongitude, result.Longitude, 0.000001)
+			require.InDelta(t, tc.ExpectedAltitude, result.Altitude, 0.000001)
+		})
+	}
+}
+
+func TestGeotaggerLocate(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2022, 4, 24, 5, 0, 0, 0, time.UTC)
+
+	// points are deliberately out of order to check they are sorted
+	finder := SlicePointFinder([]models.Point{
+		{Timestamp: start.Add(20 * time.Second), Latitude: 52.0, Longitude: 2.0},
+		{Timestamp: start, Latitude: 50.0, Longitude: 0.0},
+	})
+
+	geotagger := NewGeotagger(finder, time.Minute)
+
+	point, ok, err := geotagger.Locate(t.Context(), start.Add(10*time.Second))
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.InDelta(t, 51.0, point.Latitude, 0.000001)
+	require.InDelta(t, 1.0, point.Longitude, 0.000001)
+
+	_, ok, err = geotagger.Locate(t.Context(), start.Add(time.Hour))
+	require.NoError(t, err)
+	require.False(t, ok)
+}
@@ -0,0 +1,168 @@
+package imageproxy
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+
+	"github.com/disintegration/imaging"
+)
+
+// Edits are applied to an image before it is resized, they are stored on
+// media rather than made to the original file.
+type Edits struct {
+	// CropX, CropY, CropWidth and CropHeight are fractions of the size of the
+	// rotated and straightened image, nothing is cropped when CropWidth or
+	// CropHeight are zero.
+	CropX      float64
+	CropY      float64
+	CropWidth  float64
+	CropHeight float64
+	// Rotation is in degrees clockwise, a multiple of 90.
+	Rotation int
+	// Straighten is in degrees clockwise, between -45 and 45.
+	Straighten float64
+}
+
+// IsZero returns true if the edits leave the image unchanged.
+func (e Edits) IsZero() bool {
+	return e.rotation() == 0 && e.Straighten == 0 && !e.crops()
+}
+
+// Validate returns an error if the edits can't be applied.
+func (e Edits) Validate() error {
+	// NaN passes all the range checks below
+	for _, f := range []float64{e.CropX, e.CropY, e.CropWidth, e.CropHeight, e.Straighten} {
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return fmt.Errorf("edits must be finite numbers, got %g", f)
+		}
+	}
+
+	if e.Rotation%90 != 0 {
+		return fmt.Errorf("rotation must be a multiple of 90, got %d", e.Rotation)
+	}
+
+	if e.Straighten < -45 || e.Straighten > 45 {
+		return fmt.Errorf("straighten must be between -45 and 45, got %g", e.Straighten)
+	}
+
+	for _, f := range []float64{e.CropX, e.CropY, e.CropWidth, e.CropHeight} {
+		if f < 0 || f > 1 {
+			return fmt.Errorf("crop values must be between 0 and 1, got %g", f)
+		}
+	}
+
+	if e.CropX+e.CropWidth > 1 || e.CropY+e.CropHeight > 1 {
+		return errors.New("crop must be inside the image")
+	}
+
+	return nil
+}
+
+// CacheKey identifies the edits, it is included in thumbnail paths so that
+// changing the edits makes new thumbnails.
+func (e Edits) CacheKey() string {
+	sum := sha1.Sum(fmt.Appendf(nil, "%g %g %g %g %d %g",
+		e.CropX, e.CropY, e.CropWidth, e.CropHeight, e.rotation(), e.Straighten,
+	))
+
+	return hex.EncodeToString(sum[:4])
+}
+
+// Size returns the size of an image of the given, correctly oriented, size
+// once the edits are applied.
+func (e Edits) Size(width, height int) (int, int) {
+	if e.rotation()%180 != 0 {
+		width, height = height, width
+	}
+
+	width, height = straightenedSize(width, height, e.Straighten)
+
+	if e.crops() {
+		return max(int(math.Round(e.CropWidth*float64(width))), 1),
+			max(int(math.Round(e.CropHeight*float64(height))), 1)
+	}
+
+	return width, height
+}
+
+func (e Edits) rotation() int {
+	return ((e.Rotation % 360) + 360) % 360
+}
+
+func (e Edits) crops() bool {
+	return e.CropWidth > 0 && e.CropHeight > 0
+}
+
+// straightenedSize is the size of the largest rectangle with the same aspect
+// ratio which fits inside a width by height image turned by degrees. A pixel
+// is left on each side so that no blended edges are kept.
+func straightenedSize(width, height int, degrees float64) (int, int) {
+	a := math.Abs(degrees) * math.Pi / 180
+	if a == 0 || width == 0 || height == 0 {
+		return width, height
+	}
+
+	w, h := float64(width), float64(height)
+	sin, cos := math.Sin(a), math.Cos(a)
+	scale := min(w/(w*cos+h*sin), h/(w*sin+h*cos))
+
+	return max(int(w*scale)-2, 1), max(int(h*scale)-2, 1)
+}
+
+// apply decodes src, applies its EXIF orientation and then the edits. The
+// result is encoded as a PNG so that it is only compressed once, by the
+// resize.
+func (e Edits) apply(src []byte) ([]byte, error) {
+	img, err := imaging.Decode(bytes.NewReader(src), imaging.AutoOrientation(true))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image to edit: %w", err)
+	}
+
+	// imaging turns images counter-clockwise
+	switch e.rotation() {
+	case 90:
+		img = imaging.Rotate270(img)
+	case 180:
+		img = imaging.Rotate180(img)
+	case 270:
+		img = imaging.Rotate90(img)
+	}
+
+	if e.Straighten != 0 {
+		size := img.Bounds().Size()
+		width, height := straightenedSize(size.X, size.Y, e.Straighten)
+
+		img = imaging.CropCenter(imaging.Rotate(img, -e.Straighten, color.Transparent), width, height)
+	}
+
+	if e.crops() {
+		size := img.Bounds().Size()
+		origin := image.Point{
+			X: int(math.Round(e.CropX * float64(size.X))),
+			Y: int(math.Round(e.CropY * float64(size.Y))),
+		}
+		img = imaging.Crop(img, image.Rectangle{
+			Min: origin,
+			Max: origin.Add(image.Point{
+				X: max(int(math.Round(e.CropWidth*float64(size.X))), 1),
+				Y: max(int(math.Round(e.CropHeight*float64(size.Y))), 1),
+			}),
+		})
+	}
+
+	var buf bytes.Buffer
+	encoder := png.Encoder{CompressionLevel: png.BestSpeed}
+	err = encoder.Encode(&buf, img)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode edited image: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
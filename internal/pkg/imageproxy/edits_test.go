@@ -0,0 +1,126 @@
+package imageproxy
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"willnorris.com/go/imageproxy"
+)
+
+func TestEditsValidate(t *testing.T) {
+	t.Parallel()
+
+	require.NoError(t, Edits{}.Validate())
+	require.NoError(t, Edits{Rotation: 270, Straighten: -2.5, CropX: 0.1, CropWidth: 0.9, CropHeight: 0.5}.Validate())
+
+	testCases := map[string]Edits{
+		"rotation":        {Rotation: 45},
+		"straighten":      {Straighten: 46},
+		"negative crop":   {CropX: -0.1},
+		"crop outside":    {CropX: 0.5, CropWidth: 0.6, CropHeight: 1},
+		"crop over whole": {CropHeight: 1.5},
+		"nan crop":        {CropWidth: math.NaN(), CropHeight: 1},
+		"nan straighten":  {Straighten: math.NaN()},
+		"infinite crop":   {CropX: math.Inf(-1)},
+	}
+
+	for description, edits := range testCases {
+		t.Run(description, func(t *testing.T) {
+			t.Parallel()
+
+			require.Error(t, edits.Validate())
+		})
+	}
+}
+
+func TestEditsCacheKey(t *testing.T) {
+	t.Parallel()
+
+	require.True(t, Edits{}.IsZero())
+	require.True(t, Edits{Rotation: 360, CropWidth: 0.5}.IsZero())
+	require.False(t, Edits{Rotation: 90}.IsZero())
+
+	require.Equal(t, Edits{Rotation: 90}.CacheKey(), Edits{Rotation: -270}.CacheKey())
+	require.NotEqual(t, Edits{Rotation: 90}.CacheKey(), Edits{Rotation: 180}.CacheKey())
+	require.NotEqual(t, Edits{Straighten: 1}.CacheKey(), Edits{Straighten: 1.5}.CacheKey())
+}
+
+func TestEditsSize(t *testing.T) {
+	t.Parallel()
+
+	width, height := Edits{}.Size(400, 200)
+	require.Equal(t, []int{400, 200}, []int{width, height})
+
+	width, height = Edits{Rotation: 90}.Size(400, 200)
+	require.Equal(t, []int{200, 400}, []int{width, height})
+
+	width, height = Edits{CropWidth: 0.5, CropHeight: 0.25}.Size(400, 200)
+	require.Equal(t, []int{200, 50}, []int{width, height})
+
+	// straightened images keep their aspect ratio but lose the corners
+	width, height = Edits{Straighten: 10}.Size(400, 200)
+	require.Less(t, width, 400)
+	require.InDelta(t, 2.0, float64(width)/float64(height), 0.02)
+}
+
+func TestPoolTransformEdits(t *testing.T) {
+	t.Parallel()
+
+	// a wide image with a red left half and a blue right half
+	src := image.NewRGBA(image.Rect(0, 0, 400, 200))
+	for y := range 200 {
+		for x := range 400 {
+			c := color.RGBA{R: 255, A: 255}
+			if x >= 200 {
+				c = color.RGBA{B: 255, A: 255}
+			}
+			src.Set(x, y, c)
+		}
+	}
+
+	var buf bytes.Buffer
+	err := png.Encode(&buf, src)
+	require.NoError(t, err)
+
+	pool := NewPool(1, DefaultPixelBudget)
+
+	decode := func(b []byte) image.Image {
+		img, _, err := image.Decode(bytes.NewReader(b))
+		require.NoError(t, err)
+		return img
+	}
+
+	// turned clockwise, the red half is at the top
+	result, err := pool.transform(t.Context(), buf.Bytes(), Edits{Rotation: 90}, imageproxy.ParseOptions("png"))
+	require.NoError(t, err)
+	img := decode(result)
+	require.Equal(t, image.Rect(0, 0, 200, 400), img.Bounds())
+	r, _, b, _ := img.At(100, 50).RGBA()
+	require.Greater(t, r, b)
+
+	// cropped to the right half
+	result, err = pool.transform(
+		t.Context(), buf.Bytes(), Edits{CropX: 0.5, CropWidth: 0.5, CropHeight: 1}, imageproxy.ParseOptions("png"),
+	)
+	require.NoError(t, err)
+	img = decode(result)
+	require.Equal(t, image.Rect(0, 0, 200, 200), img.Bounds())
+	r, _, b, _ = img.At(10, 10).RGBA()
+	require.Greater(t, b, r)
+
+	// straightened with no transparent corners
+	result, err = pool.transform(t.Context(), buf.Bytes(), Edits{Straighten: 5}, imageproxy.ParseOptions("png"))
+	require.NoError(t, err)
+	img = decode(result)
+	width, height := Edits{Straighten: 5}.Size(400, 200)
+	require.Equal(t, image.Rect(0, 0, width, height), img.Bounds())
+	for _, p := range []image.Point{{0, 0}, {width - 1, 0}, {0, height - 1}, {width - 1, height - 1}} {
+		_, _, _, a := img.At(p.X, p.Y).RGBA()
+		require.Equal(t, uint32(0xffff), a, "corner %v should be opaque", p)
+	}
+}
@@ -0,0 +1,238 @@
+package imageproxy
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/sync/semaphore"
+	"willnorris.com/go/imageproxy"
+)
+
+// DefaultPixelBudget is the number of decoded source pixels which renders can
+// hold in memory at once. Decoded images use around 4 bytes a pixel, so this
+// is roughly 600MB, enough for a 50MP original and many smaller ones.
+const DefaultPixelBudget = 150_000_000
+
+// DefaultPool is used by Resizers without a Pool so that all renders in the
+// process share one memory budget.
+var DefaultPool = NewPool(runtime.NumCPU(), DefaultPixelBudget)
+
+// Pool runs renders on a fixed number of workers. Each render also holds a
+// share of the pixel budget for the size of its decoded source image. Original
+// images are large and quickly consume all available RAM, leading to OOMKills,
+// if too many are decoded at once.
+//
+// Renders of sources larger than a quarter of the budget are queued in their
+// own lane with half of the budget. Waiting renders are served in order, so
+// without this a large original waiting for memory would hold up every small
+// render behind it.
+type Pool struct {
+	workers     chan struct{}
+	pixelBudget int64
+
+	pixels         *semaphore.Weighted
+	largePixels    *semaphore.Weighted
+	largeBudget    int64
+	largeThreshold int64
+
+	mu    sync.Mutex
+	calls map[string]*call
+
+	queued        atomic.Int64
+	running       atomic.Int64
+	pixelsInUse   atomic.Int64
+	renders       atomic.Int64
+	failed        atomic.Int64
+	renderTime    atomic.Int64
+	maxRenderTime atomic.Int64
+}
+
+// Stats describes the work done by a Pool, it is used to tune the number of
+// workers and the pixel budget.
+type Stats struct {
+	Workers     int
+	PixelBudget int64
+
+	// Queued is the number of renders waiting for a worker or pixels.
+	Queued      int64
+	Running     int64
+	PixelsInUse int64
+
+	Renders           int64
+	Failed            int64
+	AverageRenderTime time.Duration
+	MaxRenderTime     time.Duration
+}
+
+// call is a render of a thumbnail key which may be shared by many requests.
+// The render is cancelled when all the requests waiting for it are.
+type call struct {
+	done    chan struct{}
+	result  []byte
+	err     error
+	waiters int
+	cancel  context.CancelFunc
+}
+
+// NewPool creates a Pool with the given number of workers and pixel budget.
+func NewPool(workers int, pixelBudget int64) *Pool {
+	if workers < 1 {
+		workers = 1
+	}
+	if pixelBudget < 1 {
+		pixelBudget = DefaultPixelBudget
+	}
+
+	largeBudget := max(pixelBudget/2, 1)
+
+	return &Pool{
+		workers:        make(chan struct{}, workers),
+		pixelBudget:    pixelBudget,
+		pixels:         semaphore.NewWeighted(max(pixelBudget-largeBudget, 1)),
+		largePixels:    semaphore.NewWeighted(largeBudget),
+		largeBudget:    largeBudget,
+		largeThreshold: pixelBudget / 4,
+		calls:          make(map[string]*call),
+	}
+}
+
+// Stats returns the current queue and the totals of past renders.
+func (p *Pool) Stats() Stats {
+	stats := Stats{
+		Workers:       cap(p.workers),
+		PixelBudget:   p.pixelBudget,
+		Queued:        p.queued.Load(),
+		Running:       p.running.Load(),
+		PixelsInUse:   p.pixelsInUse.Load(),
+		Renders:       p.renders.Load(),
+		Failed:        p.failed.Load(),
+		MaxRenderTime: time.Duration(p.maxRenderTime.Load()),
+	}
+
+	if stats.Renders > 0 {
+		stats.AverageRenderTime = time.Duration(p.renderTime.Load() / stats.Renders)
+	}
+
+	return stats
+}
+
+// do runs fn once for each key at a time. Callers with the same key while fn
+// is running wait for and share its result. ctx only cancels the wait of this
+// caller, fn is cancelled once every caller has stopped waiting.
+func (p *Pool) do(ctx context.Context, key string, fn func(context.Context) ([]byte, error)) ([]byte, error) {
+	p.mu.Lock()
+	c, ok := p.calls[key]
+	if ok {
+		c.waiters++
+	} else {
+		renderCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		c = &call{done: make(chan struct{}), waiters: 1, cancel: cancel}
+		p.calls[key] = c
+
+		go func() {
+			defer cancel()
+
+			c.result, c.err = fn(renderCtx)
+
+			p.mu.Lock()
+			if p.calls[key] == c {
+				delete(p.calls, key)
+			}
+			p.mu.Unlock()
+
+			close(c.done)
+		}()
+	}
+	p.mu.Unlock()
+
+	select {
+	case <-c.done:
+		return c.result, c.err
+	case <-ctx.Done():
+		p.mu.Lock()
+		c.waiters--
+		if c.waiters == 0 {
+			c.cancel()
+			// later callers start a new render rather than joining this one
+			if p.calls[key] == c {
+				delete(p.calls, key)
+			}
+		}
+		p.mu.Unlock()
+
+		return nil, fmt.Errorf("gave up waiting for render: %w", ctx.Err())
+	}
+}
+
+// transform applies edits to src and resizes it with options once a worker
+// and enough of the pixel budget are free.
+func (p *Pool) transform(ctx context.Context, src []byte, edits Edits, options imageproxy.Options) ([]byte, error) {
+	pixels := int64(1)
+	config, _, err := image.DecodeConfig(bytes.NewReader(src))
+	if err == nil {
+		pixels = max(int64(config.Width)*int64(config.Height), 1)
+	}
+
+	lane := p.pixels
+	if pixels > p.largeThreshold {
+		lane = p.largePixels
+		// images larger than the lane are rendered on their own
+		pixels = min(pixels, p.largeBudget)
+	}
+
+	p.queued.Add(1)
+	err = lane.Acquire(ctx, pixels)
+	if err != nil {
+		p.queued.Add(-1)
+		return nil, fmt.Errorf("failed waiting for pixel budget: %w", err)
+	}
+	defer lane.Release(pixels)
+
+	select {
+	case p.workers <- struct{}{}:
+	case <-ctx.Done():
+		p.queued.Add(-1)
+		return nil, fmt.Errorf("failed waiting for worker: %w", ctx.Err())
+	}
+	defer func() { <-p.workers }()
+
+	p.queued.Add(-1)
+	p.running.Add(1)
+	p.pixelsInUse.Add(pixels)
+	defer func() {
+		p.running.Add(-1)
+		p.pixelsInUse.Add(-pixels)
+	}()
+
+	start := time.Now()
+	var result []byte
+	if !edits.IsZero() {
+		src, err = edits.apply(src)
+	}
+	if err == nil {
+		result, err = imageproxy.Transform(src, options)
+	}
+	elapsed := time.Since(start)
+
+	p.renders.Add(1)
+	p.renderTime.Add(int64(elapsed))
+	for {
+		current := p.maxRenderTime.Load()
+		if int64(elapsed) <= current || p.maxRenderTime.CompareAndSwap(current, int64(elapsed)) {
+			break
+		}
+	}
+
+	if err != nil {
+		p.failed.Add(1)
+		return nil, fmt.Errorf("failed to transform image: %w", err)
+	}
+
+	return result, nil
+}
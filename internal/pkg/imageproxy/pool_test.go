@@ -0,0 +1,169 @@
+package imageproxy
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/png"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"willnorris.com/go/imageproxy"
+)
+
+func TestPoolDoSharesRenders(t *testing.T) {
+	t.Parallel()
+
+	pool := NewPool(1, 100)
+
+	var calls atomic.Int64
+	release := make(chan struct{})
+	fn := func(context.Context) ([]byte, error) {
+		calls.Add(1)
+		<-release
+		return []byte("thumb"), nil
+	}
+
+	var wg sync.WaitGroup
+	results := make([][]byte, 3)
+	errs := make([]error, 3)
+	for i := range results {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			results[i], errs[i] = pool.do(t.Context(), "key", fn)
+		}()
+	}
+
+	// wait for all the callers to be waiting for the first render
+	require.Eventually(t, func() bool {
+		pool.mu.Lock()
+		defer pool.mu.Unlock()
+		c, ok := pool.calls["key"]
+		return ok && c.waiters == 3
+	}, time.Second, time.Millisecond)
+
+	close(release)
+	wg.Wait()
+
+	require.Equal(t, int64(1), calls.Load())
+	for i, result := range results {
+		require.NoError(t, errs[i])
+		require.Equal(t, []byte("thumb"), result)
+	}
+
+	// later calls render again
+	_, err := pool.do(t.Context(), "key", fn)
+	require.NoError(t, err)
+	require.Equal(t, int64(2), calls.Load())
+}
+
+func TestPoolDoCancelsAbandonedRenders(t *testing.T) {
+	t.Parallel()
+
+	pool := NewPool(1, 100)
+
+	renderCancelled := make(chan struct{})
+	fn := func(ctx context.Context) ([]byte, error) {
+		<-ctx.Done()
+		close(renderCancelled)
+		return nil, ctx.Err()
+	}
+
+	ctx, cancel := context.WithCancel(t.Context())
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+
+	_, err := pool.do(ctx, "key", fn)
+	require.ErrorIs(t, err, context.Canceled)
+
+	select {
+	case <-renderCancelled:
+	case <-time.After(time.Second):
+		t.Fatal("render was not cancelled")
+	}
+}
+
+func TestPoolTransform(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 300, 200)))
+	require.NoError(t, err)
+
+	pool := NewPool(1, 100)
+
+	result, err := pool.transform(t.Context(), buf.Bytes(), Edits{}, imageproxy.ParseOptions("100,fit"))
+	require.NoError(t, err)
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(result))
+	require.NoError(t, err)
+	require.Equal(t, 100, config.Width)
+
+	stats := pool.Stats()
+	require.Equal(t, int64(1), stats.Renders)
+	require.Equal(t, int64(0), stats.Queued)
+	require.Equal(t, int64(0), stats.PixelsInUse)
+	require.Positive(t, stats.MaxRenderTime)
+
+	// renders wait for a free worker and give up when cancelled
+	pool.workers <- struct{}{}
+
+	ctx, cancel := context.WithTimeout(t.Context(), 10*time.Millisecond)
+	defer cancel()
+
+	_, err = pool.transform(ctx, buf.Bytes(), Edits{}, imageproxy.ParseOptions("100,fit"))
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.Equal(t, int64(0), pool.Stats().Queued)
+}
+
+func TestPoolTransformLargeRenderLane(t *testing.T) {
+	t.Parallel()
+
+	encode := func(width, height int) []byte {
+		var buf bytes.Buffer
+		err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, width, height)))
+		require.NoError(t, err)
+
+		return buf.Bytes()
+	}
+
+	// sources over 25 pixels are large, each lane has 50
+	pool := NewPool(1, 100)
+
+	// a large render holds its lane and a small one some of the budget
+	require.NoError(t, pool.largePixels.Acquire(t.Context(), 50))
+	require.NoError(t, pool.pixels.Acquire(t.Context(), 25))
+
+	largeDone := make(chan error)
+	go func() {
+		_, err := pool.transform(t.Context(), encode(20, 20), Edits{}, imageproxy.ParseOptions("10,fit"))
+		largeDone <- err
+	}()
+
+	require.Eventually(t, func() bool {
+		return pool.Stats().Queued == 1
+	}, time.Second, time.Millisecond)
+
+	// small renders carry on while the large one waits
+	ctx, cancel := context.WithTimeout(t.Context(), time.Second)
+	defer cancel()
+
+	_, err := pool.transform(ctx, encode(4, 4), Edits{}, imageproxy.ParseOptions("2,fit"))
+	require.NoError(t, err)
+
+	select {
+	case <-largeDone:
+		t.Fatal("large render finished before its lane was free")
+	default:
+	}
+
+	pool.largePixels.Release(50)
+	require.NoError(t, <-largeDone)
+}
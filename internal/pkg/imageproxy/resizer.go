@@ -1,129 +1,178 @@
 package imageproxy
 
 import (
-	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	_ "image/gif"
 	_ "image/jpeg"
 	_ "image/png"
 	"io"
-	"sync"
+	"log"
 
 	"gocloud.dev/blob"
 	"willnorris.com/go/imageproxy"
+
+	_ "github.com/charlieegan3/photos/internal/pkg/mediakind" // register heic and webp decoding
+	"github.com/charlieegan3/photos/internal/pkg/mediametadata"
 )
 
+// Resizer creates thumbnails of images and saves them in a bucket. Renders run
+// on Pool, or DefaultPool when it is not set, so zero value Resizers share
+// the memory budget of the whole process. Jpeg renders are marked with
+// Watermark, or DefaultWatermark when it is not set.
 type Resizer struct {
-	mu sync.Mutex
+	Pool      *Pool
+	Watermark *Watermark
 }
 
-// ResizeInBucket resizes an image in a bucket and saves it to a new path. One at a time to reduce load on the server.
-// When a batch of images are uploaded, there are many that need to be resized. This function is a throttler to ensure
-// that only one image is resized at a time and the RAM usage is contained. Original images are quite large and quickly
-// consume all available RAM leading to OOMKills.
+func (ir *Resizer) pool() *Pool {
+	if ir.Pool != nil {
+		return ir.Pool
+	}
+
+	return DefaultPool
+}
+
+func (ir *Resizer) watermark() *Watermark {
+	if ir.Watermark != nil {
+		return ir.Watermark
+	}
+
+	return DefaultWatermark
+}
+
+// ResizeInBucket resizes an image in a bucket and saves it to a new path.
+// Concurrent requests for the same thumbnail share a single render.
 func (ir *Resizer) ResizeInBucket(
 	ctx context.Context,
 	bucket *blob.Bucket,
 	originalMediaPath string,
+	edits Edits,
 	imageResizeString string,
 	thumbMediaPath string,
 ) error {
-	ir.mu.Lock()
-	defer ir.mu.Unlock()
-
-	// create a reader to get the full size media from the bucket
-	br, err := bucket.NewReader(ctx, originalMediaPath, nil)
-	if err != nil {
-		return fmt.Errorf("failed to create reader for original media: %w", err)
-	}
-	defer br.Close()
-
-	// read the full size item
-	buf := bytes.NewBuffer([]byte{})
-	_, err = io.Copy(buf, br)
-	if err != nil {
-		return fmt.Errorf("failed to copy original media into buffer: %w", err)
-	}
-
-	err = br.Close()
-	if err != nil {
-		return fmt.Errorf("failed to close bucket reader: %w", err)
-	}
-
-	// resize the image based on the current settings
-	imageOptions := imageproxy.ParseOptions(imageResizeString)
-	imageOptions.ScaleUp = false // don't attempt to make images larger if not possible
-
-	imageBytes, err := imageproxy.Transform(buf.Bytes(), imageOptions)
-	if err != nil {
-		// If transformation fails, use the original image data
-		imageBytes = buf.Bytes()
-	}
-
-	// create a writer for the new thumb
-	bw, err := bucket.NewWriter(ctx, thumbMediaPath, nil)
-	if err != nil {
-		return fmt.Errorf("failed to create writer for thumb media: %w", err)
-	}
-	defer bw.Close()
+	_, err := ir.pool().do(ctx, renderKey(bucket, thumbMediaPath), func(ctx context.Context) ([]byte, error) {
+		// read the full size item from the bucket
+		original, err := bucket.ReadAll(ctx, originalMediaPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read original media: %w", err)
+		}
 
-	_, err = io.Copy(bw, bytes.NewReader(imageBytes))
-	if err != nil {
-		return fmt.Errorf("failed to copy thumb media into bucket: %w", err)
-	}
-	err = bw.Close()
-	if err != nil {
-		return fmt.Errorf("failed to close bucket writer: %w", err)
-	}
+		return ir.render(ctx, bucket, original, edits, imageResizeString, thumbMediaPath)
+	})
 
-	return nil
+	return err
 }
 
+// CreateThumbInBucket resizes the image read from reader, saves it in the
+// bucket and returns the bytes of the thumbnail. Concurrent requests for the
+// same thumbnail share a single render.
 func (ir *Resizer) CreateThumbInBucket(
 	ctx context.Context,
 	reader io.Reader,
 	bucket *blob.Bucket,
+	edits Edits,
 	imageResizeString string,
 	thumbMediaPath string,
 ) ([]byte, error) {
-	var err error
-	ir.mu.Lock()
-	defer ir.mu.Unlock()
+	return ir.pool().do(ctx, renderKey(bucket, thumbMediaPath), func(ctx context.Context) ([]byte, error) {
+		// read the full size item
+		original, err := io.ReadAll(reader)
+		if err != nil {
+			return nil, fmt.Errorf("failed to copy original media into buffer: %w", err)
+		}
+
+		return ir.render(ctx, bucket, original, edits, imageResizeString, thumbMediaPath)
+	})
+}
 
-	// read the full size item
-	buf := bytes.NewBuffer([]byte{})
-	_, err = io.Copy(buf, reader)
+func (ir *Resizer) render(
+	ctx context.Context,
+	bucket *blob.Bucket,
+	original []byte,
+	edits Edits,
+	imageResizeString string,
+	thumbMediaPath string,
+) ([]byte, error) {
+	original, err := renderSource(original)
 	if err != nil {
-		return nil, fmt.Errorf("failed to copy original media into buffer: %w", err)
+		return nil, err
 	}
 
 	// resize the image based on the current settings
 	imageOptions := imageproxy.ParseOptions(imageResizeString)
 	imageOptions.ScaleUp = false // don't attempt to make images larger if not possible
 
-	imageBytes, err := imageproxy.Transform(buf.Bytes(), imageOptions)
+	// marked renders are made as PNGs and encoded once they are marked
+	watermark := ir.watermark()
+	if imageOptions.Format != "jpeg" {
+		watermark = nil
+	}
+	if watermark != nil {
+		imageOptions.Format = "png"
+	}
+
+	imageBytes, err := ir.pool().transform(ctx, original, edits, imageOptions)
+	if err == nil && watermark != nil {
+		imageBytes, err = watermark.apply(ctx, bucket, imageBytes, imageOptions.Quality)
+		if err != nil {
+			return nil, fmt.Errorf("failed to watermark %s: %w", thumbMediaPath, err)
+		}
+	}
 	if err != nil {
-		// If transformation fails, use the original image data
-		imageBytes = buf.Bytes()
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return nil, err
+		}
+
+		// If transformation fails, use the original image data without its
+		// location, thumbs are served publicly
+		log.Printf("failed to resize %s, using original: %s", thumbMediaPath, err)
+		imageBytes, err = mediametadata.StripGPS(original)
+		if err != nil {
+			return nil, fmt.Errorf("failed to strip location from original: %w", err)
+		}
 	}
 
-	// create a writer for the new thumb
-	bw, err := bucket.NewWriter(ctx, thumbMediaPath, nil)
+	// the render may have been cancelled while it was running, the writer
+	// discards the thumb in that case
+	err = bucket.WriteAll(ctx, thumbMediaPath, imageBytes, nil)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create writer for thumb media: %w", err)
+		return nil, fmt.Errorf("failed to write thumb media to bucket: %w", err)
 	}
-	defer bw.Close()
 
-	_, err = io.Copy(bw, bytes.NewReader(imageBytes))
+	return imageBytes, nil
+}
+
+// Preview renders an image with edits without saving it, it is used to show
+// edits before they are saved.
+func (ir *Resizer) Preview(ctx context.Context, original []byte, edits Edits, imageResizeString string) ([]byte, error) {
+	original, err := renderSource(original)
 	if err != nil {
-		return nil, fmt.Errorf("failed to copy thumb media into bucket: %w", err)
+		return nil, err
 	}
 
-	err = bw.Close()
+	return ir.pool().transform(ctx, original, edits, imageproxy.ParseOptions(imageResizeString))
+}
+
+// renderSource returns the image to render from an original, RAW files are
+// rendered from their largest embedded JPEG preview.
+func renderSource(original []byte) ([]byte, error) {
+	if !mediametadata.IsRAWFile(original) {
+		return original, nil
+	}
+
+	preview, err := mediametadata.RAWPreview(original)
 	if err != nil {
-		return nil, fmt.Errorf("failed to close bucket writer: %w", err)
+		return nil, fmt.Errorf("failed to get raw preview: %w", err)
 	}
 
-	return imageBytes, nil
+	return preview, nil
+}
+
+// renderKey identifies a thumbnail so that concurrent renders of it can be
+// shared, the bucket is included since tests use many buckets with the same
+// keys.
+func renderKey(bucket *blob.Bucket, thumbMediaPath string) string {
+	return fmt.Sprintf("%p/%s", bucket, thumbMediaPath)
 }
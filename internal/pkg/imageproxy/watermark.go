@@ -0,0 +1,264 @@
+package imageproxy
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"sync"
+
+	"gocloud.dev/blob"
+	xdraw "golang.org/x/image/draw"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+// Corners of a rendition where the watermark can be drawn.
+const (
+	CornerTopLeft     = "top-left"
+	CornerTopRight    = "top-right"
+	CornerBottomLeft  = "bottom-left"
+	CornerBottomRight = "bottom-right"
+)
+
+// defaultQuality matches the jpeg quality used by imageproxy when none is set.
+const defaultQuality = 95
+
+// DefaultWatermarkMinWidth is used when no minimum size is set, smaller
+// renditions are shown in grids where the mark can't be read. It is the width
+// of the large default profile.
+const DefaultWatermarkMinWidth = 1000
+
+// DefaultWatermark is used by Resizers without a Watermark, it is set from
+// config on start up. Renditions are not marked when it is nil.
+var DefaultWatermark *Watermark
+
+// Watermark is a copyright mark drawn in a corner of renditions, either text
+// or a PNG from the bucket. Originals are never marked.
+type Watermark struct {
+	Text string `mapstructure:"text"`
+	// Image is the bucket key of a PNG used as the mark rather than Text.
+	Image  string `mapstructure:"image"`
+	Corner string `mapstructure:"corner"`
+	// Opacity of the mark, from 0 to 1.
+	Opacity float64 `mapstructure:"opacity"`
+	// Size is the height of the mark as a fraction of the rendition's
+	// shorter side.
+	Size float64 `mapstructure:"size"`
+	// Renditions smaller than these are not marked, MinWidth is
+	// DefaultWatermarkMinWidth when neither is set.
+	MinWidth  int `mapstructure:"minWidth"`
+	MinHeight int `mapstructure:"minHeight"`
+	// Version is part of the CacheKey, change it to remake thumbnails after
+	// replacing the Image in the bucket.
+	Version string `mapstructure:"version"`
+
+	// images are the decoded Image of each bucket
+	images *sync.Map
+}
+
+// ParseWatermark validates a configured watermark and sets its defaults.
+func ParseWatermark(w Watermark) (*Watermark, error) {
+	if w.Text == "" && w.Image == "" {
+		return nil, errors.New("watermark must have text or an image")
+	}
+
+	if w.Corner == "" {
+		w.Corner = CornerBottomRight
+	}
+	switch w.Corner {
+	case CornerTopLeft, CornerTopRight, CornerBottomLeft, CornerBottomRight:
+	default:
+		return nil, fmt.Errorf("unknown watermark corner %q", w.Corner)
+	}
+
+	if w.Opacity == 0 {
+		w.Opacity = 0.5
+	}
+	if w.Opacity < 0 || w.Opacity > 1 {
+		return nil, errors.New("watermark opacity must be between 0 and 1")
+	}
+
+	if w.Size == 0 {
+		w.Size = 0.03
+	}
+	if w.Size < 0 || w.Size > 1 {
+		return nil, errors.New("watermark size must be between 0 and 1")
+	}
+
+	if w.MinWidth < 0 || w.MinHeight < 0 {
+		return nil, errors.New("watermark minimum sizes must not be negative")
+	}
+	if w.MinWidth == 0 && w.MinHeight == 0 {
+		w.MinWidth = DefaultWatermarkMinWidth
+	}
+
+	w.images = &sync.Map{}
+
+	return &w, nil
+}
+
+// CacheKey identifies the appearance of the watermark, it is included in
+// thumbnail paths so that changing the watermark makes new thumbnails.
+func (w *Watermark) CacheKey() string {
+	sum := sha1.Sum(fmt.Appendf(nil, "%q %q %s %g %g %d %d %q",
+		w.Text, w.Image, w.Corner, w.Opacity, w.Size, w.MinWidth, w.MinHeight, w.Version,
+	))
+
+	return hex.EncodeToString(sum[:4])
+}
+
+// apply draws the mark on a PNG rendition and returns it encoded as a jpeg.
+// Renditions are made as PNGs when they are to be marked so that they are
+// only compressed once.
+func (w *Watermark) apply(ctx context.Context, bucket *blob.Bucket, rendition []byte, quality int) ([]byte, error) {
+	img, err := png.Decode(bytes.NewReader(rendition))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode rendition: %w", err)
+	}
+
+	bounds := img.Bounds()
+	if bounds.Dx() >= w.MinWidth && bounds.Dy() >= w.MinHeight {
+		height := max(int(float64(min(bounds.Dx(), bounds.Dy()))*w.Size), 1)
+
+		mark, err := w.mark(ctx, bucket, height)
+		if err != nil {
+			return nil, err
+		}
+
+		marked := image.NewRGBA(bounds)
+		xdraw.Draw(marked, bounds, img, bounds.Min, xdraw.Src)
+
+		// the mark is inset by half its height
+		margin := height / 2
+		size := mark.Bounds().Size()
+		at := image.Point{X: bounds.Min.X + margin, Y: bounds.Min.Y + margin}
+		if w.Corner == CornerTopRight || w.Corner == CornerBottomRight {
+			at.X = bounds.Max.X - margin - size.X
+		}
+		if w.Corner == CornerBottomLeft || w.Corner == CornerBottomRight {
+			at.Y = bounds.Max.Y - margin - size.Y
+		}
+
+		xdraw.DrawMask(
+			marked,
+			image.Rectangle{Min: at, Max: at.Add(size)},
+			mark,
+			mark.Bounds().Min,
+			image.NewUniform(color.Alpha{A: uint8(w.Opacity * 255)}),
+			image.Point{},
+			xdraw.Over,
+		)
+
+		img = marked
+	}
+
+	if quality == 0 {
+		quality = defaultQuality
+	}
+
+	var buf bytes.Buffer
+	err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode marked rendition: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// mark returns the Image scaled to height, or the Text drawn at that height.
+func (w *Watermark) mark(ctx context.Context, bucket *blob.Bucket, height int) (image.Image, error) {
+	if w.Image == "" {
+		return textMark(w.Text, height)
+	}
+
+	src, err := w.image(ctx, bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	size := src.Bounds().Size()
+	width := max(size.X*height/max(size.Y, 1), 1)
+
+	scaled := image.NewRGBA(image.Rect(0, 0, width, height))
+	xdraw.CatmullRom.Scale(scaled, scaled.Bounds(), src, src.Bounds(), xdraw.Src, nil)
+
+	return scaled, nil
+}
+
+// image returns the decoded Image from the bucket, it is only read once for
+// each bucket.
+func (w *Watermark) image(ctx context.Context, bucket *blob.Bucket) (image.Image, error) {
+	if img, ok := w.images.Load(bucket); ok {
+		return img.(image.Image), nil
+	}
+
+	data, err := bucket.ReadAll(ctx, w.Image)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read watermark image: %w", err)
+	}
+
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode watermark image: %w", err)
+	}
+
+	w.images.Store(bucket, img)
+
+	return img, nil
+}
+
+var (
+	markFontOnce sync.Once
+	markFont     *opentype.Font
+	errMarkFont  error
+)
+
+// textMark draws white text with a dark shadow so that it can be read on
+// light and dark images.
+func textMark(text string, height int) (image.Image, error) {
+	markFontOnce.Do(func() {
+		markFont, errMarkFont = opentype.Parse(goregular.TTF)
+	})
+	if errMarkFont != nil {
+		return nil, fmt.Errorf("failed to parse watermark font: %w", errMarkFont)
+	}
+
+	face, err := opentype.NewFace(markFont, &opentype.FaceOptions{
+		Size:    float64(height),
+		DPI:     72,
+		Hinting: font.HintingFull,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create watermark font face: %w", err)
+	}
+	defer face.Close()
+
+	shadow := max(height/16, 1)
+	metrics := face.Metrics()
+	width := font.MeasureString(face, text).Ceil() + shadow
+	mark := image.NewRGBA(image.Rect(0, 0, width, (metrics.Ascent+metrics.Descent).Ceil()+shadow))
+
+	drawer := &font.Drawer{Dst: mark, Face: face}
+	for _, layer := range []struct {
+		offset int
+		color  color.Color
+	}{
+		{offset: shadow, color: color.RGBA{A: 160}},
+		{offset: 0, color: color.White},
+	} {
+		drawer.Src = image.NewUniform(layer.color)
+		drawer.Dot = fixed.P(layer.offset, metrics.Ascent.Ceil()+layer.offset)
+		drawer.DrawString(text)
+	}
+
+	return mark, nil
+}
@@ -0,0 +1,138 @@
+package imageproxy
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gocloud.dev/blob/memblob"
+)
+
+func TestParseWatermark(t *testing.T) {
+	t.Parallel()
+
+	w, err := ParseWatermark(Watermark{Text: "© Example"})
+	require.NoError(t, err)
+	require.Equal(t, CornerBottomRight, w.Corner)
+	require.InDelta(t, 0.5, w.Opacity, 0.001)
+	require.InDelta(t, 0.03, w.Size, 0.001)
+	require.Equal(t, DefaultWatermarkMinWidth, w.MinWidth)
+
+	w, err = ParseWatermark(Watermark{Text: "© Example", MinHeight: 500})
+	require.NoError(t, err)
+	require.Zero(t, w.MinWidth)
+
+	testCases := map[string]Watermark{
+		"no mark":         {},
+		"unknown corner":  {Text: "© Example", Corner: "middle"},
+		"invalid opacity": {Text: "© Example", Opacity: 2},
+		"invalid size":    {Text: "© Example", Size: -1},
+		"invalid minimum": {Text: "© Example", MinWidth: -1},
+	}
+
+	for description, w := range testCases {
+		t.Run(description, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := ParseWatermark(w)
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestWatermarkCacheKey(t *testing.T) {
+	t.Parallel()
+
+	a, err := ParseWatermark(Watermark{Text: "© Example"})
+	require.NoError(t, err)
+	b, err := ParseWatermark(Watermark{Text: "© Example"})
+	require.NoError(t, err)
+	require.Equal(t, a.CacheKey(), b.CacheKey())
+
+	for _, changed := range []Watermark{
+		{Text: "© Other"},
+		{Text: "© Example", Corner: CornerTopLeft},
+		{Text: "© Example", MinWidth: 500},
+		{Text: "© Example", Version: "2"},
+	} {
+		c, err := ParseWatermark(changed)
+		require.NoError(t, err)
+		require.NotEqual(t, a.CacheKey(), c.CacheKey())
+	}
+}
+
+// brightness is the mean brightness of the pixels of img in r.
+func brightness(img image.Image, r image.Rectangle) float64 {
+	var total float64
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			total += float64(color.GrayModel.Convert(img.At(x, y)).(color.Gray).Y)
+		}
+	}
+
+	return total / float64(r.Dx()*r.Dy())
+}
+
+func TestResizerWatermark(t *testing.T) {
+	t.Parallel()
+
+	bucket := memblob.OpenBucket(nil)
+	t.Cleanup(func() { _ = bucket.Close() })
+
+	// a white square mark
+	var mark bytes.Buffer
+	markImage := image.NewRGBA(image.Rect(0, 0, 10, 10))
+	for i := range markImage.Pix {
+		markImage.Pix[i] = 255
+	}
+	err := png.Encode(&mark, markImage)
+	require.NoError(t, err)
+	err = bucket.WriteAll(t.Context(), "watermark.png", mark.Bytes(), nil)
+	require.NoError(t, err)
+
+	var original bytes.Buffer
+	err = png.Encode(&original, image.NewRGBA(image.Rect(0, 0, 400, 200)))
+	require.NoError(t, err)
+
+	for description, w := range map[string]Watermark{
+		"image": {Image: "watermark.png", Corner: CornerTopLeft, Opacity: 1, Size: 0.1, MinWidth: 300},
+		"text":  {Text: "© Example", Corner: CornerTopLeft, Opacity: 1, Size: 0.1, MinWidth: 300},
+	} {
+		t.Run(description, func(t *testing.T) {
+			t.Parallel()
+
+			watermark, err := ParseWatermark(w)
+			require.NoError(t, err)
+
+			ir := Resizer{Pool: NewPool(1, DefaultPixelBudget), Watermark: watermark}
+
+			thumb, err := ir.CreateThumbInBucket(
+				t.Context(), bytes.NewReader(original.Bytes()), bucket, Edits{}, "400,fit,jpeg", description+"-large.jpg",
+			)
+			require.NoError(t, err)
+
+			img, err := jpeg.Decode(bytes.NewReader(thumb))
+			require.NoError(t, err)
+			require.Equal(t, image.Rect(0, 0, 400, 200), img.Bounds())
+
+			// the mark is 20px high, inset by 10px
+			require.Greater(t, brightness(img, image.Rect(10, 10, 30, 30)), 20.0)
+			require.Less(t, brightness(img, image.Rect(200, 100, 400, 200)), 5.0)
+
+			// renditions under the minimum width are not marked
+			thumb, err = ir.CreateThumbInBucket(
+				t.Context(), bytes.NewReader(original.Bytes()), bucket, Edits{}, "200,fit,jpeg", description+"-small.jpg",
+			)
+			require.NoError(t, err)
+
+			img, err = jpeg.Decode(bytes.NewReader(thumb))
+			require.NoError(t, err)
+			require.Equal(t, 200, img.Bounds().Dx())
+			require.Less(t, brightness(img, img.Bounds()), 5.0)
+		})
+	}
+}
@@ -0,0 +1,242 @@
+package ingest
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+
+	"gocloud.dev/blob"
+
+	"github.com/charlieegan3/photos/internal/pkg/geotag"
+	"github.com/charlieegan3/photos/internal/pkg/mediakind"
+	"github.com/charlieegan3/photos/internal/pkg/mediametadata"
+	"github.com/charlieegan3/photos/internal/pkg/models"
+	"github.com/charlieegan3/photos/internal/pkg/thumbnails"
+)
+
+// SHA256 returns the hex encoded checksum of an original file, used to find
+// duplicate uploads.
+func SHA256(fileBytes []byte) string {
+	sum := sha256.Sum256(fileBytes)
+	return hex.EncodeToString(sum[:])
+}
+
+// EnrichFromMetadata sets the kind of media from the file name, then the
+// other fields from the EXIF data of images or the metadata boxes of videos.
+func EnrichFromMetadata(media *models.Media, fileBytes []byte, filename string) error {
+	var err error
+	media.Kind, err = mediakind.FromFilename(filename)
+	if err != nil {
+		return err
+	}
+
+	var metadata mediametadata.Metadata
+	if mediakind.IsVideo(media.Kind) {
+		metadata, err = mediametadata.ExtractVideoMetadata(fileBytes)
+		if err != nil {
+			return fmt.Errorf("failed to get video metadata: %w", err)
+		}
+	} else {
+		metadata, err = mediametadata.ExtractMetadata(fileBytes)
+		if err != nil {
+			return fmt.Errorf("failed to get exif data file: %w", err)
+		}
+	}
+
+	media.Make = metadata.Make
+	media.Model = metadata.Model
+	media.Lens = metadata.Lens
+	media.FocalLength = metadata.FocalLength
+	media.TakenAt = metadata.DateTime
+	media.UTCCorrect = metadata.UTCCorrect
+	media.FNumber, _ = metadata.FNumber.ToDecimal()
+	media.ExposureTimeNumerator = metadata.ExposureTime.Numerator
+	media.ExposureTimeDenominator = metadata.ExposureTime.Denominator
+	media.ISOSpeed = int(metadata.ISOSpeed)
+	media.Latitude, _ = metadata.Latitude.ToDecimal()
+	media.Longitude, _ = metadata.Longitude.ToDecimal()
+	media.Altitude, _ = metadata.Altitude.ToDecimal()
+	SetExposureAndGPS(media, metadata)
+	if int(metadata.Orientation) > 0 {
+		media.Orientation = int(metadata.Orientation)
+	} else {
+		media.Orientation = 1 // Default to normal orientation if not set in EXIF
+	}
+	media.Width = metadata.Width
+	media.Height = metadata.Height
+	media.Duration = metadata.Duration
+	media.MetadataTags = metadataTags(metadata)
+
+	return nil
+}
+
+// SetExposureAndGPS sets the exposure details, camera direction, GPS time
+// and altitude reference of media from its metadata.
+func SetExposureAndGPS(media *models.Media, metadata mediametadata.Metadata) {
+	media.ExposureCompensation, _ = metadata.ExposureCompensation.ToDecimal()
+	media.ExposureProgram = metadata.ExposureProgram
+	media.MeteringMode = metadata.MeteringMode
+	media.Flash = metadata.Flash
+	media.WhiteBalance = metadata.WhiteBalance
+	media.SubjectDistance = subjectDistance(metadata.SubjectDistance)
+
+	media.AltitudeRef = int(metadata.Altitude.Ref)
+	media.GPSTime = metadata.GPSTime
+
+	media.ImageDirection, media.ImageDirectionRef = 0, ""
+	if metadata.ImageDirection.Value.Denominator != 0 {
+		media.ImageDirection, _ = metadata.ImageDirection.Value.ToDecimal()
+		media.ImageDirectionRef = metadata.ImageDirection.Ref
+		// true north is assumed when the reference is missing
+		if media.ImageDirectionRef == "" {
+			media.ImageDirectionRef = "T"
+		}
+	}
+}
+
+// subjectDistance returns the distance in metres, see
+// models.SubjectDistanceInfinity.
+func subjectDistance(distance mediametadata.Fraction) float64 {
+	if distance.Numerator == mediametadata.SubjectDistanceInfinity {
+		return models.SubjectDistanceInfinity
+	}
+
+	metres, _ := distance.ToDecimal()
+
+	return metres
+}
+
+// ExtractMetadataTags reads all the EXIF and XMP tags from the original file
+// of a media again, so that tags can be refreshed without a new upload.
+func ExtractMetadataTags(kind string, fileBytes []byte) ([]models.MetadataTag, error) {
+	if mediakind.IsVideo(kind) {
+		metadata, err := mediametadata.ExtractVideoMetadata(fileBytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get video metadata: %w", err)
+		}
+
+		return metadataTags(metadata), nil
+	}
+
+	metadata, err := mediametadata.ExtractMetadata(fileBytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get exif data file: %w", err)
+	}
+
+	return metadataTags(metadata), nil
+}
+
+func metadataTags(metadata mediametadata.Metadata) []models.MetadataTag {
+	tags := make([]models.MetadataTag, 0, len(metadata.Tags))
+	for _, tag := range metadata.Tags {
+		tags = append(tags, models.MetadataTag(tag))
+	}
+
+	return tags
+}
+
+// DefaultFavouriteRatingAbove is the star rating which XMP ratings must be
+// above to mark a draft post as a favourite.
+const DefaultFavouriteRatingAbove = 4
+
+// DraftFromXMP sets the description and favourite flag of a draft post from
+// the XMP written by photo editors, and returns the tag names for its
+// keywords. The caption is used as the description, or the title when there
+// is no caption.
+func DraftFromXMP(post *models.Post, xmp mediametadata.XMP, favouriteRatingAbove int) []string {
+	post.Description = xmp.Caption
+	if post.Description == "" {
+		post.Description = xmp.Title
+	}
+
+	post.IsFavourite = xmp.Rating > favouriteRatingAbove
+
+	return keywordTags(xmp.Keywords)
+}
+
+// keywordTags returns the tag names for XMP keywords, tags are single lower
+// case words so keywords of several words are joined.
+func keywordTags(keywords []string) []string {
+	seen := make(map[string]bool)
+	var tags []string
+	for _, keyword := range keywords {
+		tag := strings.Join(strings.Fields(strings.ToLower(keyword)), "")
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		tags = append(tags, tag)
+	}
+
+	return tags
+}
+
+// Geotag sets the location of media without GPS data from the activity
+// points recorded around the time it was taken. Media without a UTC time are
+// not located, their local time could match a point hours away.
+func Geotag(ctx context.Context, geotagger *geotag.Geotagger, media *models.Media) (bool, error) {
+	if media.TakenAt.IsZero() || !media.UTCCorrect {
+		return false, nil
+	}
+
+	point, ok, err := geotagger.Locate(ctx, media.TakenAt)
+	if err != nil {
+		return false, fmt.Errorf("failed to geotag media: %w", err)
+	}
+	if !ok {
+		return false, nil
+	}
+
+	media.Latitude = point.Latitude
+	media.Longitude = point.Longitude
+	media.Altitude = point.Altitude
+
+	return true, nil
+}
+
+// SaveOriginal writes the uploaded file to the bucket. Thumbnails are made
+// from it separately, see thumbnails.Generate.
+func SaveOriginal(
+	ctx context.Context,
+	bucket *blob.Bucket,
+	media models.Media,
+	fileBytes []byte,
+) error {
+	bw, err := bucket.NewWriter(ctx, thumbnails.OriginalPath(media), nil)
+	if err != nil {
+		return fmt.Errorf("failed initialize media storage: %w", err)
+	}
+
+	_, err = io.Copy(bw, bytes.NewReader(fileBytes))
+	if err != nil {
+		bw.Close()
+		return fmt.Errorf("failed to save to media storage: %w", err)
+	}
+
+	err = bw.Close()
+	if err != nil {
+		return fmt.Errorf("failed to close media storage writer: %w", err)
+	}
+
+	return nil
+}
+
+// SavePoster writes the still image shown for a video before it is played,
+// the video's thumbnails are made from it.
+func SavePoster(
+	ctx context.Context,
+	bucket *blob.Bucket,
+	media models.Media,
+	posterBytes []byte,
+) error {
+	err := bucket.WriteAll(ctx, thumbnails.PosterPath(media), posterBytes, nil)
+	if err != nil {
+		return fmt.Errorf("failed to save poster: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,114 @@
+package ingest
+
+import (
+	"bytes"
+	"image"
+	"image/jpeg"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"gocloud.dev/blob/memblob"
+
+	"github.com/charlieegan3/photos/internal/pkg/geotag"
+	"github.com/charlieegan3/photos/internal/pkg/mediametadata"
+	"github.com/charlieegan3/photos/internal/pkg/models"
+	"github.com/charlieegan3/photos/internal/pkg/thumbnails"
+)
+
+func TestSHA256(t *testing.T) {
+	t.Parallel()
+
+	require.Equal(
+		t,
+		"2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824",
+		SHA256([]byte("hello")),
+	)
+}
+
+func TestEnrichFromMetadata(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	err := jpeg.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 30, 20)), nil)
+	require.NoError(t, err)
+
+	var media models.Media
+	err = EnrichFromMetadata(&media, buf.Bytes(), "IMG_0001.JPEG")
+	require.NoError(t, err)
+
+	require.Equal(t, "jpg", media.Kind)
+	require.Equal(t, 1, media.Orientation)
+
+	err = EnrichFromMetadata(&media, buf.Bytes(), "IMG_0001")
+	require.Error(t, err)
+}
+
+func TestDraftFromXMP(t *testing.T) {
+	t.Parallel()
+
+	var post models.Post
+	tags := DraftFromXMP(&post, mediametadata.XMP{
+		Title:    "Harbour",
+		Caption:  "Boats at dawn",
+		Keywords: []string{"Sea", "New York", "sea", " "},
+		Rating:   5,
+	}, DefaultFavouriteRatingAbove)
+
+	require.Equal(t, "Boats at dawn", post.Description)
+	require.True(t, post.IsFavourite)
+	require.Equal(t, []string{"sea", "newyork"}, tags)
+
+	tags = DraftFromXMP(&post, mediametadata.XMP{Title: "Harbour", Rating: 4}, DefaultFavouriteRatingAbove)
+
+	require.Equal(t, "Harbour", post.Description)
+	require.False(t, post.IsFavourite)
+	require.Empty(t, tags)
+}
+
+func TestGeotag(t *testing.T) {
+	t.Parallel()
+
+	takenAt := time.Date(2022, time.July, 31, 19, 32, 4, 0, time.UTC)
+	geotagger := geotag.NewGeotagger(geotag.SlicePointFinder([]models.Point{
+		{Timestamp: takenAt, Latitude: 51.5, Longitude: -0.1},
+	}), 0)
+
+	media := models.Media{TakenAt: takenAt}
+	ok, err := Geotag(t.Context(), geotagger, &media)
+	require.NoError(t, err)
+	require.False(t, ok, "media without a UTC time should not be located")
+	require.Zero(t, media.Latitude)
+
+	media.UTCCorrect = true
+	ok, err = Geotag(t.Context(), geotagger, &media)
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.InDelta(t, 51.5, media.Latitude, 0.0001)
+}
+
+func TestSaveOriginal(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	bucket := memblob.OpenBucket(nil)
+	defer bucket.Close()
+
+	var buf bytes.Buffer
+	err := jpeg.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 300, 200)), nil)
+	require.NoError(t, err)
+
+	media := models.Media{ID: 1, Kind: "jpg", Width: 300, Height: 200}
+
+	err = SaveOriginal(ctx, bucket, media, buf.Bytes())
+	require.NoError(t, err)
+
+	original, err := bucket.ReadAll(ctx, thumbnails.OriginalPath(media))
+	require.NoError(t, err)
+	require.Equal(t, buf.Bytes(), original)
+
+	// thumbnails are made later by a job
+	stale, err := thumbnails.Stale(ctx, bucket, media)
+	require.NoError(t, err)
+	require.Equal(t, thumbnails.Profiles, stale)
+}
@@ -0,0 +1,272 @@
+package instagram
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// postsFilePattern matches the posts JSON files in the export, these are
+// found in content/ or your_instagram_activity/content/ depending on the age
+// of the export.
+var postsFilePattern = regexp.MustCompile(`(^|/)posts_\d+\.json$`)
+
+// hashtagPattern matches hashtags in captions.
+var hashtagPattern = regexp.MustCompile(`#([\p{L}\p{N}_]+)`)
+
+// hashtagWordPattern matches words which are only a hashtag.
+var hashtagWordPattern = regexp.MustCompile(`^#[\p{L}\p{N}_]+$`)
+
+// Export is an opened Instagram data export archive.
+type Export struct {
+	reader *zip.ReadCloser
+	files  map[string]*zip.File
+}
+
+// Post is a post from the export, posts with many media items are carousels.
+type Post struct {
+	Caption   string
+	Tags      []string
+	Timestamp time.Time
+	Media     []Media
+}
+
+// Media is a single media item in a post.
+type Media struct {
+	// Code identifies the media between exports. The export does not contain
+	// the post shortcodes so the file name is used instead.
+	Code      string
+	URI       string
+	Timestamp time.Time
+	Latitude  float64
+	Longitude float64
+}
+
+type exportPost struct {
+	Title             string        `json:"title"`
+	CreationTimestamp int64         `json:"creation_timestamp"`
+	Media             []exportMedia `json:"media"`
+}
+
+type exportMedia struct {
+	URI               string `json:"uri"`
+	Title             string `json:"title"`
+	CreationTimestamp int64  `json:"creation_timestamp"`
+	MediaMetadata     struct {
+		PhotoMetadata struct {
+			ExifData []struct {
+				Latitude  float64 `json:"latitude"`
+				Longitude float64 `json:"longitude"`
+			} `json:"exif_data"`
+		} `json:"photo_metadata"`
+	} `json:"media_metadata"`
+}
+
+// Open opens the export zip file at path.
+func Open(path string) (*Export, error) {
+	reader, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open export: %w", err)
+	}
+
+	files := make(map[string]*zip.File, len(reader.File))
+	for _, f := range reader.File {
+		files[f.Name] = f
+	}
+
+	return &Export{reader: reader, files: files}, nil
+}
+
+// Close closes the underlying zip file.
+func (e *Export) Close() error {
+	return e.reader.Close()
+}
+
+// Posts returns all the posts in the export, oldest first.
+func (e *Export) Posts() ([]Post, error) {
+	var names []string
+	for name := range e.files {
+		if postsFilePattern.MatchString(name) {
+			names = append(names, name)
+		}
+	}
+
+	if len(names) == 0 {
+		return nil, errors.New("no posts files found in export")
+	}
+
+	sort.Strings(names)
+
+	var posts []Post
+	for _, name := range names {
+		filePosts, err := e.readPostsFile(name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", name, err)
+		}
+
+		posts = append(posts, filePosts...)
+	}
+
+	sort.SliceStable(posts, func(i, j int) bool {
+		return posts[i].Timestamp.Before(posts[j].Timestamp)
+	})
+
+	return posts, nil
+}
+
+// ReadMedia returns the contents of the media file at uri.
+func (e *Export) ReadMedia(uri string) ([]byte, error) {
+	f, ok := e.files[uri]
+	if !ok {
+		// uris are relative to the export root, which is sometimes a
+		// directory inside the zip
+		for name, candidate := range e.files {
+			if strings.HasSuffix(name, "/"+uri) {
+				f = candidate
+				ok = true
+				break
+			}
+		}
+	}
+
+	if !ok {
+		return nil, fmt.Errorf("media file %s not found in export", uri)
+	}
+
+	rc, err := f.Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open media file: %w", err)
+	}
+	defer rc.Close()
+
+	data, err := io.ReadAll(rc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read media file: %w", err)
+	}
+
+	return data, nil
+}
+
+func (e *Export) readPostsFile(name string) ([]Post, error) {
+	rc, err := e.files[name].Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open posts file: %w", err)
+	}
+	defer rc.Close()
+
+	var exportPosts []exportPost
+	err = json.NewDecoder(rc).Decode(&exportPosts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse posts file: %w", err)
+	}
+
+	posts := make([]Post, 0, len(exportPosts))
+	for _, ep := range exportPosts {
+		if len(ep.Media) == 0 {
+			continue
+		}
+
+		// single media posts have the caption and time on the media
+		caption := ep.Title
+		if caption == "" {
+			caption = ep.Media[0].Title
+		}
+		timestamp := ep.CreationTimestamp
+		if timestamp == 0 {
+			timestamp = ep.Media[0].CreationTimestamp
+		}
+
+		caption, tags := splitCaption(fixEncoding(caption))
+
+		post := Post{
+			Caption:   caption,
+			Tags:      tags,
+			Timestamp: time.Unix(timestamp, 0).UTC(),
+		}
+
+		for _, em := range ep.Media {
+			media := Media{
+				Code:      strings.TrimSuffix(path.Base(em.URI), path.Ext(em.URI)),
+				URI:       em.URI,
+				Timestamp: time.Unix(em.CreationTimestamp, 0).UTC(),
+			}
+			if em.CreationTimestamp == 0 {
+				media.Timestamp = post.Timestamp
+			}
+
+			for _, exif := range em.MediaMetadata.PhotoMetadata.ExifData {
+				if exif.Latitude != 0 || exif.Longitude != 0 {
+					media.Latitude = exif.Latitude
+					media.Longitude = exif.Longitude
+				}
+			}
+
+			post.Media = append(post.Media, media)
+		}
+
+		posts = append(posts, post)
+	}
+
+	return posts, nil
+}
+
+// splitCaption returns the caption with any trailing block of hashtags
+// removed, and the lower case names of all the hashtags in the caption.
+// Hashtags used inline in the caption text are left in place.
+func splitCaption(caption string) (string, []string) {
+	var tags []string
+	seen := make(map[string]bool)
+	for _, match := range hashtagPattern.FindAllStringSubmatch(caption, -1) {
+		tag := strings.ToLower(match[1])
+		if !seen[tag] {
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+	}
+
+	fields := strings.Fields(caption)
+	end := len(fields)
+	for end > 0 && hashtagWordPattern.MatchString(fields[end-1]) {
+		end--
+	}
+
+	if end == len(fields) {
+		return strings.TrimSpace(caption), tags
+	}
+
+	// remove the trailing hashtags one at a time so that line breaks in the
+	// rest of the caption are preserved
+	trimmed := caption
+	for range len(fields) - end {
+		trimmed = strings.TrimSpace(trimmed)
+		trimmed = trimmed[:strings.LastIndex(trimmed, "#")]
+	}
+
+	return strings.TrimSpace(trimmed), tags
+}
+
+// fixEncoding repairs text in the export which has been written as UTF-8 bytes
+// escaped as individual latin-1 characters.
+func fixEncoding(s string) string {
+	raw := make([]byte, 0, len(s))
+	for _, r := range s {
+		if r > 0xff {
+			return s
+		}
+		raw = append(raw, byte(r))
+	}
+
+	if !utf8.Valid(raw) {
+		return s
+	}
+
+	return string(raw)
+}
@@ -0,0 +1,113 @@
+package instagram
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+const postsJSON = `[
+  {
+    "media": [
+      {
+        "uri": "media/posts/202101/123_456_n.jpg",
+        "creation_timestamp": 1610000000,
+        "media_metadata": {
+          "photo_metadata": {"exif_data": [{"latitude": 51.5, "longitude": -0.1}]}
+        },
+        "title": "A walk in #London\n\nwith friends #Walking #london"
+      }
+    ]
+  },
+  {
+    "media": [
+      {"uri": "media/posts/202001/1_n.jpg", "creation_timestamp": 1580000000, "title": ""},
+      {"uri": "media/posts/202001/2_n.jpg", "creation_timestamp": 1580000000, "title": ""}
+    ],
+    "title": "CafÃ©",
+    "creation_timestamp": 1580000000
+  }
+]`
+
+func writeExport(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	exportPath := filepath.Join(t.TempDir(), "export.zip")
+	f, err := os.Create(exportPath)
+	require.NoError(t, err)
+	defer f.Close()
+
+	w := zip.NewWriter(f)
+	for name, content := range files {
+		fw, err := w.Create(name)
+		require.NoError(t, err)
+		_, err = fw.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, w.Close())
+
+	return exportPath
+}
+
+func TestExportPosts(t *testing.T) {
+	t.Parallel()
+
+	exportPath := writeExport(t, map[string]string{
+		"instagram-example/your_instagram_activity/content/posts_1.json": postsJSON,
+		"instagram-example/media/posts/202101/123_456_n.jpg":             "image",
+	})
+
+	export, err := Open(exportPath)
+	require.NoError(t, err)
+	defer export.Close()
+
+	posts, err := export.Posts()
+	require.NoError(t, err)
+
+	require.Equal(t, []Post{
+		{
+			Caption:   "Café",
+			Timestamp: time.Unix(1580000000, 0).UTC(),
+			Media: []Media{
+				{Code: "1_n", URI: "media/posts/202001/1_n.jpg", Timestamp: time.Unix(1580000000, 0).UTC()},
+				{Code: "2_n", URI: "media/posts/202001/2_n.jpg", Timestamp: time.Unix(1580000000, 0).UTC()},
+			},
+		},
+		{
+			Caption:   "A walk in #London\n\nwith friends",
+			Tags:      []string{"london", "walking"},
+			Timestamp: time.Unix(1610000000, 0).UTC(),
+			Media: []Media{
+				{
+					Code:      "123_456_n",
+					URI:       "media/posts/202101/123_456_n.jpg",
+					Timestamp: time.Unix(1610000000, 0).UTC(),
+					Latitude:  51.5,
+					Longitude: -0.1,
+				},
+			},
+		},
+	}, posts)
+
+	data, err := export.ReadMedia(posts[1].Media[0].URI)
+	require.NoError(t, err)
+	require.Equal(t, "image", string(data))
+
+	_, err = export.ReadMedia(posts[0].Media[0].URI)
+	require.Error(t, err)
+}
+
+func TestExportPostsMissing(t *testing.T) {
+	t.Parallel()
+
+	export, err := Open(writeExport(t, map[string]string{"README.txt": ""}))
+	require.NoError(t, err)
+	defer export.Close()
+
+	_, err = export.Posts()
+	require.Error(t, err)
+}
@@ -0,0 +1,279 @@
+package jobs
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"gocloud.dev/blob"
+
+	"github.com/charlieegan3/photos/internal/pkg/database"
+	"github.com/charlieegan3/photos/internal/pkg/geoapify"
+	"github.com/charlieegan3/photos/internal/pkg/imageproxy"
+	"github.com/charlieegan3/photos/internal/pkg/models"
+	"github.com/charlieegan3/photos/internal/pkg/placeholder"
+	"github.com/charlieegan3/photos/internal/pkg/privacy"
+	"github.com/charlieegan3/photos/internal/pkg/similarity"
+	"github.com/charlieegan3/photos/internal/pkg/thumbnails"
+)
+
+// LocationMapPath is the bucket key of the static map image of a location.
+// Maps of locations in privacy zones are named by the public coordinates they
+// show, so that maps made before the zone was added or changed are not used.
+func LocationMapPath(location models.Location) string {
+	latitude, longitude, _ := privacy.Public(location.Latitude, location.Longitude)
+	if latitude == location.Latitude && longitude == location.Longitude {
+		return fmt.Sprintf("location_maps/%d.jpg", location.ID)
+	}
+
+	sum := sha256.Sum256(fmt.Appendf(nil, "%f,%f", latitude, longitude))
+
+	return fmt.Sprintf("location_maps/%d-%x.jpg", location.ID, sum[:4])
+}
+
+// Handlers run the jobs queued by the server.
+type Handlers struct {
+	DB      *sql.DB
+	Bucket  *blob.Bucket
+	Resizer imageproxy.Resizer
+
+	MapServerURL    string
+	MapServerAPIKey string
+
+	// WebhookURL is sent a JSON POST when a post is published, it is not
+	// used when empty.
+	WebhookURL string
+	// BaseURL is used to build the links to published posts sent to the
+	// webhook, e.g. https://photos.example.com
+	BaseURL string
+}
+
+// Register adds the handlers for each kind of job to runner.
+func (h *Handlers) Register(runner *Runner) {
+	runner.Handle(KindThumbnails, h.Thumbnails)
+	runner.Handle(KindLocationMap, h.LocationMap)
+	runner.Handle(KindPostPublished, h.PostPublished)
+}
+
+func decodePayload(job models.Job, payload any) error {
+	err := json.Unmarshal(job.Payload, payload)
+	if err != nil {
+		return Permanent(fmt.Errorf("failed to decode %s job payload: %w", job.Kind, err))
+	}
+
+	return nil
+}
+
+// Thumbnails makes the missing or out of date thumbnails, placeholder,
+// perceptual hash and public copy of a media.
+func (h *Handlers) Thumbnails(ctx context.Context, job models.Job) error {
+	var payload ThumbnailsPayload
+	err := decodePayload(job, &payload)
+	if err != nil {
+		return err
+	}
+
+	return h.GenerateThumbnails(ctx, payload.MediaID)
+}
+
+// GenerateThumbnails does the work of a thumbnails job for a media. The CLI
+// ingest commands call it directly since no workers run alongside them.
+func (h *Handlers) GenerateThumbnails(ctx context.Context, mediaID int) error {
+	medias, err := database.FindMediasByID(ctx, h.DB, []int{mediaID})
+	if err != nil {
+		return fmt.Errorf("failed to find media: %w", err)
+	}
+	// the media has been deleted since the job was queued
+	if len(medias) == 0 {
+		return nil
+	}
+	media := medias[0]
+
+	err = thumbnails.EnsurePublic(ctx, h.Bucket, media)
+	if err != nil {
+		return err
+	}
+
+	stale, err := thumbnails.Stale(ctx, h.Bucket, media)
+	if err != nil {
+		return err
+	}
+
+	if len(stale) > 0 {
+		source, err := h.Bucket.ReadAll(ctx, thumbnails.SourcePath(media))
+		if err != nil {
+			return fmt.Errorf("failed to read source image: %w", err)
+		}
+
+		err = thumbnails.Generate(ctx, h.Bucket, &h.Resizer, media, source, stale)
+		if err != nil {
+			return err
+		}
+	}
+
+	// the placeholder and perceptual hash match the thumbnails, so are remade
+	// with them
+	repo := database.NewMediaRepository(h.DB)
+
+	needsPlaceholder := len(stale) > 0 || media.BlurHash == ""
+	needsHash := len(stale) > 0 || media.PerceptualHash == ""
+	if !needsPlaceholder && !needsHash {
+		return nil
+	}
+
+	small, err := thumbnails.Small(ctx, h.Bucket, media)
+	if err != nil {
+		return err
+	}
+
+	if needsPlaceholder {
+		blurHash, dominantColour, err := placeholder.Make(small)
+		if err != nil {
+			return err
+		}
+
+		err = repo.SetPlaceholder(ctx, media.ID, blurHash, dominantColour)
+		if err != nil {
+			return err
+		}
+	}
+
+	if needsHash {
+		err = repo.SetPerceptualHash(ctx, media.ID, similarity.Hash(small))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// LocationMap downloads the static map image of a location into the bucket.
+func (h *Handlers) LocationMap(ctx context.Context, job models.Job) error {
+	var payload LocationMapPayload
+	err := decodePayload(job, &payload)
+	if err != nil {
+		return err
+	}
+
+	return h.saveLocationMap(ctx, payload.LocationID, true)
+}
+
+func (h *Handlers) saveLocationMap(ctx context.Context, locationID int, replace bool) error {
+	locations, err := database.FindLocationsByID(ctx, h.DB, []int{locationID})
+	if err != nil {
+		return fmt.Errorf("failed to find location: %w", err)
+	}
+	if len(locations) == 0 {
+		return nil
+	}
+	location := locations[0]
+
+	if !replace {
+		exists, err := h.Bucket.Exists(ctx, LocationMapPath(location))
+		if err != nil {
+			return fmt.Errorf("failed to check for location map: %w", err)
+		}
+		if exists {
+			return nil
+		}
+	}
+
+	// maps are public, so are made from the public coordinates
+	latitude, longitude, ok := privacy.Public(location.Latitude, location.Longitude)
+	if !ok {
+		return nil
+	}
+
+	mapBytes, err := geoapify.StaticMap(ctx, h.MapServerURL, h.MapServerAPIKey, latitude, longitude)
+	if err != nil {
+		return err
+	}
+
+	err = h.Bucket.WriteAll(ctx, LocationMapPath(location), mapBytes, nil)
+	if err != nil {
+		return fmt.Errorf("failed to write location map: %w", err)
+	}
+
+	return nil
+}
+
+// PostPublished runs when a post is published. It makes sure the images
+// shown on the post page are ready, then notifies the webhook.
+func (h *Handlers) PostPublished(ctx context.Context, job models.Job) error {
+	var payload PostPublishedPayload
+	err := decodePayload(job, &payload)
+	if err != nil {
+		return err
+	}
+
+	posts, err := database.FindPostsByID(ctx, h.DB, []int{payload.PostID})
+	if err != nil {
+		return fmt.Errorf("failed to find post: %w", err)
+	}
+	if len(posts) == 0 {
+		return nil
+	}
+	post := posts[0]
+
+	// the post has been made a draft or rescheduled, which queues another
+	// job for the new publish date
+	if post.IsDraft || !post.PublishDate.Equal(payload.PublishDate) {
+		return nil
+	}
+
+	err = h.GenerateThumbnails(ctx, post.MediaID)
+	if err != nil {
+		return err
+	}
+
+	err = h.saveLocationMap(ctx, post.LocationID, false)
+	if err != nil {
+		return err
+	}
+
+	if h.WebhookURL == "" {
+		return nil
+	}
+
+	return h.notifyWebhook(ctx, post)
+}
+
+func (h *Handlers) notifyWebhook(ctx context.Context, post models.Post) error {
+	body, err := json.Marshal(struct {
+		PostID      int       `json:"post_id"`
+		URL         string    `json:"url"`
+		PublishDate time.Time `json:"publish_date"`
+	}{
+		PostID:      post.ID,
+		URL:         fmt.Sprintf("%s/posts/%d", h.BaseURL, post.ID),
+		PublishDate: post.PublishDate.UTC(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode webhook body: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.WebhookURL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := &http.Client{}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("webhook request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook request failed: %d", resp.StatusCode)
+	}
+
+	return nil
+}
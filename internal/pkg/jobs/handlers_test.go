@@ -0,0 +1,31 @@
+package jobs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/charlieegan3/photos/internal/pkg/models"
+	"github.com/charlieegan3/photos/internal/pkg/privacy"
+)
+
+// TestLocationMapPath isn't parallel since it sets the privacy zones.
+func TestLocationMapPath(t *testing.T) {
+	zones := privacy.Zones
+	t.Cleanup(func() { privacy.Zones = zones })
+
+	home := models.Location{ID: 1, Latitude: 51.5, Longitude: -0.1}
+	away := models.Location{ID: 2, Latitude: 48.8, Longitude: 2.3}
+
+	privacy.Zones = nil
+	require.Equal(t, "location_maps/1.jpg", LocationMapPath(home))
+
+	// maps made before the zone was added are not used
+	privacy.Zones = []privacy.Zone{{Name: "home", Latitude: 51.5001, Longitude: -0.1, Radius: 100, Action: privacy.ActionSnap}}
+	snapped := LocationMapPath(home)
+	require.Regexp(t, `^location_maps/1-[0-9a-f]{8}\.jpg$`, snapped)
+	require.Equal(t, "location_maps/2.jpg", LocationMapPath(away))
+
+	privacy.Zones[0].Latitude = 51.5002
+	require.NotEqual(t, snapped, LocationMapPath(home))
+}
@@ -0,0 +1,91 @@
+// Package jobs runs work such as making thumbnails in the background, rather
+// than in the HTTP handlers which create it. Jobs are stored in the database
+// so they survive restarts and are retried when they fail.
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/charlieegan3/photos/internal/pkg/database"
+	"github.com/charlieegan3/photos/internal/pkg/models"
+)
+
+const (
+	// KindThumbnails makes the missing or out of date thumbnails of a media.
+	KindThumbnails = "thumbnails"
+	// KindLocationMap downloads the static map image of a location.
+	KindLocationMap = "location_map"
+	// KindPostPublished runs when a post becomes visible on the site.
+	KindPostPublished = "post_published"
+)
+
+// ThumbnailsPayload is the payload of KindThumbnails jobs.
+type ThumbnailsPayload struct {
+	MediaID int `json:"media_id"`
+}
+
+// LocationMapPayload is the payload of KindLocationMap jobs.
+type LocationMapPayload struct {
+	LocationID int `json:"location_id"`
+}
+
+// PostPublishedPayload is the payload of KindPostPublished jobs.
+// PublishDate is included so that a post which is rescheduled gets a new job,
+// jobs for an earlier publish date do nothing.
+type PostPublishedPayload struct {
+	PostID      int       `json:"post_id"`
+	PublishDate time.Time `json:"publish_date"`
+}
+
+// Enqueue adds a job of kind to the queue to run as soon as a worker is free.
+// The payload is encoded as JSON.
+func Enqueue(ctx context.Context, db *sql.DB, kind string, payload any) error {
+	return EnqueueAt(ctx, db, kind, payload, time.Now())
+}
+
+// EnqueueAt adds a job of kind to the queue to run at runAt. Jobs are not
+// added when the same job is already waiting to run.
+func EnqueueAt(ctx context.Context, db *sql.DB, kind string, payload any, runAt time.Time) error {
+	payloadJSON, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s job payload: %w", kind, err)
+	}
+
+	err = database.NewJobRepository(db).Enqueue(ctx, []models.Job{
+		{Kind: kind, Payload: payloadJSON, RunAt: runAt},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to enqueue %s job: %w", kind, err)
+	}
+
+	return nil
+}
+
+// EnqueueThumbnails queues the thumbnails of a media to be made.
+func EnqueueThumbnails(ctx context.Context, db *sql.DB, mediaID int) error {
+	return Enqueue(ctx, db, KindThumbnails, ThumbnailsPayload{MediaID: mediaID})
+}
+
+// EnqueueLocationMap queues the map of a location to be downloaded.
+func EnqueueLocationMap(ctx context.Context, db *sql.DB, locationID int) error {
+	return Enqueue(ctx, db, KindLocationMap, LocationMapPayload{LocationID: locationID})
+}
+
+// EnqueuePostPublished queues the publish hooks of a post to run when it is
+// published. Drafts are not published so nothing is queued for them.
+func EnqueuePostPublished(ctx context.Context, db *sql.DB, post models.Post) error {
+	if post.IsDraft {
+		return nil
+	}
+
+	payload := PostPublishedPayload{
+		PostID:      post.ID,
+		PublishDate: post.PublishDate.UTC(),
+	}
+
+	return EnqueueAt(ctx, db, KindPostPublished, payload, post.PublishDate)
+}
@@ -0,0 +1,281 @@
+package jobs
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	"gocloud.dev/blob/memblob"
+
+	"github.com/charlieegan3/photos/internal/pkg/database"
+	"github.com/charlieegan3/photos/internal/pkg/ingest"
+	"github.com/charlieegan3/photos/internal/pkg/models"
+	"github.com/charlieegan3/photos/internal/pkg/thumbnails"
+)
+
+// JobsSuite tests the running of queued jobs and their handlers.
+type JobsSuite struct {
+	suite.Suite
+
+	DB *sql.DB
+}
+
+func (s *JobsSuite) SetupTest() {
+	for _, table := range []string{"photos.jobs", "photos.posts", "photos.medias", "photos.devices", "photos.locations"} {
+		err := database.Truncate(s.T().Context(), s.DB, table)
+		s.Require().NoError(err)
+	}
+}
+
+func (s *JobsSuite) TestRunNext() {
+	runner := NewRunner(s.DB, 1)
+
+	var payloads []string
+	runner.Handle("test", func(_ context.Context, job models.Job) error {
+		payloads = append(payloads, string(job.Payload))
+		return nil
+	})
+
+	ran, err := runner.RunNext(s.T().Context())
+	s.Require().NoError(err)
+	s.False(ran)
+
+	err = Enqueue(s.T().Context(), s.DB, "test", map[string]int{"id": 1})
+	s.Require().NoError(err)
+
+	ran, err = runner.RunNext(s.T().Context())
+	s.Require().NoError(err)
+	s.True(ran)
+
+	s.Equal([]string{`{"id": 1}`}, payloads)
+
+	// completed jobs are removed
+	count, err := database.NewJobRepository(s.DB).Count(s.T().Context())
+	s.Require().NoError(err)
+	s.Equal(int64(0), count)
+}
+
+func (s *JobsSuite) TestRunNextRetriesWithBackoff() {
+	runner := NewRunner(s.DB, 1)
+	runner.Handle("test", func(context.Context, models.Job) error {
+		return errors.New("broken")
+	})
+
+	err := Enqueue(s.T().Context(), s.DB, "test", nil)
+	s.Require().NoError(err)
+
+	ran, err := runner.RunNext(s.T().Context())
+	s.Require().NoError(err)
+	s.True(ran)
+
+	jobs, err := database.NewJobRepository(s.DB).All(s.T().Context())
+	s.Require().NoError(err)
+	s.Require().Len(jobs, 1)
+
+	s.Equal(models.JobStatePending, jobs[0].State)
+	s.Equal("broken", jobs[0].LastError)
+	s.WithinDuration(time.Now().Add(Backoff(1)), jobs[0].RunAt, 5*time.Second)
+
+	// the job is not due again until after the backoff
+	ran, err = runner.RunNext(s.T().Context())
+	s.Require().NoError(err)
+	s.False(ran)
+}
+
+func (s *JobsSuite) TestRunNextFailsAfterMaxAttempts() {
+	repo := database.NewJobRepository(s.DB)
+
+	runner := NewRunner(s.DB, 1)
+	runner.Handle("test", func(context.Context, models.Job) error {
+		return errors.New("broken")
+	})
+
+	err := repo.Enqueue(s.T().Context(), []models.Job{{Kind: "test", MaxAttempts: 1}})
+	s.Require().NoError(err)
+
+	ran, err := runner.RunNext(s.T().Context())
+	s.Require().NoError(err)
+	s.True(ran)
+
+	failed, err := repo.Failed(s.T().Context())
+	s.Require().NoError(err)
+	s.Require().Len(failed, 1)
+	s.Equal("broken", failed[0].LastError)
+}
+
+func (s *JobsSuite) TestRunNextFailsPermanentErrors() {
+	repo := database.NewJobRepository(s.DB)
+
+	// there is no handler for this kind
+	err := Enqueue(s.T().Context(), s.DB, "unknown", nil)
+	s.Require().NoError(err)
+
+	ran, err := NewRunner(s.DB, 1).RunNext(s.T().Context())
+	s.Require().NoError(err)
+	s.True(ran)
+
+	failed, err := repo.Failed(s.T().Context())
+	s.Require().NoError(err)
+	s.Require().Len(failed, 1)
+	s.Equal(1, failed[0].Attempts)
+	s.Equal("no handler for unknown jobs", failed[0].LastError)
+}
+
+func (s *JobsSuite) TestThumbnails() {
+	bucket := memblob.OpenBucket(nil)
+	defer bucket.Close()
+
+	media := s.createMedia()
+
+	var buf bytes.Buffer
+	err := jpeg.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 300, 200)), nil)
+	s.Require().NoError(err)
+	err = bucket.WriteAll(s.T().Context(), thumbnails.OriginalPath(media), buf.Bytes(), nil)
+	s.Require().NoError(err)
+
+	runner := NewRunner(s.DB, 1)
+	(&Handlers{DB: s.DB, Bucket: bucket}).Register(runner)
+
+	err = EnqueueThumbnails(s.T().Context(), s.DB, media.ID)
+	s.Require().NoError(err)
+
+	ran, err := runner.RunNext(s.T().Context())
+	s.Require().NoError(err)
+	s.True(ran)
+
+	stale, err := thumbnails.Stale(s.T().Context(), bucket, media)
+	s.Require().NoError(err)
+	s.Empty(stale)
+
+	medias, err := database.FindMediasByID(s.T().Context(), s.DB, []int{media.ID})
+	s.Require().NoError(err)
+	s.Require().Len(medias, 1)
+	s.NotEmpty(medias[0].BlurHash)
+	s.Equal("#000000", medias[0].DominantColour)
+	s.Len(medias[0].PerceptualHash, 16)
+}
+
+// TestGenerateThumbnailsForUpload calls GenerateThumbnails like the upload
+// and Instagram import commands, which do not queue jobs.
+func (s *JobsSuite) TestGenerateThumbnailsForUpload() {
+	bucket := memblob.OpenBucket(nil)
+	defer bucket.Close()
+
+	media := s.createMedia()
+
+	var buf bytes.Buffer
+	err := jpeg.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 300, 200)), nil)
+	s.Require().NoError(err)
+	err = ingest.SaveOriginal(s.T().Context(), bucket, media, buf.Bytes())
+	s.Require().NoError(err)
+
+	err = (&Handlers{DB: s.DB, Bucket: bucket}).GenerateThumbnails(s.T().Context(), media.ID)
+	s.Require().NoError(err)
+
+	stale, err := thumbnails.Stale(s.T().Context(), bucket, media)
+	s.Require().NoError(err)
+	s.Empty(stale)
+
+	medias, err := database.FindMediasByID(s.T().Context(), s.DB, []int{media.ID})
+	s.Require().NoError(err)
+	s.Require().Len(medias, 1)
+	s.NotEmpty(medias[0].BlurHash)
+	s.NotEmpty(medias[0].PerceptualHash)
+}
+
+func (s *JobsSuite) TestPostPublished() {
+	bucket := memblob.OpenBucket(nil)
+	defer bucket.Close()
+
+	mapServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("map"))
+	}))
+	defer mapServer.Close()
+
+	var webhookBodies []string
+	webhook := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		webhookBodies = append(webhookBodies, string(body))
+	}))
+	defer webhook.Close()
+
+	media := s.createMedia()
+
+	var buf bytes.Buffer
+	err := jpeg.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 300, 200)), nil)
+	s.Require().NoError(err)
+	err = bucket.WriteAll(s.T().Context(), thumbnails.OriginalPath(media), buf.Bytes(), nil)
+	s.Require().NoError(err)
+
+	locations, err := database.CreateLocations(s.T().Context(), s.DB, []models.Location{
+		{Name: "London", Latitude: 51.5, Longitude: -0.1},
+	})
+	s.Require().NoError(err)
+
+	publishDate := time.Date(2021, time.November, 24, 19, 56, 0, 0, time.UTC)
+	posts, err := database.CreatePosts(s.T().Context(), s.DB, []models.Post{
+		{MediaID: media.ID, LocationID: locations[0].ID, PublishDate: publishDate},
+		{MediaID: media.ID, LocationID: locations[0].ID, PublishDate: publishDate, IsDraft: true},
+	})
+	s.Require().NoError(err)
+
+	runner := NewRunner(s.DB, 1)
+	(&Handlers{
+		DB:           s.DB,
+		Bucket:       bucket,
+		MapServerURL: mapServer.URL,
+		WebhookURL:   webhook.URL,
+		BaseURL:      "https://photos.example.com",
+	}).Register(runner)
+
+	for _, post := range posts {
+		err = EnqueuePostPublished(s.T().Context(), s.DB, post)
+		s.Require().NoError(err)
+	}
+
+	// drafts are not queued
+	count, err := database.NewJobRepository(s.DB).Count(s.T().Context())
+	s.Require().NoError(err)
+	s.Equal(int64(1), count)
+
+	ran, err := runner.RunNext(s.T().Context())
+	s.Require().NoError(err)
+	s.True(ran)
+
+	stale, err := thumbnails.Stale(s.T().Context(), bucket, media)
+	s.Require().NoError(err)
+	s.Empty(stale)
+
+	mapBytes, err := bucket.ReadAll(s.T().Context(), LocationMapPath(locations[0]))
+	s.Require().NoError(err)
+	s.Equal("map", string(mapBytes))
+
+	s.Require().Len(webhookBodies, 1)
+	var body map[string]any
+	err = json.Unmarshal([]byte(webhookBodies[0]), &body)
+	s.Require().NoError(err)
+	s.Equal(float64(posts[0].ID), body["post_id"])
+	s.Equal(fmt.Sprintf("https://photos.example.com/posts/%d", posts[0].ID), body["url"])
+}
+
+func (s *JobsSuite) createMedia() models.Media {
+	devices, err := database.CreateDevices(s.T().Context(), s.DB, []models.Device{{Name: "Example Device"}})
+	s.Require().NoError(err)
+
+	medias, err := database.CreateMedias(s.T().Context(), s.DB, []models.Media{
+		{DeviceID: devices[0].ID, Kind: "jpg", Width: 300, Height: 200, Orientation: 1},
+	})
+	s.Require().NoError(err)
+
+	return medias[0]
+}
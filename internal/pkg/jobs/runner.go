@@ -0,0 +1,191 @@
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/charlieegan3/photos/internal/pkg/database"
+	"github.com/charlieegan3/photos/internal/pkg/models"
+)
+
+const (
+	// DefaultWorkers is the number of jobs run at once when not configured.
+	DefaultWorkers = 2
+
+	// DefaultPollInterval is how often idle workers check for new jobs.
+	DefaultPollInterval = 5 * time.Second
+
+	// DefaultTimeout is how long a job can run before it is cancelled.
+	DefaultTimeout = 10 * time.Minute
+
+	minBackoff = 30 * time.Second
+	maxBackoff = time.Hour
+)
+
+// Handler runs a job. Returning an error schedules the job to be retried
+// until it runs out of attempts.
+type Handler func(ctx context.Context, job models.Job) error
+
+// Runner claims jobs from the queue and runs them with the Handler
+// registered for their kind.
+type Runner struct {
+	Workers      int
+	PollInterval time.Duration
+	Timeout      time.Duration
+
+	repo     *database.JobRepository
+	handlers map[string]Handler
+}
+
+// NewRunner creates a Runner for the queue in db with the given number of
+// workers.
+func NewRunner(db *sql.DB, workers int) *Runner {
+	if workers < 1 {
+		workers = DefaultWorkers
+	}
+
+	return &Runner{
+		Workers:      workers,
+		PollInterval: DefaultPollInterval,
+		Timeout:      DefaultTimeout,
+		repo:         database.NewJobRepository(db),
+		handlers:     make(map[string]Handler),
+	}
+}
+
+// Handle registers the handler for jobs of kind.
+func (r *Runner) Handle(kind string, handler Handler) {
+	r.handlers[kind] = handler
+}
+
+// Run runs jobs until ctx is cancelled. Jobs left running by a previous
+// process which stopped without finishing them are returned to the queue
+// first.
+func (r *Runner) Run(ctx context.Context) error {
+	// jobs are cancelled after Timeout, so ones locked for longer than
+	// that were abandoned
+	requeued, err := r.repo.RequeueStale(ctx, time.Now().Add(-r.Timeout))
+	if err != nil {
+		return fmt.Errorf("failed to requeue stale jobs: %w", err)
+	}
+	if requeued > 0 {
+		log.Printf("requeued %d stale jobs", requeued)
+	}
+
+	var wg sync.WaitGroup
+	for range r.Workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r.work(ctx)
+		}()
+	}
+	wg.Wait()
+
+	return nil
+}
+
+// work runs jobs until ctx is cancelled, waiting for PollInterval when the
+// queue is empty.
+func (r *Runner) work(ctx context.Context) {
+	for {
+		ran, err := r.RunNext(ctx)
+		if err != nil && ctx.Err() == nil {
+			log.Printf("job worker error: %s", err)
+		}
+
+		if ran && err == nil {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(r.PollInterval):
+		}
+	}
+}
+
+// RunNext claims and runs the next job which is due. ran is false when there
+// were no jobs to run.
+func (r *Runner) RunNext(ctx context.Context) (ran bool, err error) {
+	job, ok, err := r.repo.Dequeue(ctx)
+	if err != nil {
+		return false, err
+	}
+	if !ok {
+		return false, nil
+	}
+
+	jobErr := r.run(ctx, job)
+
+	// the result is recorded even when the runner is stopping so that the job
+	// is not left running
+	ctx = context.WithoutCancel(ctx)
+
+	if jobErr == nil {
+		return true, r.repo.Complete(ctx, job.ID)
+	}
+
+	log.Printf("%s job %d failed on attempt %d of %d: %s", job.Kind, job.ID, job.Attempts, job.MaxAttempts, jobErr)
+
+	var permanent *PermanentError
+	if job.Attempts >= job.MaxAttempts || errors.As(jobErr, &permanent) {
+		return true, r.repo.Fail(ctx, job.ID, jobErr.Error())
+	}
+
+	return true, r.repo.Reschedule(ctx, job.ID, time.Now().Add(Backoff(job.Attempts)), jobErr.Error())
+}
+
+func (r *Runner) run(ctx context.Context, job models.Job) (err error) {
+	handler, ok := r.handlers[job.Kind]
+	if !ok {
+		return Permanent(fmt.Errorf("no handler for %s jobs", job.Kind))
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("job panicked: %v", p)
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(ctx, r.Timeout)
+	defer cancel()
+
+	return handler(ctx, job)
+}
+
+// Backoff is the time to wait before the next attempt of a job which has
+// failed attempts times. It doubles with each attempt, up to an hour.
+func Backoff(attempts int) time.Duration {
+	backoff := minBackoff
+	for i := 1; i < attempts && backoff < maxBackoff; i++ {
+		backoff *= 2
+	}
+
+	return min(backoff, maxBackoff)
+}
+
+// PermanentError is returned by handlers for jobs which will never succeed,
+// these are failed without using their remaining attempts.
+type PermanentError struct {
+	Err error
+}
+
+func (e *PermanentError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *PermanentError) Unwrap() error {
+	return e.Err
+}
+
+// Permanent wraps err so that the job is not retried.
+func Permanent(err error) error {
+	return &PermanentError{Err: err}
+}
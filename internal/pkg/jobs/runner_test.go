@@ -0,0 +1,32 @@
+package jobs
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBackoff(t *testing.T) {
+	t.Parallel()
+
+	require.Equal(t, 30*time.Second, Backoff(0))
+	require.Equal(t, 30*time.Second, Backoff(1))
+	require.Equal(t, time.Minute, Backoff(2))
+	require.Equal(t, 4*time.Minute, Backoff(4))
+	require.Equal(t, 32*time.Minute, Backoff(7))
+	require.Equal(t, time.Hour, Backoff(8))
+	require.Equal(t, time.Hour, Backoff(100))
+}
+
+func TestPermanent(t *testing.T) {
+	t.Parallel()
+
+	err := fmt.Errorf("failed: %w", Permanent(errors.New("bad payload")))
+
+	var permanent *PermanentError
+	require.ErrorAs(t, err, &permanent)
+	require.EqualError(t, err, "failed: bad payload")
+}
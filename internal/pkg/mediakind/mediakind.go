@@ -0,0 +1,118 @@
+// Package mediakind lists the types of file which can be uploaded as media.
+// A media's kind is also the extension of its original file in the bucket.
+package mediakind
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/gen2brain/heic"
+	_ "golang.org/x/image/webp" // register webp decoding
+)
+
+const (
+	JPG  = "jpg"
+	PNG  = "png"
+	WebP = "webp"
+	HEIC = "heic"
+	DNG  = "dng"
+	ARW  = "arw"
+	RAF  = "raf"
+	CR3  = "cr3"
+	MP4  = "mp4"
+	MOV  = "mov"
+)
+
+var contentTypes = map[string]string{
+	JPG:  "image/jpeg",
+	PNG:  "image/png",
+	WebP: "image/webp",
+	HEIC: "image/heic",
+	DNG:  "image/x-adobe-dng",
+	ARW:  "image/x-sony-arw",
+	RAF:  "image/x-fuji-raf",
+	CR3:  "image/x-canon-cr3",
+	MP4:  "video/mp4",
+	MOV:  "video/quicktime",
+}
+
+// aliases maps other file extensions to the kind they are saved as.
+var aliases = map[string]string{
+	"jpeg": JPG,
+	"heif": HEIC,
+}
+
+// heifBrands are the ISOBMFF brands of HEIF image files.
+var heifBrands = []string{"heic", "heix", "heim", "heis", "mif1"}
+
+func init() {
+	// the heic package only registers the heic brand, iPhones and other
+	// cameras also write files with the other brands
+	for _, brand := range heifBrands {
+		image.RegisterFormat("heic", "????ftyp"+brand, heic.Decode, heic.DecodeConfig)
+	}
+}
+
+// IsHEIF is true for ISOBMFF files with a HEIF brand, such as HEIC photos
+// from iPhones.
+func IsHEIF(b []byte) bool {
+	if len(b) < 12 || string(b[4:8]) != "ftyp" {
+		return false
+	}
+
+	return slices.Contains(heifBrands, string(b[8:12]))
+}
+
+// FromFilename returns the kind of a file from its extension.
+func FromFilename(filename string) (string, error) {
+	ext := strings.TrimPrefix(strings.ToLower(filepath.Ext(filename)), ".")
+	if ext == "" {
+		return "", errors.New("file must have name and extension")
+	}
+
+	if kind, ok := aliases[ext]; ok {
+		return kind, nil
+	}
+
+	if _, ok := contentTypes[ext]; !ok {
+		return "", fmt.Errorf("media file must be one of %s, got %s", strings.Join(Supported(), ", "), ext)
+	}
+
+	return ext, nil
+}
+
+// Supported returns the kinds which can be uploaded.
+func Supported() []string {
+	return []string{JPG, PNG, WebP, HEIC, DNG, ARW, RAF, CR3, MP4, MOV}
+}
+
+// IsVideo is true for the kinds which are played rather than shown. Their
+// thumbnails are made from a separate poster image.
+func IsVideo(kind string) bool {
+	return kind == MP4 || kind == MOV
+}
+
+// IsRAW is true for camera RAW files. These are kept as the original, but
+// thumbnails are made from their embedded JPEG preview.
+func IsRAW(kind string) bool {
+	return kind == DNG || kind == ARW || kind == RAF || kind == CR3
+}
+
+// IsImage is true for the kinds which are decoded to make thumbnails.
+func IsImage(kind string) bool {
+	return !IsVideo(kind)
+}
+
+// ContentType is the MIME type of original files of kind. Unknown kinds are
+// served as jpeg, which all legacy media are.
+func ContentType(kind string) string {
+	if contentType, ok := contentTypes[kind]; ok {
+		return contentType
+	}
+
+	return contentTypes[JPG]
+}
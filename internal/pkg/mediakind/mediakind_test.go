@@ -0,0 +1,71 @@
+package mediakind
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFromFilename(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]string{
+		"photo.jpg":       JPG,
+		"PHOTO.JPEG":      JPG,
+		"IMG_0001.HEIC":   HEIC,
+		"image.heif":      HEIC,
+		"screenshot.png":  PNG,
+		"dir/image.webp":  WebP,
+		"video.mp4":       MP4,
+		"IMG_0001.MOV":    MOV,
+		"DSC00001.ARW":    ARW,
+		"DSCF0001.RAF":    RAF,
+		"IMG_0001.CR3":    CR3,
+		"photo.dng":       DNG,
+		"a.b.c/photo.jpg": JPG,
+	}
+
+	for filename, expected := range testCases {
+		kind, err := FromFilename(filename)
+		require.NoError(t, err, filename)
+		require.Equal(t, expected, kind, filename)
+	}
+
+	_, err := FromFilename("photo")
+	require.Error(t, err)
+
+	_, err = FromFilename("notes.txt")
+	require.Error(t, err)
+}
+
+func TestContentType(t *testing.T) {
+	t.Parallel()
+
+	require.Equal(t, "image/jpeg", ContentType(JPG))
+	require.Equal(t, "image/heic", ContentType(HEIC))
+	require.Equal(t, "image/png", ContentType(PNG))
+	require.Equal(t, "image/webp", ContentType(WebP))
+	require.Equal(t, "video/mp4", ContentType(MP4))
+	require.Equal(t, "video/quicktime", ContentType(MOV))
+	require.Equal(t, "image/x-adobe-dng", ContentType(DNG))
+	require.Equal(t, "image/jpeg", ContentType(""))
+}
+
+func TestIsHEIF(t *testing.T) {
+	t.Parallel()
+
+	require.True(t, IsHEIF([]byte("\x00\x00\x00\x18ftypheic\x00\x00\x00\x00")))
+	require.True(t, IsHEIF([]byte("\x00\x00\x00\x18ftypmif1\x00\x00\x00\x00")))
+	require.False(t, IsHEIF([]byte("\x00\x00\x00\x18ftypisom\x00\x00\x00\x00")))
+	require.False(t, IsHEIF([]byte("\xff\xd8\xff\xe0")))
+}
+
+func TestIsRAW(t *testing.T) {
+	t.Parallel()
+
+	require.True(t, IsRAW(DNG))
+	require.True(t, IsRAW(CR3))
+	require.True(t, IsImage(ARW))
+	require.False(t, IsRAW(JPG))
+	require.False(t, IsRAW(HEIC))
+}
@@ -6,12 +6,15 @@ import (
 	"fmt"
 	"image"
 	_ "image/jpeg"
+	_ "image/png"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/dsoprea/go-exif/v3"
 	exifcommon "github.com/dsoprea/go-exif/v3/common"
+
+	"github.com/charlieegan3/photos/internal/pkg/mediakind"
 )
 
 type Orientation int
@@ -31,19 +34,44 @@ type Metadata struct {
 	FocalLength string
 
 	DateTime time.Time
+	// UTCCorrect is true when DateTime was read with its time zone offset,
+	// otherwise it is the local time of the camera clock
+	UTCCorrect bool
 
 	FNumber      Fraction
 	ExposureTime Fraction
 	ISOSpeed     uint16
 
+	ExposureCompensation SignedFraction
+	// ExposureProgram, MeteringMode, Flash and WhiteBalance are the names
+	// of the modes used, empty when unknown
+	ExposureProgram string
+	MeteringMode    string
+	Flash           string
+	WhiteBalance    string
+	// SubjectDistance is in metres, see SubjectDistanceInfinity
+	SubjectDistance Fraction
+
 	Latitude  Coordinate
 	Longitude Coordinate
 	Altitude  Altitude
 
+	ImageDirection Direction
+	// GPSTime is the UTC time of the GPS fix, which is not affected by the
+	// camera clock being wrong
+	GPSTime time.Time
+
 	Orientation Orientation
 
 	Height int
 	Width  int
+
+	// Duration is only set for videos
+	Duration time.Duration
+
+	// Tags are all the EXIF and XMP tags in the file, including those
+	// not extracted into the fields above
+	Tags []Tag
 }
 
 type Coordinate struct {
@@ -109,9 +137,23 @@ func (f *Fraction) ToDecimal() (float64, error) {
 
 //nolint:maintidx
 func ExtractMetadata(b []byte) (metadata Metadata, err error) {
-	rawExif, err := exif.SearchAndExtractExif(b)
+	var rawExif []byte
+	switch {
+	case mediakind.IsHEIF(b):
+		rawExif, err = heifExif(b)
+		if errors.Is(err, errNoHEIFExif) {
+			err = exif.ErrNoExif
+		}
+	case IsRAWFile(b):
+		rawExif, err = rawFileExif(b)
+	default:
+		rawExif, err = exif.SearchAndExtractExif(b)
+	}
 	if errors.Is(err, exif.ErrNoExif) {
-		return metadata, nil
+		// files without EXIF data, such as screenshots, still have a size
+		metadata.Tags = append(metadata.Tags, fileXMPTags(b)...)
+		metadata.Width, metadata.Height, err = dimensions(b)
+		return metadata, err
 	} else if err != nil {
 		return metadata, fmt.Errorf("failed to get raw exif data: %w", err)
 	}
@@ -132,7 +174,10 @@ func ExtractMetadata(b []byte) (metadata Metadata, err error) {
 	var focalLength35mm string
 
 	var offsetTimeOriginal string
+	var gps gpsTimestamp
 	cb := func(_ *exif.Ifd, ite *exif.IfdTagEntry) error {
+		extractExposureAndGPS(&metadata, &gps, ite)
+
 		if ite.TagName() == "Make" {
 			rawValue, err := ite.Value()
 			if err != nil {
@@ -433,6 +478,19 @@ func ExtractMetadata(b []byte) (metadata Metadata, err error) {
 		return metadata, fmt.Errorf("failed to walk exif data tree: %w", err)
 	}
 
+	metadata.GPSTime = gps.Time()
+
+	// the recursive walk above visits child IFDs more than once, so the tags
+	// are listed from each IFD in the index instead
+	for _, ifd := range index.Ifds {
+		for _, ite := range ifd.Entries() {
+			if tag, ok := exifTag(ifd, ite); ok {
+				metadata.Tags = append(metadata.Tags, tag)
+			}
+		}
+	}
+	metadata.Tags = append(metadata.Tags, fileXMPTags(b)...)
+
 	if focalLength != "" {
 		metadata.FocalLength = focalLength + "mm"
 
@@ -457,6 +515,7 @@ func ExtractMetadata(b []byte) (metadata Metadata, err error) {
 			duration, err := time.ParseDuration(durationString)
 			if err == nil {
 				metadata.DateTime = metadata.DateTime.Add(duration * time.Duration(offsetSign))
+				metadata.UTCCorrect = true
 			}
 		}
 	}
@@ -471,13 +530,37 @@ func ExtractMetadata(b []byte) (metadata Metadata, err error) {
 		}
 	}
 
-	m, _, err := image.Decode(bytes.NewReader(b))
+	// HEIF decoders apply the rotation in the container, so thumbnails and
+	// dimensions are already upright and the EXIF orientation must not be
+	// applied again
+	if mediakind.IsHEIF(b) {
+		metadata.Orientation = OrientationNormal
+	}
+
+	metadata.Width, metadata.Height, err = dimensions(b)
 	if err != nil {
-		return metadata, fmt.Errorf("failed to decode image for size check: %w", err)
+		return metadata, err
 	}
-	bounds := m.Bounds()
-	metadata.Width = bounds.Dx()
-	metadata.Height = bounds.Dy()
 
 	return metadata, nil
 }
+
+// dimensions returns the width and height of an image in any of the
+// registered formats. RAW files have the size of their preview, which is
+// what their thumbnails are made from.
+func dimensions(b []byte) (int, int, error) {
+	if IsRAWFile(b) {
+		preview, err := RAWPreview(b)
+		if err != nil {
+			return 0, 0, fmt.Errorf("failed to get raw preview for size check: %w", err)
+		}
+		b = preview
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(b))
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to decode image for size check: %w", err)
+	}
+
+	return config.Width, config.Height, nil
+}
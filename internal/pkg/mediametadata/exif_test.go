@@ -1,6 +1,9 @@
 package mediametadata
 
 import (
+	"bytes"
+	"image"
+	"image/png"
 	"os"
 	"testing"
 	"time"
@@ -24,6 +27,7 @@ func TestExtract(t *testing.T) {
 				Lens:        "iPhone 11 Pro Max back triple camera 6mm f/2",
 				FocalLength: "6mm (52mm in 35mm format)",
 				DateTime:    time.Date(2021, time.November, 9, 8, 33, 11, 0, time.UTC),
+				UTCCorrect:  true,
 				FNumber: Fraction{
 					Numerator: 2, Denominator: 1,
 				},
@@ -82,6 +86,7 @@ func TestExtract(t *testing.T) {
 				Lens:        "iPhone 11 Pro Max back triple camera 4.25mm f/1.8",
 				FocalLength: "4.25mm (26mm in 35mm format)",
 				DateTime:    time.Date(2022, time.July, 31, 19, 32, 0o4, 0, time.UTC),
+				UTCCorrect:  true,
 				FNumber: Fraction{
 					Numerator: 9, Denominator: 5,
 				},
@@ -168,8 +173,15 @@ func TestExtract(t *testing.T) {
 					Denominator: 70,
 				},
 				ISOSpeed: 1600,
-				Width:    1717,
-				Height:   1717,
+				ExposureCompensation: SignedFraction{
+					Numerator: 0, Denominator: 100,
+				},
+				ExposureProgram: "Program",
+				MeteringMode:    "Pattern",
+				Flash:           "Not fired",
+				WhiteBalance:    "Auto",
+				Width:           1717,
+				Height:          1717,
 			},
 		},
 		"rotated jpg": {
@@ -242,7 +254,34 @@ func TestExtract(t *testing.T) {
 			metadata, err := ExtractMetadata(b)
 			require.NoError(t, err)
 
-			td.Cmp(t, metadata, testCase.expectedMetadata)
+			// the full list of tags is checked in TestExtractMetadataTags
+			fields := td.StructFields{"Tags": td.NotEmpty()}
+
+			// cases without an exposure program predate the extraction of
+			// the exposure and GPS details, these are checked elsewhere
+			if testCase.expectedMetadata.ExposureProgram == "" {
+				for _, field := range []string{
+					"ExposureCompensation", "ExposureProgram", "MeteringMode", "Flash",
+					"WhiteBalance", "SubjectDistance", "ImageDirection", "GPSTime",
+				} {
+					fields[field] = td.Ignore()
+				}
+			}
+
+			td.Cmp(t, metadata, td.SStruct(testCase.expectedMetadata, fields))
 		})
 	}
 }
+
+func TestExtractWithoutExif(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 300, 200)))
+	require.NoError(t, err)
+
+	metadata, err := ExtractMetadata(buf.Bytes())
+	require.NoError(t, err)
+
+	td.Cmp(t, metadata, Metadata{Width: 300, Height: 200})
+}
@@ -0,0 +1,176 @@
+package mediametadata
+
+import (
+	"errors"
+	"time"
+
+	"github.com/dsoprea/go-exif/v3"
+	exifcommon "github.com/dsoprea/go-exif/v3/common"
+)
+
+// exposurePrograms are the names of the values of the ExposureProgram tag, 0
+// is not defined.
+var exposurePrograms = map[uint16]string{
+	1: "Manual",
+	2: "Program",
+	3: "Aperture priority",
+	4: "Shutter priority",
+	5: "Creative",
+	6: "Action",
+	7: "Portrait",
+	8: "Landscape",
+}
+
+// meteringModes are the names of the values of the MeteringMode tag, 0 is
+// unknown.
+var meteringModes = map[uint16]string{
+	1:   "Average",
+	2:   "Centre-weighted average",
+	3:   "Spot",
+	4:   "Multi-spot",
+	5:   "Pattern",
+	6:   "Partial",
+	255: "Other",
+}
+
+var whiteBalances = map[uint16]string{
+	0: "Auto",
+	1: "Manual",
+}
+
+const (
+	// flashFired is the bit of the Flash tag set when the flash fired
+	flashFired = 0x01
+	// flashNotPresent is the bit of the Flash tag set when there is no flash
+	flashNotPresent = 0x20
+)
+
+// SubjectDistanceInfinity is the numerator of a SubjectDistance at infinity.
+const SubjectDistanceInfinity = 0xffffffff
+
+// Direction is the direction the camera was pointing, Ref is T for true north
+// or M for magnetic north.
+type Direction struct {
+	Value Fraction
+	Ref   string
+}
+
+// SignedFraction is a fraction which may be negative, such as an exposure
+// compensation.
+type SignedFraction struct {
+	Numerator, Denominator int32
+}
+
+func (f *SignedFraction) ToDecimal() (float64, error) {
+	if f.Denominator == 0 {
+		return 0, errors.New("fraction with 0 denominator cannot be converted to decimal")
+	}
+
+	return float64(f.Numerator) / float64(f.Denominator), nil
+}
+
+// gpsTimestamp collects the GPSDateStamp and GPSTimeStamp tags, which are
+// combined once the walk of the tags is complete.
+type gpsTimestamp struct {
+	date string
+	time []exifcommon.Rational
+}
+
+// Time returns the UTC time of the GPS fix, or the zero time if either tag is
+// missing or invalid.
+func (g gpsTimestamp) Time() time.Time {
+	if g.date == "" || len(g.time) != 3 {
+		return time.Time{}
+	}
+
+	date, err := time.Parse("2006:01:02", g.date)
+	if err != nil {
+		return time.Time{}
+	}
+
+	var seconds float64
+	for i, unit := range []float64{3600, 60, 1} {
+		if g.time[i].Denominator == 0 {
+			return time.Time{}
+		}
+		seconds += float64(g.time[i].Numerator) / float64(g.time[i].Denominator) * unit
+	}
+
+	return date.Add(time.Duration(seconds * float64(time.Second)))
+}
+
+// extractExposureAndGPS sets the exposure details and GPS direction and time
+// from a tag. Unlike the core fields, values in an unexpected format are
+// skipped since these are only shown alongside the image.
+func extractExposureAndGPS(metadata *Metadata, gps *gpsTimestamp, ite *exif.IfdTagEntry) {
+	switch ite.TagName() {
+	case "ExposureBiasValue":
+		if v, ok := singleValue[exifcommon.SignedRational](ite); ok {
+			metadata.ExposureCompensation = SignedFraction{Numerator: v.Numerator, Denominator: v.Denominator}
+		}
+	case "ExposureProgram":
+		if v, ok := singleValue[uint16](ite); ok {
+			metadata.ExposureProgram = exposurePrograms[v]
+		}
+	case "MeteringMode":
+		if v, ok := singleValue[uint16](ite); ok {
+			metadata.MeteringMode = meteringModes[v]
+		}
+	case "Flash":
+		if v, ok := singleValue[uint16](ite); ok {
+			switch {
+			case v&flashFired != 0:
+				metadata.Flash = "Fired"
+			case v&flashNotPresent == 0:
+				metadata.Flash = "Not fired"
+			}
+		}
+	case "WhiteBalance":
+		if v, ok := singleValue[uint16](ite); ok {
+			metadata.WhiteBalance = whiteBalances[v]
+		}
+	case "SubjectDistance":
+		if v, ok := singleValue[exifcommon.Rational](ite); ok {
+			metadata.SubjectDistance = Fraction{Numerator: v.Numerator, Denominator: v.Denominator}
+		}
+	case "GPSImgDirectionRef":
+		if v, err := ite.Value(); err == nil {
+			if ref, ok := v.(string); ok {
+				metadata.ImageDirection.Ref = ref
+			}
+		}
+	case "GPSImgDirection":
+		if v, ok := singleValue[exifcommon.Rational](ite); ok {
+			metadata.ImageDirection.Value = Fraction{Numerator: v.Numerator, Denominator: v.Denominator}
+		}
+	case "GPSDateStamp":
+		if v, err := ite.Value(); err == nil {
+			if date, ok := v.(string); ok {
+				gps.date = date
+			}
+		}
+	case "GPSTimeStamp":
+		if v, err := ite.Value(); err == nil {
+			if hms, ok := v.([]exifcommon.Rational); ok {
+				gps.time = hms
+			}
+		}
+	}
+}
+
+// singleValue returns the value of a tag holding a single value of type T.
+func singleValue[T any](ite *exif.IfdTagEntry) (T, bool) {
+	var zero T
+
+	raw, err := ite.Value()
+	if err != nil {
+		return zero, false
+	}
+
+	values, ok := raw.([]T)
+	if !ok || len(values) != 1 {
+		return zero, false
+	}
+
+	return values[0], true
+}
@@ -0,0 +1,132 @@
+package mediametadata
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// testIFDEntry is a tag in an IFD built by testIFD, values longer than four
+// bytes are written after the IFD.
+type testIFDEntry struct {
+	tag, fieldType uint16
+	count          uint32
+	value          []byte
+}
+
+// testIFD returns a big endian IFD to be written at offset, with no next IFD.
+func testIFD(offset uint32, entries ...testIFDEntry) []byte {
+	dataOffset := offset + 2 + uint32(12*len(entries)) + 4
+
+	var ifd, data []byte
+	ifd = append(ifd, testUint16(uint16(len(entries)))...)
+	for _, e := range entries {
+		ifd = append(ifd, testUint16(e.tag)...)
+		ifd = append(ifd, testUint16(e.fieldType)...)
+		ifd = append(ifd, testUint32(e.count)...)
+
+		if len(e.value) <= 4 {
+			ifd = append(ifd, append(e.value, make([]byte, 4-len(e.value))...)...)
+			continue
+		}
+
+		ifd = append(ifd, testUint32(dataOffset+uint32(len(data)))...)
+		data = append(data, e.value...)
+	}
+	ifd = append(ifd, testUint32(0)...)
+
+	return append(ifd, data...)
+}
+
+func testRationals(values ...uint32) []byte {
+	var b []byte
+	for _, v := range values {
+		b = append(b, testUint32(v)...)
+	}
+
+	return b
+}
+
+// testExposureExif builds TIFF formatted EXIF data with the exposure details
+// in the Exif IFD and the direction, time and altitude in the GPS IFD.
+func testExposureExif() []byte {
+	const ifd0Offset = 8
+	// two entries and no data
+	const exifOffset = ifd0Offset + 2 + 2*12 + 4
+
+	exifIFD := testIFD(exifOffset,
+		// -2/3 EV
+		testIFDEntry{0x9204, 10, 1, testRationals(0xfffffffe, 3)},
+		// aperture priority
+		testIFDEntry{0x8822, 3, 1, testUint16(3)},
+		// spot
+		testIFDEntry{0x9207, 3, 1, testUint16(3)},
+		// fired, return light detected
+		testIFDEntry{0x9209, 3, 1, testUint16(0x0f)},
+		// manual
+		testIFDEntry{0xa403, 3, 1, testUint16(1)},
+		// 2.5m
+		testIFDEntry{0x9206, 5, 1, testRationals(25, 10)},
+	)
+
+	gpsOffset := exifOffset + uint32(len(exifIFD))
+	gpsIFD := testIFD(gpsOffset,
+		testIFDEntry{0x0005, 1, 1, []byte{1}},
+		testIFDEntry{0x0007, 5, 3, testRationals(13, 1, 4, 1, 305, 10)},
+		testIFDEntry{0x0010, 2, 2, []byte("M\x00")},
+		testIFDEntry{0x0011, 5, 1, testRationals(2705, 10)},
+		testIFDEntry{0x001d, 2, 11, []byte("2024:05:06\x00")},
+	)
+
+	return bytes.Join([][]byte{
+		[]byte("MM\x00*"), testUint32(ifd0Offset),
+		testIFD(ifd0Offset,
+			testIFDEntry{0x8769, 4, 1, testUint32(exifOffset)},
+			testIFDEntry{gpsIFDTag, 4, 1, testUint32(gpsOffset)},
+		),
+		exifIFD,
+		gpsIFD,
+	}, nil)
+}
+
+func TestExtractExposureAndGPS(t *testing.T) {
+	t.Parallel()
+
+	metadata, err := ExtractMetadata(testJPEG(t, append([]byte("Exif\x00\x00"), testExposureExif()...)))
+	require.NoError(t, err)
+
+	require.Equal(t, SignedFraction{Numerator: -2, Denominator: 3}, metadata.ExposureCompensation)
+	require.Equal(t, "Aperture priority", metadata.ExposureProgram)
+	require.Equal(t, "Spot", metadata.MeteringMode)
+	require.Equal(t, "Fired", metadata.Flash)
+	require.Equal(t, "Manual", metadata.WhiteBalance)
+	require.Equal(t, Fraction{Numerator: 25, Denominator: 10}, metadata.SubjectDistance)
+	require.Equal(t, Direction{Value: Fraction{Numerator: 2705, Denominator: 10}, Ref: "M"}, metadata.ImageDirection)
+	require.Equal(t, time.Date(2024, time.May, 6, 13, 4, 30, 500000000, time.UTC), metadata.GPSTime)
+	require.Equal(t, byte(1), metadata.Altitude.Ref)
+}
+
+func TestExtractFlash(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[uint16]string{
+		0x00: "Not fired",
+		0x01: "Fired",
+		0x10: "Not fired",
+		0x19: "Fired",
+		0x20: "",
+	}
+
+	for value, expected := range testCases {
+		tiff := bytes.Join([][]byte{
+			[]byte("MM\x00*"), testUint32(8),
+			testIFD(8, testIFDEntry{0x9209, 3, 1, testUint16(value)}),
+		}, nil)
+
+		metadata, err := ExtractMetadata(testJPEG(t, append([]byte("Exif\x00\x00"), tiff...)))
+		require.NoError(t, err)
+		require.Equal(t, expected, metadata.Flash, "flash %#x", value)
+	}
+}
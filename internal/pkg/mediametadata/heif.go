@@ -0,0 +1,207 @@
+package mediametadata
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// errNoHEIFExif is returned when a HEIF file has no Exif item.
+var errNoHEIFExif = errors.New("no exif item in heif file")
+
+// heifExif returns the TIFF formatted EXIF data from the Exif item of a HEIF
+// file. The item is found by type in the iinf box and its location in the
+// file is read from the iloc box.
+func heifExif(b []byte) ([]byte, error) {
+	boxes, err := readBoxes(b)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read heif boxes: %w", err)
+	}
+
+	meta, ok := findBox(boxes, "meta")
+	if !ok || len(meta.Data) < 4 {
+		return nil, errNoHEIFExif
+	}
+
+	// meta is a full box, skip the version and flags
+	metaBoxes, err := readBoxes(meta.Data[4:])
+	if err != nil {
+		return nil, fmt.Errorf("failed to read heif meta boxes: %w", err)
+	}
+
+	iinf, ok := findBox(metaBoxes, "iinf")
+	if !ok {
+		return nil, errNoHEIFExif
+	}
+
+	itemID, ok, err := exifItemID(iinf.Data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read heif item info: %w", err)
+	}
+	if !ok {
+		return nil, errNoHEIFExif
+	}
+
+	iloc, ok := findBox(metaBoxes, "iloc")
+	if !ok {
+		return nil, errors.New("heif file has no item locations")
+	}
+
+	offset, length, err := itemLocation(iloc.Data, itemID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read heif item locations: %w", err)
+	}
+
+	if offset > uint64(len(b)) || length > uint64(len(b))-offset || length < 4 {
+		return nil, errors.New("heif exif item is outside of file")
+	}
+	item := b[offset : offset+length]
+
+	// the item starts with the offset to the TIFF header
+	tiffOffset := uint64(binary.BigEndian.Uint32(item[0:4])) + 4
+	if tiffOffset >= uint64(len(item)) {
+		return nil, errors.New("heif exif item has invalid header offset")
+	}
+
+	tiff := item[tiffOffset:]
+	if !bytes.HasPrefix(tiff, []byte("II*\x00")) && !bytes.HasPrefix(tiff, []byte("MM\x00*")) {
+		return nil, errors.New("heif exif item is not in tiff format")
+	}
+
+	return tiff, nil
+}
+
+// exifItemID returns the ID of the item with type Exif from the data of an
+// iinf box.
+func exifItemID(data []byte) (uint64, bool, error) {
+	r := &reader{b: data}
+
+	version := r.uint(1)
+	r.uint(3) // flags
+
+	countSize := 2
+	if version > 0 {
+		countSize = 4
+	}
+	r.uint(countSize)
+
+	if r.err != nil {
+		return 0, false, r.err
+	}
+
+	entries, err := readBoxes(r.b)
+	if err != nil {
+		return 0, false, err
+	}
+
+	for _, entry := range entries {
+		if entry.Type != "infe" {
+			continue
+		}
+
+		er := &reader{b: entry.Data}
+		entryVersion := er.uint(1)
+		er.uint(3) // flags
+
+		// only version 2 and 3 entries have item types
+		if entryVersion < 2 {
+			continue
+		}
+
+		idSize := 2
+		if entryVersion == 3 {
+			idSize = 4
+		}
+		id := er.uint(idSize)
+		er.uint(2) // protection index
+		itemType := string(er.bytes(4))
+
+		if er.err != nil {
+			return 0, false, er.err
+		}
+
+		if itemType == "Exif" {
+			return id, true, nil
+		}
+	}
+
+	return 0, false, nil
+}
+
+// itemLocation returns the offset and length of an item in the file from the
+// data of an iloc box.
+func itemLocation(data []byte, itemID uint64) (uint64, uint64, error) {
+	r := &reader{b: data}
+
+	version := r.uint(1)
+	r.uint(3) // flags
+
+	sizes := r.uint(1)
+	offsetSize, lengthSize := int(sizes>>4), int(sizes&0xf)
+	sizes = r.uint(1)
+	baseOffsetSize, indexSize := int(sizes>>4), int(sizes&0xf)
+	if version == 0 {
+		indexSize = 0
+	}
+
+	idSize := 2
+	if version == 2 {
+		idSize = 4
+	}
+	itemCount := r.uint(idSize)
+
+	for range itemCount {
+		id := r.uint(idSize)
+
+		constructionMethod := uint64(0)
+		if version > 0 {
+			constructionMethod = r.uint(2) & 0xf
+		}
+
+		r.uint(2) // data reference index
+		baseOffset := r.uint(baseOffsetSize)
+		extentCount := r.uint(2)
+
+		var offset, length uint64
+		var overflow bool
+		for i := range extentCount {
+			r.uint(indexSize)
+			extentOffset := r.uint(offsetSize)
+			extentLength := r.uint(lengthSize)
+
+			if i == 0 {
+				// offsets can be 8 bytes, so the sum can overflow
+				overflow = extentOffset > math.MaxUint64-baseOffset
+				offset, length = baseOffset+extentOffset, extentLength
+			}
+		}
+
+		if r.err != nil {
+			return 0, 0, r.err
+		}
+
+		if id != itemID {
+			continue
+		}
+
+		if constructionMethod != 0 {
+			return 0, 0, fmt.Errorf("unsupported construction method %d", constructionMethod)
+		}
+		if extentCount != 1 {
+			return 0, 0, fmt.Errorf("unsupported extent count %d", extentCount)
+		}
+		if overflow {
+			return 0, 0, errors.New("item offset is too large")
+		}
+
+		return offset, length, nil
+	}
+
+	if r.err != nil {
+		return 0, 0, r.err
+	}
+
+	return 0, 0, fmt.Errorf("item %d has no location", itemID)
+}
@@ -0,0 +1,119 @@
+package mediametadata
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// testHEIF builds a HEIF file with an image item and, when exif is set, an
+// Exif item stored in the mdat box.
+func testHEIF(exif []byte) []byte {
+	ftyp := testBox("ftyp", []byte("heic"), testUint32(0), []byte("mif1heic"))
+
+	fullBox := []byte{0, 0, 0, 0}
+	infeVersion2 := []byte{2, 0, 0, 0}
+
+	entries := [][]byte{
+		testBox("infe", infeVersion2, testUint16(1), testUint16(0), []byte("hvc1"), []byte{0}),
+	}
+	if exif != nil {
+		entries = append(entries,
+			testBox("infe", infeVersion2, testUint16(2), testUint16(0), []byte("Exif"), []byte{0}),
+		)
+	}
+	iinf := testBox("iinf", fullBox, testUint16(uint16(len(entries))), bytes.Join(entries, nil))
+
+	// the Exif item data is a header offset followed by a prefix and the
+	// TIFF formatted data
+	item := append(testUint32(6), []byte("Exif\x00\x00")...)
+	item = append(item, exif...)
+
+	iloc := func(offset uint32) []byte {
+		return testBox("iloc",
+			fullBox,
+			[]byte{0x44, 0x00}, // 4 byte offsets and lengths, no base offset
+			testUint16(1),
+			testUint16(2),
+			testUint16(0),
+			testUint16(1),
+			testUint32(offset),
+			testUint32(uint32(len(item))),
+		)
+	}
+
+	// the size of the file before the item is the same whatever the offset
+	meta := testBox("meta", fullBox, iinf, iloc(0))
+	offset := uint32(len(ftyp) + len(meta) + 8)
+	meta = testBox("meta", fullBox, iinf, iloc(offset))
+
+	return bytes.Join([][]byte{ftyp, meta, testBox("mdat", item)}, nil)
+}
+
+func TestHEIFExif(t *testing.T) {
+	t.Parallel()
+
+	tiff := []byte("MM\x00*\x00\x00\x00\x08example")
+
+	rawExif, err := heifExif(testHEIF(tiff))
+	require.NoError(t, err)
+	require.Equal(t, tiff, rawExif)
+}
+
+func TestHEIFExifMissing(t *testing.T) {
+	t.Parallel()
+
+	_, err := heifExif(testHEIF(nil))
+	require.ErrorIs(t, err, errNoHEIFExif)
+}
+
+func TestHEIFExifTruncated(t *testing.T) {
+	t.Parallel()
+
+	b := testHEIF([]byte("II*\x00\x08\x00\x00\x00"))
+
+	_, err := heifExif(b[:len(b)-4])
+	require.Error(t, err)
+}
+
+// testHEIFLocation builds a HEIF file like testHEIF with an Exif item at the
+// given 8 byte base and extent offsets.
+func testHEIFLocation(baseOffset, extentOffset uint64) []byte {
+	ftyp := testBox("ftyp", []byte("heic"), testUint32(0), []byte("mif1heic"))
+
+	fullBox := []byte{0, 0, 0, 0}
+	iinf := testBox("iinf", fullBox, testUint16(1),
+		testBox("infe", []byte{2, 0, 0, 0}, testUint16(1), testUint16(0), []byte("Exif"), []byte{0}),
+	)
+	iloc := testBox("iloc",
+		fullBox,
+		[]byte{0x84, 0x80}, // 8 byte offsets and base offset, 4 byte lengths
+		testUint16(1),
+		testUint16(1),
+		testUint16(0),
+		binary.BigEndian.AppendUint64(nil, baseOffset),
+		testUint16(1),
+		binary.BigEndian.AppendUint64(nil, extentOffset),
+		testUint32(8),
+	)
+
+	return bytes.Join([][]byte{ftyp, testBox("meta", fullBox, iinf, iloc), testBox("mdat", make([]byte, 8))}, nil)
+}
+
+func TestHEIFExifOutOfRange(t *testing.T) {
+	t.Parallel()
+
+	// offset+length overflows
+	_, err := heifExif(testHEIFLocation(0, math.MaxUint64-1))
+	require.ErrorContains(t, err, "outside of file")
+
+	// baseOffset+extentOffset overflows
+	_, err = heifExif(testHEIFLocation(math.MaxUint64-1, 8))
+	require.ErrorContains(t, err, "too large")
+
+	_, err = ExtractMetadata(testHEIFLocation(0, math.MaxUint64-1))
+	require.Error(t, err)
+}
@@ -0,0 +1,97 @@
+package mediametadata
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// box is an ISOBMFF box, data excludes the size and type header.
+type box struct {
+	Type string
+	Data []byte
+}
+
+// readBoxes splits b into the boxes it contains.
+func readBoxes(b []byte) ([]box, error) {
+	var boxes []box
+	for len(b) > 0 {
+		if len(b) < 8 {
+			return nil, errors.New("truncated box header")
+		}
+
+		size := uint64(binary.BigEndian.Uint32(b[0:4]))
+		boxType := string(b[4:8])
+		headerSize := uint64(8)
+
+		switch size {
+		case 0:
+			// the box extends to the end of the file
+			size = uint64(len(b))
+		case 1:
+			if len(b) < 16 {
+				return nil, errors.New("truncated box header")
+			}
+			size = binary.BigEndian.Uint64(b[8:16])
+			headerSize = 16
+		}
+
+		if size < headerSize || size > uint64(len(b)) {
+			return nil, fmt.Errorf("invalid size %d for %s box", size, boxType)
+		}
+
+		boxes = append(boxes, box{Type: boxType, Data: b[headerSize:size]})
+		b = b[size:]
+	}
+
+	return boxes, nil
+}
+
+func findBox(boxes []box, boxType string) (box, bool) {
+	for _, b := range boxes {
+		if b.Type == boxType {
+			return b, true
+		}
+	}
+
+	return box{}, false
+}
+
+// reader reads big endian integers from an ISOBMFF box.
+type reader struct {
+	b   []byte
+	err error
+}
+
+func (r *reader) uint(size int) uint64 {
+	if r.err != nil {
+		return 0
+	}
+	if len(r.b) < size {
+		r.err = errors.New("unexpected end of box")
+		return 0
+	}
+
+	var v uint64
+	for _, c := range r.b[:size] {
+		v = v<<8 | uint64(c)
+	}
+	r.b = r.b[size:]
+
+	return v
+}
+
+func (r *reader) bytes(size int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if len(r.b) < size {
+		r.err = errors.New("unexpected end of box")
+		return nil
+	}
+
+	v := r.b[:size]
+	r.b = r.b[size:]
+
+	return v
+}
@@ -0,0 +1,48 @@
+package mediametadata
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func testBox(boxType string, data ...[]byte) []byte {
+	payload := bytes.Join(data, nil)
+
+	b := binary.BigEndian.AppendUint32(nil, uint32(8+len(payload)))
+	b = append(b, boxType...)
+
+	return append(b, payload...)
+}
+
+func testUint16(v uint16) []byte {
+	return binary.BigEndian.AppendUint16(nil, v)
+}
+
+func testUint32(v uint32) []byte {
+	return binary.BigEndian.AppendUint32(nil, v)
+}
+
+func TestReadBoxes(t *testing.T) {
+	t.Parallel()
+
+	b := bytes.Join([][]byte{
+		testBox("ftyp", []byte("qt  ")),
+		testBox("free"),
+		// a size of zero extends to the end of the file
+		testUint32(0), []byte("mdat"), []byte("data"),
+	}, nil)
+
+	boxes, err := readBoxes(b)
+	require.NoError(t, err)
+	require.Equal(t, []box{
+		{Type: "ftyp", Data: []byte("qt  ")},
+		{Type: "free", Data: []byte{}},
+		{Type: "mdat", Data: []byte("data")},
+	}, boxes)
+
+	_, err = readBoxes(testBox("ftyp", []byte("qt  "))[:10])
+	require.Error(t, err)
+}
@@ -0,0 +1,665 @@
+package mediametadata
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image/jpeg"
+	"slices"
+
+	"github.com/dsoprea/go-exif/v3"
+)
+
+// TIFF tags used to find the previews in RAW files.
+const (
+	compressionTag     = 0x0103
+	stripOffsetsTag    = 0x0111
+	orientationTag     = 0x0112
+	stripByteCountsTag = 0x0117
+	subIFDsTag         = 0x014a
+	jpegOffsetTag      = 0x0201
+	jpegLengthTag      = 0x0202
+	exifIFDTag         = 0x8769
+)
+
+// maxRAWIFDs limits the IFDs read from a RAW file, so that files with IFDs
+// which point to each other can't be read forever.
+const maxRAWIFDs = 32
+
+// rafMagic starts Fujifilm RAF files.
+const rafMagic = "FUJIFILMCCD-RAW "
+
+// The uuid boxes of CR3 files, one holds the metadata and thumbnail and the
+// other the preview.
+var (
+	cr3MetadataUUID = []byte{
+		0x85, 0xc0, 0xb6, 0x87, 0x82, 0x0f, 0x11, 0xe0, 0x81, 0x11, 0xf4, 0xce, 0x46, 0x2b, 0x6a, 0x48,
+	}
+	cr3PreviewUUID = []byte{
+		0xea, 0xf4, 0x2b, 0x5e, 0x1c, 0x98, 0x4b, 0x88, 0xb9, 0xfb, 0xb7, 0xdc, 0x40, 0x6e, 0x4d, 0x16,
+	}
+)
+
+// errNoRAWPreview is returned when a RAW file has no JPEG preview which can
+// be decoded.
+var errNoRAWPreview = errors.New("no jpeg preview in raw file")
+
+// IsRAWFile is true for camera RAW files. DNG and ARW files are TIFF files,
+// RAF files have their own header and CR3 files are ISOBMFF with the crx
+// brand.
+func IsRAWFile(b []byte) bool {
+	return isTIFF(b) || isRAF(b) || isCR3(b)
+}
+
+func isTIFF(b []byte) bool {
+	return bytes.HasPrefix(b, []byte("II*\x00")) || bytes.HasPrefix(b, []byte("MM\x00*"))
+}
+
+func isRAF(b []byte) bool {
+	return bytes.HasPrefix(b, []byte(rafMagic))
+}
+
+func isCR3(b []byte) bool {
+	return len(b) >= 12 && string(b[4:8]) == "ftyp" && string(b[8:12]) == "crx "
+}
+
+// RAWPreview returns the largest JPEG preview embedded in a RAW file, which
+// is used in place of the RAW data for thumbnails. Previews without EXIF
+// data are given the orientation of the RAW file so that they are shown the
+// right way up.
+func RAWPreview(b []byte) ([]byte, error) {
+	var candidates [][]byte
+	var orientation uint16
+	var err error
+
+	switch {
+	case isTIFF(b):
+		candidates, orientation, err = tiffPreviews(b)
+	case isRAF(b):
+		candidates, err = rafPreviews(b)
+	case isCR3(b):
+		candidates, orientation, err = cr3Previews(b)
+	default:
+		return nil, errors.New("file is not a supported raw format")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var preview []byte
+	var pixels int
+	for _, candidate := range candidates {
+		if !bytes.HasPrefix(candidate, []byte{0xff, 0xd8}) {
+			continue
+		}
+
+		// lossless JPEG raw data has a JPEG header too, but can't be
+		// decoded
+		config, err := jpeg.DecodeConfig(bytes.NewReader(candidate))
+		if err != nil {
+			continue
+		}
+
+		if config.Width*config.Height > pixels {
+			preview, pixels = candidate, config.Width*config.Height
+		}
+	}
+
+	if preview == nil {
+		return nil, errNoRAWPreview
+	}
+
+	return withOrientation(preview, orientation), nil
+}
+
+// rawFileExif returns the TIFF formatted EXIF data of a RAW file.
+func rawFileExif(b []byte) ([]byte, error) {
+	switch {
+	case isTIFF(b):
+		// the file is the EXIF data
+		return b, nil
+	case isRAF(b):
+		// RAF files keep their EXIF data in the JPEG preview
+		previews, err := rafPreviews(b)
+		if err != nil {
+			return nil, err
+		}
+
+		return exif.SearchAndExtractExif(previews[0])
+	case isCR3(b):
+		return cr3Exif(b)
+	}
+
+	return nil, exif.ErrNoExif
+}
+
+// tiffPreviews returns the JPEG images in the IFDs of a TIFF based RAW file,
+// and its orientation. Previews are either stored as JPEG interchange format
+// data, or as a single strip of JPEG compressed data.
+func tiffPreviews(b []byte) ([][]byte, uint16, error) {
+	t, err := newTIFF(b)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var previews [][]byte
+	var orientation uint16
+	var visited []uint32
+	queue := []uint32{t.order.Uint32(b[4:8])}
+	for len(queue) > 0 && len(visited) < maxRAWIFDs {
+		offset := queue[0]
+		queue = queue[1:]
+		if offset == 0 || slices.Contains(visited, offset) {
+			continue
+		}
+		visited = append(visited, offset)
+
+		entries, next, err := t.ifd(offset)
+		if err != nil {
+			return nil, 0, err
+		}
+		queue = append(queue, next)
+
+		values := make(map[uint16][]uint32)
+		for _, entry := range entries {
+			values[entry.Tag] = t.uints(entry)
+		}
+
+		if len(visited) == 1 && len(values[orientationTag]) == 1 {
+			orientation = uint16(values[orientationTag][0])
+		}
+
+		queue = append(queue, values[subIFDsTag]...)
+
+		if offsets, lengths := values[jpegOffsetTag], values[jpegLengthTag]; len(offsets) == 1 && len(lengths) == 1 {
+			previews = append(previews, t.slice(offsets[0], lengths[0]))
+		}
+
+		compression := values[compressionTag]
+		offsets, lengths := values[stripOffsetsTag], values[stripByteCountsTag]
+		if len(compression) == 1 && (compression[0] == 6 || compression[0] == 7) &&
+			len(offsets) == 1 && len(lengths) == 1 {
+			previews = append(previews, t.slice(offsets[0], lengths[0]))
+		}
+	}
+
+	return previews, orientation, nil
+}
+
+// rafPreviews returns the JPEG preview of a RAF file, its offset and length
+// are stored in the header.
+func rafPreviews(b []byte) ([][]byte, error) {
+	if len(b) < 92 {
+		return nil, errors.New("raf header is too short")
+	}
+
+	offset := uint64(binary.BigEndian.Uint32(b[84:88]))
+	length := uint64(binary.BigEndian.Uint32(b[88:92]))
+	if offset > uint64(len(b)) || length > uint64(len(b))-offset {
+		return nil, errors.New("raf preview is outside of file")
+	}
+
+	return [][]byte{b[offset : offset+length]}, nil
+}
+
+// cr3Previews returns the JPEG images in a CR3 file and its orientation.
+// These are the thumbnail in the metadata box, the preview in its own box,
+// and the full size JPEG stored as the first sample of a track.
+func cr3Previews(b []byte) ([][]byte, uint16, error) {
+	boxes, err := readBoxes(b)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read cr3 boxes: %w", err)
+	}
+
+	var previews [][]byte
+	var orientation uint16
+
+	metadata, err := cr3MetadataBoxes(boxes)
+	if err != nil {
+		return nil, 0, err
+	}
+	if thumbnail, ok := findBox(metadata, "THMB"); ok {
+		previews = append(previews, fromJPEGStart(thumbnail.Data))
+	}
+	if cmt1, ok := findBox(metadata, "CMT1"); ok {
+		orientation = tiffOrientation(cmt1.Data)
+	}
+
+	for _, uuid := range boxes {
+		if uuid.Type != "uuid" || !bytes.HasPrefix(uuid.Data, cr3PreviewUUID) || len(uuid.Data) < 24 {
+			continue
+		}
+
+		// the preview box follows the uuid and 8 unknown bytes
+		previewBoxes, err := readBoxes(uuid.Data[24:])
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to read cr3 preview boxes: %w", err)
+		}
+		if preview, ok := findBox(previewBoxes, "PRVW"); ok {
+			previews = append(previews, fromJPEGStart(preview.Data))
+		}
+	}
+
+	moov, _ := findBox(boxes, "moov")
+	moovBoxes, err := readBoxes(moov.Data)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read cr3 moov boxes: %w", err)
+	}
+	for _, trak := range moovBoxes {
+		if trak.Type != "trak" {
+			continue
+		}
+
+		offset, length, ok := firstSample(trak)
+		// the sample offset and length are 64 bit, so offset+length can overflow
+		if ok && offset <= uint64(len(b)) && length <= uint64(len(b))-offset {
+			previews = append(previews, b[offset:offset+length])
+		}
+	}
+
+	return previews, orientation, nil
+}
+
+// cr3MetadataBoxes returns the boxes in the Canon uuid box of the moov box,
+// which hold the EXIF data and thumbnail.
+func cr3MetadataBoxes(boxes []box) ([]box, error) {
+	moov, ok := findBox(boxes, "moov")
+	if !ok {
+		return nil, errors.New("cr3 file has no moov box")
+	}
+
+	moovBoxes, err := readBoxes(moov.Data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cr3 moov boxes: %w", err)
+	}
+
+	for _, uuid := range moovBoxes {
+		if uuid.Type == "uuid" && bytes.HasPrefix(uuid.Data, cr3MetadataUUID) {
+			metadata, err := readBoxes(uuid.Data[len(cr3MetadataUUID):])
+			if err != nil {
+				return nil, fmt.Errorf("failed to read cr3 metadata boxes: %w", err)
+			}
+
+			return metadata, nil
+		}
+	}
+
+	return nil, errors.New("cr3 file has no metadata box")
+}
+
+// firstSample returns the offset and length of the first sample of a track,
+// read from its sample size and chunk offset boxes.
+func firstSample(trak box) (uint64, uint64, bool) {
+	stbl, ok := nestedBox(trak, "mdia", "minf", "stbl")
+	if !ok {
+		return 0, 0, false
+	}
+
+	stblBoxes, err := readBoxes(stbl.Data)
+	if err != nil {
+		return 0, 0, false
+	}
+
+	stsz, ok := findBox(stblBoxes, "stsz")
+	if !ok {
+		return 0, 0, false
+	}
+	sizes := &reader{b: stsz.Data}
+	sizes.uint(4) // version and flags
+	length := sizes.uint(4)
+	count := sizes.uint(4)
+	// samples of different sizes are listed after the count
+	if length == 0 && count > 0 {
+		length = sizes.uint(4)
+	}
+
+	// chunk offsets are 64 bit in co64 boxes and 32 bit in stco boxes
+	offsets, ok := findBox(stblBoxes, "co64")
+	offsetSize := 8
+	if !ok {
+		offsets, ok = findBox(stblBoxes, "stco")
+		offsetSize = 4
+	}
+	if !ok {
+		return 0, 0, false
+	}
+
+	chunks := &reader{b: offsets.Data}
+	chunks.uint(4) // version and flags
+	var offset uint64
+	if chunks.uint(4) > 0 {
+		offset = chunks.uint(offsetSize)
+	}
+
+	return offset, length, sizes.err == nil && chunks.err == nil && offset > 0 && length > 0
+}
+
+// nestedBox returns the box found by following the path of box types from
+// parent.
+func nestedBox(parent box, path ...string) (box, bool) {
+	current := parent
+	for _, boxType := range path {
+		children, err := readBoxes(current.Data)
+		if err != nil {
+			return box{}, false
+		}
+
+		var ok bool
+		current, ok = findBox(children, boxType)
+		if !ok {
+			return box{}, false
+		}
+	}
+
+	return current, true
+}
+
+// fromJPEGStart returns b from the first JPEG start of image marker, the
+// JPEG boxes of CR3 files have a header of their own before the image.
+func fromJPEGStart(b []byte) []byte {
+	start := bytes.Index(b, []byte{0xff, 0xd8, 0xff})
+	if start < 0 {
+		return nil
+	}
+
+	return b[start:]
+}
+
+// cr3Exif joins the TIFF data of the CMT boxes of a CR3 file into a single
+// TIFF, with the Exif and GPS IFDs linked from IFD0 as they are in other
+// files.
+func cr3Exif(b []byte) ([]byte, error) {
+	boxes, err := readBoxes(b)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cr3 boxes: %w", err)
+	}
+
+	metadata, err := cr3MetadataBoxes(boxes)
+	if err != nil {
+		return nil, err
+	}
+
+	cmt1, ok := findBox(metadata, "CMT1")
+	if !ok {
+		return nil, exif.ErrNoExif
+	}
+
+	ifd0, err := firstIFD(cmt1.Data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cr3 ifd0: %w", err)
+	}
+	order := ifd0.order
+
+	ifd0.entries = slices.DeleteFunc(ifd0.entries, func(e tiffEntry) bool {
+		return e.Tag == exifIFDTag || e.Tag == gpsIFDTag
+	})
+
+	// the IFDs are linked with pointer entries in IFD0, their values are
+	// set once the size of each IFD is known
+	var children []tiffIFD
+	for _, child := range []struct {
+		boxType string
+		tag     uint16
+	}{{"CMT2", exifIFDTag}, {"CMT4", gpsIFDTag}} {
+		cmt, ok := findBox(metadata, child.boxType)
+		if !ok {
+			continue
+		}
+
+		ifd, err := firstIFD(cmt.Data)
+		// Canon writes all the CMT boxes in the same byte order, IFDs
+		// in another can't be copied as they are
+		if err != nil || ifd.order != order {
+			continue
+		}
+
+		ifd0.entries = append(ifd0.entries, tiffEntry{Tag: child.tag, Type: 4, Count: 1, Value: make([]byte, 4)})
+		children = append(children, tiffIFD{order: order, entries: ifd.entries, pointerTag: child.tag})
+	}
+	slices.SortFunc(ifd0.entries, func(a, b tiffEntry) int {
+		return int(a.Tag) - int(b.Tag)
+	})
+
+	offset := uint32(8) + ifd0.size()
+	for _, child := range children {
+		for i := range ifd0.entries {
+			if ifd0.entries[i].Tag == child.pointerTag {
+				order.PutUint32(ifd0.entries[i].Value, offset)
+			}
+		}
+		offset += child.size()
+	}
+
+	out := tiffHeader(order)
+	out = append(out, ifd0.encode(8)...)
+	for _, child := range children {
+		out = append(out, child.encode(uint32(len(out)))...)
+	}
+
+	return out, nil
+}
+
+// tiffOrientation returns the orientation in IFD0 of TIFF data, or 0 when it
+// has none.
+func tiffOrientation(b []byte) uint16 {
+	ifd0, err := firstIFD(b)
+	if err != nil {
+		return 0
+	}
+
+	for _, entry := range ifd0.entries {
+		if entry.Tag != orientationTag {
+			continue
+		}
+
+		values := tiffFile{b: b, order: ifd0.order}.uints(entry)
+		if len(values) == 1 {
+			return uint16(values[0])
+		}
+	}
+
+	return 0
+}
+
+// withOrientation adds EXIF data with the orientation to a JPEG preview
+// which has no EXIF data of its own.
+func withOrientation(preview []byte, orientation uint16) []byte {
+	if orientation <= 1 {
+		return preview
+	}
+
+	_, err := exif.SearchAndExtractExif(preview)
+	if !errors.Is(err, exif.ErrNoExif) {
+		return preview
+	}
+
+	value := make([]byte, 2)
+	binary.BigEndian.PutUint16(value, orientation)
+	ifd := tiffIFD{
+		order:   binary.BigEndian,
+		entries: []tiffEntry{{Tag: orientationTag, Type: 3, Count: 1, Value: value}},
+	}
+	segment := append([]byte("Exif\x00\x00"), tiffHeader(binary.BigEndian)...)
+	segment = append(segment, ifd.encode(8)...)
+
+	length := make([]byte, 2)
+	binary.BigEndian.PutUint16(length, uint16(len(segment)+2))
+
+	return bytes.Join([][]byte{preview[:2], {0xff, 0xe1}, length, segment, preview[2:]}, nil)
+}
+
+// tiffFile is TIFF data with its byte order.
+type tiffFile struct {
+	b     []byte
+	order binary.ByteOrder
+}
+
+func newTIFF(b []byte) (tiffFile, error) {
+	switch {
+	case bytes.HasPrefix(b, []byte("II*\x00")):
+		return tiffFile{b: b, order: binary.LittleEndian}, nil
+	case bytes.HasPrefix(b, []byte("MM\x00*")):
+		return tiffFile{b: b, order: binary.BigEndian}, nil
+	}
+
+	return tiffFile{}, errors.New("data is not in tiff format")
+}
+
+// tiffEntry is an IFD entry, Value holds the bytes of the value whether it
+// was stored in the entry or elsewhere in the file.
+type tiffEntry struct {
+	Tag   uint16
+	Type  uint16
+	Count uint32
+	Value []byte
+}
+
+// ifd returns the entries of the IFD at offset and the offset of the next
+// IFD. Entries of unknown types are skipped.
+func (t tiffFile) ifd(offset uint32) ([]tiffEntry, uint32, error) {
+	start := uint64(offset)
+	if start+2 > uint64(len(t.b)) {
+		return nil, 0, errors.New("tiff ifd is outside of data")
+	}
+
+	count := uint64(t.order.Uint16(t.b[start : start+2]))
+	end := start + 2 + count*12
+	if end+4 > uint64(len(t.b)) {
+		return nil, 0, errors.New("tiff ifd entries are outside of data")
+	}
+
+	entries := make([]tiffEntry, 0, count)
+	for i := range count {
+		raw := t.b[start+2+i*12 : start+2+(i+1)*12]
+
+		entry := tiffEntry{
+			Tag:   t.order.Uint16(raw[0:2]),
+			Type:  t.order.Uint16(raw[2:4]),
+			Count: t.order.Uint32(raw[4:8]),
+		}
+
+		typeSize, ok := tiffTypeSizes[entry.Type]
+		if !ok {
+			continue
+		}
+
+		size := uint64(typeSize) * uint64(entry.Count)
+		if size <= 4 {
+			entry.Value = raw[8 : 8+size]
+		} else {
+			valueOffset := uint64(t.order.Uint32(raw[8:12]))
+			if valueOffset+size > uint64(len(t.b)) {
+				continue
+			}
+			entry.Value = t.b[valueOffset : valueOffset+size]
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, t.order.Uint32(t.b[end : end+4]), nil
+}
+
+// uints returns the values of a SHORT or LONG entry.
+func (t tiffFile) uints(entry tiffEntry) []uint32 {
+	var values []uint32
+	switch entry.Type {
+	case 3:
+		for i := 0; i+2 <= len(entry.Value); i += 2 {
+			values = append(values, uint32(t.order.Uint16(entry.Value[i:i+2])))
+		}
+	case 4, 13:
+		for i := 0; i+4 <= len(entry.Value); i += 4 {
+			values = append(values, t.order.Uint32(entry.Value[i:i+4]))
+		}
+	}
+
+	return values
+}
+
+// slice returns the bytes at offset, or nil when they are outside of the
+// data.
+func (t tiffFile) slice(offset, length uint32) []byte {
+	end := uint64(offset) + uint64(length)
+	if end > uint64(len(t.b)) {
+		return nil
+	}
+
+	return t.b[offset:end]
+}
+
+// tiffIFD is an IFD being written to new TIFF data.
+type tiffIFD struct {
+	order   binary.ByteOrder
+	entries []tiffEntry
+	// pointerTag is the IFD0 tag which links to this IFD
+	pointerTag uint16
+}
+
+// firstIFD reads IFD0 of TIFF data.
+func firstIFD(b []byte) (tiffIFD, error) {
+	t, err := newTIFF(b)
+	if err != nil {
+		return tiffIFD{}, err
+	}
+
+	entries, _, err := t.ifd(t.order.Uint32(b[4:8]))
+	if err != nil {
+		return tiffIFD{}, err
+	}
+
+	return tiffIFD{order: t.order, entries: entries}, nil
+}
+
+// size is the number of bytes the IFD and its values take when encoded.
+func (ifd tiffIFD) size() uint32 {
+	size := 2 + 12*uint32(len(ifd.entries)) + 4
+	for _, entry := range ifd.entries {
+		if len(entry.Value) > 4 {
+			// values start on word boundaries
+			size += uint32(len(entry.Value) + len(entry.Value)%2)
+		}
+	}
+
+	return size
+}
+
+// encode returns the IFD followed by the values which don't fit in the
+// entries, for an IFD starting at offset. There is no next IFD.
+func (ifd tiffIFD) encode(offset uint32) []byte {
+	dataOffset := offset + 2 + 12*uint32(len(ifd.entries)) + 4
+	// both the little and big endian byte orders can append
+	order, _ := ifd.order.(binary.AppendByteOrder)
+
+	out := order.AppendUint16(nil, uint16(len(ifd.entries)))
+	var data []byte
+	for _, entry := range ifd.entries {
+		out = order.AppendUint16(out, entry.Tag)
+		out = order.AppendUint16(out, entry.Type)
+		out = order.AppendUint32(out, entry.Count)
+
+		if len(entry.Value) <= 4 {
+			out = append(out, entry.Value...)
+			out = append(out, make([]byte, 4-len(entry.Value))...)
+			continue
+		}
+
+		out = order.AppendUint32(out, dataOffset+uint32(len(data)))
+		data = append(data, entry.Value...)
+		if len(entry.Value)%2 == 1 {
+			data = append(data, 0)
+		}
+	}
+	out = order.AppendUint32(out, 0)
+
+	return append(out, data...)
+}
+
+func tiffHeader(order binary.ByteOrder) []byte {
+	if order == binary.LittleEndian {
+		return []byte("II*\x00\x08\x00\x00\x00")
+	}
+
+	return []byte("MM\x00*\x00\x00\x00\x08")
+}
@@ -0,0 +1,233 @@
+package mediametadata
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/jpeg"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func testSizedJPEG(t *testing.T, width, height int) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	err := jpeg.Encode(&buf, image.NewGray(image.Rect(0, 0, width, height)), nil)
+	require.NoError(t, err)
+
+	return buf.Bytes()
+}
+
+func testLong(order binary.ByteOrder, v uint32) []byte {
+	b := make([]byte, 4)
+	order.PutUint32(b, v)
+
+	return b
+}
+
+func testShort(order binary.ByteOrder, v uint16) []byte {
+	b := make([]byte, 2)
+	order.PutUint16(b, v)
+
+	return b
+}
+
+// testDNG builds a little endian TIFF RAW file with a small preview in IFD0
+// and a larger one in a sub IFD, like DNG and ARW files.
+func testDNG(t *testing.T) []byte {
+	t.Helper()
+
+	order := binary.LittleEndian
+	small := testSizedJPEG(t, 16, 8)
+	large := testSizedJPEG(t, 64, 32)
+	// lossless JPEG raw data can't be decoded and isn't a preview
+	rawData := []byte{0xff, 0xd8, 0xff, 0xc3, 0x00, 0x00}
+
+	ifd0 := tiffIFD{order: order, entries: []tiffEntry{
+		{Tag: 0x010f, Type: 2, Count: 4, Value: []byte("Foo\x00")},
+		{Tag: orientationTag, Type: 3, Count: 1, Value: testShort(order, 6)},
+		// the preview and raw data sub IFDs
+		{Tag: subIFDsTag, Type: 4, Count: 2, Value: make([]byte, 8)},
+		{Tag: jpegOffsetTag, Type: 4, Count: 1, Value: make([]byte, 4)},
+		{Tag: jpegLengthTag, Type: 4, Count: 1, Value: testLong(order, uint32(len(small)))},
+	}}
+	preview := tiffIFD{order: order, entries: []tiffEntry{
+		{Tag: compressionTag, Type: 3, Count: 1, Value: testShort(order, 7)},
+		{Tag: stripOffsetsTag, Type: 4, Count: 1, Value: make([]byte, 4)},
+		{Tag: stripByteCountsTag, Type: 4, Count: 1, Value: testLong(order, uint32(len(large)))},
+	}}
+	raw := tiffIFD{order: order, entries: []tiffEntry{
+		{Tag: compressionTag, Type: 3, Count: 1, Value: testShort(order, 7)},
+		{Tag: stripOffsetsTag, Type: 4, Count: 1, Value: make([]byte, 4)},
+		{Tag: stripByteCountsTag, Type: 4, Count: 1, Value: testLong(order, uint32(len(rawData)))},
+	}}
+
+	previewOffset := 8 + ifd0.size()
+	rawOffset := previewOffset + preview.size()
+	smallOffset := rawOffset + raw.size()
+	largeOffset := smallOffset + uint32(len(small))
+	rawDataOffset := largeOffset + uint32(len(large))
+
+	order.PutUint32(ifd0.entries[2].Value[0:4], previewOffset)
+	order.PutUint32(ifd0.entries[2].Value[4:8], rawOffset)
+	order.PutUint32(ifd0.entries[3].Value, smallOffset)
+	order.PutUint32(preview.entries[1].Value, largeOffset)
+	order.PutUint32(raw.entries[1].Value, rawDataOffset)
+
+	return bytes.Join([][]byte{
+		tiffHeader(order),
+		ifd0.encode(8),
+		preview.encode(previewOffset),
+		raw.encode(rawOffset),
+		small,
+		large,
+		rawData,
+	}, nil)
+}
+
+// testCR3 builds a CR3 file with EXIF data in the CMT boxes, a thumbnail, a
+// preview and a full size JPEG in a track.
+func testCR3(t *testing.T) []byte {
+	t.Helper()
+
+	return testCR3WithSampleOffset(t, 0)
+}
+
+// testCR3WithSampleOffset builds a CR3 file like testCR3 with the given
+// offset for the full size JPEG, 0 uses its real offset.
+func testCR3WithSampleOffset(t *testing.T, offset uint64) []byte {
+	t.Helper()
+
+	order := binary.LittleEndian
+	cmt1 := tiffIFD{order: order, entries: []tiffEntry{
+		{Tag: 0x010f, Type: 2, Count: 6, Value: []byte("Canon\x00")},
+		{Tag: orientationTag, Type: 3, Count: 1, Value: testShort(order, 8)},
+	}}
+	cmt2 := tiffIFD{order: order, entries: []tiffEntry{
+		{Tag: 0x829d, Type: 5, Count: 1, Value: append(testLong(order, 28), testLong(order, 10)...)},
+		{Tag: 0x8827, Type: 3, Count: 1, Value: testShort(order, 200)},
+	}}
+
+	ftyp := testBox("ftyp", []byte("crx "), testUint32(1), []byte("crx isom"))
+	moov := func(sampleOffset uint64) []byte {
+		return testBox("moov",
+			testBox("uuid", cr3MetadataUUID,
+				testBox("CMT1", tiffHeader(order), cmt1.encode(8)),
+				testBox("CMT2", tiffHeader(order), cmt2.encode(8)),
+				testBox("THMB", make([]byte, 16), testSizedJPEG(t, 16, 8)),
+			),
+			testBox("trak", testBox("mdia", testBox("minf", testBox("stbl",
+				testBox("stsz", testUint32(0), testUint32(0), testUint32(1), testUint32(uint32(len(testSizedJPEG(t, 64, 32))))),
+				testBox("co64", testUint32(0), testUint32(1), binary.BigEndian.AppendUint64(nil, sampleOffset)),
+			)))),
+		)
+	}
+	prvw := testBox("uuid", cr3PreviewUUID, make([]byte, 8), testBox("PRVW", make([]byte, 16), testSizedJPEG(t, 32, 16)))
+
+	// the full size JPEG is the only data in mdat
+	sampleOffset := uint64(len(ftyp)+len(moov(0))+len(prvw)) + 8
+	if offset != 0 {
+		sampleOffset = offset
+	}
+
+	return bytes.Join([][]byte{ftyp, moov(sampleOffset), prvw, testBox("mdat", testSizedJPEG(t, 64, 32))}, nil)
+}
+
+// testRAF builds a RAF file with a JPEG preview holding its EXIF data.
+func testRAF(t *testing.T) []byte {
+	t.Helper()
+
+	preview := testJPEG(t, append([]byte("Exif\x00\x00"), testGPSExif()...))
+
+	header := make([]byte, 100)
+	copy(header, rafMagic)
+	binary.BigEndian.PutUint32(header[84:88], uint32(len(header)))
+	binary.BigEndian.PutUint32(header[88:92], uint32(len(preview)))
+
+	return append(header, preview...)
+}
+
+func TestIsRAWFile(t *testing.T) {
+	t.Parallel()
+
+	require.True(t, IsRAWFile(testDNG(t)))
+	require.True(t, IsRAWFile(testCR3(t)))
+	require.True(t, IsRAWFile(testRAF(t)))
+
+	require.False(t, IsRAWFile(testJPEG(t)))
+	require.False(t, IsRAWFile([]byte("\x00\x00\x00\x18ftypheic")))
+}
+
+func TestRAWPreview(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		file        []byte
+		width       int
+		orientation Orientation
+	}{
+		"dng": {file: testDNG(t), width: 64, orientation: OrientationRotate90CCW},
+		"cr3": {file: testCR3(t), width: 64, orientation: OrientationRotate90CW},
+		"raf": {file: testRAF(t), width: 4},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			preview, err := RAWPreview(tc.file)
+			require.NoError(t, err)
+
+			metadata, err := ExtractMetadata(preview)
+			require.NoError(t, err)
+			require.Equal(t, tc.width, metadata.Width)
+			require.Equal(t, tc.orientation, metadata.Orientation)
+		})
+	}
+
+	_, err := RAWPreview([]byte("II*\x00\x08\x00\x00\x00\x00\x00\x00\x00\x00\x00"))
+	require.ErrorIs(t, err, errNoRAWPreview)
+
+	// offset+length overflows, so the sample is skipped for the PRVW preview
+	preview, err := RAWPreview(testCR3WithSampleOffset(t, math.MaxUint64-8))
+	require.NoError(t, err)
+	metadata, err := ExtractMetadata(preview)
+	require.NoError(t, err)
+	require.Equal(t, 32, metadata.Width)
+
+	raf := testRAF(t)
+	binary.BigEndian.PutUint32(raf[84:88], math.MaxUint32)
+	binary.BigEndian.PutUint32(raf[88:92], math.MaxUint32)
+	_, err = RAWPreview(raf)
+	require.Error(t, err)
+}
+
+func TestExtractRAWMetadata(t *testing.T) {
+	t.Parallel()
+
+	metadata, err := ExtractMetadata(testDNG(t))
+	require.NoError(t, err)
+	require.Equal(t, "Foo", metadata.Make)
+	require.Equal(t, OrientationRotate90CCW, metadata.Orientation)
+	require.Equal(t, 64, metadata.Width)
+	require.Equal(t, 32, metadata.Height)
+
+	metadata, err = ExtractMetadata(testCR3(t))
+	require.NoError(t, err)
+	require.Equal(t, "Canon", metadata.Make)
+	require.Equal(t, OrientationRotate90CW, metadata.Orientation)
+	require.Equal(t, uint16(200), metadata.ISOSpeed)
+	require.Equal(t, Fraction{Numerator: 28, Denominator: 10}, metadata.FNumber)
+	require.Equal(t, 64, metadata.Width)
+
+	metadata, err = ExtractMetadata(testRAF(t))
+	require.NoError(t, err)
+	require.Equal(t, "Foo", metadata.Make)
+	latitude, err := metadata.Latitude.ToDecimal()
+	require.NoError(t, err)
+	require.InDelta(t, 51.5, latitude, 0.001)
+	require.Equal(t, 4, metadata.Width)
+}
@@ -0,0 +1,393 @@
+package mediametadata
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"regexp"
+	"strings"
+
+	"github.com/charlieegan3/photos/internal/pkg/mediakind"
+)
+
+// gpsIFDTag is the IFD0 tag pointing to the GPS IFD.
+const gpsIFDTag = 0x8825
+
+// tiffTypeSizes are the sizes in bytes of the TIFF field types.
+var tiffTypeSizes = map[uint16]uint32{
+	1: 1, 2: 1, 3: 2, 4: 4, 5: 8, 6: 1, 7: 1, 8: 2, 9: 4, 10: 8, 11: 4, 12: 8, 13: 4,
+}
+
+// xmpGPSPattern matches the GPS properties of XMP packets, both as
+// attributes and as elements.
+var xmpGPSPattern = regexp.MustCompile(
+	`exif:GPS[A-Za-z]+="[^"]*"|<exif:GPS[A-Za-z]+>[^<]*</exif:GPS[A-Za-z]+>`,
+)
+
+// StripGPS returns a copy of b with the GPS location removed from its
+// metadata. The GPS values are blanked in place rather than removed so the
+// file layout is unchanged, the rest of the metadata is kept.
+func StripGPS(b []byte) ([]byte, error) {
+	out := bytes.Clone(b)
+
+	var err error
+	switch {
+	case mediakind.IsHEIF(out):
+		err = stripHEIFGPS(out)
+	case bytes.HasPrefix(out, []byte{0xff, 0xd8}):
+		err = stripJPEGGPS(out)
+	case bytes.HasPrefix(out, []byte("\x89PNG\r\n\x1a\n")):
+		err = stripPNGGPS(out)
+	case len(out) >= 12 && string(out[0:4]) == "RIFF" && string(out[8:12]) == "WEBP":
+		err = stripWebPGPS(out)
+	case len(out) >= 8 && string(out[4:8]) == "ftyp":
+		// the remaining ISOBMFF files are videos
+		err = stripVideoGPS(out)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	blankXMPGPS(out)
+
+	return out, nil
+}
+
+func stripHEIFGPS(b []byte) error {
+	tiff, err := heifExif(b)
+	if errors.Is(err, errNoHEIFExif) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	return stripTIFFGPS(tiff)
+}
+
+// stripJPEGGPS strips the GPS IFD of the Exif APP1 segments before the
+// image data.
+func stripJPEGGPS(b []byte) error {
+	offset := 2
+	for offset+4 <= len(b) {
+		if b[offset] != 0xff {
+			return errors.New("invalid jpeg segment marker")
+		}
+
+		marker := b[offset+1]
+		// start of scan, the image data follows
+		if marker == 0xda {
+			return nil
+		}
+		// markers without a length
+		if marker == 0x01 || (marker >= 0xd0 && marker <= 0xd7) || marker == 0xff {
+			offset++
+			continue
+		}
+
+		length := int(binary.BigEndian.Uint16(b[offset+2 : offset+4]))
+		end := offset + 2 + length
+		if length < 2 || end > len(b) {
+			return errors.New("invalid jpeg segment length")
+		}
+
+		segment := b[offset+4 : end]
+		if marker == 0xe1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			err := stripTIFFGPS(segment[6:])
+			if err != nil {
+				return err
+			}
+		}
+
+		offset = end
+	}
+
+	return nil
+}
+
+// stripPNGGPS strips the GPS IFD of the eXIf chunk and updates its CRC.
+func stripPNGGPS(b []byte) error {
+	offset := 8
+	for offset+12 <= len(b) {
+		length := int(binary.BigEndian.Uint32(b[offset : offset+4]))
+		end := offset + 12 + length
+		if length < 0 || end > len(b) {
+			return errors.New("invalid png chunk length")
+		}
+
+		chunkType := string(b[offset+4 : offset+8])
+		if chunkType == "eXIf" {
+			err := stripTIFFGPS(b[offset+8 : offset+8+length])
+			if err != nil {
+				return err
+			}
+
+			binary.BigEndian.PutUint32(b[end-4:end], crc32.ChecksumIEEE(b[offset+4:end-4]))
+		}
+		if chunkType == "IEND" {
+			return nil
+		}
+
+		offset = end
+	}
+
+	return nil
+}
+
+// stripWebPGPS strips the GPS IFD of the EXIF chunk.
+func stripWebPGPS(b []byte) error {
+	offset := 12
+	for offset+8 <= len(b) {
+		length := int(binary.LittleEndian.Uint32(b[offset+4 : offset+8]))
+		end := offset + 8 + length
+		if length < 0 || end > len(b) {
+			return errors.New("invalid webp chunk length")
+		}
+
+		if string(b[offset:offset+4]) == "EXIF" {
+			// some writers include the jpeg Exif header
+			data := bytes.TrimPrefix(b[offset+8:end], []byte("Exif\x00\x00"))
+
+			err := stripTIFFGPS(data)
+			if err != nil {
+				return err
+			}
+		}
+
+		// chunks are padded to an even length
+		offset = end + length%2
+	}
+
+	return nil
+}
+
+// stripTIFFGPS empties the GPS IFD of TIFF formatted EXIF data. The values
+// and entries are zeroed and the entry count set to zero, the IFD0 entry
+// pointing to it is left as is.
+func stripTIFFGPS(tiff []byte) error {
+	if len(tiff) < 8 {
+		return errors.New("exif data is too short")
+	}
+
+	var order binary.ByteOrder
+	switch string(tiff[0:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return errors.New("exif data has invalid byte order")
+	}
+
+	ifd0 := order.Uint32(tiff[4:8])
+	gpsOffset, ok, err := tiffIFDValue(tiff, order, ifd0, gpsIFDTag)
+	if err != nil || !ok {
+		return err
+	}
+
+	if uint64(gpsOffset)+2 > uint64(len(tiff)) {
+		return errors.New("exif gps ifd is outside of data")
+	}
+
+	count := uint32(order.Uint16(tiff[gpsOffset : gpsOffset+2]))
+	entriesEnd := uint64(gpsOffset) + 2 + uint64(count)*12
+	if entriesEnd > uint64(len(tiff)) {
+		return errors.New("exif gps ifd entries are outside of data")
+	}
+
+	for i := range count {
+		entry := tiff[gpsOffset+2+i*12 : gpsOffset+2+(i+1)*12]
+
+		size := tiffTypeSizes[order.Uint16(entry[2:4])] * order.Uint32(entry[4:8])
+		if size > 4 {
+			valueOffset := uint64(order.Uint32(entry[8:12]))
+			if valueOffset+uint64(size) > uint64(len(tiff)) {
+				return errors.New("exif gps value is outside of data")
+			}
+
+			clear(tiff[valueOffset : valueOffset+uint64(size)])
+		}
+
+		clear(entry)
+	}
+
+	// the next IFD offset is now read from the zeroed entries
+	order.PutUint16(tiff[gpsOffset:gpsOffset+2], 0)
+
+	return nil
+}
+
+// tiffIFDValue returns the value of a LONG tag in the IFD at offset.
+func tiffIFDValue(tiff []byte, order binary.ByteOrder, offset uint32, tag uint16) (uint32, bool, error) {
+	if uint64(offset)+2 > uint64(len(tiff)) {
+		return 0, false, errors.New("exif ifd is outside of data")
+	}
+
+	count := uint32(order.Uint16(tiff[offset : offset+2]))
+	if uint64(offset)+2+uint64(count)*12 > uint64(len(tiff)) {
+		return 0, false, errors.New("exif ifd entries are outside of data")
+	}
+
+	for i := range count {
+		entry := tiff[offset+2+i*12 : offset+2+(i+1)*12]
+		if order.Uint16(entry[0:2]) == tag {
+			return order.Uint32(entry[8:12]), true, nil
+		}
+	}
+
+	return 0, false, nil
+}
+
+// blankXMPGPS replaces the values of XMP GPS properties with spaces.
+func blankXMPGPS(b []byte) {
+	for _, match := range xmpGPSPattern.FindAllIndex(b, -1) {
+		property := b[match[0]:match[1]]
+
+		var start, end int
+		if property[0] == '<' {
+			start = bytes.IndexByte(property, '>') + 1
+			end = bytes.LastIndexByte(property, '<')
+		} else {
+			start = bytes.IndexByte(property, '"') + 1
+			end = len(property) - 1
+		}
+
+		blank(property[start:end])
+	}
+}
+
+// blank replaces b with spaces.
+func blank(b []byte) {
+	for i := range b {
+		b[i] = ' '
+	}
+}
+
+// stripVideoGPS blanks the QuickTime location values in the moov box, blank
+// locations are ignored by ExtractVideoMetadata.
+func stripVideoGPS(b []byte) error {
+	boxes, err := readBoxes(b)
+	if err != nil {
+		return fmt.Errorf("failed to read video boxes: %w", err)
+	}
+
+	moov, ok := findBox(boxes, "moov")
+	if !ok {
+		return nil
+	}
+
+	return stripMovieGPS(moov.Data)
+}
+
+func stripMovieGPS(data []byte) error {
+	boxes, err := readBoxes(data)
+	if err != nil {
+		return err
+	}
+
+	for _, b := range boxes {
+		switch b.Type {
+		case "trak":
+			err = stripMovieGPS(b.Data)
+		case "udta":
+			err = stripUserDataGPS(b.Data)
+		case "meta":
+			err = stripQuickTimeMetadataGPS(b.Data)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// stripUserDataGPS blanks the ©xyz location in a udta box.
+func stripUserDataGPS(data []byte) error {
+	boxes, err := readBoxes(data)
+	if err != nil {
+		return err
+	}
+
+	for _, b := range boxes {
+		if b.Type == "\xa9xyz" && len(b.Data) > 4 {
+			// keep the size and language
+			blank(b.Data[4:])
+		}
+		if b.Type == "meta" {
+			err = stripQuickTimeMetadataGPS(b.Data)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// stripQuickTimeMetadataGPS blanks the values of the location keys in a
+// QuickTime meta box.
+func stripQuickTimeMetadataGPS(data []byte) error {
+	if len(data) >= 8 && string(data[4:8]) != "hdlr" {
+		data = data[4:]
+	}
+
+	boxes, err := readBoxes(data)
+	if err != nil {
+		return err
+	}
+
+	keysBox, ok := findBox(boxes, "keys")
+	if !ok {
+		return nil
+	}
+	ilst, ok := findBox(boxes, "ilst")
+	if !ok {
+		return nil
+	}
+
+	r := &reader{b: keysBox.Data}
+	r.uint(4) // version and flags
+	count := r.uint(4)
+
+	var keys []string
+	for range count {
+		size := r.uint(4)
+		r.uint(4) // namespace
+		if size < 8 {
+			return errors.New("invalid key size")
+		}
+		keys = append(keys, string(r.bytes(int(size-8))))
+	}
+	if r.err != nil {
+		return r.err
+	}
+
+	items, err := readBoxes(ilst.Data)
+	if err != nil {
+		return err
+	}
+
+	for _, item := range items {
+		index := int(binary.BigEndian.Uint32([]byte(item.Type)))
+		if index < 1 || index > len(keys) || !strings.HasPrefix(keys[index-1], "com.apple.quicktime.location.") {
+			continue
+		}
+
+		itemBoxes, err := readBoxes(item.Data)
+		if err != nil {
+			return err
+		}
+
+		dataBox, ok := findBox(itemBoxes, "data")
+		if !ok || len(dataBox.Data) < 8 {
+			continue
+		}
+
+		// keep the type and locale
+		blank(dataBox.Data[8:])
+	}
+
+	return nil
+}
@@ -0,0 +1,180 @@
+package mediametadata
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"testing"
+
+	exif "github.com/dsoprea/go-exif/v3"
+	exifcommon "github.com/dsoprea/go-exif/v3/common"
+	"github.com/stretchr/testify/require"
+)
+
+// testGPSExif builds TIFF formatted EXIF data with a Make tag and a GPS IFD
+// holding a latitude.
+func testGPSExif() []byte {
+	entry := func(tag, fieldType uint16, count uint32, value []byte) []byte {
+		return bytes.Join([][]byte{testUint16(tag), testUint16(fieldType), testUint32(count), value}, nil)
+	}
+
+	return bytes.Join([][]byte{
+		[]byte("MM\x00*"), testUint32(8),
+		// IFD0 at 8
+		testUint16(2),
+		entry(0x010f, 2, 4, []byte("Foo\x00")),
+		entry(gpsIFDTag, 4, 1, testUint32(38)),
+		testUint32(0),
+		// GPS IFD at 38
+		testUint16(2),
+		entry(0x0001, 2, 2, []byte("N\x00\x00\x00")),
+		entry(0x0002, 5, 3, testUint32(68)),
+		testUint32(0),
+		// latitude at 68
+		testUint32(51), testUint32(1), testUint32(30), testUint32(1), testUint32(0), testUint32(1),
+	}, nil)
+}
+
+func exifTagNames(t *testing.T, rawExif []byte) []string {
+	t.Helper()
+
+	im, err := exifcommon.NewIfdMappingWithStandard()
+	require.NoError(t, err)
+
+	_, index, err := exif.Collect(im, exif.NewTagIndex(), rawExif)
+	require.NoError(t, err)
+
+	var names []string
+	for _, ifd := range index.Ifds {
+		for _, ite := range ifd.Entries() {
+			names = append(names, ite.TagName())
+		}
+	}
+
+	return names
+}
+
+func TestStripTIFFGPS(t *testing.T) {
+	t.Parallel()
+
+	tiff := testGPSExif()
+	require.Contains(t, exifTagNames(t, tiff), "GPSLatitude")
+
+	err := stripTIFFGPS(tiff)
+	require.NoError(t, err)
+
+	names := exifTagNames(t, tiff)
+	require.Contains(t, names, "Make")
+	require.NotContains(t, names, "GPSLatitudeRef")
+	require.NotContains(t, names, "GPSLatitude")
+
+	require.Equal(t, make([]byte, len(tiff)-38), tiff[38:])
+}
+
+func TestStripGPS(t *testing.T) {
+	t.Parallel()
+
+	xmp := []byte(`<x:xmpmeta><rdf:Description exif:GPSLatitude="51,30.0N" ` +
+		`tiff:Make="Foo"><exif:GPSLongitude>0,7.2W</exif:GPSLongitude></rdf:Description></x:xmpmeta>`)
+	blankedXMP := []byte(`<x:xmpmeta><rdf:Description exif:GPSLatitude="        " ` +
+		`tiff:Make="Foo"><exif:GPSLongitude>      </exif:GPSLongitude></rdf:Description></x:xmpmeta>`)
+
+	jpegSegment := func(marker byte, data []byte) []byte {
+		return bytes.Join([][]byte{{0xff, marker}, testUint16(uint16(len(data) + 2)), data}, nil)
+	}
+
+	jpeg := bytes.Join([][]byte{
+		{0xff, 0xd8},
+		jpegSegment(0xe1, append([]byte("Exif\x00\x00"), testGPSExif()...)),
+		jpegSegment(0xe1, append([]byte("http://ns.adobe.com/xap/1.0/\x00"), xmp...)),
+		jpegSegment(0xda, []byte("scan")),
+		[]byte("image data"),
+		{0xff, 0xd9},
+	}, nil)
+
+	stripped, err := StripGPS(jpeg)
+	require.NoError(t, err)
+	require.Len(t, stripped, len(jpeg))
+	require.NotEqual(t, jpeg, stripped, "the input should not be modified")
+
+	rawExif, err := exif.SearchAndExtractExif(stripped)
+	require.NoError(t, err)
+	require.NotContains(t, exifTagNames(t, rawExif), "GPSLatitude")
+	require.Contains(t, exifTagNames(t, rawExif), "Make")
+	require.True(t, bytes.Contains(stripped, blankedXMP))
+	require.True(t, bytes.HasSuffix(stripped, []byte("image data\xff\xd9")))
+
+	pngChunk := func(chunkType string, data []byte) []byte {
+		crc := crc32.ChecksumIEEE(append([]byte(chunkType), data...))
+		return bytes.Join([][]byte{testUint32(uint32(len(data))), []byte(chunkType), data, testUint32(crc)}, nil)
+	}
+
+	png := bytes.Join([][]byte{
+		[]byte("\x89PNG\r\n\x1a\n"),
+		pngChunk("IHDR", make([]byte, 13)),
+		pngChunk("eXIf", testGPSExif()),
+		pngChunk("IEND", nil),
+	}, nil)
+
+	stripped, err = StripGPS(png)
+	require.NoError(t, err)
+
+	exifChunk := stripped[8+25:]
+	length := binary.BigEndian.Uint32(exifChunk[0:4])
+	require.Equal(t, "eXIf", string(exifChunk[4:8]))
+	require.NotContains(t, exifTagNames(t, exifChunk[8:8+length]), "GPSLatitude")
+	require.Equal(t,
+		crc32.ChecksumIEEE(exifChunk[4:8+length]),
+		binary.BigEndian.Uint32(exifChunk[8+length:12+length]),
+	)
+
+	rawExif, err = heifExif(testHEIF(testGPSExif()))
+	require.NoError(t, err)
+	require.Contains(t, exifTagNames(t, rawExif), "GPSLatitude")
+
+	stripped, err = StripGPS(testHEIF(testGPSExif()))
+	require.NoError(t, err)
+	rawExif, err = heifExif(stripped)
+	require.NoError(t, err)
+	require.NotContains(t, exifTagNames(t, rawExif), "GPSLatitude")
+
+	stripped, err = StripGPS([]byte("no metadata"))
+	require.NoError(t, err)
+	require.Equal(t, []byte("no metadata"), stripped)
+}
+
+func TestStripGPSVideo(t *testing.T) {
+	t.Parallel()
+
+	video := bytes.Join([][]byte{
+		testBox("ftyp", []byte("qt  "), testUint32(0), []byte("qt  ")),
+		testBox("moov",
+			testMovieHeader(0, 600, 6300),
+			testTrack("vide", 1920, 1080, false),
+			testQuickTimeMetadata(
+				map[string]string{
+					"com.apple.quicktime.make":             "Apple",
+					"com.apple.quicktime.location.ISO6709": "+51.5595-000.1686+012.345/",
+				},
+				"com.apple.quicktime.location.ISO6709",
+				"com.apple.quicktime.make",
+			),
+			testBox("udta",
+				testBox("\xa9xyz", testUint16(uint16(len("-33.8568+151.2153/"))), testUint16(0x15c7),
+					[]byte("-33.8568+151.2153/")),
+			),
+		),
+		testBox("mdat", []byte("video")),
+	}, nil)
+
+	stripped, err := StripGPS(video)
+	require.NoError(t, err)
+	require.Len(t, stripped, len(video))
+	require.False(t, bytes.Contains(stripped, []byte("+51.5595-000.1686")))
+	require.False(t, bytes.Contains(stripped, []byte("-33.8568+151.2153")))
+
+	metadata, err := ExtractVideoMetadata(stripped)
+	require.NoError(t, err)
+	require.Equal(t, "Apple", metadata.Make)
+	require.Equal(t, Coordinate{}, metadata.Latitude)
+}
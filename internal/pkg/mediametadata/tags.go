@@ -0,0 +1,283 @@
+package mediametadata
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/dsoprea/go-exif/v3"
+	exifcommon "github.com/dsoprea/go-exif/v3/common"
+)
+
+// XMPIFD is used as the IFD of tags from the XMP packet.
+const XMPIFD = "XMP"
+
+// maxTagBytes is the largest byte value kept in full, larger values such as
+// maker notes and embedded thumbnails are recorded by their size only.
+const maxTagBytes = 64
+
+// Tag is a single EXIF or XMP tag. Value is a string, number or a list of
+// them, rationals are strings such as 1/250.
+type Tag struct {
+	IFD   string `json:"ifd"`
+	Name  string `json:"name"`
+	Type  string `json:"type"`
+	Value any    `json:"value"`
+}
+
+// exifTag returns the tag of an EXIF entry, tags which point to other IFDs
+// are not returned.
+func exifTag(ifd *exif.Ifd, ite *exif.IfdTagEntry) (Tag, bool) {
+	if ite.ChildIfdPath() != "" {
+		return Tag{}, false
+	}
+
+	tag := Tag{
+		IFD:  ifd.IfdIdentity().String(),
+		Name: ite.TagName(),
+		Type: ite.TagType().String(),
+	}
+
+	// undefined tags which go-exif can't decode are left without a value
+	raw, err := ite.Value()
+	if err != nil {
+		return tag, true
+	}
+
+	tag.Value = tagValue(raw)
+
+	return tag, true
+}
+
+// tagValue converts a decoded EXIF value to one which can be stored as JSON,
+// single values are not kept in a list.
+func tagValue(raw any) any {
+	switch v := raw.(type) {
+	case string:
+		return cleanString(v)
+	case []byte:
+		if len(v) > maxTagBytes {
+			return fmt.Sprintf("%d bytes", len(v))
+		}
+		ints := make([]int, len(v))
+		for i, b := range v {
+			ints[i] = int(b)
+		}
+		return single(ints)
+	case []uint16:
+		return single(v)
+	case []uint32:
+		return single(v)
+	case []int32:
+		return single(v)
+	case []float32:
+		return single(v)
+	case []float64:
+		return single(v)
+	case []exifcommon.Rational:
+		values := make([]string, len(v))
+		for i, r := range v {
+			values[i] = fmt.Sprintf("%d/%d", r.Numerator, r.Denominator)
+		}
+		return single(values)
+	case []exifcommon.SignedRational:
+		values := make([]string, len(v))
+		for i, r := range v {
+			values[i] = fmt.Sprintf("%d/%d", r.Numerator, r.Denominator)
+		}
+		return single(values)
+	case fmt.Stringer:
+		return cleanString(v.String())
+	default:
+		phrase, err := exifcommon.FormatFromType(raw, false)
+		if err != nil {
+			return nil
+		}
+		return cleanString(phrase)
+	}
+}
+
+func single[T any](values []T) any {
+	if len(values) == 1 {
+		return values[0]
+	}
+
+	return values
+}
+
+// cleanString removes the padding and NULs from EXIF strings, NULs can't be
+// stored in JSONB.
+func cleanString(s string) string {
+	return strings.TrimSpace(strings.ReplaceAll(s, "\x00", ""))
+}
+
+// xmpNamespaces are the prefixes used in tag names for common XMP namespaces,
+// others are named by their URI.
+var xmpNamespaces = map[string]string{
+	"http://purl.org/dc/elements/1.1/":                 "dc",
+	"http://ns.adobe.com/xap/1.0/":                     "xmp",
+	"http://ns.adobe.com/xap/1.0/mm/":                  "xmpMM",
+	"http://ns.adobe.com/xap/1.0/rights/":              "xmpRights",
+	"http://ns.adobe.com/exif/1.0/":                    "exif",
+	"http://ns.adobe.com/exif/1.0/aux/":                "aux",
+	"http://ns.adobe.com/tiff/1.0/":                    "tiff",
+	"http://ns.adobe.com/photoshop/1.0/":               "photoshop",
+	"http://ns.adobe.com/camera-raw-settings/1.0/":     "crs",
+	"http://ns.adobe.com/lightroom/1.0/":               "lr",
+	"http://iptc.org/std/Iptc4xmpCore/1.0/xmlns/":      "Iptc4xmpCore",
+	"http://iptc.org/std/Iptc4xmpExt/2008-02-29/":      "Iptc4xmpExt",
+	"http://ns.apple.com/faceinfo/1.0/":                "apple-fi",
+	"http://cipa.jp/exif/1.0/":                         "exifEX",
+	"http://www.w3.org/1999/02/22-rdf-syntax-ns#":      "rdf",
+	"http://ns.adobe.com/xap/1.0/sType/ResourceEvent#": "stEvt",
+	"http://ns.adobe.com/xap/1.0/sType/ResourceRef#":   "stRef",
+}
+
+const rdfNamespace = "http://www.w3.org/1999/02/22-rdf-syntax-ns#"
+
+// xmpPacket returns the first XMP packet in b, or nil if there isn't one.
+// Packets are stored as plain XML in all the supported formats so they are
+// found by searching rather than parsing each container.
+func xmpPacket(b []byte) []byte {
+	start := bytes.Index(b, []byte("<x:xmpmeta"))
+	if start < 0 {
+		return nil
+	}
+
+	end := bytes.Index(b[start:], []byte("</x:xmpmeta>"))
+	if end < 0 {
+		return nil
+	}
+
+	return b[start : start+end+len("</x:xmpmeta>")]
+}
+
+// fileXMPTags returns the tags in the XMP packet of a file. Packets which
+// can't be parsed are skipped rather than failing the extraction of the rest
+// of the metadata.
+func fileXMPTags(b []byte) []Tag {
+	packet := xmpPacket(b)
+	if packet == nil {
+		return nil
+	}
+
+	tags, err := xmpTags(packet)
+	if err != nil {
+		return nil
+	}
+
+	return tags
+}
+
+// xmpNode is an element of an XMP packet.
+type xmpNode struct {
+	XMLName  xml.Name
+	Attrs    []xml.Attr `xml:",any,attr"`
+	Text     string     `xml:",chardata"`
+	Children []xmpNode  `xml:",any"`
+}
+
+// xmpTags returns the properties in an XMP packet as tags. Structures are
+// flattened with / between the names, and the items of lists are returned
+// as a list of strings.
+func xmpTags(packet []byte) ([]Tag, error) {
+	var root xmpNode
+	decoder := xml.NewDecoder(bytes.NewReader(packet))
+	decoder.CharsetReader = func(_ string, input io.Reader) (io.Reader, error) {
+		return input, nil
+	}
+
+	err := decoder.Decode(&root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse xmp packet: %w", err)
+	}
+
+	var tags []Tag
+	var walk func(node xmpNode)
+	walk = func(node xmpNode) {
+		if node.XMLName.Space == rdfNamespace && node.XMLName.Local == "Description" {
+			tags = append(tags, xmpProperties(node, "")...)
+			return
+		}
+
+		for _, child := range node.Children {
+			walk(child)
+		}
+	}
+	walk(root)
+
+	return tags, nil
+}
+
+// xmpProperties returns the properties set as attributes and child elements
+// of an rdf:Description, or another element with properties.
+func xmpProperties(node xmpNode, prefix string) []Tag {
+	var tags []Tag
+	for _, attr := range node.Attrs {
+		if attr.Name.Space == "xmlns" || attr.Name.Space == "" || attr.Name.Space == rdfNamespace {
+			continue
+		}
+
+		tags = append(tags, Tag{
+			IFD:   XMPIFD,
+			Name:  prefix + xmpName(attr.Name),
+			Type:  "Text",
+			Value: cleanString(attr.Value),
+		})
+	}
+
+	for _, child := range node.Children {
+		tags = append(tags, xmpProperty(child, prefix+xmpName(child.XMLName))...)
+	}
+
+	return tags
+}
+
+// xmpProperty returns the tags of a property element, which holds either
+// text, a list or a structure.
+func xmpProperty(node xmpNode, name string) []Tag {
+	if len(node.Children) == 0 {
+		return append(
+			[]Tag{{IFD: XMPIFD, Name: name, Type: "Text", Value: cleanString(node.Text)}},
+			xmpProperties(xmpNode{Attrs: node.Attrs}, name+"/")...,
+		)
+	}
+
+	child := node.Children[0]
+	if child.XMLName.Space == rdfNamespace {
+		switch child.XMLName.Local {
+		case "Bag", "Seq", "Alt":
+			var items []string
+			for _, li := range child.Children {
+				items = append(items, cleanString(li.Text))
+			}
+
+			// alternatives are the same text in other languages, the first
+			// is the default
+			if child.XMLName.Local == "Alt" {
+				var value string
+				if len(items) > 0 {
+					value = items[0]
+				}
+				return []Tag{{IFD: XMPIFD, Name: name, Type: "LangAlt", Value: value}}
+			}
+
+			return []Tag{{IFD: XMPIFD, Name: name, Type: child.XMLName.Local, Value: items}}
+		case "Description":
+			return xmpProperties(child, name+"/")
+		}
+	}
+
+	return xmpProperties(node, name+"/")
+}
+
+func xmpName(name xml.Name) string {
+	prefix, ok := xmpNamespaces[name.Space]
+	if !ok {
+		prefix = name.Space
+	}
+
+	return prefix + ":" + name.Local
+}
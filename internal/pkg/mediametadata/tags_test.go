@@ -0,0 +1,98 @@
+package mediametadata
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/jpeg"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// testJPEG returns a small JPEG with the segments inserted after the start of
+// image marker.
+func testJPEG(t *testing.T, segments ...[]byte) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	err := jpeg.Encode(&buf, image.NewGray(image.Rect(0, 0, 4, 2)), nil)
+	require.NoError(t, err)
+
+	b := buf.Bytes()
+	parts := [][]byte{b[:2]}
+	for _, segment := range segments {
+		length := make([]byte, 2)
+		binary.BigEndian.PutUint16(length, uint16(len(segment)+2))
+		parts = append(parts, []byte{0xff, 0xe1}, length, segment)
+	}
+	parts = append(parts, b[2:])
+
+	return bytes.Join(parts, nil)
+}
+
+func TestExtractMetadataTags(t *testing.T) {
+	t.Parallel()
+
+	xmp := []byte(`<x:xmpmeta xmlns:x="adobe:ns:meta/">` +
+		`<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">` +
+		`<rdf:Description rdf:about="" xmlns:xmp="http://ns.adobe.com/xap/1.0/" ` +
+		`xmlns:dc="http://purl.org/dc/elements/1.1/" xmp:Rating="4">` +
+		`<dc:subject><rdf:Bag><rdf:li>sea</rdf:li><rdf:li>boat</rdf:li></rdf:Bag></dc:subject>` +
+		`<dc:description><rdf:Alt><rdf:li xml:lang="x-default">At the harbour</rdf:li></rdf:Alt></dc:description>` +
+		`</rdf:Description></rdf:RDF></x:xmpmeta>`)
+
+	b := testJPEG(t,
+		append([]byte("Exif\x00\x00"), testGPSExif()...),
+		append([]byte("http://ns.adobe.com/xap/1.0/\x00"), xmp...),
+	)
+
+	metadata, err := ExtractMetadata(b)
+	require.NoError(t, err)
+
+	require.Equal(t, "Foo", metadata.Make)
+	require.Equal(t, 4, metadata.Width)
+
+	require.Equal(t, []Tag{
+		{IFD: "IFD", Name: "Make", Type: "ASCII", Value: "Foo"},
+		{IFD: "IFD/GPSInfo", Name: "GPSLatitudeRef", Type: "ASCII", Value: "N"},
+		{IFD: "IFD/GPSInfo", Name: "GPSLatitude", Type: "RATIONAL", Value: []string{"51/1", "30/1", "0/1"}},
+		{IFD: XMPIFD, Name: "xmp:Rating", Type: "Text", Value: "4"},
+		{IFD: XMPIFD, Name: "dc:subject", Type: "Bag", Value: []string{"sea", "boat"}},
+		{IFD: XMPIFD, Name: "dc:description", Type: "LangAlt", Value: "At the harbour"},
+	}, metadata.Tags)
+}
+
+func TestXMPTags(t *testing.T) {
+	t.Parallel()
+
+	packet := xmpPacket([]byte(`junk<x:xmpmeta xmlns:x="adobe:ns:meta/">` +
+		`<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">` +
+		`<rdf:Description xmlns:Iptc4xmpCore="http://iptc.org/std/Iptc4xmpCore/1.0/xmlns/" ` +
+		`xmlns:foo="http://example.com/foo/">` +
+		`<Iptc4xmpCore:CreatorContactInfo rdf:parseType="Resource">` +
+		`<Iptc4xmpCore:CiAdrCity>London</Iptc4xmpCore:CiAdrCity>` +
+		`</Iptc4xmpCore:CreatorContactInfo>` +
+		`<foo:Bar>baz</foo:Bar>` +
+		`</rdf:Description></rdf:RDF></x:xmpmeta>junk`))
+
+	tags, err := xmpTags(packet)
+	require.NoError(t, err)
+
+	require.Equal(t, []Tag{
+		{IFD: XMPIFD, Name: "Iptc4xmpCore:CreatorContactInfo/Iptc4xmpCore:CiAdrCity", Type: "Text", Value: "London"},
+		{IFD: XMPIFD, Name: "http://example.com/foo/:Bar", Type: "Text", Value: "baz"},
+	}, tags)
+
+	require.Nil(t, xmpPacket([]byte("<x:xmpmeta>")))
+}
+
+func TestTagValue(t *testing.T) {
+	t.Parallel()
+
+	require.Equal(t, "Foo", tagValue("Foo\x00 "))
+	require.Equal(t, uint16(1), tagValue([]uint16{1}))
+	require.Equal(t, []uint16{1, 2}, tagValue([]uint16{1, 2}))
+	require.Equal(t, []int{0, 2, 3, 0}, tagValue([]byte{0, 2, 3, 0}))
+	require.Equal(t, "100 bytes", tagValue(make([]byte, 100)))
+}
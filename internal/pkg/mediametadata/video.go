@@ -0,0 +1,359 @@
+package mediametadata
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// quickTimeEpoch is the time which QuickTime and MP4 timestamps count from.
+var quickTimeEpoch = time.Date(1904, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// iso6709Pattern matches the decimal degree locations written by phones,
+// e.g. +51.5500-000.1700+012.000/
+var iso6709Pattern = regexp.MustCompile(`^([+-]\d+(?:\.\d+)?)([+-]\d+(?:\.\d+)?)([+-]\d+(?:\.\d+)?)?/?$`)
+
+// ExtractVideoMetadata reads the creation time, duration, dimensions and
+// location from the boxes of an MP4 or QuickTime file.
+func ExtractVideoMetadata(b []byte) (metadata Metadata, err error) {
+	boxes, err := readBoxes(b)
+	if err != nil {
+		return metadata, fmt.Errorf("failed to read video boxes: %w", err)
+	}
+
+	moov, ok := findBox(boxes, "moov")
+	if !ok {
+		return metadata, errors.New("video has no moov box")
+	}
+
+	moovBoxes, err := readBoxes(moov.Data)
+	if err != nil {
+		return metadata, fmt.Errorf("failed to read video moov boxes: %w", err)
+	}
+
+	mvhd, ok := findBox(moovBoxes, "mvhd")
+	if !ok {
+		return metadata, errors.New("video has no mvhd box")
+	}
+
+	metadata.DateTime, metadata.Duration, err = movieHeader(mvhd.Data)
+	if err != nil {
+		return metadata, fmt.Errorf("failed to read video movie header: %w", err)
+	}
+
+	metadata.Orientation = OrientationNormal
+	for _, trak := range moovBoxes {
+		if trak.Type != "trak" {
+			continue
+		}
+
+		width, height, isVideo, err := videoTrackSize(trak.Data)
+		if err != nil {
+			return metadata, fmt.Errorf("failed to read video track: %w", err)
+		}
+
+		if isVideo {
+			metadata.Width, metadata.Height = width, height
+			break
+		}
+	}
+
+	// phones write the make, model, location and local creation time as
+	// QuickTime metadata, older cameras use user data
+	values := map[string]string{}
+	if udta, ok := findBox(moovBoxes, "udta"); ok {
+		err = userData(udta.Data, values)
+		if err != nil {
+			return metadata, fmt.Errorf("failed to read video user data: %w", err)
+		}
+	}
+	if meta, ok := findBox(moovBoxes, "meta"); ok {
+		err = quickTimeMetadata(meta.Data, values)
+		if err != nil {
+			return metadata, fmt.Errorf("failed to read video metadata: %w", err)
+		}
+	}
+
+	metadata.Make = firstValue(values, "com.apple.quicktime.make", "\xa9mak")
+	metadata.Model = firstValue(values, "com.apple.quicktime.model", "\xa9mod")
+
+	if creationDate := values["com.apple.quicktime.creationdate"]; creationDate != "" {
+		t, err := time.Parse("2006-01-02T15:04:05-0700", creationDate)
+		if err == nil {
+			metadata.DateTime = t.UTC()
+			metadata.UTCCorrect = true
+		}
+	}
+
+	// locations are blanked by StripGPS
+	location := strings.TrimSpace(firstValue(values, "com.apple.quicktime.location.ISO6709", "\xa9xyz"))
+	if location != "" {
+		metadata.Latitude, metadata.Longitude, metadata.Altitude, err = parseISO6709(location)
+		if err != nil {
+			return metadata, fmt.Errorf("failed to parse video location: %w", err)
+		}
+	}
+
+	return metadata, nil
+}
+
+func firstValue(values map[string]string, keys ...string) string {
+	for _, key := range keys {
+		if v := values[key]; v != "" {
+			return v
+		}
+	}
+
+	return ""
+}
+
+// movieHeader returns the creation time and duration from an mvhd box.
+func movieHeader(data []byte) (time.Time, time.Duration, error) {
+	r := &reader{b: data}
+
+	version := r.uint(1)
+	r.uint(3) // flags
+
+	size := 4
+	if version == 1 {
+		size = 8
+	}
+
+	creationTime := r.uint(size)
+	r.uint(size) // modification time
+	timescale := r.uint(4)
+	duration := r.uint(size)
+
+	if r.err != nil {
+		return time.Time{}, 0, r.err
+	}
+
+	var createdAt time.Time
+	if creationTime > 0 {
+		createdAt = quickTimeEpoch.Add(time.Duration(creationTime) * time.Second)
+	}
+
+	var d time.Duration
+	if timescale > 0 {
+		d = time.Duration(float64(duration) / float64(timescale) * float64(time.Second)).Round(time.Millisecond)
+	}
+
+	return createdAt, d, nil
+}
+
+// videoTrackSize returns the display size of a trak box, and whether it is a
+// video track at all. Sizes are swapped for tracks which are rotated by a
+// quarter turn when played.
+func videoTrackSize(data []byte) (int, int, bool, error) {
+	boxes, err := readBoxes(data)
+	if err != nil {
+		return 0, 0, false, err
+	}
+
+	mdia, ok := findBox(boxes, "mdia")
+	if !ok {
+		return 0, 0, false, nil
+	}
+	mdiaBoxes, err := readBoxes(mdia.Data)
+	if err != nil {
+		return 0, 0, false, err
+	}
+	hdlr, ok := findBox(mdiaBoxes, "hdlr")
+	if !ok || len(hdlr.Data) < 12 || string(hdlr.Data[8:12]) != "vide" {
+		return 0, 0, false, nil
+	}
+
+	tkhd, ok := findBox(boxes, "tkhd")
+	if !ok {
+		return 0, 0, false, errors.New("video track has no tkhd box")
+	}
+
+	r := &reader{b: tkhd.Data}
+	version := r.uint(1)
+	r.uint(3) // flags
+
+	if version == 1 {
+		r.bytes(8 + 8 + 4 + 4 + 8) // times, track ID, reserved and duration
+	} else {
+		r.bytes(4 + 4 + 4 + 4 + 4)
+	}
+	r.bytes(8 + 2 + 2 + 2 + 2) // reserved, layer, group, volume, reserved
+
+	// the first two values of the transformation matrix are zero when the
+	// track is rotated by 90 or 270 degrees
+	a := r.uint(4)
+	r.bytes(4 * 3)
+	d := r.uint(4)
+	r.bytes(4 * 4)
+
+	// 16.16 fixed point
+	width := int(r.uint(4) >> 16)
+	height := int(r.uint(4) >> 16)
+
+	if r.err != nil {
+		return 0, 0, false, r.err
+	}
+
+	if a == 0 && d == 0 {
+		width, height = height, width
+	}
+
+	return width, height, true, nil
+}
+
+// userData adds the QuickTime text values in a udta box to values, keyed by
+// their box type, e.g. ©xyz for the location.
+func userData(data []byte, values map[string]string) error {
+	boxes, err := readBoxes(data)
+	if err != nil {
+		return err
+	}
+
+	for _, b := range boxes {
+		if b.Type[0] != 0xa9 {
+			continue
+		}
+
+		r := &reader{b: b.Data}
+		size := r.uint(2)
+		r.uint(2) // language
+		value := r.bytes(int(size))
+		if r.err != nil {
+			// some writers use the MP4 style with a data box instead
+			continue
+		}
+
+		values[b.Type] = string(value)
+	}
+
+	return nil
+}
+
+// quickTimeMetadata adds the string values of the keys and ilst boxes in a
+// QuickTime meta box to values, keyed by name.
+func quickTimeMetadata(data []byte, values map[string]string) error {
+	// the ISO meta box is a full box, the QuickTime one starts with hdlr
+	if len(data) >= 8 && string(data[4:8]) != "hdlr" {
+		data = data[4:]
+	}
+
+	boxes, err := readBoxes(data)
+	if err != nil {
+		return err
+	}
+
+	keysBox, ok := findBox(boxes, "keys")
+	if !ok {
+		return nil
+	}
+	ilst, ok := findBox(boxes, "ilst")
+	if !ok {
+		return nil
+	}
+
+	r := &reader{b: keysBox.Data}
+	r.uint(4) // version and flags
+	count := r.uint(4)
+
+	var keys []string
+	for range count {
+		size := r.uint(4)
+		r.uint(4) // namespace
+		if size < 8 {
+			return errors.New("invalid key size")
+		}
+		keys = append(keys, string(r.bytes(int(size-8))))
+	}
+	if r.err != nil {
+		return r.err
+	}
+
+	items, err := readBoxes(ilst.Data)
+	if err != nil {
+		return err
+	}
+
+	for _, item := range items {
+		// items are typed by the one based index of their key
+		index := int(uint32(item.Type[0])<<24 | uint32(item.Type[1])<<16 | uint32(item.Type[2])<<8 | uint32(item.Type[3]))
+		if index < 1 || index > len(keys) {
+			continue
+		}
+
+		itemBoxes, err := readBoxes(item.Data)
+		if err != nil {
+			return err
+		}
+
+		dataBox, ok := findBox(itemBoxes, "data")
+		// only UTF-8 values, type 1, are used
+		if !ok || len(dataBox.Data) < 8 || dataBox.Data[3] != 1 {
+			continue
+		}
+
+		values[keys[index-1]] = string(dataBox.Data[8:])
+	}
+
+	return nil
+}
+
+// parseISO6709 returns the coordinates and altitude in a location string like
+// +51.5500-000.1700+012.000/
+func parseISO6709(s string) (Coordinate, Coordinate, Altitude, error) {
+	matches := iso6709Pattern.FindStringSubmatch(s)
+	if matches == nil {
+		return Coordinate{}, Coordinate{}, Altitude{}, fmt.Errorf("unsupported location format %q", s)
+	}
+
+	latitude, err := strconv.ParseFloat(matches[1], 64)
+	if err != nil {
+		return Coordinate{}, Coordinate{}, Altitude{}, fmt.Errorf("invalid latitude: %w", err)
+	}
+	longitude, err := strconv.ParseFloat(matches[2], 64)
+	if err != nil {
+		return Coordinate{}, Coordinate{}, Altitude{}, fmt.Errorf("invalid longitude: %w", err)
+	}
+
+	var altitude Altitude
+	if matches[3] != "" {
+		value, err := strconv.ParseFloat(matches[3], 64)
+		if err != nil {
+			return Coordinate{}, Coordinate{}, Altitude{}, fmt.Errorf("invalid altitude: %w", err)
+		}
+
+		altitude.Value = decimalFraction(value, 1000)
+		if value < 0 {
+			altitude.Ref = 1
+		}
+	}
+
+	return decimalCoordinate(latitude, "N", "S"), decimalCoordinate(longitude, "E", "W"), altitude, nil
+}
+
+// decimalCoordinate converts decimal degrees into a Coordinate, which like
+// EXIF stores the sign in the reference.
+func decimalCoordinate(degrees float64, positiveRef, negativeRef string) Coordinate {
+	c := Coordinate{
+		Degrees: decimalFraction(degrees, 1000000),
+		Minutes: Fraction{Numerator: 0, Denominator: 1},
+		Seconds: Fraction{Numerator: 0, Denominator: 1},
+		Ref:     positiveRef,
+	}
+
+	if degrees < 0 {
+		c.Ref = negativeRef
+	}
+
+	return c
+}
+
+func decimalFraction(value float64, denominator uint32) Fraction {
+	return Fraction{
+		Numerator:   uint32(math.Round(math.Abs(value) * float64(denominator))),
+		Denominator: denominator,
+	}
+}
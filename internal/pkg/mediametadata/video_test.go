@@ -0,0 +1,197 @@
+package mediametadata
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/maxatome/go-testdeep/td"
+	"github.com/stretchr/testify/require"
+)
+
+// testTrack builds a trak box with the given handler type and size, rotated
+// by a quarter turn when rotated is set.
+func testTrack(handler string, width, height uint32, rotated bool) []byte {
+	matrix := [][]byte{
+		testUint32(0x00010000), testUint32(0), testUint32(0),
+		testUint32(0), testUint32(0x00010000), testUint32(0),
+		testUint32(0), testUint32(0), testUint32(0x40000000),
+	}
+	if rotated {
+		matrix[0], matrix[1] = testUint32(0), testUint32(0x00010000)
+		matrix[3], matrix[4] = testUint32(0xffff0000), testUint32(0)
+	}
+
+	tkhd := testBox("tkhd",
+		[]byte{0, 0, 0, 7},
+		make([]byte, 4*5),       // times, track ID, reserved and duration
+		make([]byte, 8+2+2+2+2), // reserved, layer, group, volume, reserved
+		bytes.Join(matrix, nil),
+		testUint32(width<<16),
+		testUint32(height<<16),
+	)
+
+	hdlr := testBox("hdlr", []byte{0, 0, 0, 0}, testUint32(0), []byte(handler), make([]byte, 12), []byte{0})
+
+	return testBox("trak", tkhd, testBox("mdia", hdlr))
+}
+
+func testMovieHeader(creationTime, timescale, duration uint32) []byte {
+	return testBox("mvhd",
+		[]byte{0, 0, 0, 0},
+		testUint32(creationTime),
+		testUint32(creationTime),
+		testUint32(timescale),
+		testUint32(duration),
+		make([]byte, 80),
+	)
+}
+
+// testQuickTimeMetadata builds a meta box with keys and ilst boxes in the
+// style used by iPhones.
+func testQuickTimeMetadata(values map[string]string, keys ...string) []byte {
+	var keyBoxes, items [][]byte
+	for i, key := range keys {
+		keyBoxes = append(keyBoxes, testUint32(uint32(8+len(key))), []byte("mdta"), []byte(key))
+
+		data := testBox("data", testUint32(1), testUint32(0), []byte(values[key]))
+		items = append(items, testBox(string(testUint32(uint32(i+1))), data))
+	}
+
+	hdlr := testBox("hdlr", []byte{0, 0, 0, 0}, testUint32(0), []byte("mdta"), make([]byte, 12), []byte{0})
+	keysBox := testBox("keys", []byte{0, 0, 0, 0}, testUint32(uint32(len(keys))), bytes.Join(keyBoxes, nil))
+
+	return testBox("meta", hdlr, keysBox, testBox("ilst", items...))
+}
+
+func TestExtractVideoMetadata(t *testing.T) {
+	t.Parallel()
+
+	// 2021-11-09 08:33:11 UTC as seconds since 1904
+	creationTime := uint32(time.Date(2021, time.November, 9, 8, 33, 11, 0, time.UTC).Sub(quickTimeEpoch) / time.Second)
+
+	testCases := map[string]struct {
+		video            []byte
+		expectedMetadata Metadata
+	}{
+		"iphone mov": {
+			video: bytes.Join([][]byte{
+				testBox("ftyp", []byte("qt  "), testUint32(0), []byte("qt  ")),
+				testBox("moov",
+					testMovieHeader(creationTime, 600, 6300),
+					testTrack("soun", 0, 0, false),
+					testTrack("vide", 1920, 1080, true),
+					testQuickTimeMetadata(
+						map[string]string{
+							"com.apple.quicktime.make":             "Apple",
+							"com.apple.quicktime.model":            "iPhone 11 Pro Max",
+							"com.apple.quicktime.creationdate":     "2021-11-09T09:33:11+0100",
+							"com.apple.quicktime.location.ISO6709": "+51.5595-000.1686+012.345/",
+						},
+						"com.apple.quicktime.location.ISO6709",
+						"com.apple.quicktime.make",
+						"com.apple.quicktime.model",
+						"com.apple.quicktime.creationdate",
+					),
+				),
+				testBox("mdat", []byte("video")),
+			}, nil),
+			expectedMetadata: Metadata{
+				Make:     "Apple",
+				Model:    "iPhone 11 Pro Max",
+				DateTime: time.Date(2021, time.November, 9, 8, 33, 11, 0, time.UTC),
+				// the creation date has an offset
+				UTCCorrect: true,
+				Latitude: Coordinate{
+					Degrees: Fraction{Numerator: 51559500, Denominator: 1000000},
+					Minutes: Fraction{Numerator: 0, Denominator: 1},
+					Seconds: Fraction{Numerator: 0, Denominator: 1},
+					Ref:     "N",
+				},
+				Longitude: Coordinate{
+					Degrees: Fraction{Numerator: 168600, Denominator: 1000000},
+					Minutes: Fraction{Numerator: 0, Denominator: 1},
+					Seconds: Fraction{Numerator: 0, Denominator: 1},
+					Ref:     "W",
+				},
+				Altitude:    Altitude{Value: Fraction{Numerator: 12345, Denominator: 1000}},
+				Orientation: OrientationNormal,
+				Width:       1080,
+				Height:      1920,
+				Duration:    10500 * time.Millisecond,
+			},
+		},
+		"mp4 with user data location": {
+			video: bytes.Join([][]byte{
+				testBox("ftyp", []byte("isom"), testUint32(0), []byte("isommp41")),
+				testBox("moov",
+					testMovieHeader(creationTime, 1000, 2000),
+					testTrack("vide", 640, 480, false),
+					testBox("udta",
+						testBox("\xa9xyz", testUint16(uint16(len("-33.8568+151.2153/"))), testUint16(0x15c7),
+							[]byte("-33.8568+151.2153/")),
+					),
+				),
+			}, nil),
+			expectedMetadata: Metadata{
+				DateTime: time.Date(2021, time.November, 9, 8, 33, 11, 0, time.UTC),
+				Latitude: Coordinate{
+					Degrees: Fraction{Numerator: 33856800, Denominator: 1000000},
+					Minutes: Fraction{Numerator: 0, Denominator: 1},
+					Seconds: Fraction{Numerator: 0, Denominator: 1},
+					Ref:     "S",
+				},
+				Longitude: Coordinate{
+					Degrees: Fraction{Numerator: 151215300, Denominator: 1000000},
+					Minutes: Fraction{Numerator: 0, Denominator: 1},
+					Seconds: Fraction{Numerator: 0, Denominator: 1},
+					Ref:     "E",
+				},
+				Orientation: OrientationNormal,
+				Width:       640,
+				Height:      480,
+				Duration:    2 * time.Second,
+			},
+		},
+	}
+
+	for description, testCase := range testCases {
+		t.Run(description, func(t *testing.T) {
+			t.Parallel()
+
+			metadata, err := ExtractVideoMetadata(testCase.video)
+			require.NoError(t, err)
+
+			td.Cmp(t, metadata, testCase.expectedMetadata)
+		})
+	}
+}
+
+func TestExtractVideoMetadataWithoutMovie(t *testing.T) {
+	t.Parallel()
+
+	_, err := ExtractVideoMetadata(testBox("ftyp", []byte("isom")))
+	require.Error(t, err)
+}
+
+func TestParseISO6709(t *testing.T) {
+	t.Parallel()
+
+	latitude, longitude, altitude, err := parseISO6709("+51.5595-000.1686-012.5/")
+	require.NoError(t, err)
+
+	lat, err := latitude.ToDecimal()
+	require.NoError(t, err)
+	require.InDelta(t, 51.5595, lat, 0.000001)
+
+	long, err := longitude.ToDecimal()
+	require.NoError(t, err)
+	require.InDelta(t, -0.1686, long, 0.000001)
+
+	alt, err := altitude.ToDecimal()
+	require.NoError(t, err)
+	require.InDelta(t, -12.5, alt, 0.000001)
+
+	_, _, _, err = parseISO6709("51°N")
+	require.Error(t, err)
+}
@@ -0,0 +1,110 @@
+package mediametadata
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ErrNoXMP is returned when there is no XMP packet in a file.
+var ErrNoXMP = errors.New("no xmp packet found")
+
+// XMP is the descriptive metadata written by editors such as Lightroom and
+// Capture One, either in the image file or a .xmp sidecar file.
+type XMP struct {
+	Title    string
+	Caption  string
+	Keywords []string
+	// Rating is the number of stars from 0 to 5, -1 is rejected
+	Rating int
+	// HasRating is set when the XMP has a rating, since 0 stars is a rating
+	HasRating bool
+}
+
+// ParseXMP reads the XMP packet embedded in an image, or the contents of a
+// sidecar file.
+func ParseXMP(b []byte) (XMP, error) {
+	packet := xmpPacket(b)
+	if packet == nil {
+		return XMP{}, ErrNoXMP
+	}
+
+	tags, err := xmpTags(packet)
+	if err != nil {
+		return XMP{}, err
+	}
+
+	return XMPFromTags(tags), nil
+}
+
+// XMPFromTags reads the descriptive metadata from the XMP tags of a media,
+// other tags are ignored.
+func XMPFromTags(tags []Tag) XMP {
+	var xmp XMP
+	for _, tag := range tags {
+		switch tag.Name {
+		case "dc:title":
+			xmp.Title, _ = tag.Value.(string)
+		case "dc:description":
+			xmp.Caption, _ = tag.Value.(string)
+		case "dc:subject":
+			switch v := tag.Value.(type) {
+			case []string:
+				xmp.Keywords = v
+			case []any:
+				// stored tags have been through JSON
+				for _, keyword := range v {
+					keyword, _ := keyword.(string)
+					xmp.Keywords = append(xmp.Keywords, keyword)
+				}
+			case string:
+				xmp.Keywords = []string{v}
+			}
+		case "xmp:Rating":
+			value, _ := tag.Value.(string)
+			// some editors write ratings as decimals
+			rating, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+			if err == nil {
+				xmp.Rating = int(math.Round(rating))
+				xmp.HasRating = true
+			}
+		}
+	}
+
+	var keywords []string
+	for _, keyword := range xmp.Keywords {
+		if keyword != "" {
+			keywords = append(keywords, keyword)
+		}
+	}
+	xmp.Keywords = keywords
+
+	return xmp
+}
+
+// MergeXMP returns the XMP of an image with a sidecar. Sidecars are written
+// by editors which leave the image unchanged, so the fields set in the
+// sidecar are used over those embedded in the image.
+func MergeXMP(embedded, sidecar XMP) XMP {
+	merged := embedded
+
+	if sidecar.Title != "" {
+		merged.Title = sidecar.Title
+	}
+
+	if sidecar.Caption != "" {
+		merged.Caption = sidecar.Caption
+	}
+
+	if len(sidecar.Keywords) > 0 {
+		merged.Keywords = sidecar.Keywords
+	}
+
+	if sidecar.HasRating {
+		merged.Rating = sidecar.Rating
+		merged.HasRating = true
+	}
+
+	return merged
+}
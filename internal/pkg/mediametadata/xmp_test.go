@@ -0,0 +1,76 @@
+package mediametadata
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseXMP(t *testing.T) {
+	t.Parallel()
+
+	sidecar := []byte(`<?xpacket begin="" id="W5M0MpCehiHzreSzNTczkc9d"?>` +
+		`<x:xmpmeta xmlns:x="adobe:ns:meta/">` +
+		`<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">` +
+		`<rdf:Description rdf:about="" xmlns:xmp="http://ns.adobe.com/xap/1.0/" ` +
+		`xmlns:dc="http://purl.org/dc/elements/1.1/" xmp:Rating="5.0">` +
+		`<dc:title><rdf:Alt><rdf:li xml:lang="x-default">Harbour</rdf:li></rdf:Alt></dc:title>` +
+		`<dc:description><rdf:Alt><rdf:li xml:lang="x-default">Boats at dawn</rdf:li>` +
+		`<rdf:li xml:lang="fr">Bateaux</rdf:li></rdf:Alt></dc:description>` +
+		`<dc:subject><rdf:Bag><rdf:li>sea</rdf:li><rdf:li> </rdf:li><rdf:li>boat</rdf:li></rdf:Bag></dc:subject>` +
+		`</rdf:Description></rdf:RDF></x:xmpmeta><?xpacket end="w"?>`)
+
+	xmp, err := ParseXMP(sidecar)
+	require.NoError(t, err)
+	require.Equal(t, XMP{
+		Title:     "Harbour",
+		Caption:   "Boats at dawn",
+		Keywords:  []string{"sea", "boat"},
+		Rating:    5,
+		HasRating: true,
+	}, xmp)
+
+	rejected := []byte(`<x:xmpmeta xmlns:x="adobe:ns:meta/">` +
+		`<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">` +
+		`<rdf:Description rdf:about="" xmlns:xmp="http://ns.adobe.com/xap/1.0/">` +
+		`<xmp:Rating>-1</xmp:Rating>` +
+		`</rdf:Description></rdf:RDF></x:xmpmeta>`)
+
+	embedded, err := ParseXMP(testJPEG(t, append([]byte("http://ns.adobe.com/xap/1.0/\x00"), rejected...)))
+	require.NoError(t, err)
+	require.Equal(t, XMP{Rating: -1, HasRating: true}, embedded)
+
+	_, err = ParseXMP(testJPEG(t))
+	require.ErrorIs(t, err, ErrNoXMP)
+}
+
+func TestMergeXMP(t *testing.T) {
+	t.Parallel()
+
+	embedded := XMP{Title: "Harbour", Caption: "Boats", Keywords: []string{"sea"}, Rating: 3, HasRating: true}
+
+	require.Equal(t, embedded, MergeXMP(embedded, XMP{}))
+	require.Equal(t,
+		XMP{Title: "Harbour", Caption: "Boats at dawn", Keywords: []string{"boat"}, Rating: 5, HasRating: true},
+		MergeXMP(embedded, XMP{Caption: "Boats at dawn", Keywords: []string{"boat"}, Rating: 5, HasRating: true}),
+	)
+
+	// a sidecar can clear the rating of the image
+	require.Equal(t,
+		XMP{Title: "Harbour", Caption: "Boats", Keywords: []string{"sea"}, Rating: 0, HasRating: true},
+		MergeXMP(embedded, XMP{HasRating: true}),
+	)
+}
+
+func TestXMPFromTags(t *testing.T) {
+	t.Parallel()
+
+	// stored tags have been through JSON, so lists are []any
+	xmp := XMPFromTags([]Tag{
+		{IFD: XMPIFD, Name: "dc:title", Type: "LangAlt", Value: "Harbour"},
+		{IFD: XMPIFD, Name: "dc:subject", Type: "Bag", Value: []any{"sea", "", "boat"}},
+		{IFD: XMPIFD, Name: "xmp:Rating", Type: "Text", Value: "0"},
+		{IFD: "IFD0", Name: "Make", Type: "ASCII", Value: "Foo"},
+	})
+	require.Equal(t, XMP{Title: "Harbour", Keywords: []string{"sea", "boat"}, HasRating: true}, xmp)
+}
@@ -2,6 +2,8 @@ package models
 
 import "time"
 
+// Activity is a recorded GPS activity, such as a run or a ride, which spans a
+// period of time and may have points recorded along the way.
 type Activity struct {
 	ID int64
 
@@ -12,9 +14,26 @@ type Activity struct {
 	StartTime time.Time
 	EndTime   time.Time
 
-	ImporterID int64
-	CallerID   int64
-
 	CreatedAt time.Time
 	UpdatedAt time.Time
 }
+
+// Point is a single location recorded as part of an activity.
+type Point struct {
+	ID int64
+
+	ActivityID int64
+
+	Timestamp time.Time
+
+	Latitude  float64
+	Longitude float64
+	Altitude  float64
+
+	Accuracy         float64
+	VerticalAccuracy float64
+
+	Velocity float64
+
+	CreatedAt time.Time
+}
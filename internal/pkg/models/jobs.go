@@ -0,0 +1,32 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	JobStatePending = "pending"
+	JobStateRunning = "running"
+	JobStateFailed  = "failed"
+)
+
+// Job is a piece of background work, such as making the thumbnails of a media.
+// Successful jobs are deleted, failed ones are kept until they are retried.
+type Job struct {
+	ID int
+
+	Kind    string
+	Payload json.RawMessage
+
+	State       string
+	Attempts    int
+	MaxAttempts int
+	LastError   string
+
+	RunAt    time.Time
+	LockedAt time.Time
+
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
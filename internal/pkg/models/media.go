@@ -2,6 +2,10 @@ package models
 
 import "time"
 
+// SubjectDistanceInfinity is the SubjectDistance of media focused at
+// infinity.
+const SubjectDistanceInfinity = -1
+
 // Media represents a media item uploaded to the system.
 type Media struct {
 	ID int
@@ -21,9 +25,31 @@ type Media struct {
 	ExposureTimeDenominator uint32
 	ISOSpeed                int
 
+	// ExposureCompensation is in stops, e.g. -0.67
+	ExposureCompensation float64
+	// ExposureProgram, MeteringMode, Flash and WhiteBalance are the names
+	// of the modes used, they are empty when unknown.
+	ExposureProgram string
+	MeteringMode    string
+	Flash           string
+	WhiteBalance    string
+	// SubjectDistance is in metres, it is zero when unknown and
+	// SubjectDistanceInfinity when focused at infinity.
+	SubjectDistance float64
+
 	Latitude  float64
 	Longitude float64
 	Altitude  float64
+	// AltitudeRef is 1 when the altitude is below sea level.
+	AltitudeRef int
+
+	// ImageDirection is the compass direction of the camera in degrees,
+	// ImageDirectionRef is T for true or M for magnetic north and is empty
+	// when the direction is unknown.
+	ImageDirection    float64
+	ImageDirectionRef string
+	// GPSTime is the time of the GPS fix in UTC, it is zero when unknown.
+	GPSTime time.Time
 
 	CreatedAt time.Time
 	UpdatedAt time.Time
@@ -44,4 +70,47 @@ type Media struct {
 	Orientation int
 
 	DisplayOffset int
+
+	// SHA256 is the hex encoded checksum of the original file, it is empty
+	// for older media which have not been backfilled.
+	SHA256 string
+
+	// Duration is the running time of videos, it is zero for images.
+	Duration time.Duration
+
+	// CropX, CropY, CropWidth and CropHeight are the part of the rotated
+	// and straightened image shown, as fractions of its size. The whole
+	// image is shown when CropWidth or CropHeight are zero.
+	CropX      float64
+	CropY      float64
+	CropWidth  float64
+	CropHeight float64
+	// Rotation turns the image clockwise by a multiple of 90 degrees after
+	// Orientation is applied, for media with incorrect EXIF orientation.
+	Rotation int
+	// Straighten turns the image clockwise by up to 45 degrees, the image is
+	// then cropped to remove the empty corners.
+	Straighten float64
+
+	// BlurHash and DominantColour are shown in place of the image while its
+	// thumbnail loads, they are made from the thumbnails so include the edits.
+	BlurHash string
+	// DominantColour is a CSS hex colour, e.g. #a0b1c2
+	DominantColour string
+
+	// PerceptualHash is used to find media which look alike, see the
+	// similarity package.
+	PerceptualHash string
+
+	// MetadataTags are all the EXIF and XMP tags read from the original
+	// file, most media fields are derived from these.
+	MetadataTags []MetadataTag
+}
+
+// MetadataTag is an EXIF or XMP tag, see mediametadata.Tag.
+type MetadataTag struct {
+	IFD   string `json:"ifd"`
+	Name  string `json:"name"`
+	Type  string `json:"type"`
+	Value any    `json:"value"`
 }
@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// ObjectChecksum records the checksum of a file in the bucket.
+type ObjectChecksum struct {
+	ID int
+
+	Key    string
+	SHA256 string
+	Size   int64
+
+	ModTime time.Time
+
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
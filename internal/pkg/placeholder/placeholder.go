@@ -0,0 +1,132 @@
+// Package placeholder makes the BlurHash and dominant colour of images, these
+// are shown in place of thumbnails while they load. A BlurHash encodes a few
+// cosine components of an image which decode to a blurred copy of it, see
+// https://blurha.sh
+package placeholder
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/png"
+	"sync"
+
+	"github.com/disintegration/imaging"
+)
+
+// hashSize is the longest side images are scaled to before they are hashed,
+// the hash only holds a few components so more detail is not needed.
+const hashSize = 64
+
+// decodeSize is the longest side of decoded placeholders, browsers smooth
+// them when scaled up.
+const decodeSize = 16
+
+// Make returns the BlurHash and dominant colour of img.
+func Make(img image.Image) (string, string, error) {
+	small := imaging.Fit(img, hashSize, hashSize, imaging.Box)
+
+	// more components are used along the longer side
+	xComponents, yComponents := 4, 3
+	if small.Bounds().Dy() > small.Bounds().Dx() {
+		xComponents, yComponents = 3, 4
+	}
+
+	hash, err := EncodeBlurHash(small, xComponents, yComponents)
+	if err != nil {
+		return "", "", err
+	}
+
+	return hash, DominantColour(small), nil
+}
+
+// DominantColour returns the most common colour of img as a CSS hex colour.
+// Similar shades are counted together and the mean of the most common is
+// used.
+func DominantColour(img image.Image) string {
+	type bucket struct {
+		count   int
+		r, g, b int
+	}
+
+	// colours are grouped on the top 3 bits of each channel
+	var buckets [512]bucket
+	var dominant *bucket
+
+	bounds := img.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			r, g, b, a := img.At(x, y).RGBA()
+			if a == 0 {
+				continue
+			}
+			r, g, b = r>>8, g>>8, b>>8
+
+			bk := &buckets[(r>>5)<<6|(g>>5)<<3|b>>5]
+			bk.count++
+			bk.r += int(r)
+			bk.g += int(g)
+			bk.b += int(b)
+
+			if dominant == nil || bk.count > dominant.count {
+				dominant = bk
+			}
+		}
+	}
+
+	if dominant == nil {
+		return ""
+	}
+
+	return fmt.Sprintf(
+		"#%02x%02x%02x",
+		dominant.r/dominant.count,
+		dominant.g/dominant.count,
+		dominant.b/dominant.count,
+	)
+}
+
+// dataURIKey identifies a decoded placeholder in dataURIs.
+type dataURIKey struct {
+	hash          string
+	width, height int
+}
+
+// dataURIs caches the data URIs of placeholders since they are rendered on
+// every page. There is an entry for each media, which are small enough for
+// them all to be kept.
+var dataURIs sync.Map
+
+// DataURI returns a BlurHash decoded as a PNG data URI, the image has the
+// aspect ratio of a width by height image.
+func DataURI(hash string, width, height int) (string, error) {
+	w, h := decodeSize, decodeSize
+	switch {
+	case width > height && width > 0:
+		h = max(decodeSize*height/width, 1)
+	case height > width && height > 0:
+		w = max(decodeSize*width/height, 1)
+	}
+
+	key := dataURIKey{hash: hash, width: w, height: h}
+	if uri, ok := dataURIs.Load(key); ok {
+		return uri.(string), nil
+	}
+
+	img, err := DecodeBlurHash(hash, w, h)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	err = png.Encode(&buf, img)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode placeholder: %w", err)
+	}
+
+	uri := "data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes())
+	dataURIs.Store(key, uri)
+
+	return uri, nil
+}
@@ -0,0 +1,122 @@
+package placeholder
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// testImage is red on the left and blue on the right quarter.
+func testImage(width, height int) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := range height {
+		for x := range width {
+			c := color.RGBA{R: 200, G: 10, B: 10, A: 255}
+			if x >= width*3/4 {
+				c = color.RGBA{R: 10, G: 10, B: 200, A: 255}
+			}
+			img.Set(x, y, c)
+		}
+	}
+
+	return img
+}
+
+func TestBlurHash(t *testing.T) {
+	t.Parallel()
+
+	hash, err := EncodeBlurHash(testImage(40, 30), 4, 3)
+	require.NoError(t, err)
+	require.Len(t, hash, 4+2*4*3)
+
+	img, err := DecodeBlurHash(hash, 40, 30)
+	require.NoError(t, err)
+	require.Equal(t, image.Rect(0, 0, 40, 30), img.Bounds())
+
+	// the blurred copy keeps the red and blue sides
+	left := img.NRGBAAt(5, 15)
+	require.Greater(t, left.R, left.B)
+	right := img.NRGBAAt(38, 15)
+	require.Greater(t, right.B, right.R)
+
+	// a single component is the mean colour
+	solid := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	for i := 0; i < len(solid.Pix); i += 4 {
+		copy(solid.Pix[i:], []byte{255, 128, 0, 255})
+	}
+	hash, err = EncodeBlurHash(solid, 1, 1)
+	require.NoError(t, err)
+	img, err = DecodeBlurHash(hash, 2, 2)
+	require.NoError(t, err)
+	require.Equal(t, color.NRGBA{R: 255, G: 128, A: 255}, img.NRGBAAt(1, 1))
+
+	for _, invalid := range []string{"", "00", "L00000", "00~~~!"} {
+		_, err = DecodeBlurHash(invalid, 2, 2)
+		require.Error(t, err, invalid)
+	}
+
+	_, err = EncodeBlurHash(solid, 0, 10)
+	require.Error(t, err)
+}
+
+func TestMake(t *testing.T) {
+	t.Parallel()
+
+	hash, colour, err := Make(testImage(400, 300))
+	require.NoError(t, err)
+	require.Equal(t, "#c80a0a", colour)
+
+	// landscape images use 4 by 3 components, portrait 3 by 4
+	require.Len(t, hash, 4+2*4*3)
+	require.Equal(t, encodeBase83(3+2*9, 1), hash[0:1])
+
+	hash, _, err = Make(testImage(300, 400))
+	require.NoError(t, err)
+	require.Equal(t, encodeBase83(2+3*9, 1), hash[0:1])
+}
+
+func TestDataURI(t *testing.T) {
+	t.Parallel()
+
+	hash, _, err := Make(testImage(400, 300))
+	require.NoError(t, err)
+
+	for _, tc := range []struct {
+		width, height int
+		expected      image.Rectangle
+	}{
+		{width: 4000, height: 3000, expected: image.Rect(0, 0, 16, 12)},
+		{width: 3000, height: 4000, expected: image.Rect(0, 0, 12, 16)},
+		{width: 0, height: 0, expected: image.Rect(0, 0, 16, 16)},
+	} {
+		uri, err := DataURI(hash, tc.width, tc.height)
+		require.NoError(t, err)
+
+		data, found := strings.CutPrefix(uri, "data:image/png;base64,")
+		require.True(t, found)
+		decoded, err := base64.StdEncoding.DecodeString(data)
+		require.NoError(t, err)
+
+		img, err := png.Decode(bytes.NewReader(decoded))
+		require.NoError(t, err)
+		require.Equal(t, tc.expected, img.Bounds())
+	}
+
+	// decoded placeholders are cached for the same hash and size
+	uri, err := DataURI(hash, 4000, 3000)
+	require.NoError(t, err)
+	cached, ok := dataURIs.Load(dataURIKey{hash: hash, width: 16, height: 12})
+	require.True(t, ok)
+	require.Equal(t, uri, cached)
+
+	_, err = DataURI("invalid", 1, 1)
+	require.Error(t, err)
+	_, ok = dataURIs.Load(dataURIKey{hash: "invalid", width: 16, height: 16})
+	require.False(t, ok)
+}
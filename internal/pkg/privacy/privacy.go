@@ -0,0 +1,106 @@
+package privacy
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+const (
+	// ActionSnap shows coordinates inside a zone as the centre of the zone.
+	ActionSnap = "snap"
+	// ActionOmit hides coordinates inside a zone.
+	ActionOmit = "omit"
+)
+
+// earthRadius is the mean radius of the earth in metres.
+const earthRadius = 6371000.0
+
+// Zone is a sensitive area, such as a home, where exact coordinates are not
+// shown publicly. Admin pages always show exact coordinates.
+type Zone struct {
+	Name      string  `mapstructure:"name"`
+	Latitude  float64 `mapstructure:"latitude"`
+	Longitude float64 `mapstructure:"longitude"`
+	// Radius is the size of the zone in metres.
+	Radius float64 `mapstructure:"radius"`
+	Action string  `mapstructure:"action"`
+}
+
+// Zones is the registry of privacy zones, it is replaced with the configured
+// zones on start up.
+var Zones []Zone
+
+// ParseZones validates configured zones and sets the default action.
+func ParseZones(zones []Zone) ([]Zone, error) {
+	parsed := make([]Zone, 0, len(zones))
+	for _, z := range zones {
+		if z.Name == "" {
+			return nil, errors.New("zone name is required")
+		}
+
+		if z.Latitude < -90 || z.Latitude > 90 || z.Longitude < -180 || z.Longitude > 180 {
+			return nil, fmt.Errorf("zone %q has invalid coordinates", z.Name)
+		}
+
+		if z.Radius <= 0 {
+			return nil, fmt.Errorf("zone %q must have a positive radius", z.Name)
+		}
+
+		if z.Action == "" {
+			z.Action = ActionSnap
+		}
+		if z.Action != ActionSnap && z.Action != ActionOmit {
+			return nil, fmt.Errorf("zone %q action must be %q or %q", z.Name, ActionSnap, ActionOmit)
+		}
+
+		parsed = append(parsed, z)
+	}
+
+	return parsed, nil
+}
+
+// Contains returns true if the point is inside the zone.
+func (z Zone) Contains(latitude, longitude float64) bool {
+	return Distance(z.Latitude, z.Longitude, latitude, longitude) <= z.Radius
+}
+
+// Find returns the first of Zones containing the point.
+func Find(latitude, longitude float64) (Zone, bool) {
+	for _, z := range Zones {
+		if z.Contains(latitude, longitude) {
+			return z, true
+		}
+	}
+
+	return Zone{}, false
+}
+
+// Public returns the coordinates that can be shown publicly for a point.
+// Points in a snap zone are moved to its centre and ok is false for points
+// in an omit zone.
+func Public(latitude, longitude float64) (float64, float64, bool) {
+	z, found := Find(latitude, longitude)
+	if !found {
+		return latitude, longitude, true
+	}
+
+	if z.Action == ActionOmit {
+		return 0, 0, false
+	}
+
+	return z.Latitude, z.Longitude, true
+}
+
+// Distance is the great circle distance in metres between two points.
+func Distance(lat1, lon1, lat2, lon2 float64) float64 {
+	phi1 := lat1 * math.Pi / 180
+	phi2 := lat2 * math.Pi / 180
+	dPhi := (lat2 - lat1) * math.Pi / 180
+	dLambda := (lon2 - lon1) * math.Pi / 180
+
+	a := math.Sin(dPhi/2)*math.Sin(dPhi/2) +
+		math.Cos(phi1)*math.Cos(phi2)*math.Sin(dLambda/2)*math.Sin(dLambda/2)
+
+	return 2 * earthRadius * math.Asin(math.Min(1, math.Sqrt(a)))
+}
@@ -0,0 +1,82 @@
+package privacy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseZones(t *testing.T) {
+	t.Parallel()
+
+	zones, err := ParseZones([]Zone{
+		{Name: "home", Latitude: 51.5, Longitude: -0.12, Radius: 500},
+		{Name: "work", Latitude: 51.52, Longitude: -0.08, Radius: 200, Action: ActionOmit},
+	})
+	require.NoError(t, err)
+	require.Equal(t, []Zone{
+		{Name: "home", Latitude: 51.5, Longitude: -0.12, Radius: 500, Action: ActionSnap},
+		{Name: "work", Latitude: 51.52, Longitude: -0.08, Radius: 200, Action: ActionOmit},
+	}, zones)
+
+	testCases := map[string][]Zone{
+		"no name":        {{Latitude: 51.5, Longitude: -0.12, Radius: 500}},
+		"bad latitude":   {{Name: "home", Latitude: 91, Longitude: -0.12, Radius: 500}},
+		"bad longitude":  {{Name: "home", Latitude: 51.5, Longitude: 181, Radius: 500}},
+		"no radius":      {{Name: "home", Latitude: 51.5, Longitude: -0.12}},
+		"unknown action": {{Name: "home", Latitude: 51.5, Longitude: -0.12, Radius: 500, Action: "blur"}},
+	}
+
+	for description, zones := range testCases {
+		t.Run(description, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := ParseZones(zones)
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestDistance(t *testing.T) {
+	t.Parallel()
+
+	// London to Paris is about 344km
+	require.InDelta(t, 343500, Distance(51.5074, -0.1278, 48.8566, 2.3522), 1000)
+	require.InDelta(t, 0, Distance(51.5, -0.12, 51.5, -0.12), 0.001)
+}
+
+func TestZoneContains(t *testing.T) {
+	t.Parallel()
+
+	z := Zone{Name: "home", Latitude: 51.5, Longitude: -0.12, Radius: 500}
+
+	require.True(t, z.Contains(51.5, -0.12))
+	// about 330m north
+	require.True(t, z.Contains(51.503, -0.12))
+	// about 1.1km north
+	require.False(t, z.Contains(51.51, -0.12))
+}
+
+// TestPublic is not parallel since it replaces the global Zones.
+func TestPublic(t *testing.T) {
+	previous := Zones
+	t.Cleanup(func() { Zones = previous })
+
+	Zones = []Zone{
+		{Name: "home", Latitude: 51.5, Longitude: -0.12, Radius: 500, Action: ActionSnap},
+		{Name: "work", Latitude: 51.52, Longitude: -0.08, Radius: 200, Action: ActionOmit},
+	}
+
+	lat, lon, ok := Public(51.501, -0.121)
+	require.True(t, ok)
+	require.InDelta(t, 51.5, lat, 0.0000001)
+	require.InDelta(t, -0.12, lon, 0.0000001)
+
+	_, _, ok = Public(51.52, -0.0801)
+	require.False(t, ok)
+
+	lat, lon, ok = Public(48.8566, 2.3522)
+	require.True(t, ok)
+	require.InDelta(t, 48.8566, lat, 0.0000001)
+	require.InDelta(t, 2.3522, lon, 0.0000001)
+}
@@ -0,0 +1,109 @@
+package route
+
+import (
+	"math"
+
+	"github.com/charlieegan3/photos/internal/pkg/models"
+)
+
+// DefaultTolerance is the distance in metres that a simplified route may
+// deviate from the recorded points. This is small enough to be invisible at
+// the zoom levels used to show a trip.
+const DefaultTolerance = 15.0
+
+const earthRadius = 6371000.0
+
+// Segments splits points into one line per activity, so that separate
+// activities are not joined together on a map. Points without a location are
+// dropped. The points must be in time order.
+func Segments(points []models.Point) [][]models.Point {
+	var segments [][]models.Point
+
+	var current []models.Point
+	for i := range points {
+		if points[i].Latitude == 0 && points[i].Longitude == 0 {
+			continue
+		}
+
+		if len(current) > 0 && current[len(current)-1].ActivityID != points[i].ActivityID {
+			segments = append(segments, current)
+			current = nil
+		}
+
+		current = append(current, points[i])
+	}
+
+	if len(current) > 0 {
+		segments = append(segments, current)
+	}
+
+	return segments
+}
+
+// Simplify reduces the number of points in a line using the Ramer-Douglas-Peucker
+// algorithm. Points closer than tolerance metres to the simplified line are
+// removed, the first and last points are always kept.
+func Simplify(points []models.Point, tolerance float64) []models.Point {
+	if len(points) < 3 {
+		return points
+	}
+
+	keep := make([]bool, len(points))
+	keep[0] = true
+	keep[len(points)-1] = true
+
+	// ranges are processed from a stack rather than with recursion since
+	// multi-day trips can have hundreds of thousands of points
+	stack := [][2]int{{0, len(points) - 1}}
+	for len(stack) > 0 {
+		first, last := stack[len(stack)-1][0], stack[len(stack)-1][1]
+		stack = stack[:len(stack)-1]
+
+		maxDistance := 0.0
+		index := 0
+		for i := first + 1; i < last; i++ {
+			distance := perpendicularDistance(points[i], points[first], points[last])
+			if distance > maxDistance {
+				maxDistance = distance
+				index = i
+			}
+		}
+
+		if maxDistance > tolerance {
+			keep[index] = true
+			stack = append(stack, [2]int{first, index}, [2]int{index, last})
+		}
+	}
+
+	simplified := make([]models.Point, 0, len(points))
+	for i := range points {
+		if keep[i] {
+			simplified = append(simplified, points[i])
+		}
+	}
+
+	return simplified
+}
+
+// perpendicularDistance returns the distance in metres from p to the line
+// through a and b. An equirectangular projection is used, which is accurate
+// enough over the short distances between recorded points.
+func perpendicularDistance(p, a, b models.Point) float64 {
+	lat := a.Latitude * math.Pi / 180
+	project := func(point models.Point) (float64, float64) {
+		x := point.Longitude * math.Pi / 180 * math.Cos(lat) * earthRadius
+		y := point.Latitude * math.Pi / 180 * earthRadius
+		return x, y
+	}
+
+	px, py := project(p)
+	ax, ay := project(a)
+	bx, by := project(b)
+
+	dx, dy := bx-ax, by-ay
+	if dx == 0 && dy == 0 {
+		return math.Hypot(px-ax, py-ay)
+	}
+
+	return math.Abs(dy*px-dx*py+bx*ay-by*ax) / math.Hypot(dx, dy)
+}
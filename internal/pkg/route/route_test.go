@@ -0,0 +1,95 @@
+package route
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/charlieegan3/photos/internal/pkg/models"
+)
+
+func TestSimplify(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		Points   []models.Point
+		Expected []models.Point
+	}{
+		"too few points": {
+			Points: []models.Point{
+				{Latitude: 51.5, Longitude: -0.1},
+				{Latitude: 51.6, Longitude: -0.1},
+			},
+			Expected: []models.Point{
+				{Latitude: 51.5, Longitude: -0.1},
+				{Latitude: 51.6, Longitude: -0.1},
+			},
+		},
+		"straight line": {
+			Points: []models.Point{
+				{Latitude: 51.5, Longitude: -0.1},
+				{Latitude: 51.51, Longitude: -0.1},
+				{Latitude: 51.52, Longitude: -0.1},
+				{Latitude: 51.53, Longitude: -0.1},
+			},
+			Expected: []models.Point{
+				{Latitude: 51.5, Longitude: -0.1},
+				{Latitude: 51.53, Longitude: -0.1},
+			},
+		},
+		"small wobble is removed": {
+			Points: []models.Point{
+				{Latitude: 51.5, Longitude: -0.1},
+				{Latitude: 51.51, Longitude: -0.10005},
+				{Latitude: 51.52, Longitude: -0.1},
+			},
+			Expected: []models.Point{
+				{Latitude: 51.5, Longitude: -0.1},
+				{Latitude: 51.52, Longitude: -0.1},
+			},
+		},
+		"corner is kept": {
+			Points: []models.Point{
+				{Latitude: 51.5, Longitude: -0.1},
+				{Latitude: 51.51, Longitude: -0.1},
+				{Latitude: 51.52, Longitude: -0.1},
+				{Latitude: 51.52, Longitude: -0.09},
+				{Latitude: 51.52, Longitude: -0.08},
+			},
+			Expected: []models.Point{
+				{Latitude: 51.5, Longitude: -0.1},
+				{Latitude: 51.52, Longitude: -0.1},
+				{Latitude: 51.52, Longitude: -0.08},
+			},
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			require.Equal(t, testCase.Expected, Simplify(testCase.Points, DefaultTolerance))
+		})
+	}
+}
+
+func TestSegments(t *testing.T) {
+	t.Parallel()
+
+	points := []models.Point{
+		{ActivityID: 1, Latitude: 51.5, Longitude: -0.1},
+		{ActivityID: 1},
+		{ActivityID: 1, Latitude: 51.6, Longitude: -0.1},
+		{ActivityID: 2, Latitude: 55.9, Longitude: -3.2},
+	}
+
+	require.Equal(t, [][]models.Point{
+		{
+			{ActivityID: 1, Latitude: 51.5, Longitude: -0.1},
+			{ActivityID: 1, Latitude: 51.6, Longitude: -0.1},
+		},
+		{
+			{ActivityID: 2, Latitude: 55.9, Longitude: -3.2},
+		},
+	}, Segments(points))
+}
@@ -4,6 +4,7 @@ import (
 	_ "embed"
 	"net/http"
 
+	"github.com/charlieegan3/photos/internal/pkg/imageproxy"
 	"github.com/charlieegan3/photos/internal/pkg/server/templating"
 	"github.com/gobuffalo/plush"
 )
@@ -16,6 +17,8 @@ func BuildAdminIndexHandler(renderer templating.PageRenderer) func(http.Response
 		w.Header().Set("Content-Type", "text/html; charset=UTF-8")
 
 		ctx := plush.NewContext()
+		ctx.Set("renders", imageproxy.DefaultPool.Stats())
+
 		err := renderer(ctx, adminIndexTemplate, w)
 		if err != nil {
 			w.WriteHeader(http.StatusInternalServerError)
@@ -35,4 +35,7 @@ func TestIndexPage(t *testing.T) {
 
 	// items from the shared header
 	assert.Contains(t, string(body), "Posts")
+
+	// render stats from the thumbnail pool
+	assert.Contains(t, string(body), "Thumbnail renders")
 }
@@ -0,0 +1,98 @@
+package jobs
+
+import (
+	"database/sql"
+	_ "embed"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gobuffalo/plush"
+	"github.com/gorilla/mux"
+
+	"github.com/charlieegan3/photos/internal/pkg/database"
+	"github.com/charlieegan3/photos/internal/pkg/models"
+	"github.com/charlieegan3/photos/internal/pkg/server/templating"
+)
+
+//go:embed templates/index.html.plush
+var indexTemplate string
+
+// BuildIndexHandler lists the jobs which have failed all their attempts.
+func BuildIndexHandler(db *sql.DB, renderer templating.PageRenderer) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=UTF-8")
+
+		jobs, err := database.NewJobRepository(db).Failed(r.Context())
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = w.Write([]byte(err.Error()))
+			return
+		}
+
+		ctx := plush.NewContext()
+		ctx.Set("jobs", jobs)
+		ctx.Set("payload", func(job models.Job) string {
+			return string(job.Payload)
+		})
+
+		err = renderer(ctx, indexTemplate, w)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = w.Write([]byte(err.Error()))
+			return
+		}
+	}
+}
+
+// BuildFormHandler retries (PUT) or deletes (DELETE) a failed job.
+func BuildFormHandler(db *sql.DB, _ templating.PageRenderer) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=UTF-8")
+
+		id, err := strconv.Atoi(mux.Vars(r)["jobID"])
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte("failed to parse job ID"))
+			return
+		}
+
+		repo := database.NewJobRepository(db)
+
+		job, err := repo.FindByID(r.Context(), int64(id))
+		if errors.Is(err, sql.ErrNoRows) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = w.Write([]byte(err.Error()))
+			return
+		}
+
+		err = r.ParseForm()
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte("failed to parse form"))
+			return
+		}
+
+		switch r.Form.Get("_method") {
+		case http.MethodPut:
+			err = repo.Retry(r.Context(), job.ID)
+		case http.MethodDelete:
+			err = repo.Delete(r.Context(), []models.Job{*job})
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte("expected _method to be PUT or DELETE in form"))
+			return
+		}
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = w.Write([]byte(err.Error()))
+			return
+		}
+
+		http.Redirect(w, r, "/admin/jobs", http.StatusSeeOther)
+	}
+}
@@ -0,0 +1,126 @@
+package jobs
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/charlieegan3/photos/internal/pkg/database"
+	"github.com/charlieegan3/photos/internal/pkg/models"
+	"github.com/charlieegan3/photos/internal/pkg/server/templating"
+)
+
+type EndpointsJobsSuite struct {
+	suite.Suite
+
+	DB *sql.DB
+}
+
+func (s *EndpointsJobsSuite) SetupTest() {
+	err := database.Truncate(s.T().Context(), s.DB, "photos.jobs")
+	s.Require().NoError(err)
+}
+
+// createFailedJob queues a job and fails it.
+func (s *EndpointsJobsSuite) createFailedJob(kind string) models.Job {
+	repo := database.NewJobRepository(s.DB)
+
+	err := repo.Enqueue(s.T().Context(), []models.Job{
+		{Kind: kind, Payload: json.RawMessage(`{"media_id": 1}`)},
+	})
+	s.Require().NoError(err)
+
+	job, ok, err := repo.Dequeue(s.T().Context())
+	s.Require().NoError(err)
+	s.Require().True(ok)
+
+	err = repo.Fail(s.T().Context(), job.ID, "failed to read source image")
+	s.Require().NoError(err)
+
+	return job
+}
+
+func (s *EndpointsJobsSuite) TestListFailedJobs() {
+	s.createFailedJob("thumbnails")
+
+	err := database.NewJobRepository(s.DB).Enqueue(s.T().Context(), []models.Job{{Kind: "pending_kind"}})
+	s.Require().NoError(err)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/admin/jobs", BuildIndexHandler(s.DB, templating.BuildPageRenderFunc(true, "")))
+
+	req, err := http.NewRequestWithContext(s.T().Context(), http.MethodGet, "/admin/jobs", nil)
+	s.Require().NoError(err)
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	s.Require().Equal(http.StatusOK, rr.Code)
+
+	body, err := io.ReadAll(rr.Body)
+	s.Require().NoError(err)
+
+	s.Contains(string(body), "1 failed jobs")
+	s.Contains(string(body), "thumbnails")
+	s.Contains(string(body), `{&#34;media_id&#34;: 1}`)
+	s.Contains(string(body), "failed to read source image")
+	s.NotContains(string(body), "pending_kind")
+}
+
+func (s *EndpointsJobsSuite) TestRetryJob() {
+	job := s.createFailedJob("thumbnails")
+
+	rr := s.submitForm(job.ID, http.MethodPut)
+	s.Require().Equal(http.StatusSeeOther, rr.Code)
+
+	retried, err := database.NewJobRepository(s.DB).FindByID(s.T().Context(), int64(job.ID))
+	s.Require().NoError(err)
+
+	s.Equal(models.JobStatePending, retried.State)
+	s.Equal(0, retried.Attempts)
+}
+
+func (s *EndpointsJobsSuite) TestDeleteJob() {
+	job := s.createFailedJob("thumbnails")
+
+	rr := s.submitForm(job.ID, http.MethodDelete)
+	s.Require().Equal(http.StatusSeeOther, rr.Code)
+
+	count, err := database.NewJobRepository(s.DB).Count(s.T().Context())
+	s.Require().NoError(err)
+	s.Equal(int64(0), count)
+
+	rr = s.submitForm(job.ID, http.MethodDelete)
+	s.Equal(http.StatusNotFound, rr.Code)
+}
+
+func (s *EndpointsJobsSuite) submitForm(id int, method string) *httptest.ResponseRecorder {
+	router := mux.NewRouter()
+	router.HandleFunc("/admin/jobs/{jobID}", BuildFormHandler(s.DB, templating.BuildPageRenderFunc(true, ""))).
+		Methods(http.MethodPost)
+
+	form := url.Values{}
+	form.Add("_method", method)
+
+	req, err := http.NewRequestWithContext(
+		s.T().Context(),
+		http.MethodPost,
+		fmt.Sprintf("/admin/jobs/%d", id),
+		strings.NewReader(form.Encode()),
+	)
+	s.Require().NoError(err)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	return rr
+}
@@ -14,6 +14,7 @@ import (
 
 	"github.com/charlieegan3/photos/internal/pkg/database"
 	"github.com/charlieegan3/photos/internal/pkg/geoapify"
+	"github.com/charlieegan3/photos/internal/pkg/jobs"
 	"github.com/charlieegan3/photos/internal/pkg/models"
 	"github.com/charlieegan3/photos/internal/pkg/server/templating"
 )
@@ -183,6 +184,13 @@ func BuildCreateHandler(db *sql.DB, _ templating.PageRenderer) func(http.Respons
 			return
 		}
 
+		err = jobs.EnqueueLocationMap(r.Context(), db, persistedLocations[0].ID)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = w.Write([]byte(err.Error()))
+			return
+		}
+
 		http.Redirect(w, r, fmt.Sprintf("/admin/locations/%d", persistedLocations[0].ID), http.StatusSeeOther)
 	}
 }
@@ -243,7 +251,7 @@ func BuildFormHandler(
 		}
 
 		if r.Form.Get("_method") == http.MethodDelete {
-			mapKey := fmt.Sprintf("location_maps/%d.jpg", existingLocations[0].ID)
+			mapKey := jobs.LocationMapPath(existingLocations[0])
 			exists, err := bucket.Exists(r.Context(), mapKey)
 			if err != nil {
 				w.WriteHeader(http.StatusInternalServerError)
@@ -286,7 +294,7 @@ func BuildFormHandler(
 				return
 			}
 			if newLocationID != 0 {
-				mapKey := fmt.Sprintf("location_maps/%d.jpg", existingLocations[0].ID)
+				mapKey := jobs.LocationMapPath(existingLocations[0])
 				exists, err := bucket.Exists(r.Context(), mapKey)
 				if err != nil {
 					w.WriteHeader(http.StatusInternalServerError)
@@ -336,6 +344,16 @@ func BuildFormHandler(
 			return
 		}
 
+		// the map is replaced when the location moves
+		if location.Latitude != existingLocations[0].Latitude || location.Longitude != existingLocations[0].Longitude {
+			err = jobs.EnqueueLocationMap(r.Context(), db, updatedLocations[0].ID)
+			if err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				_, _ = w.Write([]byte(err.Error()))
+				return
+			}
+		}
+
 		http.Redirect(
 			w,
 			r,
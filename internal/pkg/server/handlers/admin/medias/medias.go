@@ -1,14 +1,15 @@
 package medias
 
 import (
-	"bytes"
 	"context"
 	"database/sql"
 	_ "embed"
 	"errors"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
 	"time"
@@ -18,13 +19,18 @@ import (
 	"gocloud.dev/blob"
 	_ "gocloud.dev/blob/fileblob"
 	_ "gocloud.dev/blob/memblob"
+	"gocloud.dev/gcerrors"
 
 	"github.com/charlieegan3/photos/internal/pkg/database"
+	"github.com/charlieegan3/photos/internal/pkg/geotag"
 	"github.com/charlieegan3/photos/internal/pkg/imageproxy"
-	"github.com/charlieegan3/photos/internal/pkg/mediametadata"
+	"github.com/charlieegan3/photos/internal/pkg/ingest"
+	"github.com/charlieegan3/photos/internal/pkg/jobs"
+	"github.com/charlieegan3/photos/internal/pkg/mediakind"
 	"github.com/charlieegan3/photos/internal/pkg/models"
 	"github.com/charlieegan3/photos/internal/pkg/server/handlers/shared"
 	"github.com/charlieegan3/photos/internal/pkg/server/templating"
+	"github.com/charlieegan3/photos/internal/pkg/thumbnails"
 )
 
 //go:embed templates/index.html.plush
@@ -36,10 +42,6 @@ var newTemplate string
 //go:embed templates/show.html.plush
 var showTemplate string
 
-// requiredThumbs is a list of the required thumbnail sizes
-// Note: this must be ordered.
-var requiredThumbs = []int{2000, 1000, 500, 200}
-
 func BuildIndexHandler(db *sql.DB, renderer templating.PageRenderer) func(http.ResponseWriter, *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "text/html; charset=UTF-8")
@@ -143,6 +145,15 @@ func BuildGetHandler(db *sql.DB, renderer templating.PageRenderer) func(http.Res
 		ctx.Set("devices", deviceOptionMap)
 		ctx.Set("lenses", lensOptionMap)
 		ctx.Set("posts", posts)
+		ctx.Set("metadataTags", metadataTagRows(medias[0].MetadataTags))
+		ctx.Set("editQuery", url.Values{
+			"Rotation":   {strconv.Itoa(medias[0].Rotation)},
+			"Straighten": {strconv.FormatFloat(medias[0].Straighten, 'f', -1, 64)},
+			"CropX":      {strconv.FormatFloat(medias[0].CropX, 'f', -1, 64)},
+			"CropY":      {strconv.FormatFloat(medias[0].CropY, 'f', -1, 64)},
+			"CropWidth":  {strconv.FormatFloat(medias[0].CropWidth, 'f', -1, 64)},
+			"CropHeight": {strconv.FormatFloat(medias[0].CropHeight, 'f', -1, 64)},
+		}.Encode())
 
 		err = renderer(ctx, showTemplate, w)
 		if err != nil {
@@ -220,7 +231,6 @@ func BuildUpdateHandler(
 	db *sql.DB,
 	bucket *blob.Bucket,
 ) func(http.ResponseWriter, *http.Request) {
-	ir := imageproxy.Resizer{}
 	return func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "text/html; charset=UTF-8")
 
@@ -264,6 +274,29 @@ func BuildUpdateHandler(
 			return
 		}
 
+		// a replacement file needs a new checksum and may be of another kind
+		replacementKind, replacementSHA256, err := uploadedFileKindAndSHA256(r)
+		if err != nil {
+			shared.WriteError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if replacementSHA256 != "" {
+			duplicates, err := database.NewMediaRepository(db).FindBySHA256(r.Context(), replacementSHA256)
+			if err != nil {
+				shared.WriteError(w, http.StatusInternalServerError, err.Error())
+				return
+			}
+			for _, duplicate := range duplicates {
+				if duplicate.ID != media.ID {
+					writeDuplicateError(w, duplicate)
+					return
+				}
+			}
+
+			media.Kind = replacementKind
+			media.SHA256 = replacementSHA256
+		}
+
 		updatedMedias, err := database.UpdateMedias(r.Context(), db, []models.Media{media})
 		if err != nil {
 			shared.WriteError(w, http.StatusInternalServerError, err.Error())
@@ -275,7 +308,36 @@ func BuildUpdateHandler(
 			return
 		}
 
-		err = processMediaFileIfProvided(r, bucket, &ir, updatedMedias[0], media)
+		err = processMediaFileIfProvided(r, bucket, updatedMedias[0], existingMedias[0])
+		if err != nil {
+			shared.WriteError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		posterBytes, err := processUploadedPoster(r)
+		if err != nil {
+			shared.WriteError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if posterBytes != nil && mediakind.IsVideo(updatedMedias[0].Kind) {
+			err = ingest.SavePoster(r.Context(), bucket, updatedMedias[0], posterBytes)
+			if err != nil {
+				shared.WriteError(w, http.StatusInternalServerError, err.Error())
+				return
+			}
+		}
+
+		// thumbnails of the old edits are named by them and no longer used
+		if thumbnails.Edits(updatedMedias[0]) != thumbnails.Edits(existingMedias[0]) {
+			err = thumbnails.DeleteUnused(r.Context(), bucket, updatedMedias[0])
+			if err != nil {
+				shared.WriteError(w, http.StatusInternalServerError, err.Error())
+				return
+			}
+		}
+
+		// a new file, poster, dimensions or edits make the thumbnails stale
+		err = jobs.EnqueueThumbnails(r.Context(), db, updatedMedias[0].ID)
 		if err != nil {
 			shared.WriteError(w, http.StatusInternalServerError, err.Error())
 			return
@@ -290,6 +352,95 @@ func BuildUpdateHandler(
 	}
 }
 
+// BuildPreviewHandler renders the media with the edits in the query string
+// so that they can be checked before they are saved.
+func BuildPreviewHandler(db *sql.DB, bucket *blob.Bucket) func(http.ResponseWriter, *http.Request) {
+	ir := imageproxy.Resizer{}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(mux.Vars(r)["mediaID"])
+		if err != nil {
+			shared.WriteError(w, http.StatusBadRequest, "failed to parse media ID")
+			return
+		}
+
+		medias, err := database.FindMediasByID(r.Context(), db, []int{id})
+		if err != nil {
+			shared.WriteError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		if len(medias) == 0 {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		edits, err := parseEdits(r.URL.Query())
+		if err != nil {
+			shared.WriteError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		source, err := bucket.ReadAll(r.Context(), thumbnails.SourcePath(medias[0]))
+		if err != nil {
+			shared.WriteError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		preview, err := ir.Preview(r.Context(), source, edits, thumbnails.Options("500,fit"))
+		if err != nil {
+			shared.WriteError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		w.Header().Set("Content-Type", "image/jpeg")
+		w.Header().Set("Cache-Control", "no-store")
+		_, _ = w.Write(preview)
+	}
+}
+
+// BuildOriginalHandler downloads the original file of media as it was
+// uploaded, such as the RAW file of media shown from a RAW preview.
+func BuildOriginalHandler(db *sql.DB, bucket *blob.Bucket) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(mux.Vars(r)["mediaID"])
+		if err != nil {
+			shared.WriteError(w, http.StatusBadRequest, "failed to parse media ID")
+			return
+		}
+
+		medias, err := database.FindMediasByID(r.Context(), db, []int{id})
+		if err != nil {
+			shared.WriteError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		if len(medias) == 0 {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		reader, err := bucket.NewReader(r.Context(), thumbnails.OriginalPath(medias[0]), nil)
+		if err != nil {
+			shared.WriteError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		defer reader.Close()
+
+		w.Header().Set("Content-Type", mediakind.ContentType(medias[0].Kind))
+		w.Header().Set("Content-Length", strconv.FormatInt(reader.Size(), 10))
+		w.Header().Set(
+			"Content-Disposition",
+			fmt.Sprintf("attachment; filename=\"%d.%s\"", medias[0].ID, medias[0].Kind),
+		)
+		_, err = io.Copy(w, reader)
+		if err != nil {
+			// the headers have been sent, so the error can only be logged
+			log.Printf("failed to send original of media %d: %s", medias[0].ID, err)
+		}
+	}
+}
+
 func BuildFormHandler(
 	db *sql.DB,
 	bucket *blob.Bucket,
@@ -324,8 +475,20 @@ func deleteMediaFiles(ctx context.Context, bucket *blob.Bucket, media models.Med
 		return fmt.Errorf("failed to delete media file: %w", err)
 	}
 
+	err = bucket.Delete(ctx, thumbnails.PublicPath(media))
+	if err != nil && gcerrors.Code(err) != gcerrors.NotFound {
+		return fmt.Errorf("failed to delete public media file: %w", err)
+	}
+
+	if mediakind.IsVideo(media.Kind) {
+		err = bucket.Delete(ctx, thumbnails.PosterPath(media))
+		if err != nil && gcerrors.Code(err) != gcerrors.NotFound {
+			return fmt.Errorf("failed to delete poster: %w", err)
+		}
+	}
+
 	listOptions := &blob.ListOptions{
-		Prefix: fmt.Sprintf("thumbs/%d-", media.ID),
+		Prefix: fmt.Sprintf("thumbs/media/%d-", media.ID),
 	}
 	iter := bucket.List(listOptions)
 	for {
@@ -378,6 +541,11 @@ func parseMediaForm(r *http.Request, existing models.Media) (models.Media, error
 		}
 	}
 
+	edits, err := parseEdits(r.PostForm)
+	if err != nil {
+		return models.Media{}, err
+	}
+
 	var orientation int
 	var hasOrientation bool
 	if val := r.PostForm.Get("Orientation"); val != "" {
@@ -437,6 +605,25 @@ func parseMediaForm(r *http.Request, existing models.Media) (models.Media, error
 		DisplayOffset:           displayOffset,
 		Width:                   existing.Width,
 		Height:                  existing.Height,
+		SHA256:                  existing.SHA256,
+		Duration:                existing.Duration,
+		CropX:                   edits.CropX,
+		CropY:                   edits.CropY,
+		CropWidth:               edits.CropWidth,
+		CropHeight:              edits.CropHeight,
+		Rotation:                edits.Rotation,
+		Straighten:              edits.Straighten,
+		MetadataTags:            existing.MetadataTags,
+		ExposureCompensation:    existing.ExposureCompensation,
+		ExposureProgram:         existing.ExposureProgram,
+		MeteringMode:            existing.MeteringMode,
+		Flash:                   existing.Flash,
+		WhiteBalance:            existing.WhiteBalance,
+		SubjectDistance:         existing.SubjectDistance,
+		AltitudeRef:             existing.AltitudeRef,
+		ImageDirection:          existing.ImageDirection,
+		ImageDirectionRef:       existing.ImageDirectionRef,
+		GPSTime:                 existing.GPSTime,
 	}
 
 	if hasOrientation {
@@ -461,33 +648,84 @@ func parseMediaForm(r *http.Request, existing models.Media) (models.Media, error
 	return media, nil
 }
 
+// parseEdits reads the crop and rotation edits from form values, missing
+// values are zero.
+func parseEdits(values url.Values) (imageproxy.Edits, error) {
+	floats := make(map[string]float64)
+	for _, key := range []string{"CropX", "CropY", "CropWidth", "CropHeight", "Straighten"} {
+		if val := values.Get(key); val != "" {
+			f, err := strconv.ParseFloat(val, 64)
+			if err != nil {
+				return imageproxy.Edits{}, fmt.Errorf("%s value %v was invalid", key, val)
+			}
+			floats[key] = f
+		}
+	}
+
+	var rotation int
+	if val := values.Get("Rotation"); val != "" {
+		var err error
+		rotation, err = strconv.Atoi(val)
+		if err != nil {
+			return imageproxy.Edits{}, fmt.Errorf("rotation value %v was invalid", val)
+		}
+	}
+
+	edits := imageproxy.Edits{
+		CropX:      floats["CropX"],
+		CropY:      floats["CropY"],
+		CropWidth:  floats["CropWidth"],
+		CropHeight: floats["CropHeight"],
+		Rotation:   rotation,
+		Straighten: floats["Straighten"],
+	}
+
+	err := edits.Validate()
+	if err != nil {
+		return imageproxy.Edits{}, fmt.Errorf("invalid edits: %w", err)
+	}
+
+	return edits, nil
+}
+
+// uploadedFileKindAndSHA256 returns the kind and checksum of the uploaded
+// file, or empty strings when no file was uploaded.
+func uploadedFileKindAndSHA256(r *http.Request) (string, string, error) {
+	f, header, err := r.FormFile("File")
+	if errors.Is(err, http.ErrMissingFile) {
+		return "", "", nil
+	}
+	if err != nil {
+		return "", "", fmt.Errorf("failed to open uploaded file: %w", err)
+	}
+	defer f.Close()
+
+	kind, err := mediakind.FromFilename(header.Filename)
+	if err != nil {
+		return "", "", err
+	}
+
+	fileBytes, err := io.ReadAll(f)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to read uploaded file data: %w", err)
+	}
+
+	return kind, ingest.SHA256(fileBytes), nil
+}
+
 func processMediaFileIfProvided(
 	r *http.Request,
 	bucket *blob.Bucket,
-	ir *imageproxy.Resizer,
 	updated models.Media,
-	media models.Media,
+	existing models.Media,
 ) error {
-	f, header, err := r.FormFile("File")
+	f, _, err := r.FormFile("File")
 	if err != nil {
 		return nil
 	}
 	defer f.Close()
 
-	lowerFilename := strings.ToLower(header.Filename)
-	if !strings.HasSuffix(lowerFilename, ".jpg") &&
-		!strings.HasSuffix(lowerFilename, ".jpeg") &&
-		!strings.HasSuffix(lowerFilename, ".mp4") {
-		return errors.New("media file must be jpg or mp4")
-	}
-
-	// Determine the file extension from the uploaded file
-	fileKind := "jpg"
-	if strings.HasSuffix(lowerFilename, ".mp4") {
-		fileKind = "mp4"
-	}
-
-	key := fmt.Sprintf("media/%d.%s", updated.ID, fileKind)
+	key := thumbnails.OriginalPath(updated)
 
 	bw, err := bucket.NewWriter(r.Context(), key, nil)
 	if err != nil {
@@ -500,44 +738,21 @@ func processMediaFileIfProvided(
 		return fmt.Errorf("failed to save to media storage: %w", err)
 	}
 
-	// Close the writer before attempting to read
 	err = bw.Close()
 	if err != nil {
 		return fmt.Errorf("failed to close media storage writer: %w", err)
 	}
 
-	br, err := bucket.NewReader(r.Context(), key, nil)
-	if err != nil {
-		return fmt.Errorf("failed to read from media storage: %w", err)
-	}
-	defer br.Close()
-
-	imageBytes, err := io.ReadAll(br)
-	if err != nil {
-		return fmt.Errorf("failed to read from media storage: %w", err)
-	}
-
-	for _, thumbSize := range requiredThumbs {
-		imageResizeString := fmt.Sprintf("%dx", thumbSize)
-		if media.Width != 0 && media.Height != 0 {
-			imageResizeString = fmt.Sprintf("%d,fit", thumbSize)
+	// the original of another kind is saved under a different key
+	if existing.Kind != updated.Kind {
+		err = bucket.Delete(r.Context(), thumbnails.OriginalPath(existing))
+		if err != nil && gcerrors.Code(err) != gcerrors.NotFound {
+			return fmt.Errorf("failed to delete previous media file: %w", err)
 		}
-		thumbMediaPath := fmt.Sprintf(
-			"thumbs/media/%d-%s.%s",
-			updated.ID,
-			strings.Replace(imageResizeString, ",", "-", 1),
-			fileKind,
-		)
 
-		imageBytes, err = ir.CreateThumbInBucket(
-			r.Context(),
-			bytes.NewReader(imageBytes),
-			bucket,
-			imageResizeString,
-			thumbMediaPath,
-		)
-		if err != nil {
-			return fmt.Errorf("failed to create thumbnail: %w", err)
+		err = bucket.Delete(r.Context(), thumbnails.PublicPath(existing))
+		if err != nil && gcerrors.Code(err) != gcerrors.NotFound {
+			return fmt.Errorf("failed to delete previous public media file: %w", err)
 		}
 	}
 
@@ -599,9 +814,10 @@ func BuildNewHandler(db *sql.DB, renderer templating.PageRenderer) func(http.Res
 func BuildCreateHandler(
 	db *sql.DB,
 	bucket *blob.Bucket,
+	geotagMaxGap time.Duration,
 	_ templating.PageRenderer,
 ) func(http.ResponseWriter, *http.Request) {
-	ir := imageproxy.Resizer{}
+	geotagger := geotag.NewGeotagger(database.NewActivityRepository(db).PointsBetween, geotagMaxGap)
 
 	return func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "text/html; charset=UTF-8")
@@ -629,13 +845,55 @@ func BuildCreateHandler(
 			return
 		}
 
-		err = enrichMediaFromEXIF(&media, fileBytes, filename)
+		media.SHA256 = ingest.SHA256(fileBytes)
+		existingMedias, err := database.NewMediaRepository(db).FindBySHA256(r.Context(), media.SHA256)
 		if err != nil {
 			shared.WriteError(w, http.StatusInternalServerError, err.Error())
 			return
 		}
+		if len(existingMedias) > 0 {
+			if r.Form.Get("UseExisting") != "" {
+				http.Redirect(w, r, fmt.Sprintf("/admin/medias/%d", existingMedias[0].ID), http.StatusSeeOther)
+				return
+			}
 
-		matchDeviceAndLens(r.Context(), db, &media)
+			writeDuplicateError(w, existingMedias[0])
+			return
+		}
+
+		err = ingest.EnrichFromMetadata(&media, fileBytes, filename)
+		if err != nil {
+			shared.WriteError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		posterBytes, err := processUploadedPoster(r)
+		if err != nil {
+			shared.WriteError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if mediakind.IsVideo(media.Kind) && posterBytes == nil {
+			shared.WriteError(w, http.StatusBadRequest, "videos must be uploaded with a poster image")
+			return
+		}
+
+		if media.Latitude == 0 && media.Longitude == 0 {
+			located, err := ingest.Geotag(r.Context(), geotagger, &media)
+			if err != nil {
+				shared.WriteError(w, http.StatusInternalServerError, err.Error())
+				return
+			}
+			if !located {
+				shared.WriteError(
+					w,
+					http.StatusBadRequest,
+					"images must have a location set or be taken during an imported activity",
+				)
+				return
+			}
+		}
+
+		database.MatchDeviceAndLens(r.Context(), db, &media)
 
 		persistedMedias, err := database.CreateMedias(r.Context(), db, []models.Media{media})
 		if err != nil {
@@ -648,7 +906,21 @@ func BuildCreateHandler(
 			return
 		}
 
-		err = saveMediaAndGenerateThumbs(r.Context(), bucket, &ir, persistedMedias[0], fileBytes)
+		err = ingest.SaveOriginal(r.Context(), bucket, persistedMedias[0], fileBytes)
+		if err != nil {
+			shared.WriteError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		if mediakind.IsVideo(persistedMedias[0].Kind) {
+			err = ingest.SavePoster(r.Context(), bucket, persistedMedias[0], posterBytes)
+			if err != nil {
+				shared.WriteError(w, http.StatusInternalServerError, err.Error())
+				return
+			}
+		}
+
+		err = jobs.EnqueueThumbnails(r.Context(), db, persistedMedias[0].ID)
 		if err != nil {
 			shared.WriteError(w, http.StatusInternalServerError, err.Error())
 			return
@@ -658,10 +930,21 @@ func BuildCreateHandler(
 	}
 }
 
+// writeDuplicateError responds with a link to the media which already has the
+// uploaded file.
+func writeDuplicateError(w http.ResponseWriter, existing models.Media) {
+	w.WriteHeader(http.StatusConflict)
+	fmt.Fprintf(
+		w,
+		`this file has already been uploaded as <a href="/admin/medias/%d">media %d</a>`,
+		existing.ID,
+		existing.ID,
+	)
+}
+
 func parseCreateForm(r *http.Request) (models.Media, error) {
 	media := models.Media{
-		Make:       r.Form.Get("Make"),
-		UTCCorrect: true,
+		Make: r.Form.Get("Make"),
 	}
 
 	deviceID, err := strconv.ParseInt(r.Form.Get("DeviceID"), 10, 64)
@@ -688,9 +971,9 @@ func processUploadedFile(r *http.Request) ([]byte, string, error) {
 	}
 	defer f.Close()
 
-	lowerFilename := strings.ToLower(header.Filename)
-	if !strings.HasSuffix(lowerFilename, ".jpg") && !strings.HasSuffix(lowerFilename, ".jpeg") {
-		return nil, "", errors.New("media file must be jpg")
+	_, err = mediakind.FromFilename(header.Filename)
+	if err != nil {
+		return nil, "", err
 	}
 
 	fileBytes, err := io.ReadAll(f)
@@ -701,124 +984,30 @@ func processUploadedFile(r *http.Request) ([]byte, string, error) {
 	return fileBytes, header.Filename, nil
 }
 
-func enrichMediaFromEXIF(media *models.Media, fileBytes []byte, filename string) error {
-	exifData, err := mediametadata.ExtractMetadata(fileBytes)
-	if err != nil {
-		return fmt.Errorf("failed to get exif data file: %w", err)
-	}
-
-	latValue, _ := exifData.Latitude.ToDecimal()
-	longValue, _ := exifData.Longitude.ToDecimal()
-	if latValue == 0 && longValue == 0 {
-		return errors.New("images must have a location set")
-	}
-
-	media.Make = exifData.Make
-	media.Model = exifData.Model
-	media.Lens = exifData.Lens
-	media.FocalLength = exifData.FocalLength
-	media.TakenAt = exifData.DateTime
-	media.FNumber, _ = exifData.FNumber.ToDecimal()
-	media.ExposureTimeNumerator = exifData.ExposureTime.Numerator
-	media.ExposureTimeDenominator = exifData.ExposureTime.Denominator
-	media.ISOSpeed = int(exifData.ISOSpeed)
-	media.Latitude, _ = exifData.Latitude.ToDecimal()
-	media.Longitude, _ = exifData.Longitude.ToDecimal()
-	media.Altitude, _ = exifData.Altitude.ToDecimal()
-	if int(exifData.Orientation) > 0 {
-		media.Orientation = int(exifData.Orientation)
-	} else {
-		media.Orientation = 1 // Default to normal orientation if not set in EXIF
-	}
-	media.Width = exifData.Width
-	media.Height = exifData.Height
-
-	if len(strings.Split(strings.ToLower(filename), ".")) > 1 {
-		parts := strings.Split(strings.ToLower(filename), ".")
-		media.Kind = parts[len(parts)-1]
-		if media.Kind == "jpeg" {
-			media.Kind = "jpg"
-		}
-	} else {
-		return errors.New("file must have name and extension")
-	}
-
-	return nil
-}
-
-func matchDeviceAndLens(ctx context.Context, db *sql.DB, media *models.Media) {
-	deviceRepo := database.NewDeviceRepository(db)
-	modelMatchedDevice, err := deviceRepo.FindByModelMatches(ctx, media.Model)
-	if err == nil && modelMatchedDevice != nil {
-		media.DeviceID = modelMatchedDevice.ID
+// processUploadedPoster returns the poster image uploaded with a video, or
+// nil when there is none.
+func processUploadedPoster(r *http.Request) ([]byte, error) {
+	f, header, err := r.FormFile("Poster")
+	if errors.Is(err, http.ErrMissingFile) {
+		return nil, nil
 	}
-
-	lensMatchLens, err := database.FindLensByLensMatches(ctx, db, media.Lens)
-	if err == nil && lensMatchLens != nil {
-		media.LensID = lensMatchLens.ID
-	}
-}
-
-func saveMediaAndGenerateThumbs(
-	ctx context.Context,
-	bucket *blob.Bucket,
-	ir *imageproxy.Resizer,
-	media models.Media,
-	fileBytes []byte,
-) error {
-	key := fmt.Sprintf("media/%d.%s", media.ID, media.Kind)
-
-	bw, err := bucket.NewWriter(ctx, key, nil)
 	if err != nil {
-		return fmt.Errorf("failed initialize media storage: %w", err)
+		return nil, fmt.Errorf("failed to open uploaded poster: %w", err)
 	}
+	defer f.Close()
 
-	_, err = io.Copy(bw, bytes.NewReader(fileBytes))
+	kind, err := mediakind.FromFilename(header.Filename)
 	if err != nil {
-		bw.Close()
-		return fmt.Errorf("failed to save to media storage: %w", err)
+		return nil, err
 	}
-
-	// Close the writer before attempting to read
-	err = bw.Close()
-	if err != nil {
-		return fmt.Errorf("failed to close media storage writer: %w", err)
-	}
-
-	br, err := bucket.NewReader(ctx, key, nil)
-	if err != nil {
-		return fmt.Errorf("failed to read from media storage: %w", err)
+	if !mediakind.IsImage(kind) {
+		return nil, fmt.Errorf("poster must be an image, got %s", kind)
 	}
-	defer br.Close()
 
-	imageBytes, err := io.ReadAll(br)
+	posterBytes, err := io.ReadAll(f)
 	if err != nil {
-		return fmt.Errorf("failed to read from media storage: %w", err)
+		return nil, fmt.Errorf("failed to read uploaded poster data: %w", err)
 	}
 
-	for _, thumbSize := range requiredThumbs {
-		imageResizeString := fmt.Sprintf("%dx", thumbSize)
-		if media.Width != 0 && media.Height != 0 {
-			imageResizeString = fmt.Sprintf("%d,fit", thumbSize)
-		}
-		thumbMediaPath := fmt.Sprintf(
-			"thumbs/media/%d-%s.%s",
-			media.ID,
-			strings.Replace(imageResizeString, ",", "-", 1),
-			media.Kind,
-		)
-
-		imageBytes, err = ir.CreateThumbInBucket(
-			ctx,
-			bytes.NewReader(imageBytes),
-			bucket,
-			imageResizeString,
-			thumbMediaPath,
-		)
-		if err != nil {
-			return fmt.Errorf("failed to create thumbnail: %w", err)
-		}
-	}
-
-	return nil
+	return posterBytes, nil
 }
@@ -4,10 +4,14 @@ import (
 	"bytes"
 	"context"
 	"crypto/sha1"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/binary"
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"image"
+	"image/jpeg"
 	"io"
 	"mime/multipart"
 	"net/http"
@@ -27,6 +31,8 @@ import (
 	_ "gocloud.dev/blob/memblob"
 
 	"github.com/charlieegan3/photos/internal/pkg/database"
+	"github.com/charlieegan3/photos/internal/pkg/geotag"
+	"github.com/charlieegan3/photos/internal/pkg/jobs"
 	"github.com/charlieegan3/photos/internal/pkg/models"
 	"github.com/charlieegan3/photos/internal/pkg/server/templating"
 )
@@ -40,7 +46,9 @@ type EndpointsMediasSuite struct {
 }
 
 func (s *EndpointsMediasSuite) SetupTest() {
-	err := database.Truncate(s.T().Context(), s.DB, "photos.medias")
+	err := database.Truncate(s.T().Context(), s.DB, "photos.jobs")
+	s.Require().NoError(err)
+	err = database.Truncate(s.T().Context(), s.DB, "photos.medias")
 	s.Require().NoError(err)
 
 	err = database.Truncate(s.T().Context(), s.DB, "photos.devices")
@@ -394,7 +402,7 @@ func (s *EndpointsMediasSuite) TestDeleteMedia() {
 	// write a thumbnail to test these are also deleted, in this case there's only one thumb
 	imageFile, err = os.Open(imageFilePath)
 	s.Require().NoError(err)
-	bw, err = s.Bucket.NewWriter(context.Background(), fmt.Sprintf("thumbs/%d-foobar.jpg", persistedMedias[0].ID), nil)
+	bw, err = s.Bucket.NewWriter(context.Background(), fmt.Sprintf("thumbs/media/%d-foobar.jpg", persistedMedias[0].ID), nil)
 	s.Require().NoError(err)
 	_, err = io.Copy(bw, imageFile)
 	s.Require().NoError(err)
@@ -512,7 +520,7 @@ func (s *EndpointsMediasSuite) TestCreateMedia() {
 
 	router := mux.NewRouter()
 	router.HandleFunc("/admin/medias",
-		BuildCreateHandler(s.DB, s.Bucket, templating.BuildPageRenderFunc(true, ""))).
+		BuildCreateHandler(s.DB, s.Bucket, geotag.DefaultMaxGap, templating.BuildPageRenderFunc(true, ""))).
 		Methods(http.MethodPost)
 
 	// open the image to be uploaded in the form
@@ -624,7 +632,14 @@ func (s *EndpointsMediasSuite) TestCreateMedia() {
 
 	s.Require().Equal(bucketDigest, sourceDigest, "image in bucket does not match original image")
 
-	// check that the thumbs have been created too
+	// check that the thumbs are made by the queued job
+	runner := jobs.NewRunner(s.DB, 1)
+	(&jobs.Handlers{DB: s.DB, Bucket: s.Bucket}).Register(runner)
+
+	ran, err := runner.RunNext(s.T().Context())
+	s.Require().NoError(err)
+	s.Require().True(ran, "expected a thumbnails job to be queued")
+
 	var thumbs []string
 	listOptions := &blob.ListOptions{
 		Prefix: fmt.Sprintf("thumbs/media/%d-", returnedMedias[0].ID),
@@ -641,9 +656,314 @@ func (s *EndpointsMediasSuite) TestCreateMedia() {
 	}
 
 	s.Require().ElementsMatchf(thumbs, []string{
+		fmt.Sprintf("thumbs/media/%d-100-fit.jpg", returnedMedias[0].ID),
 		fmt.Sprintf("thumbs/media/%d-200-fit.jpg", returnedMedias[0].ID),
 		fmt.Sprintf("thumbs/media/%d-500-fit.jpg", returnedMedias[0].ID),
 		fmt.Sprintf("thumbs/media/%d-1000-fit.jpg", returnedMedias[0].ID),
 		fmt.Sprintf("thumbs/media/%d-2000-fit.jpg", returnedMedias[0].ID),
 	}, "thumbs not created correctly")
 }
+
+func (s *EndpointsMediasSuite) TestCreateMediaDuplicate() {
+	returnedDevices, err := database.CreateDevices(s.T().Context(), s.DB, []models.Device{{Name: "Example Device"}})
+	s.Require().NoError(err)
+
+	imageFilePath := "../../../pkg/mediametadata/samples/iphone-11-pro-max.jpg"
+	imageBytes, err := os.ReadFile(imageFilePath)
+	s.Require().NoError(err)
+
+	imageHash := sha256.Sum256(imageBytes)
+	existingMedias, err := database.CreateMedias(s.T().Context(), s.DB, []models.Media{
+		{
+			DeviceID:    returnedDevices[0].ID,
+			Kind:        "jpg",
+			Orientation: 1,
+			SHA256:      hex.EncodeToString(imageHash[:]),
+		},
+	})
+	s.Require().NoError(err)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/admin/medias",
+		BuildCreateHandler(s.DB, s.Bucket, geotag.DefaultMaxGap, templating.BuildPageRenderFunc(true, ""))).
+		Methods(http.MethodPost)
+
+	post := func(useExisting bool) *httptest.ResponseRecorder {
+		var b bytes.Buffer
+		w := multipart.NewWriter(&b)
+
+		fw, err := w.CreateFormFile("File", "iphone-11-pro-max.jpg")
+		s.Require().NoError(err)
+		_, err = fw.Write(imageBytes)
+		s.Require().NoError(err)
+
+		err = w.WriteField("DeviceID", strconv.FormatInt(returnedDevices[0].ID, 10))
+		s.Require().NoError(err)
+		if useExisting {
+			err = w.WriteField("UseExisting", "true")
+			s.Require().NoError(err)
+		}
+		w.Close()
+
+		req, err := http.NewRequestWithContext(s.T().Context(), http.MethodPost, "/admin/medias", &b)
+		s.Require().NoError(err)
+		req.Header.Set("Content-Type", w.FormDataContentType())
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		return rr
+	}
+
+	existingPath := fmt.Sprintf("/admin/medias/%d", existingMedias[0].ID)
+
+	// duplicates are rejected with a link to the existing media
+	rr := post(false)
+	s.Equal(http.StatusConflict, rr.Code)
+	s.Contains(rr.Body.String(), existingPath)
+
+	// or the existing media is returned when requested
+	rr = post(true)
+	s.Equal(http.StatusSeeOther, rr.Code)
+	s.Equal(existingPath, rr.Result().Header.Get("Location"))
+
+	medias, err := database.AllMedias(s.T().Context(), s.DB, false)
+	s.Require().NoError(err)
+	s.Len(medias, 1)
+}
+
+func (s *EndpointsMediasSuite) TestUpdateMediaDuplicate() {
+	returnedDevices, err := database.CreateDevices(s.T().Context(), s.DB, []models.Device{{Name: "Example Device"}})
+	s.Require().NoError(err)
+
+	imageFilePath := "../../../pkg/mediametadata/samples/iphone-11-pro-max.jpg"
+	imageBytes, err := os.ReadFile(imageFilePath)
+	s.Require().NoError(err)
+
+	imageHash := sha256.Sum256(imageBytes)
+	medias, err := database.CreateMedias(s.T().Context(), s.DB, []models.Media{
+		{DeviceID: returnedDevices[0].ID, Kind: "jpg", Orientation: 1, SHA256: hex.EncodeToString(imageHash[:])},
+		{DeviceID: returnedDevices[0].ID, Kind: "jpg", Orientation: 1, SHA256: "other"},
+	})
+	s.Require().NoError(err)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/admin/medias/{mediaID}",
+		BuildFormHandler(s.DB, s.Bucket, templating.BuildPageRenderFunc(true, ""))).
+		Methods(http.MethodPost)
+
+	var b bytes.Buffer
+	w := multipart.NewWriter(&b)
+
+	fw, err := w.CreateFormFile("File", "iphone-11-pro-max.jpg")
+	s.Require().NoError(err)
+	_, err = fw.Write(imageBytes)
+	s.Require().NoError(err)
+
+	err = w.WriteField("DeviceID", strconv.FormatInt(returnedDevices[0].ID, 10))
+	s.Require().NoError(err)
+	err = w.WriteField("_method", http.MethodPut)
+	s.Require().NoError(err)
+	w.Close()
+
+	// replace the file of the second media with that of the first
+	req, err := http.NewRequestWithContext(
+		s.T().Context(),
+		http.MethodPost,
+		fmt.Sprintf("/admin/medias/%d", medias[1].ID),
+		&b,
+	)
+	s.Require().NoError(err)
+	req.Header.Set("Content-Type", w.FormDataContentType())
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	s.Equal(http.StatusConflict, rr.Code)
+	s.Contains(rr.Body.String(), fmt.Sprintf("/admin/medias/%d", medias[0].ID))
+
+	unchanged, err := database.FindMediasByID(s.T().Context(), s.DB, []int{medias[1].ID})
+	s.Require().NoError(err)
+	s.Require().Len(unchanged, 1)
+	s.Equal("other", unchanged[0].SHA256)
+}
+
+func (s *EndpointsMediasSuite) TestSimilarMedias() {
+	devices, err := database.CreateDevices(s.T().Context(), s.DB, []models.Device{{Name: "Example Device"}})
+	s.Require().NoError(err)
+
+	medias, err := database.CreateMedias(s.T().Context(), s.DB, []models.Media{
+		{DeviceID: devices[0].ID, Kind: "jpg", Orientation: 1, PerceptualHash: "0000000000000000"},
+		{DeviceID: devices[0].ID, Kind: "jpg", Orientation: 1, PerceptualHash: "0000000000000003"},
+		{DeviceID: devices[0].ID, Kind: "jpg", Orientation: 1, PerceptualHash: "ffffffffffffffff"},
+	})
+	s.Require().NoError(err)
+
+	for _, media := range medias {
+		err = s.Bucket.WriteAll(s.T().Context(), fmt.Sprintf("media/%d.jpg", media.ID), []byte("jpg"), nil)
+		s.Require().NoError(err)
+		err = s.Bucket.WriteAll(s.T().Context(), fmt.Sprintf("thumbs/media/%d-100-fit.jpg", media.ID), []byte("jpg"), nil)
+		s.Require().NoError(err)
+	}
+
+	router := mux.NewRouter()
+	router.HandleFunc("/admin/medias/similar",
+		BuildSimilarHandler(s.DB, templating.BuildPageRenderFunc(true, ""))).Methods(http.MethodGet)
+	router.HandleFunc("/admin/medias/similar",
+		BuildRemoveSimilarHandler(s.DB, s.Bucket)).Methods(http.MethodPost)
+
+	req, err := http.NewRequestWithContext(s.T().Context(), http.MethodGet, "/admin/medias/similar", nil)
+	s.Require().NoError(err)
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	s.Require().Equal(http.StatusOK, rr.Code)
+	s.Contains(rr.Body.String(), "1 groups, page 1 of 1")
+	s.Contains(rr.Body.String(), fmt.Sprintf(`href="/admin/medias/%d"`, medias[1].ID))
+	s.NotContains(rr.Body.String(), fmt.Sprintf(`href="/admin/medias/%d"`, medias[2].ID))
+	s.Contains(rr.Body.String(), fmt.Sprintf(`name="remove" value="%d"`, medias[1].ID))
+
+	// pages after the last go to the last page
+	req, err = http.NewRequestWithContext(s.T().Context(), http.MethodGet, "/admin/medias/similar?page=3", nil)
+	s.Require().NoError(err)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	s.Equal(http.StatusSeeOther, rr.Code)
+	s.Equal("/admin/medias/similar?distance=6&page=1", rr.Header().Get("Location"))
+
+	req, err = http.NewRequestWithContext(s.T().Context(), http.MethodGet, "/admin/medias/similar?distance=64", nil)
+	s.Require().NoError(err)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	s.Equal(http.StatusBadRequest, rr.Code)
+
+	form := url.Values{"remove": {strconv.Itoa(medias[1].ID)}}
+	req, err = http.NewRequestWithContext(
+		s.T().Context(),
+		http.MethodPost,
+		"/admin/medias/similar",
+		strings.NewReader(form.Encode()),
+	)
+	s.Require().NoError(err)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	s.Require().Equal(http.StatusSeeOther, rr.Code)
+
+	remaining, err := database.AllMedias(s.T().Context(), s.DB, false)
+	s.Require().NoError(err)
+	s.Len(remaining, 2)
+
+	for _, key := range []string{
+		fmt.Sprintf("media/%d.jpg", medias[1].ID),
+		fmt.Sprintf("thumbs/media/%d-100-fit.jpg", medias[1].ID),
+	} {
+		exists, err := s.Bucket.Exists(s.T().Context(), key)
+		s.Require().NoError(err)
+		s.False(exists, key)
+	}
+
+	exists, err := s.Bucket.Exists(s.T().Context(), fmt.Sprintf("media/%d.jpg", medias[0].ID))
+	s.Require().NoError(err)
+	s.True(exists)
+}
+
+func (s *EndpointsMediasSuite) TestReextractMetadata() {
+	devices, err := database.CreateDevices(s.T().Context(), s.DB, []models.Device{{Name: "Example Device"}})
+	s.Require().NoError(err)
+
+	medias, err := database.CreateMedias(s.T().Context(), s.DB, []models.Media{
+		{DeviceID: devices[0].ID, Kind: "jpg", Orientation: 1, Make: "Edited"},
+	})
+	s.Require().NoError(err)
+	s.Empty(medias[0].MetadataTags)
+
+	var buf bytes.Buffer
+	err = jpeg.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 30, 20)), nil)
+	s.Require().NoError(err)
+
+	// insert an XMP packet after the start of image marker
+	xmp := append(
+		[]byte("http://ns.adobe.com/xap/1.0/\x00"),
+		[]byte(`<x:xmpmeta xmlns:x="adobe:ns:meta/">`+
+			`<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">`+
+			`<rdf:Description xmlns:xmp="http://ns.adobe.com/xap/1.0/" xmp:Rating="5"/>`+
+			`</rdf:RDF></x:xmpmeta>`)...,
+	)
+	length := make([]byte, 2)
+	binary.BigEndian.PutUint16(length, uint16(len(xmp)+2))
+	original := bytes.Join([][]byte{buf.Bytes()[:2], {0xff, 0xe1}, length, xmp, buf.Bytes()[2:]}, nil)
+
+	err = s.Bucket.WriteAll(s.T().Context(), fmt.Sprintf("media/%d.jpg", medias[0].ID), original, nil)
+	s.Require().NoError(err)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/admin/medias/{mediaID}/metadata",
+		BuildReextractMetadataHandler(s.DB, s.Bucket)).Methods(http.MethodPost)
+	router.HandleFunc("/admin/medias/{mediaID}",
+		BuildGetHandler(s.DB, templating.BuildPageRenderFunc(true, ""))).Methods(http.MethodGet)
+
+	req, err := http.NewRequestWithContext(
+		s.T().Context(), http.MethodPost, fmt.Sprintf("/admin/medias/%d/metadata", medias[0].ID), nil,
+	)
+	s.Require().NoError(err)
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	s.Require().Equal(http.StatusSeeOther, rr.Code, rr.Body.String())
+
+	updated, err := database.FindMediasByID(s.T().Context(), s.DB, []int{medias[0].ID})
+	s.Require().NoError(err)
+	s.Require().Len(updated, 1)
+
+	s.Equal([]models.MetadataTag{
+		{IFD: "XMP", Name: "xmp:Rating", Type: "Text", Value: "5"},
+	}, updated[0].MetadataTags)
+	s.Equal("Edited", updated[0].Make, "other fields should not be changed")
+
+	req, err = http.NewRequestWithContext(
+		s.T().Context(), http.MethodGet, fmt.Sprintf("/admin/medias/%d", medias[0].ID), nil,
+	)
+	s.Require().NoError(err)
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	s.Require().Equal(http.StatusOK, rr.Code)
+	s.Contains(rr.Body.String(), "xmp:Rating")
+}
+
+func (s *EndpointsMediasSuite) TestDownloadOriginal() {
+	devices, err := database.CreateDevices(s.T().Context(), s.DB, []models.Device{{Name: "Example Device"}})
+	s.Require().NoError(err)
+
+	medias, err := database.CreateMedias(s.T().Context(), s.DB, []models.Media{
+		{DeviceID: devices[0].ID, Kind: "dng", Orientation: 1},
+	})
+	s.Require().NoError(err)
+
+	original := []byte("II*\x00raw data")
+	err = s.Bucket.WriteAll(s.T().Context(), fmt.Sprintf("media/%d.dng", medias[0].ID), original, nil)
+	s.Require().NoError(err)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/admin/medias/{mediaID}/original",
+		BuildOriginalHandler(s.DB, s.Bucket)).Methods(http.MethodGet)
+
+	req, err := http.NewRequestWithContext(
+		s.T().Context(), http.MethodGet, fmt.Sprintf("/admin/medias/%d/original", medias[0].ID), nil,
+	)
+	s.Require().NoError(err)
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	s.Require().Equal(http.StatusOK, rr.Code, rr.Body.String())
+	s.Equal("image/x-adobe-dng", rr.Header().Get("Content-Type"))
+	s.Equal(fmt.Sprintf("attachment; filename=\"%d.dng\"", medias[0].ID), rr.Header().Get("Content-Disposition"))
+	s.Equal(original, rr.Body.Bytes())
+}
@@ -0,0 +1,98 @@
+package medias
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"gocloud.dev/blob"
+
+	"github.com/charlieegan3/photos/internal/pkg/database"
+	"github.com/charlieegan3/photos/internal/pkg/ingest"
+	"github.com/charlieegan3/photos/internal/pkg/models"
+	"github.com/charlieegan3/photos/internal/pkg/server/handlers/shared"
+	"github.com/charlieegan3/photos/internal/pkg/thumbnails"
+)
+
+// metadataTagRow is a row in the table of metadata tags on the media page.
+type metadataTagRow struct {
+	IFD   string
+	Name  string
+	Type  string
+	Value string
+}
+
+// metadataTagRows formats the tag values for display, strings are shown as
+// they are and other values as JSON.
+func metadataTagRows(tags []models.MetadataTag) []metadataTagRow {
+	rows := make([]metadataTagRow, 0, len(tags))
+	for _, tag := range tags {
+		row := metadataTagRow{IFD: tag.IFD, Name: tag.Name, Type: tag.Type}
+
+		switch v := tag.Value.(type) {
+		case string:
+			row.Value = v
+		case nil:
+		default:
+			b, err := json.Marshal(v)
+			if err != nil {
+				row.Value = fmt.Sprintf("%v", v)
+			} else {
+				row.Value = string(b)
+			}
+		}
+
+		rows = append(rows, row)
+	}
+
+	return rows
+}
+
+// BuildReextractMetadataHandler reads the metadata tags from the original
+// file again. Only the tags are replaced, fields which may have been edited
+// are left unchanged.
+func BuildReextractMetadataHandler(db *sql.DB, bucket *blob.Bucket) func(http.ResponseWriter, *http.Request) {
+	repo := database.NewMediaRepository(db)
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(mux.Vars(r)["mediaID"])
+		if err != nil {
+			shared.WriteError(w, http.StatusBadRequest, "failed to parse media ID")
+			return
+		}
+
+		medias, err := database.FindMediasByID(r.Context(), db, []int{id})
+		if err != nil {
+			shared.WriteError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		if len(medias) == 0 {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		original, err := bucket.ReadAll(r.Context(), thumbnails.OriginalPath(medias[0]))
+		if err != nil {
+			shared.WriteError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		tags, err := ingest.ExtractMetadataTags(medias[0].Kind, original)
+		if err != nil {
+			shared.WriteError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		err = repo.SetMetadataTags(r.Context(), medias[0].ID, tags)
+		if err != nil {
+			shared.WriteError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		http.Redirect(w, r, fmt.Sprintf("/admin/medias/%d#metadata", medias[0].ID), http.StatusSeeOther)
+	}
+}
@@ -0,0 +1,227 @@
+package medias
+
+import (
+	"database/sql"
+	_ "embed"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gobuffalo/plush"
+	"gocloud.dev/blob"
+
+	"github.com/charlieegan3/photos/internal/pkg/database"
+	"github.com/charlieegan3/photos/internal/pkg/models"
+	"github.com/charlieegan3/photos/internal/pkg/server/handlers/shared"
+	"github.com/charlieegan3/photos/internal/pkg/server/templating"
+	"github.com/charlieegan3/photos/internal/pkg/similarity"
+)
+
+//go:embed templates/similar.html.plush
+var similarTemplate string
+
+// similarMedia is a media in a group of similar media.
+type similarMedia struct {
+	Media models.Media
+	// Distance is the distance of the perceptual hash from the first media
+	// in the group.
+	Distance int
+	Posted   bool
+	// Others are the IDs of the rest of the group, these are removed when
+	// this media is kept. It is empty when any of the others are posted.
+	Others []int
+}
+
+// similarPageSize is the number of groups of similar media shown on each
+// page, only the media in these are loaded.
+const similarPageSize = 20
+
+// BuildSimilarHandler lists groups of media which look alike, such as
+// re-exports and burst frames, so that the extras can be removed.
+func BuildSimilarHandler(db *sql.DB, renderer templating.PageRenderer) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=UTF-8")
+
+		distance := similarity.DefaultDistance
+		if value := r.URL.Query().Get("distance"); value != "" {
+			d, err := strconv.Atoi(value)
+			if err != nil || d < 0 || d > similarity.MaxDistance {
+				shared.WriteError(w, http.StatusBadRequest,
+					fmt.Sprintf("distance must be a number from 0 to %d", similarity.MaxDistance))
+				return
+			}
+			distance = d
+		}
+
+		page := 1
+		if value := r.URL.Query().Get("page"); value != "" {
+			p, err := strconv.Atoi(value)
+			if err != nil || p < 1 {
+				shared.WriteError(w, http.StatusBadRequest, "page must be a positive number")
+				return
+			}
+			page = p
+		}
+
+		// only the hashes of all media are loaded to find the groups
+		hashes, err := database.NewMediaRepository(db).PerceptualHashes(r.Context())
+		if err != nil {
+			shared.WriteError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		allGroups := similarity.Groups(hashes, distance)
+		lastPage := max((len(allGroups)+similarPageSize-1)/similarPageSize, 1)
+		if page > lastPage {
+			http.Redirect(w, r,
+				fmt.Sprintf("/admin/medias/similar?distance=%d&page=%d", distance, lastPage),
+				http.StatusSeeOther)
+			return
+		}
+		pageGroups := allGroups[(page-1)*similarPageSize : min(page*similarPageSize, len(allGroups))]
+
+		var ids []int
+		for _, group := range pageGroups {
+			ids = append(ids, group...)
+		}
+
+		medias, err := database.FindMediasByID(r.Context(), db, ids)
+		if err != nil {
+			shared.WriteError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		mediaMap := make(map[int]models.Media, len(medias))
+		for _, media := range medias {
+			mediaMap[media.ID] = media
+		}
+
+		posts, err := database.FindPostsByMediaIDs(r.Context(), db, ids)
+		if err != nil {
+			shared.WriteError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		postMediaMap := make(map[int]bool)
+		for i := range posts {
+			postMediaMap[posts[i].MediaID] = true
+		}
+
+		var groups [][]similarMedia
+		for _, ids := range pageGroups {
+			group := make([]similarMedia, 0, len(ids))
+			for _, id := range ids {
+				group = append(group, similarMedia{
+					Media:    mediaMap[id],
+					Distance: similarity.Distance(hashes[ids[0]], hashes[id]),
+					Posted:   postMediaMap[id],
+				})
+			}
+
+			for i := range group {
+				var others []int
+				for j := range group {
+					if i == j {
+						continue
+					}
+					if group[j].Posted {
+						others = nil
+						break
+					}
+					others = append(others, group[j].Media.ID)
+				}
+				group[i].Others = others
+			}
+
+			groups = append(groups, group)
+		}
+
+		ctx := plush.NewContext()
+		ctx.Set("groups", groups)
+		ctx.Set("groupCount", len(allGroups))
+		ctx.Set("distance", distance)
+		ctx.Set("maxDistance", similarity.MaxDistance)
+		ctx.Set("page", page)
+		ctx.Set("lastPage", lastPage)
+
+		err = renderer(ctx, similarTemplate, w)
+		if err != nil {
+			shared.WriteError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+}
+
+// BuildRemoveSimilarHandler deletes the media listed in the form, the media
+// kept from a group of similar media is not sent. Media used in posts are not
+// removed.
+func BuildRemoveSimilarHandler(db *sql.DB, bucket *blob.Bucket) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=UTF-8")
+
+		err := shared.ValidateContentType(r, "application/x-www-form-urlencoded")
+		if err != nil {
+			shared.WriteError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		err = r.ParseForm()
+		if err != nil {
+			shared.WriteError(w, http.StatusBadRequest, "failed to parse remove form")
+			return
+		}
+
+		var ids []int
+		for _, value := range r.PostForm["remove"] {
+			id, err := strconv.Atoi(value)
+			if err != nil {
+				shared.WriteError(w, http.StatusBadRequest, "failed to parse media ID")
+				return
+			}
+			ids = append(ids, id)
+		}
+
+		if len(ids) == 0 {
+			shared.WriteError(w, http.StatusBadRequest, "no medias to remove")
+			return
+		}
+
+		existingMedias, err := database.FindMediasByID(r.Context(), db, ids)
+		if err != nil {
+			shared.WriteError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		if len(existingMedias) != len(ids) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		for _, media := range existingMedias {
+			posts, err := database.FindPostsByMediaID(r.Context(), db, media.ID)
+			if err != nil {
+				shared.WriteError(w, http.StatusInternalServerError, err.Error())
+				return
+			}
+
+			if len(posts) > 0 {
+				shared.WriteError(w, http.StatusBadRequest, fmt.Sprintf("media %d is used in posts", media.ID))
+				return
+			}
+		}
+
+		err = database.DeleteMedias(r.Context(), db, existingMedias)
+		if err != nil {
+			shared.WriteError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		for _, media := range existingMedias {
+			err = deleteMediaFiles(r.Context(), bucket, media)
+			if err != nil {
+				shared.WriteError(w, http.StatusInternalServerError, err.Error())
+				return
+			}
+		}
+
+		http.Redirect(w, r, "/admin/medias/similar", http.StatusSeeOther)
+	}
+}
@@ -13,6 +13,9 @@ import (
 	"github.com/gorilla/mux"
 
 	"github.com/charlieegan3/photos/internal/pkg/database"
+	"github.com/charlieegan3/photos/internal/pkg/ingest"
+	"github.com/charlieegan3/photos/internal/pkg/jobs"
+	"github.com/charlieegan3/photos/internal/pkg/mediametadata"
 	"github.com/charlieegan3/photos/internal/pkg/models"
 	"github.com/charlieegan3/photos/internal/pkg/server/templating"
 )
@@ -204,11 +207,16 @@ func BuildGetHandler(db *sql.DB, renderer templating.PageRenderer) func(http.Res
 	}
 }
 
-func BuildNewHandler(db *sql.DB, renderer templating.PageRenderer) func(http.ResponseWriter, *http.Request) {
+func BuildNewHandler(
+	db *sql.DB,
+	favouriteRatingAbove int,
+	renderer templating.PageRenderer,
+) func(http.ResponseWriter, *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "text/html; charset=UTF-8")
 
 		newPost := models.Post{}
+		var newTags []string
 
 		mediaID := r.URL.Query().Get("mediaID")
 		if mediaID != "" {
@@ -219,6 +227,21 @@ func BuildNewHandler(db *sql.DB, renderer templating.PageRenderer) func(http.Res
 				return
 			}
 			newPost.MediaID = int(i)
+
+			medias, err := database.FindMediasByID(r.Context(), db, []int{newPost.MediaID})
+			if err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				_, _ = w.Write([]byte(err.Error()))
+				return
+			}
+			// prefill the form from the XMP of the media like uploaded drafts
+			if len(medias) == 1 {
+				tags := make([]mediametadata.Tag, len(medias[0].MetadataTags))
+				for j, tag := range medias[0].MetadataTags {
+					tags[j] = mediametadata.Tag(tag)
+				}
+				newTags = ingest.DraftFromXMP(&newPost, mediametadata.XMPFromTags(tags), favouriteRatingAbove)
+			}
 		}
 
 		locationID := r.URL.Query().Get("locationID")
@@ -273,6 +296,7 @@ func BuildNewHandler(db *sql.DB, renderer templating.PageRenderer) func(http.Res
 
 		ctx := plush.NewContext()
 		ctx.Set("post", newPost)
+		ctx.Set("tags", strings.Join(newTags, " "))
 		ctx.Set("locations", formLocations)
 		ctx.Set("medias", formMedias)
 
@@ -356,6 +380,13 @@ func BuildCreateHandler(db *sql.DB, _ templating.PageRenderer) func(http.Respons
 			return
 		}
 
+		err = jobs.EnqueuePostPublished(r.Context(), db, persistedPosts[0])
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = w.Write([]byte(err.Error()))
+			return
+		}
+
 		http.Redirect(w, r, fmt.Sprintf("/admin/posts/%d", persistedPosts[0].ID), http.StatusSeeOther)
 	}
 }
@@ -524,6 +555,18 @@ func BuildFormHandler(db *sql.DB, _ templating.PageRenderer) func(http.ResponseW
 			}
 		}
 
+		// posts which were already visible have had their publish hooks run
+		wasPublished := !existingPosts[0].IsDraft && !existingPosts[0].PublishDate.After(time.Now())
+		if !wasPublished && (existingPosts[0].IsDraft != updatedPosts[0].IsDraft ||
+			!existingPosts[0].PublishDate.Equal(updatedPosts[0].PublishDate)) {
+			err = jobs.EnqueuePostPublished(r.Context(), db, updatedPosts[0])
+			if err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				_, _ = w.Write([]byte(err.Error()))
+				return
+			}
+		}
+
 		http.Redirect(
 			w,
 			r,
@@ -19,6 +19,9 @@ import (
 	_ "gocloud.dev/blob/memblob"
 
 	"github.com/charlieegan3/photos/internal/pkg/database"
+	"github.com/charlieegan3/photos/internal/pkg/ingest"
+	"github.com/charlieegan3/photos/internal/pkg/jobs"
+	"github.com/charlieegan3/photos/internal/pkg/mediametadata"
 	"github.com/charlieegan3/photos/internal/pkg/models"
 	"github.com/charlieegan3/photos/internal/pkg/server/templating"
 )
@@ -44,6 +47,8 @@ func (s *EndpointsPostsSuite) SetupTest() {
 	s.Require().NoError(err)
 	err = database.Truncate(s.T().Context(), s.DB, "photos.taggings")
 	s.Require().NoError(err)
+	err = database.Truncate(s.T().Context(), s.DB, "photos.jobs")
+	s.Require().NoError(err)
 }
 
 func (s *EndpointsPostsSuite) TestListPosts() {
@@ -223,7 +228,7 @@ func (s *EndpointsPostsSuite) TestGetPost() {
 func (s *EndpointsPostsSuite) TestNewPost() {
 	router := mux.NewRouter()
 	router.HandleFunc("/admin/posts/new",
-		BuildNewHandler(s.DB, templating.BuildPageRenderFunc(true, ""))).
+		BuildNewHandler(s.DB, ingest.DefaultFavouriteRatingAbove, templating.BuildPageRenderFunc(true, ""))).
 		Methods(http.MethodGet)
 
 	req, err := http.NewRequestWithContext(s.T().Context(), http.MethodGet, "/admin/posts/new", nil)
@@ -243,6 +248,46 @@ func (s *EndpointsPostsSuite) TestNewPost() {
 	s.Contains(string(body), "MediaID")
 }
 
+func (s *EndpointsPostsSuite) TestNewPostFromXMP() {
+	devices, err := database.CreateDevices(s.T().Context(), s.DB, []models.Device{{Name: "Example Device"}})
+	s.Require().NoError(err)
+
+	medias, err := database.CreateMedias(s.T().Context(), s.DB, []models.Media{
+		{
+			DeviceID: devices[0].ID,
+			TakenAt:  time.Date(2021, time.November, 23, 19, 56, 0, 0, time.UTC),
+			MetadataTags: []models.MetadataTag{
+				{IFD: mediametadata.XMPIFD, Name: "dc:description", Type: "LangAlt", Value: "Boats at dawn"},
+				{IFD: mediametadata.XMPIFD, Name: "dc:subject", Type: "Bag", Value: []string{"sea", "boat"}},
+				{IFD: mediametadata.XMPIFD, Name: "xmp:Rating", Type: "Text", Value: "5"},
+			},
+		},
+	})
+	s.Require().NoError(err)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/admin/posts/new",
+		BuildNewHandler(s.DB, ingest.DefaultFavouriteRatingAbove, templating.BuildPageRenderFunc(true, ""))).
+		Methods(http.MethodGet)
+
+	req, err := http.NewRequestWithContext(
+		s.T().Context(), http.MethodGet, fmt.Sprintf("/admin/posts/new?mediaID=%d", medias[0].ID), nil,
+	)
+	s.Require().NoError(err)
+	rr := httptest.NewRecorder()
+
+	router.ServeHTTP(rr, req)
+
+	s.Require().Equal(http.StatusOK, rr.Code)
+
+	body, err := io.ReadAll(rr.Body)
+	s.Require().NoError(err)
+
+	s.Contains(string(body), ">Boats at dawn</textarea>")
+	s.Contains(string(body), ">sea boat</textarea>")
+	s.Contains(string(body), `name="IsFavourite" value="true" checked`)
+}
+
 func (s *EndpointsPostsSuite) TestCreatePost() {
 	devices := []models.Device{
 		{
@@ -492,6 +537,84 @@ func (s *EndpointsPostsSuite) TestUpdatePost() {
 	s.Require().Equal(tagD[0].ID, persistedTaggings[0].TagID)
 }
 
+func (s *EndpointsPostsSuite) TestUpdatePostQueuesPublishHooks() {
+	returnedDevices, err := database.CreateDevices(s.T().Context(), s.DB, []models.Device{{Name: "Example Device"}})
+	s.Require().NoError(err)
+
+	returnedMedias, err := database.CreateMedias(s.T().Context(), s.DB, []models.Media{
+		{DeviceID: returnedDevices[0].ID, Orientation: 1},
+	})
+	s.Require().NoError(err)
+
+	returnedLocations, err := database.CreateLocations(s.T().Context(), s.DB, []models.Location{{Name: "London"}})
+	s.Require().NoError(err)
+
+	publishDate := time.Date(2021, time.November, 24, 19, 56, 0, 0, time.UTC)
+	persistedPosts, err := database.CreatePosts(s.T().Context(), s.DB, []models.Post{
+		{
+			PublishDate: publishDate,
+			IsDraft:     true,
+			MediaID:     returnedMedias[0].ID,
+			LocationID:  returnedLocations[0].ID,
+		},
+	})
+	s.Require().NoError(err)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/admin/posts/{postID}",
+		BuildFormHandler(s.DB, templating.BuildPageRenderFunc(true, ""))).
+		Methods(http.MethodPost)
+
+	update := func(isDraft bool) {
+		form := url.Values{}
+		form.Add("_method", http.MethodPut)
+		form.Add("PublishDate", publishDate.Format("2006-01-02"))
+		form.Add("PublishTime", publishDate.Format("15:04"))
+		if isDraft {
+			form.Add("IsDraft", "true")
+		}
+		form.Add("MediaID", strconv.Itoa(returnedMedias[0].ID))
+		form.Add("LocationID", strconv.Itoa(returnedLocations[0].ID))
+
+		req, err := http.NewRequestWithContext(
+			s.T().Context(),
+			http.MethodPost,
+			fmt.Sprintf("/admin/posts/%d", persistedPosts[0].ID),
+			strings.NewReader(form.Encode()),
+		)
+		s.Require().NoError(err)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		s.Require().Equal(http.StatusSeeOther, rr.Code)
+	}
+
+	jobRepo := database.NewJobRepository(s.DB)
+
+	// saving a draft queues nothing
+	update(true)
+	count, err := jobRepo.Count(s.T().Context())
+	s.Require().NoError(err)
+	s.Equal(int64(0), count)
+
+	update(false)
+	queued, err := jobRepo.All(s.T().Context())
+	s.Require().NoError(err)
+	s.Require().Len(queued, 1)
+	s.Equal(jobs.KindPostPublished, queued[0].Kind)
+	s.True(publishDate.Equal(queued[0].RunAt))
+
+	err = jobRepo.Complete(s.T().Context(), queued[0].ID)
+	s.Require().NoError(err)
+
+	// the post was already published so the hooks are not run again
+	update(false)
+	count, err = jobRepo.Count(s.T().Context())
+	s.Require().NoError(err)
+	s.Equal(int64(0), count)
+}
+
 func (s *EndpointsPostsSuite) TestDeletePost() {
 	devices := []models.Device{
 		{
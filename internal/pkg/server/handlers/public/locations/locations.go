@@ -7,19 +7,19 @@ import (
 	"fmt"
 	"io"
 	"net/http"
-	"net/url"
 	"strconv"
 
 	"github.com/gobuffalo/plush"
 
 	"github.com/charlieegan3/photos/internal/pkg/models"
+	"github.com/charlieegan3/photos/internal/pkg/privacy"
 	"github.com/charlieegan3/photos/internal/pkg/server/templating"
 
 	"github.com/gorilla/mux"
-	"github.com/pkg/errors"
 	"gocloud.dev/blob"
 
 	"github.com/charlieegan3/photos/internal/pkg/database"
+	"github.com/charlieegan3/photos/internal/pkg/jobs"
 )
 
 //go:embed templates/index.html.plush
@@ -34,26 +34,6 @@ const HeadContent = `
 <script type="text/javascript" src="https://unpkg.com/maplibre-gl@1.15.2/dist/maplibre-gl.js"></script>
 `
 
-func mapURL(serverURL, apiKey string, latitude, longitude float64) (string, error) {
-	mapURL, err := url.Parse(serverURL)
-	if err != nil {
-		return "", errors.Wrap(err, "failed to parse mapURL")
-	}
-	values := url.Values{
-		"style":       []string{"osm-bright-smooth"},
-		"center":      []string{fmt.Sprintf("lonlat:%f,%f", longitude, latitude)},
-		"zoom":        []string{"10.3497"},
-		"width":       []string{"400"},
-		"height":      []string{"400"},
-		"scaleFactor": []string{"2"},
-		"marker":      []string{fmt.Sprintf("lonlat:%f,%f;type:awesome;color:#e01401", longitude, latitude)},
-		"apiKey":      []string{apiKey},
-	}
-	mapURL.RawQuery = values.Encode()
-
-	return mapURL.String(), nil
-}
-
 func BuildGetHandler(db *sql.DB, renderer templating.PageRenderer) func(http.ResponseWriter, *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
 		rawID, ok := mux.Vars(r)["locationID"]
@@ -108,8 +88,13 @@ func BuildGetHandler(db *sql.DB, renderer templating.PageRenderer) func(http.Res
 			}
 		}
 
+		location := locations[0]
+		var showMap bool
+		location.Latitude, location.Longitude, showMap = privacy.Public(location.Latitude, location.Longitude)
+
 		ctx := plush.NewContext()
-		ctx.Set("location", locations[0])
+		ctx.Set("location", location)
+		ctx.Set("showMap", showMap)
 		ctx.Set("posts", posts)
 		ctx.Set("medias", mediasByID)
 
@@ -140,7 +125,17 @@ func BuildIndexHandler(
 			return
 		}
 
-		locationsJSON, err := json.Marshal(locations)
+		// locations in privacy zones are moved or left off the map
+		public := []models.Location{}
+		for _, l := range locations {
+			var ok bool
+			l.Latitude, l.Longitude, ok = privacy.Public(l.Latitude, l.Longitude)
+			if ok {
+				public = append(public, l)
+			}
+		}
+
+		locationsJSON, err := json.Marshal(public)
 		if err != nil {
 			w.WriteHeader(http.StatusInternalServerError)
 			_, _ = w.Write([]byte(err.Error()))
@@ -163,7 +158,6 @@ func BuildIndexHandler(
 func BuildMapHandler(
 	db *sql.DB,
 	bucket *blob.Bucket,
-	mapServerURL, mapServerAPIKey string,
 ) func(http.ResponseWriter, *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "image/jpeg")
@@ -201,7 +195,13 @@ func BuildMapHandler(
 			return
 		}
 
-		mapPath := fmt.Sprintf("location_maps/%d.jpg", locations[0].ID)
+		// there is no map of locations in omit privacy zones
+		if _, _, ok := privacy.Public(locations[0].Latitude, locations[0].Longitude); !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		mapPath := jobs.LocationMapPath(locations[0])
 
 		exists, err := bucket.Exists(r.Context(), mapPath)
 		if err != nil {
@@ -210,56 +210,19 @@ func BuildMapHandler(
 			return
 		}
 
+		// maps are downloaded by a job rather than while the page waits
 		if !exists {
-			mapImageURL, err := mapURL(mapServerURL, mapServerAPIKey, locations[0].Latitude, locations[0].Longitude)
+			err = jobs.EnqueueLocationMap(r.Context(), db, locations[0].ID)
 			if err != nil {
 				w.WriteHeader(http.StatusInternalServerError)
 				_, _ = w.Write([]byte(err.Error()))
 				return
 			}
 
-			client := &http.Client{}
-
-			req, err := http.NewRequestWithContext(r.Context(), http.MethodGet, mapImageURL, nil)
-			if err != nil {
-				w.WriteHeader(http.StatusInternalServerError)
-				fmt.Fprintf(w, "failed to create request to upstream map server: %s", err)
-				return
-			}
-
-			resp, err := client.Do(req)
-			if err != nil {
-				w.WriteHeader(http.StatusInternalServerError)
-				fmt.Fprintf(w, "upstream map server request failed: %s", err)
-				return
-			}
-			defer resp.Body.Close()
-			if resp.StatusCode != http.StatusOK {
-				w.WriteHeader(http.StatusInternalServerError)
-				fmt.Fprintf(w, "upstream map server request failed: %d", resp.StatusCode)
-				return
-			}
-
-			bw, err := bucket.NewWriter(r.Context(), mapPath, nil)
-			if err != nil {
-				w.WriteHeader(http.StatusInternalServerError)
-				_, _ = w.Write([]byte("failed to open bucket to stash map"))
-				return
-			}
-
-			_, err = io.Copy(bw, resp.Body)
-			if err != nil {
-				w.WriteHeader(http.StatusInternalServerError)
-				_, _ = w.Write([]byte("failed to copy map into bucket"))
-				return
-			}
-
-			err = bw.Close()
-			if err != nil {
-				w.WriteHeader(http.StatusInternalServerError)
-				_, _ = w.Write([]byte("failed to close bucket after writing"))
-				return
-			}
+			w.Header().Set("Cache-Control", "no-store")
+			w.Header().Set("Retry-After", "10")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
 		}
 
 		br, err := bucket.NewReader(r.Context(), mapPath, nil)
@@ -21,6 +21,7 @@ import (
 	_ "gocloud.dev/blob/memblob"
 
 	"github.com/charlieegan3/photos/internal/pkg/database"
+	"github.com/charlieegan3/photos/internal/pkg/jobs"
 	"github.com/charlieegan3/photos/internal/pkg/models"
 )
 
@@ -42,6 +43,8 @@ func (s *LocationsSuite) SetupTest() {
 	s.Require().NoError(err)
 	err = database.Truncate(s.T().Context(), s.DB, "photos.devices")
 	s.Require().NoError(err)
+	err = database.Truncate(s.T().Context(), s.DB, "photos.jobs")
+	s.Require().NoError(err)
 }
 
 func (s *LocationsSuite) TestLocationsMapIndex() {
@@ -186,15 +189,29 @@ func (s *LocationsSuite) TestGetLocationMap() {
 
 	router := mux.NewRouter()
 	router.HandleFunc("/locations/{locationID}/map.jpg",
-		BuildMapHandler(s.DB, s.Bucket, mapServer.URL, "")).
+		BuildMapHandler(s.DB, s.Bucket)).
 		Methods(http.MethodGet)
 
 	req, err := http.NewRequestWithContext(
 		s.T().Context(), http.MethodGet, fmt.Sprintf("/locations/%d/map.jpg", returnedLocations[0].ID), nil,
 	)
 	s.Require().NoError(err)
+
+	// the map is queued to be downloaded on the first request
 	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	s.Equal(http.StatusServiceUnavailable, rr.Code)
+	s.Equal("10", rr.Header().Get("Retry-After"))
+	s.Equal(0, requested)
+
+	runner := jobs.NewRunner(s.DB, 1)
+	(&jobs.Handlers{DB: s.DB, Bucket: s.Bucket, MapServerURL: mapServer.URL}).Register(runner)
 
+	ran, err := runner.RunNext(s.T().Context())
+	s.Require().NoError(err)
+	s.Require().True(ran)
+
+	rr = httptest.NewRecorder()
 	router.ServeHTTP(rr, req)
 
 	rr = httptest.NewRecorder()
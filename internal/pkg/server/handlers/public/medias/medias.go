@@ -2,31 +2,18 @@ package public
 
 import (
 	"database/sql"
-	"fmt"
-	"io"
 	"net/http"
 	"strconv"
-	"strings"
 
 	"github.com/gorilla/mux"
 	"gocloud.dev/blob"
 
 	"github.com/charlieegan3/photos/internal/pkg/database"
 	"github.com/charlieegan3/photos/internal/pkg/imageproxy"
+	"github.com/charlieegan3/photos/internal/pkg/mediakind"
+	"github.com/charlieegan3/photos/internal/pkg/thumbnails"
 )
 
-var validMediaResizeSizes = map[string]bool{
-	"100,fit":  true,
-	"200,fit":  true,
-	"500,fit":  true,
-	"1000,fit": true,
-	"2000,fit": true,
-	"200x":     true,
-	"500x":     true,
-	"1000x":    true,
-	"2000x":    true,
-}
-
 func BuildMediaHandler(db *sql.DB, bucket *blob.Bucket) func(http.ResponseWriter, *http.Request) {
 	ir := imageproxy.Resizer{}
 
@@ -70,34 +57,37 @@ func BuildMediaHandler(db *sql.DB, bucket *blob.Bucket) func(http.ResponseWriter
 		}
 
 		w.Header().Set("Cache-Control", "public, max-age=604800")
-		w.Header().Set("Content-Type", "image/jpeg")
 
-		originalMediaPath := fmt.Sprintf("media/%d.%s", medias[0].ID, medias[0].Kind)
+		profileName := r.URL.Query().Get("o")
+		// if there is no profile, serve the copy of the upload without the
+		// GPS location
+		if profileName == "" {
+			err = thumbnails.EnsurePublic(r.Context(), bucket, medias[0])
+			if err != nil {
+				w.Header().Set("Content-Type", "application/text")
+				w.WriteHeader(http.StatusInternalServerError)
+				_, _ = w.Write([]byte(err.Error()))
+				return
+			}
 
-		imageResizeString := r.URL.Query().Get("o")
-		// if there are no options, serve the image from the media upload path
-		if imageResizeString == "" {
-			serveImageFromBucket(w, r, bucket, originalMediaPath)
+			w.Header().Set("Content-Type", mediakind.ContentType(thumbnails.PublicKind(medias[0])))
+			serveFromBucket(w, r, bucket, thumbnails.PublicPath(medias[0]))
 			return
 		}
 
-		// Validate resize string to prevent path injection
-		if !validMediaResizeSizes[imageResizeString] {
+		// thumbs are always jpeg, whatever the kind of the original
+		w.Header().Set("Content-Type", "image/jpeg")
+
+		// only configured profiles are served to prevent path injection
+		profile, ok := thumbnails.Find(profileName)
+		if !ok {
 			w.Header().Set("Content-Type", "application/text")
 			w.WriteHeader(http.StatusBadRequest)
 			_, _ = w.Write([]byte("Invalid resize parameter"))
 			return
 		}
 
-		// if the image is an old, '0x0' image with no size information. Replace
-		// requests for 'fit' with 'x' to use the old existing thumbs
-		if medias[0].Width == 0 || medias[0].Height == 0 {
-			imageResizeString = strings.Replace(imageResizeString, ",fit", "x", 1)
-		} else {
-			imageResizeString = strings.Replace(imageResizeString, ",", "-", 1)
-		}
-
-		thumbMediaPath := fmt.Sprintf("thumbs/media/%d-%s.jpg", medias[0].ID, imageResizeString)
+		thumbMediaPath := thumbnails.Path(medias[0], profile)
 
 		exists, err := bucket.Exists(r.Context(), thumbMediaPath)
 		if err != nil {
@@ -107,11 +97,13 @@ func BuildMediaHandler(db *sql.DB, bucket *blob.Bucket) func(http.ResponseWriter
 			return
 		}
 		if !exists {
+			// videos use their poster
 			err := ir.ResizeInBucket(
 				r.Context(),
 				bucket,
-				originalMediaPath,
-				strings.Replace(imageResizeString, "-", ",", 1),
+				thumbnails.SourcePath(medias[0]),
+				thumbnails.Edits(medias[0]),
+				thumbnails.Options(profile.ResizeString(medias[0].Width, medias[0].Height)),
 				thumbMediaPath,
 			)
 			if err != nil {
@@ -122,11 +114,13 @@ func BuildMediaHandler(db *sql.DB, bucket *blob.Bucket) func(http.ResponseWriter
 			}
 		}
 
-		serveImageFromBucket(w, r, bucket, thumbMediaPath)
+		serveFromBucket(w, r, bucket, thumbMediaPath)
 	}
 }
 
-func serveImageFromBucket(w http.ResponseWriter, r *http.Request, bucket *blob.Bucket, path string) {
+// serveFromBucket writes the object at path to the response. Range requests
+// are supported so that browsers can seek in videos without downloading them.
+func serveFromBucket(w http.ResponseWriter, r *http.Request, bucket *blob.Bucket, path string) {
 	attrs, err := bucket.Attributes(r.Context(), path)
 	if err != nil {
 		w.Header().Set("Content-Type", "application/text")
@@ -145,28 +139,13 @@ func serveImageFromBucket(w http.ResponseWriter, r *http.Request, bucket *blob.B
 		}
 	}
 
-	br, err := bucket.NewReader(r.Context(), path, nil)
-	if err != nil {
-		w.Header().Set("Content-Type", "application/text")
-		w.WriteHeader(http.StatusInternalServerError)
-		_, _ = w.Write([]byte(err.Error()))
-		return
-	}
-	defer br.Close()
-
-	_, err = io.Copy(w, br)
-	if err != nil {
-		w.Header().Set("Content-Type", "application/text")
-		w.WriteHeader(http.StatusInternalServerError)
-		fmt.Fprintf(w, "failed to copy image to response: %s", err.Error())
-		return
+	rs := &bucketReadSeeker{
+		ctx:    r.Context(),
+		bucket: bucket,
+		key:    path,
+		size:   attrs.Size,
 	}
+	defer rs.Close()
 
-	err = br.Close()
-	if err != nil {
-		w.Header().Set("Content-Type", "application/text")
-		w.WriteHeader(http.StatusInternalServerError)
-		_, _ = w.Write([]byte("failed to close bucket reader"))
-		return
-	}
+	http.ServeContent(w, r, path, attrs.ModTime, rs)
 }
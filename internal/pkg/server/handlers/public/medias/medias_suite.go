@@ -8,6 +8,9 @@ import (
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
 	"io"
 	"net/http"
 	"net/http/httptest"
@@ -245,3 +248,169 @@ func (s *MediasSuite) TestGetMediaFit() {
 	objectSha := hex.EncodeToString(h.Sum(nil))
 	s.Equal(objectSha, imageSha)
 }
+
+func (s *MediasSuite) TestGetMediaPNG() {
+	returnedDevices, err := database.CreateDevices(s.T().Context(), s.DB, []models.Device{{Name: "Example Device"}})
+	s.Require().NoError(err)
+
+	returnedMedias, err := database.CreateMedias(s.T().Context(), s.DB, []models.Media{
+		{
+			DeviceID:    returnedDevices[0].ID,
+			Kind:        "png",
+			Width:       300,
+			Height:      200,
+			Orientation: 1,
+		},
+	})
+	s.Require().NoError(err)
+
+	var original bytes.Buffer
+	err = png.Encode(&original, image.NewRGBA(image.Rect(0, 0, 300, 200)))
+	s.Require().NoError(err)
+
+	err = s.Bucket.WriteAll(
+		s.T().Context(),
+		fmt.Sprintf("media/%d.png", returnedMedias[0].ID),
+		original.Bytes(),
+		nil,
+	)
+	s.Require().NoError(err)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/medias/{mediaID}/{file}.{kind}",
+		BuildMediaHandler(s.DB, s.Bucket)).
+		Methods(http.MethodGet)
+
+	// the original is served with the content type of its kind
+	req, err := http.NewRequestWithContext(
+		s.T().Context(), http.MethodGet, fmt.Sprintf("/medias/%d/file.png", returnedMedias[0].ID), nil,
+	)
+	s.Require().NoError(err)
+	rr := httptest.NewRecorder()
+
+	router.ServeHTTP(rr, req)
+
+	s.Require().Equal(http.StatusOK, rr.Code)
+	s.Equal("image/png", rr.Header().Get("Content-Type"))
+	s.Equal(original.Bytes(), rr.Body.Bytes())
+
+	// thumbs are converted to jpeg
+	req, err = http.NewRequestWithContext(
+		s.T().Context(), http.MethodGet, fmt.Sprintf("/medias/%d/file.jpg?o=thumb", returnedMedias[0].ID), nil,
+	)
+	s.Require().NoError(err)
+	rr = httptest.NewRecorder()
+
+	router.ServeHTTP(rr, req)
+
+	s.Require().Equal(http.StatusOK, rr.Code)
+	s.Equal("image/jpeg", rr.Header().Get("Content-Type"))
+
+	config, format, err := image.DecodeConfig(rr.Body)
+	s.Require().NoError(err)
+	s.Equal("jpeg", format)
+	s.Equal(100, config.Width)
+
+	// only configured profiles can be requested
+	req, err = http.NewRequestWithContext(
+		s.T().Context(), http.MethodGet, fmt.Sprintf("/medias/%d/file.jpg?o=300,fit", returnedMedias[0].ID), nil,
+	)
+	s.Require().NoError(err)
+	rr = httptest.NewRecorder()
+
+	router.ServeHTTP(rr, req)
+
+	s.Equal(http.StatusBadRequest, rr.Code)
+}
+
+func (s *MediasSuite) TestGetMediaVideoRange() {
+	returnedDevices, err := database.CreateDevices(s.T().Context(), s.DB, []models.Device{{Name: "Example Device"}})
+	s.Require().NoError(err)
+
+	returnedMedias, err := database.CreateMedias(s.T().Context(), s.DB, []models.Media{
+		{
+			DeviceID: returnedDevices[0].ID,
+			Kind:     "mov",
+		},
+	})
+	s.Require().NoError(err)
+
+	err = s.Bucket.WriteAll(
+		s.T().Context(),
+		fmt.Sprintf("media/%d.mov", returnedMedias[0].ID),
+		[]byte("0123456789"),
+		nil,
+	)
+	s.Require().NoError(err)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/medias/{mediaID}/{file}.{kind}",
+		BuildMediaHandler(s.DB, s.Bucket)).
+		Methods(http.MethodGet)
+
+	req, err := http.NewRequestWithContext(
+		s.T().Context(), http.MethodGet, fmt.Sprintf("/medias/%d/file.mov", returnedMedias[0].ID), nil,
+	)
+	s.Require().NoError(err)
+	req.Header.Set("Range", "bytes=2-5")
+	rr := httptest.NewRecorder()
+
+	router.ServeHTTP(rr, req)
+
+	s.Require().Equal(http.StatusPartialContent, rr.Code)
+	s.Equal("video/quicktime", rr.Header().Get("Content-Type"))
+	s.Equal("bytes 2-5/10", rr.Header().Get("Content-Range"))
+	s.Equal("2345", rr.Body.String())
+}
+
+func (s *MediasSuite) TestGetMediaStripsGPS() {
+	returnedDevices, err := database.CreateDevices(s.T().Context(), s.DB, []models.Device{{Name: "Example Device"}})
+	s.Require().NoError(err)
+
+	returnedMedias, err := database.CreateMedias(s.T().Context(), s.DB, []models.Media{
+		{
+			DeviceID:    returnedDevices[0].ID,
+			Kind:        "jpg",
+			Width:       300,
+			Height:      200,
+			Orientation: 1,
+		},
+	})
+	s.Require().NoError(err)
+
+	var encoded bytes.Buffer
+	err = jpeg.Encode(&encoded, image.NewRGBA(image.Rect(0, 0, 300, 200)), nil)
+	s.Require().NoError(err)
+
+	// add an XMP segment with a location after the start of image marker
+	xmp := []byte(`http://ns.adobe.com/xap/1.0/` + "\x00" +
+		`<x:xmpmeta><rdf:Description exif:GPSLatitude="51,30.0N"/></x:xmpmeta>`)
+	segment := append([]byte{0xff, 0xe1, byte((len(xmp) + 2) >> 8), byte(len(xmp) + 2)}, xmp...)
+	original := bytes.Join([][]byte{encoded.Bytes()[:2], segment, encoded.Bytes()[2:]}, nil)
+
+	originalPath := fmt.Sprintf("media/%d.jpg", returnedMedias[0].ID)
+	err = s.Bucket.WriteAll(s.T().Context(), originalPath, original, nil)
+	s.Require().NoError(err)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/medias/{mediaID}/{file}.{kind}",
+		BuildMediaHandler(s.DB, s.Bucket)).
+		Methods(http.MethodGet)
+
+	req, err := http.NewRequestWithContext(
+		s.T().Context(), http.MethodGet, fmt.Sprintf("/medias/%d/file.jpg", returnedMedias[0].ID), nil,
+	)
+	s.Require().NoError(err)
+	rr := httptest.NewRecorder()
+
+	router.ServeHTTP(rr, req)
+
+	s.Require().Equal(http.StatusOK, rr.Code)
+	s.Len(rr.Body.Bytes(), len(original))
+	s.NotContains(rr.Body.String(), "51,30.0N")
+
+	// the original keeps its location
+	stored, err := s.Bucket.ReadAll(s.T().Context(), originalPath)
+	s.Require().NoError(err)
+	s.Equal(original, stored)
+}
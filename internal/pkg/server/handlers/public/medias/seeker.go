@@ -0,0 +1,85 @@
+package public
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"gocloud.dev/blob"
+)
+
+// bucketReadSeeker reads an object in a bucket from any offset, this is needed
+// by http.ServeContent to respond to range requests. A range reader is opened
+// from the current offset on the first read after each seek.
+type bucketReadSeeker struct {
+	ctx    context.Context
+	bucket *blob.Bucket
+	key    string
+	size   int64
+
+	offset int64
+	reader *blob.Reader
+}
+
+func (s *bucketReadSeeker) Read(p []byte) (int, error) {
+	if s.offset >= s.size {
+		return 0, io.EOF
+	}
+
+	if s.reader == nil {
+		reader, err := s.bucket.NewRangeReader(s.ctx, s.key, s.offset, -1, nil)
+		if err != nil {
+			return 0, fmt.Errorf("failed to create range reader: %w", err)
+		}
+		s.reader = reader
+	}
+
+	n, err := s.reader.Read(p)
+	s.offset += int64(n)
+
+	return n, err
+}
+
+func (s *bucketReadSeeker) Seek(offset int64, whence int) (int64, error) {
+	var next int64
+	switch whence {
+	case io.SeekStart:
+		next = offset
+	case io.SeekCurrent:
+		next = s.offset + offset
+	case io.SeekEnd:
+		next = s.size + offset
+	default:
+		return 0, errors.New("invalid whence")
+	}
+
+	if next < 0 {
+		return 0, errors.New("negative position")
+	}
+
+	if next != s.offset {
+		err := s.Close()
+		if err != nil {
+			return 0, err
+		}
+		s.offset = next
+	}
+
+	return next, nil
+}
+
+// Close closes the current range reader, if any.
+func (s *bucketReadSeeker) Close() error {
+	if s.reader == nil {
+		return nil
+	}
+
+	err := s.reader.Close()
+	s.reader = nil
+	if err != nil {
+		return fmt.Errorf("failed to close bucket reader: %w", err)
+	}
+
+	return nil
+}
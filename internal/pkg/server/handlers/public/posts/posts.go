@@ -19,7 +19,9 @@ import (
 
 	"github.com/charlieegan3/photos/internal/pkg/database"
 	"github.com/charlieegan3/photos/internal/pkg/models"
+	"github.com/charlieegan3/photos/internal/pkg/privacy"
 	"github.com/charlieegan3/photos/internal/pkg/server/templating"
+	"github.com/charlieegan3/photos/internal/pkg/thumbnails"
 )
 
 //go:embed templates/index.html.plush
@@ -332,11 +334,15 @@ func BuildGetHandler(db *sql.DB, renderer templating.PageRenderer) func(http.Res
 		ctx.Set("location", locations[0])
 		ctx.Set("tags", tags)
 
+		// there is no map of locations in omit privacy zones
+		_, _, showMap := privacy.Public(locations[0].Latitude, locations[0].Longitude)
+		ctx.Set("showMap", showMap)
+
 		randomParam := r.URL.Query().Get("random")
 		ctx.Set("isRandom", randomParam == "true")
 
-		// Determine effective display dimensions based on orientation
-		effectiveWidth, effectiveHeight := getEffectiveDimensions(medias[0].Width, medias[0].Height, medias[0].Orientation)
+		// Determine effective display dimensions based on orientation and edits
+		effectiveWidth, effectiveHeight := thumbnails.EffectiveDimensions(medias[0])
 
 		// Calculate aspect ratio for placeholder container
 		aspectRatio := float64(effectiveWidth) / float64(effectiveHeight)
@@ -702,7 +708,10 @@ func BuildRSSHandler(db *sql.DB) func(http.ResponseWriter, *http.Request) {
 		for i := range posts {
 			md := fmt.Sprintf("%s\n\n%s\n\n%s",
 				posts[i].Description,
-				fmt.Sprintf("![post image](https://photos.charlieegan3.com/medias/%d/image.jpg?o=1000,fit)", posts[i].MediaID),
+				fmt.Sprintf(
+					"![post image](https://photos.charlieegan3.com%s)",
+					thumbnails.URL(posts[i].MediaID, thumbnails.Nearest(1000)),
+				),
 				"Taken on "+deviceMap[mediaMap[posts[i].MediaID].DeviceID].Name,
 			)
 
@@ -876,15 +885,3 @@ func BuildRandomHandler(db *sql.DB) func(http.ResponseWriter, *http.Request) {
 		http.Redirect(w, r, fmt.Sprintf("/posts/%d?random=true", postID), http.StatusSeeOther)
 	}
 }
-
-// getEffectiveDimensions returns the effective display dimensions of an image
-// considering EXIF orientation. For orientations 6 and 8 (90° rotations),
-// width and height are swapped.
-func getEffectiveDimensions(width, height, orientation int) (int, int) {
-	switch orientation {
-	case 6, 8: // 90° rotations - swap dimensions
-		return height, width
-	default: // 1 (normal), 3 (180°), or any other value - keep original
-		return width, height
-	}
-}
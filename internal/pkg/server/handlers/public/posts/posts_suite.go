@@ -143,10 +143,18 @@ func (s *PostsSuite) TestGetPost() {
 			FNumber:  2.0,
 			ISOSpeed: 100,
 
+			ExposureCompensation: 0.7,
+			ExposureProgram:      "Aperture priority",
+			MeteringMode:         "Pattern",
+			Flash:                "Not fired",
+
 			Latitude:  51.1,
 			Longitude: 52.2,
 			Altitude:  100.0,
 
+			ImageDirection:    90,
+			ImageDirectionRef: "T",
+
 			Orientation: 1,
 		},
 	}
@@ -205,6 +213,8 @@ func (s *PostsSuite) TestGetPost() {
 
 	s.Contains(string(body), "Here is a shot I took")
 	s.NotContains(string(body), "another photo")
+	s.Contains(string(body),
+		"+0.7 EV &middot; Aperture priority &middot; Pattern metering &middot; No flash &middot; Facing E 90°")
 }
 
 func (s *PostsSuite) TestPeriodHandler() {
@@ -510,7 +520,7 @@ func (s *PostsSuite) TestRSS() {
         <title>November 25, 2021 - London</title>
         <link>https://photos.charlieegan3.com/posts/%d</link>
         <description>&lt;p&gt;Here is photo I took&lt;/p&gt;&#xA;&#xA;&lt;p&gt;&lt;img `+
-		`src=&#34;https://photos.charlieegan3.com/medias/%d/image.jpg?o=1000,fit&#34; `+
+		`src=&#34;https://photos.charlieegan3.com/medias/%d/image.jpg?o=large&#34; `+
 		`alt=&#34;post image&#34; /&gt;&lt;/p&gt;&#xA;&#xA;&lt;p&gt;Taken on Example Device&lt;/p&gt;&#xA;</description>
         <guid>https://photos.charlieegan3.com/posts/%d</guid>
         <pubDate>Thu, 25 Nov 2021 19:56:00 +0000</pubDate>
@@ -1,8 +1,10 @@
 package public
 
 import (
+	"context"
 	"database/sql"
 	_ "embed"
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"strconv"
@@ -13,13 +15,61 @@ import (
 
 	"github.com/charlieegan3/photos/internal/pkg/database"
 	"github.com/charlieegan3/photos/internal/pkg/models"
+	"github.com/charlieegan3/photos/internal/pkg/privacy"
+	"github.com/charlieegan3/photos/internal/pkg/route"
 	"github.com/charlieegan3/photos/internal/pkg/server/templating"
 )
 
 //go:embed templates/show.html.plush
 var showTemplate string
 
-func BuildGetHandler(db *sql.DB, renderer templating.PageRenderer) func(http.ResponseWriter, *http.Request) {
+// mapMarker is a post location shown on the trip map.
+type mapMarker struct {
+	PostID    int
+	Name      string
+	Latitude  float64
+	Longitude float64
+}
+
+// routeLines loads the activity points recorded during the trip and returns
+// them as simplified [longitude, latitude] lines, one per activity. Lines
+// are cut where they pass through privacy zones.
+func routeLines(ctx context.Context, db *sql.DB, from, to time.Time) ([][][2]float64, error) {
+	points, err := database.NewActivityRepository(db).PointsBetween(ctx, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load trip route: %w", err)
+	}
+
+	lines := [][][2]float64{}
+	for _, segment := range route.Segments(points) {
+		simplified := route.Simplify(segment, route.DefaultTolerance)
+
+		line := make([][2]float64, 0, len(simplified))
+		for i := range simplified {
+			if _, ok := privacy.Find(simplified[i].Latitude, simplified[i].Longitude); ok {
+				if len(line) > 0 {
+					lines = append(lines, line)
+				}
+				line = [][2]float64{}
+				continue
+			}
+
+			line = append(line, [2]float64{simplified[i].Longitude, simplified[i].Latitude})
+		}
+
+		if len(line) > 0 {
+			lines = append(lines, line)
+		}
+	}
+
+	return lines, nil
+}
+
+func BuildGetHandler(
+	db *sql.DB,
+	mapServerAPIKey string,
+	renderer templating.PageRenderer,
+) func(http.ResponseWriter, *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "text/html; charset=UTF-8")
 
@@ -137,6 +187,47 @@ func BuildGetHandler(db *sql.DB, renderer templating.PageRenderer) func(http.Res
 			postGroups[key] = append(postGroups[key], posts[i])
 		}
 
+		lines, err := routeLines(r.Context(), db, trip.StartDate, toTime)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = w.Write([]byte(err.Error()))
+			return
+		}
+
+		markers := []mapMarker{}
+		for i := range posts {
+			location, ok := locationsByID[posts[i].LocationID]
+			if !ok || (location.Latitude == 0 && location.Longitude == 0) {
+				continue
+			}
+
+			latitude, longitude, ok := privacy.Public(location.Latitude, location.Longitude)
+			if !ok {
+				continue
+			}
+
+			markers = append(markers, mapMarker{
+				PostID:    posts[i].ID,
+				Name:      location.Name,
+				Latitude:  latitude,
+				Longitude: longitude,
+			})
+		}
+
+		routeJSON, err := json.Marshal(lines)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = w.Write([]byte(err.Error()))
+			return
+		}
+
+		markersJSON, err := json.Marshal(markers)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = w.Write([]byte(err.Error()))
+			return
+		}
+
 		showDates := trip.StartDate.Add(24 * time.Hour).Before(toTime)
 
 		if len(postGroupKeys) == 1 {
@@ -153,6 +244,10 @@ func BuildGetHandler(db *sql.DB, renderer templating.PageRenderer) func(http.Res
 		ctx.Set("timeFormat", timeFormat)
 		ctx.Set("dateTitle", dateTitle)
 		ctx.Set("showDates", showDates)
+		ctx.Set("showMap", len(lines) > 0 || len(markers) > 0)
+		ctx.Set("route", string(routeJSON))
+		ctx.Set("markers", string(markersJSON))
+		ctx.Set("api_key", mapServerAPIKey)
 
 		err = renderer(ctx, showTemplate, w)
 		if err != nil {
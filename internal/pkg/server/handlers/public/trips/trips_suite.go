@@ -37,6 +37,8 @@ func (s *TripsSuite) SetupTest() {
 	s.Require().NoError(err)
 	err = database.Truncate(s.T().Context(), s.DB, "photos.trips")
 	s.Require().NoError(err)
+	err = database.Truncate(s.T().Context(), s.DB, "photos.activities")
+	s.Require().NoError(err)
 }
 
 func (s *TripsSuite) TestGetTrip() {
@@ -89,7 +91,7 @@ func (s *TripsSuite) TestGetTrip() {
 	router := mux.NewRouter()
 	router.HandleFunc(
 		"/trips/{tripID}",
-		BuildGetHandler(s.DB, templating.BuildPageRenderFunc(true, "")),
+		BuildGetHandler(s.DB, "", templating.BuildPageRenderFunc(true, "")),
 	).Methods(http.MethodGet)
 
 	req, err := http.NewRequestWithContext(
@@ -117,3 +119,79 @@ func (s *TripsSuite) TestGetTrip() {
 	s.Contains(string(body), `post from london`)
 	s.NotContains(string(body), `New York`)
 }
+
+func (s *TripsSuite) TestGetTripRoute() {
+	devices := []models.Device{{Name: "Example Device"}}
+	returnedDevices, err := database.CreateDevices(s.T().Context(), s.DB, devices)
+	s.Require().NoError(err)
+
+	returnedMedias, err := database.CreateMedias(s.T().Context(), s.DB, []models.Media{
+		{DeviceID: returnedDevices[0].ID, Orientation: 1},
+	})
+	s.Require().NoError(err)
+
+	returnedLocations, err := database.CreateLocations(s.T().Context(), s.DB, []models.Location{
+		{Name: "Edinburgh", Latitude: 55.95, Longitude: -3.19},
+	})
+	s.Require().NoError(err)
+
+	returnedTrips, err := database.CreateTrips(s.T().Context(), s.DB, []models.Trip{
+		{
+			Title:     "Edinburgh",
+			StartDate: time.Date(2021, time.January, 1, 0, 0, 0, 0, time.UTC),
+			EndDate:   time.Date(2021, time.January, 2, 0, 0, 0, 0, time.UTC),
+		},
+	})
+	s.Require().NoError(err)
+
+	_, err = database.CreatePosts(s.T().Context(), s.DB, []models.Post{
+		{
+			Description: "post from edinburgh",
+			PublishDate: time.Date(2021, time.January, 1, 19, 56, 0, 0, time.UTC),
+			MediaID:     returnedMedias[0].ID,
+			LocationID:  returnedLocations[0].ID,
+		},
+	})
+	s.Require().NoError(err)
+
+	startTime := time.Date(2021, time.January, 1, 10, 0, 0, 0, time.UTC)
+	_, err = database.NewActivityRepository(s.DB).CreateWithPoints(s.T().Context(), models.Activity{
+		Title:     "Walk",
+		StartTime: startTime,
+		EndTime:   startTime.Add(2 * time.Minute),
+	}, []models.Point{
+		{Timestamp: startTime, Latitude: 55.9, Longitude: -3.2},
+		{Timestamp: startTime.Add(time.Minute), Latitude: 55.91, Longitude: -3.2},
+		{Timestamp: startTime.Add(2 * time.Minute), Latitude: 55.92, Longitude: -3.2},
+	})
+	s.Require().NoError(err)
+
+	router := mux.NewRouter()
+	router.HandleFunc(
+		"/trips/{tripID}",
+		BuildGetHandler(s.DB, "", templating.BuildPageRenderFunc(true, "")),
+	).Methods(http.MethodGet)
+
+	req, err := http.NewRequestWithContext(
+		s.T().Context(),
+		http.MethodGet, fmt.Sprintf("/trips/%d", returnedTrips[0].ID), nil,
+	)
+	s.Require().NoError(err)
+	rr := httptest.NewRecorder()
+
+	router.ServeHTTP(rr, req)
+
+	if !s.Equal(http.StatusOK, rr.Code) {
+		bodyString, err := io.ReadAll(rr.Body)
+		s.Require().NoError(err)
+		s.T().Fatalf("request failed with: %s", bodyString)
+	}
+
+	body, err := io.ReadAll(rr.Body)
+	s.Require().NoError(err)
+
+	s.Contains(string(body), `trip-map`)
+	// the middle point is on a straight line and is simplified away
+	s.Contains(string(body), `[[[-3.2,55.9],[-3.2,55.92]]]`)
+	s.Contains(string(body), `"Name":"Edinburgh"`)
+}
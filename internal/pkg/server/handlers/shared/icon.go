@@ -7,19 +7,14 @@ import (
 	"io"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"gocloud.dev/blob"
 
 	"github.com/charlieegan3/photos/internal/pkg/imageproxy"
+	"github.com/charlieegan3/photos/internal/pkg/thumbnails"
 )
 
-var validResizeSizes = map[string]bool{
-	"100x":  true,
-	"200x":  true,
-	"500x":  true,
-	"1000x": true,
-}
-
 type IconEntity interface {
 	GetID() int64
 	GetIconPath() string
@@ -115,18 +110,21 @@ func BuildIconHandler[T any](
 			contentType = "image/jpeg" // fallback
 		}
 		w.Header().Set("Content-Type", contentType)
-		imageResizeString := r.URL.Query().Get("o")
-
-		// Validate resize string to prevent path injection
-		if imageResizeString != "" {
-			if !validResizeSizes[imageResizeString] {
+		// icons use the media thumbnail profiles, resized by width only. Only
+		// configured profiles are served to prevent path injection
+		var imageResizeString string
+		if profileName := r.URL.Query().Get("o"); profileName != "" {
+			profile, ok := thumbnails.Find(profileName)
+			if !ok {
 				WriteError(w, http.StatusBadRequest, "Invalid resize parameter")
 				return
 			}
+
+			imageResizeString = profile.ResizeString(0, 0)
 		}
 
 		originalIconPath := icon.GetIconPath()
-		thumbIconPath := icon.GetThumbPath(imageResizeString)
+		thumbIconPath := icon.GetThumbPath(strings.ReplaceAll(imageResizeString, ",", "-"))
 
 		if imageResizeString == "" {
 			attrs, err := bucket.Attributes(r.Context(), originalIconPath)
@@ -154,7 +152,7 @@ func BuildIconHandler[T any](
 		}
 
 		ir := imageproxy.Resizer{}
-		err = ir.ResizeInBucket(r.Context(), bucket, originalIconPath, imageResizeString, thumbIconPath)
+		err = ir.ResizeInBucket(r.Context(), bucket, originalIconPath, imageproxy.Edits{}, imageResizeString, thumbIconPath)
 		if err != nil {
 			WriteError(w, http.StatusInternalServerError, err.Error())
 			return
@@ -1,6 +1,7 @@
 package server
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"log"
@@ -13,10 +14,14 @@ import (
 	"gocloud.dev/blob"
 	_ "gocloud.dev/blob/fileblob"
 
+	"github.com/charlieegan3/photos/internal/pkg/geotag"
+	"github.com/charlieegan3/photos/internal/pkg/ingest"
+	"github.com/charlieegan3/photos/internal/pkg/jobs"
 	"github.com/charlieegan3/photos/internal/pkg/server/handlers"
 	"github.com/charlieegan3/photos/internal/pkg/server/handlers/admin"
 	"github.com/charlieegan3/photos/internal/pkg/server/handlers/admin/collections"
 	"github.com/charlieegan3/photos/internal/pkg/server/handlers/admin/devices"
+	adminjobs "github.com/charlieegan3/photos/internal/pkg/server/handlers/admin/jobs"
 	"github.com/charlieegan3/photos/internal/pkg/server/handlers/admin/lenses"
 	"github.com/charlieegan3/photos/internal/pkg/server/handlers/admin/locations"
 	"github.com/charlieegan3/photos/internal/pkg/server/handlers/admin/medias"
@@ -42,10 +47,12 @@ func Attach(
 	router *mux.Router,
 	db *sql.DB,
 	bucket *blob.Bucket,
-	mapServerURL, mapServerAPIKey string,
+	mapServerAPIKey string,
 	adminPath string,
 	environment string,
 	permittedEmailSuffix string,
+	geotagMaxGap time.Duration,
+	favouriteRatingAbove int,
 ) error {
 	renderer := templating.BuildPageRenderFunc(true, "")
 	rendererMenu := templating.BuildPageRenderFunc(false, "")
@@ -95,7 +102,7 @@ func Attach(
 		Methods(http.MethodGet)
 	router.HandleFunc("/locations/{locationID}", publiclocations.BuildGetHandler(db, renderer)).Methods(http.MethodGet)
 	router.HandleFunc("/locations/{locationID}/map.jpg",
-		publiclocations.BuildMapHandler(db, bucket, mapServerURL, mapServerAPIKey)).
+		publiclocations.BuildMapHandler(db, bucket)).
 		Methods(http.MethodGet)
 
 	router.HandleFunc("/medias/{mediaID}/{file}.{kind}",
@@ -110,7 +117,8 @@ func Attach(
 
 	router.HandleFunc("/random", publicposts.BuildRandomHandler(db)).Methods(http.MethodGet)
 
-	router.HandleFunc("/trips/{tripID}", publictrips.BuildGetHandler(db, renderer)).Methods(http.MethodGet)
+	router.HandleFunc("/trips/{tripID}",
+		publictrips.BuildGetHandler(db, mapServerAPIKey, rendererMap)).Methods(http.MethodGet)
 
 	router.HandleFunc("/collections", publiccollections.BuildIndexHandler(db, renderer)).Methods(http.MethodGet)
 	router.HandleFunc("/collections/{collectionID}",
@@ -158,15 +166,23 @@ func Attach(
 		Methods(http.MethodPost)
 
 	adminRouter.HandleFunc("/medias", medias.BuildIndexHandler(db, rendererAdmin)).Methods(http.MethodGet)
-	adminRouter.HandleFunc("/medias", medias.BuildCreateHandler(db, bucket, rendererAdmin)).Methods(http.MethodPost)
+	adminRouter.HandleFunc("/medias", medias.BuildCreateHandler(db, bucket, geotagMaxGap, rendererAdmin)).Methods(http.MethodPost)
 	adminRouter.HandleFunc("/medias/new", medias.BuildNewHandler(db, rendererAdmin)).Methods(http.MethodGet)
+	adminRouter.HandleFunc("/medias/similar", medias.BuildSimilarHandler(db, rendererAdmin)).Methods(http.MethodGet)
+	adminRouter.HandleFunc("/medias/similar", medias.BuildRemoveSimilarHandler(db, bucket)).Methods(http.MethodPost)
 	adminRouter.HandleFunc("/medias/{mediaID}", medias.BuildGetHandler(db, rendererAdmin)).Methods(http.MethodGet)
+	adminRouter.HandleFunc("/medias/{mediaID}/preview.jpg",
+		medias.BuildPreviewHandler(db, bucket)).Methods(http.MethodGet)
+	adminRouter.HandleFunc("/medias/{mediaID}/metadata",
+		medias.BuildReextractMetadataHandler(db, bucket)).Methods(http.MethodPost)
+	adminRouter.HandleFunc("/medias/{mediaID}/original",
+		medias.BuildOriginalHandler(db, bucket)).Methods(http.MethodGet)
 	adminRouter.HandleFunc("/medias/{mediaID}",
 		medias.BuildFormHandler(db, bucket, rendererAdmin)).Methods(http.MethodPost)
 
 	adminRouter.HandleFunc("/posts", posts.BuildIndexHandler(db, rendererAdmin)).Methods(http.MethodGet)
 	adminRouter.HandleFunc("/posts", posts.BuildCreateHandler(db, rendererAdmin)).Methods(http.MethodPost)
-	adminRouter.HandleFunc("/posts/new", posts.BuildNewHandler(db, rendererAdmin)).Methods(http.MethodGet)
+	adminRouter.HandleFunc("/posts/new", posts.BuildNewHandler(db, favouriteRatingAbove, rendererAdmin)).Methods(http.MethodGet)
 	adminRouter.HandleFunc("/posts/{postID}", posts.BuildGetHandler(db, rendererAdmin)).Methods(http.MethodGet)
 	adminRouter.HandleFunc("/posts/{postID}", posts.BuildFormHandler(db, rendererAdmin)).Methods(http.MethodPost)
 
@@ -176,6 +192,9 @@ func Attach(
 	adminRouter.HandleFunc("/trips/{tripID}", trips.BuildGetHandler(db, rendererAdmin)).Methods(http.MethodGet)
 	adminRouter.HandleFunc("/trips/{tripID}", trips.BuildFormHandler(db, rendererAdmin)).Methods(http.MethodPost)
 
+	adminRouter.HandleFunc("/jobs", adminjobs.BuildIndexHandler(db, rendererAdmin)).Methods(http.MethodGet)
+	adminRouter.HandleFunc("/jobs/{jobID}", adminjobs.BuildFormHandler(db, rendererAdmin)).Methods(http.MethodPost)
+
 	adminRouter.HandleFunc("/collections", collections.BuildIndexHandler(db, rendererAdmin)).Methods(http.MethodGet)
 	adminRouter.HandleFunc("/collections", collections.BuildCreateHandler(db, rendererAdmin)).Methods(http.MethodPost)
 	adminRouter.HandleFunc("/collections/new", collections.BuildNewHandler(rendererAdmin)).Methods(http.MethodGet)
@@ -202,20 +221,51 @@ func Serve(
 	// Email authentication configuration for reverse proxy
 	permittedEmailSuffix := viper.GetString("admin.auth.permitted_email_suffix")
 
+	// media without GPS data are located using activity points within this gap
+	geotagMaxGap := viper.GetDuration("geotag.maxGap")
+	if geotagMaxGap == 0 {
+		geotagMaxGap = geotag.DefaultMaxGap
+	}
+
+	// new posts are marked as favourites when their XMP rating is above this
+	favouriteRatingAbove := ingest.DefaultFavouriteRatingAbove
+	if viper.IsSet("upload.favouriteRatingAbove") {
+		favouriteRatingAbove = viper.GetInt("upload.favouriteRatingAbove")
+	}
+
 	err := Attach(
 		router,
 		db,
 		bucket,
-		mapServerURL,
 		mapServerAPIKey,
 		"/admin",
 		environment,
 		permittedEmailSuffix,
+		geotagMaxGap,
+		favouriteRatingAbove,
 	)
 	if err != nil {
 		log.Fatal(err)
 	}
 
+	// uploads, maps and publish hooks queue work for these workers rather
+	// than doing it in the request
+	runner := jobs.NewRunner(db, viper.GetInt("jobs.workers"))
+	(&jobs.Handlers{
+		DB:              db,
+		Bucket:          bucket,
+		MapServerURL:    mapServerURL,
+		MapServerAPIKey: mapServerAPIKey,
+		WebhookURL:      viper.GetString("notification_webhook.endpoint"),
+		BaseURL:         "https://" + hostname,
+	}).Register(runner)
+	go func() {
+		err := runner.Run(context.Background())
+		if err != nil {
+			log.Fatal(err)
+		}
+	}()
+
 	// Check if port is available before starting server
 	serverAddr := fmt.Sprintf("%s:%s", addr, port)
 	listener, err := net.Listen("tcp", serverAddr)
@@ -228,7 +278,8 @@ func Serve(
 		Handler:      router,
 		Addr:         serverAddr,
 		WriteTimeout: 30 * time.Second,
-		// this is set to 3 mins to allow many images to resized in a queue
+		// this is set to 3 mins to allow large uploads, such as videos, on slow
+		// connections. Thumbnails are made afterwards by the job runner.
 		ReadTimeout: 180 * time.Second,
 	}
 
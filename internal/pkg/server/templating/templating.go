@@ -13,7 +13,10 @@ import (
 	"github.com/gomarkdown/markdown"
 	"github.com/pkg/errors"
 
+	"github.com/charlieegan3/photos/internal/pkg/mediakind"
 	"github.com/charlieegan3/photos/internal/pkg/models"
+	"github.com/charlieegan3/photos/internal/pkg/placeholder"
+	"github.com/charlieegan3/photos/internal/pkg/thumbnails"
 )
 
 //go:embed base.html
@@ -22,6 +25,85 @@ var baseTemplate string
 //go:embed base.admin.html
 var baseTemplateAdmin string
 
+// displayOffset is the CSS position of a media's image when cropped to a
+// square, the offset is along the longer side of the image as displayed.
+func displayOffset(media models.Media) string {
+	x := 50
+	y := 50
+
+	width, height := thumbnails.EffectiveDimensions(media)
+	if width > height {
+		x = media.DisplayOffset
+		y = 0
+	} else if width < height {
+		y = media.DisplayOffset
+		x = 0
+	}
+
+	return fmt.Sprintf("%d%% %d%%", x, y)
+}
+
+// compassPoints are the names of the directions at each 45 degrees from north.
+var compassPoints = []string{"N", "NE", "E", "SE", "S", "SW", "W", "NW"}
+
+// cameraDetails returns the exposure details, direction and GPS time of a
+// media for display, details which are unknown are left out.
+func cameraDetails(media models.Media) []string {
+	var details []string
+
+	if media.ExposureCompensation != 0 {
+		details = append(details, fmt.Sprintf("%+.1f EV", media.ExposureCompensation))
+	}
+
+	if media.ExposureProgram != "" {
+		details = append(details, media.ExposureProgram)
+	}
+
+	if media.MeteringMode != "" {
+		details = append(details, media.MeteringMode+" metering")
+	}
+
+	switch media.Flash {
+	case "Fired":
+		details = append(details, "Flash fired")
+	case "Not fired":
+		details = append(details, "No flash")
+	}
+
+	if media.WhiteBalance != "" {
+		details = append(details, media.WhiteBalance+" white balance")
+	}
+
+	switch {
+	case media.SubjectDistance == models.SubjectDistanceInfinity:
+		details = append(details, "Subject at infinity")
+	case media.SubjectDistance > 0:
+		details = append(details, fmt.Sprintf("Subject at %sm",
+			strings.TrimSuffix(strings.TrimRight(fmt.Sprintf("%.2f", media.SubjectDistance), "0"), ".")))
+	}
+
+	if media.ImageDirectionRef != "" {
+		direction := math.Mod(media.ImageDirection, 360)
+		point := compassPoints[int(math.Round(direction/45))%len(compassPoints)]
+
+		facing := fmt.Sprintf("Facing %s %.0f°", point, direction)
+		if media.ImageDirectionRef == "M" {
+			facing += " magnetic"
+		}
+		details = append(details, facing)
+	}
+
+	if media.AltitudeRef == 1 {
+		details = append(details, "Below sea level")
+	}
+
+	if !media.GPSTime.IsZero() {
+		details = append(details, "GPS time "+media.GPSTime.UTC().Format("2006-01-02 15:04:05")+" UTC")
+	}
+
+	return details
+}
+
 type PageRenderer func(*plush.Context, string, io.Writer) error
 
 func BuildPageRenderFunc(showMenu bool, headContent string, intermediateTemplates ...string) PageRenderer {
@@ -49,30 +131,54 @@ func BuildPageRenderFunc(showMenu bool, headContent string, intermediateTemplate
 			return s[:length]
 		})
 
-		ctx.Set("display_offset", func(media models.Media) string {
-			x := 50
-			y := 50
+		ctx.Set("display_offset", displayOffset)
 
-			isRotated := media.Orientation == 6 || media.Orientation == 8
+		// placeholder returns the CSS for the background of a media's img,
+		// shown until the thumbnail loads. The blurred image has the aspect
+		// ratio of the media and is positioned like the thumbnail.
+		ctx.Set("placeholder", func(media models.Media) string {
+			var rules []string
+			if media.DominantColour != "" {
+				rules = append(rules, "background-color: "+media.DominantColour)
+			}
 
-			if isRotated {
-				if media.Width < media.Height {
-					x = media.DisplayOffset
-					y = 0
-				} else if media.Width > media.Height {
-					y = media.DisplayOffset
-					x = 0
-				}
-			} else {
-				if media.Width > media.Height {
-					x = media.DisplayOffset
-					y = 0
-				} else if media.Width < media.Height {
-					y = media.DisplayOffset
-					x = 0
+			if media.BlurHash != "" {
+				width, height := thumbnails.EffectiveDimensions(media)
+				uri, err := placeholder.DataURI(media.BlurHash, width, height)
+				if err == nil {
+					rules = append(rules,
+						fmt.Sprintf("background-image: url(%s)", uri),
+						"background-position: "+displayOffset(media),
+					)
 				}
 			}
-			return fmt.Sprintf("%d%% %d%%", x, y)
+
+			return strings.Join(rules, "; ")
+		})
+
+		ctx.Set("is_video", func(media models.Media) bool {
+			return mediakind.IsVideo(media.Kind)
+		})
+
+		ctx.Set("is_raw", func(media models.Media) bool {
+			return mediakind.IsRAW(media.Kind)
+		})
+
+		// profile is the name of the thumbnail profile to use for an image
+		// shown at a width, in CSS pixels
+		ctx.Set("profile", func(width int) string {
+			return thumbnails.Nearest(width).Name
+		})
+
+		// srcset returns the srcset and sizes attributes of a media's img so
+		// that browsers pick a thumbnail for the screen size and density
+		ctx.Set("srcset", func(mediaID int, sizes string) template.HTML {
+			//nolint:gosec // the srcset is made of paths and profile names
+			return template.HTML(fmt.Sprintf(
+				`srcset="%s" sizes="%s"`,
+				thumbnails.Srcset(mediaID),
+				template.HTMLEscapeString(sizes),
+			))
 		})
 
 		ctx.Set("days_diff", func(t1, t2 time.Time) string {
@@ -86,6 +192,8 @@ func BuildPageRenderFunc(showMenu bool, headContent string, intermediateTemplate
 			return fmt.Sprintf("%.1f", f)
 		})
 
+		ctx.Set("camera_details", cameraDetails)
+
 		body, err := plush.Render(t, ctx)
 		if err != nil {
 			return errors.Wrap(err, "failed to evaluate provided template")
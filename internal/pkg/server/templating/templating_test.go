@@ -3,10 +3,13 @@ package templating
 import (
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/gobuffalo/plush"
 	"github.com/maxatome/go-testdeep/td"
 	"github.com/stretchr/testify/require"
+
+	"github.com/charlieegan3/photos/internal/pkg/models"
 )
 
 func TestRenderPage(t *testing.T) {
@@ -20,7 +23,7 @@ func TestRenderPage(t *testing.T) {
     <meta charset="utf-8">
     <title>Photos</title>
     <meta name="viewport" content="width=device-width, initial-scale=1.0">
-    <link rel="stylesheet" href="/styles.css?v=1">
+    <link rel="stylesheet" href="/styles.css?v=2">
 
     <link rel="apple-touch-icon" sizes="180x180" href="/apple-touch-icon.png">
     <link rel="icon" type="image/png" sizes="32x32" href="/favicon-32x32.png">
@@ -56,3 +59,100 @@ func TestRenderPage(t *testing.T) {
 
 	td.Cmp(t, b.String(), expectedResult)
 }
+
+func TestRenderPageImageHelpers(t *testing.T) {
+	t.Parallel()
+
+	nestedTemplate := `<img src="/medias/1/image.jpg?o=<%= profile(600) %>" <%= srcset(1, "(min-width: 60em) 50vw, 100vw") %>>`
+
+	b := new(strings.Builder)
+
+	renderFunc := BuildPageRenderFunc(true, "")
+
+	err := renderFunc(plush.NewContext(), nestedTemplate, b)
+	require.NoError(t, err)
+
+	require.Contains(
+		t,
+		b.String(),
+		`<img src="/medias/1/image.jpg?o=large" srcset="/medias/1/image.jpg?o=thumb 100w, `+
+			`/medias/1/image.jpg?o=small 200w, /medias/1/image.jpg?o=medium 500w, `+
+			`/medias/1/image.jpg?o=large 1000w, /medias/1/image.jpg?o=xlarge 2000w" `+
+			`sizes="(min-width: 60em) 50vw, 100vw">`,
+	)
+}
+
+func TestRenderPagePlaceholder(t *testing.T) {
+	t.Parallel()
+
+	nestedTemplate := `<img style="object-position: <%= display_offset(media) %>; <%= placeholder(media) %>">`
+
+	b := new(strings.Builder)
+
+	ctx := plush.NewContext()
+	ctx.Set("media", models.Media{
+		Width:          4000,
+		Height:         3000,
+		Orientation:    6,
+		DisplayOffset:  20,
+		BlurHash:       "LEHV6nWB2yk8pyo0adR*.7kCMdnj",
+		DominantColour: "#a0b1c2",
+	})
+
+	renderFunc := BuildPageRenderFunc(true, "")
+
+	err := renderFunc(ctx, nestedTemplate, b)
+	require.NoError(t, err)
+
+	// the image is portrait once rotated, so is offset vertically
+	require.Contains(t, b.String(), `<img style="object-position: 0% 20%; background-color: #a0b1c2; `+
+		`background-image: url(data:image/png;base64,`)
+	require.Contains(t, b.String(), `); background-position: 0% 20%">`)
+
+	b.Reset()
+	ctx.Set("media", models.Media{Width: 4000, Height: 3000, DisplayOffset: 30})
+
+	err = renderFunc(ctx, nestedTemplate, b)
+	require.NoError(t, err)
+	require.Contains(t, b.String(), `<img style="object-position: 30% 0%; ">`)
+}
+
+func TestRenderPageCameraDetails(t *testing.T) {
+	t.Parallel()
+
+	nestedTemplate := `<p><%= for (i, detail) in camera_details(media) { %>` +
+		`<%= if (i > 0) { %> &middot; <% } %><%= detail %><% } %></p>`
+
+	b := new(strings.Builder)
+
+	ctx := plush.NewContext()
+	ctx.Set("media", models.Media{
+		ExposureCompensation: -0.67,
+		ExposureProgram:      "Aperture priority",
+		MeteringMode:         "Spot",
+		Flash:                "Fired",
+		WhiteBalance:         "Manual",
+		SubjectDistance:      2.5,
+		ImageDirection:       270.5,
+		ImageDirectionRef:    "M",
+		AltitudeRef:          1,
+		GPSTime:              time.Date(2024, time.May, 6, 13, 4, 30, 0, time.UTC),
+	})
+
+	renderFunc := BuildPageRenderFunc(true, "")
+
+	err := renderFunc(ctx, nestedTemplate, b)
+	require.NoError(t, err)
+
+	require.Contains(t, b.String(), "<p>-0.7 EV &middot; Aperture priority &middot; Spot metering &middot; "+
+		"Flash fired &middot; Manual white balance &middot; Subject at 2.5m &middot; "+
+		"Facing W 270° magnetic &middot; Below sea level &middot; GPS time 2024-05-06 13:04:30 UTC</p>")
+
+	require.Equal(t, []string{"No flash", "Subject at infinity", "Facing N 359°"}, cameraDetails(models.Media{
+		Flash:             "Not fired",
+		SubjectDistance:   models.SubjectDistanceInfinity,
+		ImageDirection:    359,
+		ImageDirectionRef: "T",
+	}))
+	require.Empty(t, cameraDetails(models.Media{}))
+}
@@ -0,0 +1,163 @@
+// Package similarity finds media which look alike, such as re-exports, small
+// crops and burst frames, which are missed by comparing checksums.
+package similarity
+
+import (
+	"fmt"
+	"image"
+	"math/bits"
+	"slices"
+	"strconv"
+
+	"github.com/disintegration/imaging"
+)
+
+// DefaultDistance is the largest number of differing bits at which two hashes
+// are considered similar.
+const DefaultDistance = 6
+
+// MaxDistance is the largest distance Groups should be used with. Hashes
+// further apart than this are rarely alike, and larger distances compare
+// most hashes with each other.
+const MaxDistance = 16
+
+// Hash returns the 64 bit difference hash of img as 16 hex characters. Each
+// bit records if a pixel of a 9x8 grey copy of img is brighter than the pixel
+// to its right, so the hash is unchanged by resizing and recompression.
+func Hash(img image.Image) string {
+	grey := imaging.Grayscale(imaging.Resize(img, 9, 8, imaging.Box))
+
+	var hash uint64
+	for y := range 8 {
+		for x := range 8 {
+			hash <<= 1
+			if grey.Pix[grey.PixOffset(x, y)] > grey.Pix[grey.PixOffset(x+1, y)] {
+				hash |= 1
+			}
+		}
+	}
+
+	return fmt.Sprintf("%016x", hash)
+}
+
+// Distance returns the number of bits which differ between two hashes, or -1
+// if either is not a valid hash.
+func Distance(a, b string) int {
+	x, ok := parse(a)
+	if !ok {
+		return -1
+	}
+
+	y, ok := parse(b)
+	if !ok {
+		return -1
+	}
+
+	return bits.OnesCount64(x ^ y)
+}
+
+// Groups returns the IDs of media with hashes within maxDistance of another
+// in the group. Groups and the IDs in them are in ascending order, media with
+// missing or invalid hashes are left out.
+//
+// Hashes are not all compared with each other. Each is split into
+// maxDistance+1 bands, and hashes within maxDistance must have at least one
+// band the same, so only hashes which share a band are compared.
+func Groups(hashes map[int]string, maxDistance int) [][]int {
+	ids := make([]int, 0, len(hashes))
+	parsed := make(map[int]uint64, len(hashes))
+	for id, h := range hashes {
+		if v, ok := parse(h); ok {
+			ids = append(ids, id)
+			parsed[id] = v
+		}
+	}
+	slices.Sort(ids)
+
+	// parents is a union find of the groups, each ID points towards the
+	// lowest ID in its group
+	parents := make(map[int]int, len(ids))
+	var find func(id int) int
+	find = func(id int) int {
+		parent, ok := parents[id]
+		if !ok || parent == id {
+			return id
+		}
+		root := find(parent)
+		parents[id] = root
+		return root
+	}
+
+	type band struct {
+		index int
+		value uint64
+	}
+	bandCount := min(maxDistance+1, 64)
+	// hashes which differ in every bit share no band
+	if maxDistance >= 64 {
+		bandCount = 1
+	}
+
+	candidates := make(map[band][]int)
+	for _, a := range ids {
+		for i := range bandCount {
+			key := band{index: i, value: bandValue(parsed[a], i, bandCount)}
+			if maxDistance >= 64 {
+				key.value = 0
+			}
+
+			for _, b := range candidates[key] {
+				rootA, rootB := find(a), find(b)
+				if rootA == rootB || bits.OnesCount64(parsed[a]^parsed[b]) > maxDistance {
+					continue
+				}
+				parents[max(rootA, rootB)] = min(rootA, rootB)
+			}
+
+			candidates[key] = append(candidates[key], a)
+		}
+	}
+
+	members := make(map[int][]int)
+	var roots []int
+	for _, id := range ids {
+		root := find(id)
+		if _, ok := members[root]; !ok {
+			roots = append(roots, root)
+		}
+		members[root] = append(members[root], id)
+	}
+
+	var groups [][]int
+	for _, root := range roots {
+		if len(members[root]) > 1 {
+			groups = append(groups, members[root])
+		}
+	}
+
+	return groups
+}
+
+// bandValue returns the bits of band i when hash is split into count bands
+// of about the same width.
+func bandValue(hash uint64, i, count int) uint64 {
+	start, end := i*64/count, (i+1)*64/count
+	if end-start == 64 {
+		return hash
+	}
+
+	return (hash >> start) & (1<<(end-start) - 1)
+}
+
+func parse(hash string) (uint64, bool) {
+	if len(hash) != 16 {
+		return 0, false
+	}
+
+	v, err := strconv.ParseUint(hash, 16, 64)
+	if err != nil {
+		return 0, false
+	}
+
+	return v, true
+}
@@ -0,0 +1,135 @@
+package similarity
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"maps"
+	"math/rand/v2"
+	"slices"
+	"testing"
+
+	"github.com/disintegration/imaging"
+	"github.com/stretchr/testify/require"
+)
+
+// testImage is a diagonal gradient with a dark square, offset by shift.
+func testImage(width, height, shift int) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := range height {
+		for x := range width {
+			v := uint8((x + y) * 255 / (width + height))
+			if x > width/4+shift && x < width/2+shift && y > height/4 && y < height/2 {
+				v = 20
+			}
+			img.Set(x, y, color.RGBA{R: v, G: v, B: v, A: 255})
+		}
+	}
+
+	return img
+}
+
+func TestHash(t *testing.T) {
+	t.Parallel()
+
+	original := Hash(testImage(400, 300, 0))
+	require.Len(t, original, 16)
+
+	// resized copies have the same hash
+	require.Equal(t, 0, Distance(original, Hash(imaging.Resize(testImage(400, 300, 0), 100, 75, imaging.Lanczos))))
+
+	// small changes have a small distance
+	shifted := Distance(original, Hash(testImage(400, 300, 20)))
+	require.GreaterOrEqual(t, shifted, 0)
+	require.LessOrEqual(t, shifted, DefaultDistance)
+
+	// other images do not
+	require.Greater(t, Distance(original, Hash(imaging.FlipH(testImage(400, 300, 0)))), DefaultDistance)
+}
+
+func TestDistance(t *testing.T) {
+	t.Parallel()
+
+	require.Equal(t, 0, Distance("00000000000000ff", "00000000000000ff"))
+	require.Equal(t, 8, Distance("0000000000000000", "00000000000000ff"))
+	require.Equal(t, 64, Distance("0000000000000000", "ffffffffffffffff"))
+	require.Equal(t, -1, Distance("", "0000000000000000"))
+	require.Equal(t, -1, Distance("000000000000000g", "0000000000000000"))
+}
+
+func TestGroups(t *testing.T) {
+	t.Parallel()
+
+	groups := Groups(map[int]string{
+		1: "0000000000000000",
+		2: "ffffffffffffffff",
+		3: "0000000000000003",
+		// within the distance of 3 but not 1
+		4: "000000000000003f",
+		5: "fffffffffffffff0",
+		6: "0f0f0f0f0f0f0f0f",
+		7: "",
+	}, 4)
+
+	require.Equal(t, [][]int{{1, 3, 4}, {2, 5}}, groups)
+
+	require.Empty(t, Groups(map[int]string{1: "0000000000000000", 2: "00000000000000ff"}, 4))
+}
+
+func TestGroupsMatchesAllPairs(t *testing.T) {
+	t.Parallel()
+
+	// hashes near a few random ones, so that there are groups at each distance
+	random := rand.New(rand.NewPCG(1, 2))
+	hashes := make(map[int]string)
+	for id := 1; id <= 300; id++ {
+		hash := random.Uint64()
+		if id > 10 {
+			hash, _ = parse(hashes[random.IntN(10)+1])
+			for range random.IntN(24) {
+				hash ^= 1 << random.IntN(64)
+			}
+		}
+		hashes[id] = fmt.Sprintf("%016x", hash)
+	}
+
+	for _, distance := range []int{0, 1, 6, 11, MaxDistance, 64} {
+		require.Equal(t, allPairsGroups(hashes, distance), Groups(hashes, distance), distance)
+	}
+}
+
+// allPairsGroups is Groups comparing every pair of hashes.
+func allPairsGroups(hashes map[int]string, maxDistance int) [][]int {
+	ids := slices.Sorted(maps.Keys(hashes))
+
+	group := make(map[int]int)
+	for _, id := range ids {
+		group[id] = id
+	}
+	for i, a := range ids {
+		for _, b := range ids[i+1:] {
+			if Distance(hashes[a], hashes[b]) > maxDistance || group[a] == group[b] {
+				continue
+			}
+			from, to := max(group[a], group[b]), min(group[a], group[b])
+			for id, g := range group {
+				if g == from {
+					group[id] = to
+				}
+			}
+		}
+	}
+
+	var groups [][]int
+	members := make(map[int][]int)
+	for _, id := range ids {
+		members[group[id]] = append(members[group[id]], id)
+	}
+	for _, id := range ids {
+		if len(members[id]) > 1 {
+			groups = append(groups, members[id])
+		}
+	}
+
+	return groups
+}
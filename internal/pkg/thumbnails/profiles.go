@@ -0,0 +1,199 @@
+package thumbnails
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+const (
+	// ModeFit resizes an image to fit in a Width square, keeping its aspect
+	// ratio.
+	ModeFit = "fit"
+	// ModeFill resizes and crops an image to fill a Width square.
+	ModeFill = "fill"
+
+	// CropSmart crops fill profiles to the most interesting part of the image
+	// rather than the centre.
+	CropSmart = "smart"
+)
+
+// Profile is a named rendition of media. Profile names are used in URLs, e.g.
+// /medias/1/image.jpg?o=medium
+type Profile struct {
+	Name    string `mapstructure:"name"`
+	Width   int    `mapstructure:"width"`
+	Mode    string `mapstructure:"mode"`
+	Crop    string `mapstructure:"crop"`
+	Quality int    `mapstructure:"quality"`
+}
+
+// DefaultProfiles are used when no profiles are configured, they match the
+// sizes used before profiles could be configured so that existing thumbnails
+// are still used.
+var DefaultProfiles = []Profile{
+	{Name: "xlarge", Width: 2000, Mode: ModeFit},
+	{Name: "large", Width: 1000, Mode: ModeFit},
+	{Name: "medium", Width: 500, Mode: ModeFit},
+	{Name: "small", Width: 200, Mode: ModeFit},
+	{Name: "thumb", Width: 100, Mode: ModeFit},
+}
+
+// Profiles is the registry of thumbnail profiles made for each media, it is
+// replaced with the configured profiles on start up.
+// Note: this must be ordered largest first.
+var Profiles = DefaultProfiles
+
+var profileNamePattern = regexp.MustCompile(`^[a-z0-9-]+$`)
+
+// ParseProfiles validates configured profiles and returns them ordered
+// largest first, ready to be used as Profiles.
+func ParseProfiles(profiles []Profile) ([]Profile, error) {
+	if len(profiles) == 0 {
+		return nil, errors.New("at least one profile is required")
+	}
+
+	parsed := make([]Profile, 0, len(profiles))
+	names := make(map[string]bool)
+	hasFit := false
+	for _, p := range profiles {
+		if !profileNamePattern.MatchString(p.Name) {
+			return nil, fmt.Errorf("profile name %q must only use a-z, 0-9 and -", p.Name)
+		}
+		if names[p.Name] {
+			return nil, fmt.Errorf("profile %q is defined more than once", p.Name)
+		}
+		names[p.Name] = true
+
+		if p.Width <= 0 {
+			return nil, fmt.Errorf("profile %q must have a positive width", p.Name)
+		}
+
+		if p.Mode == "" {
+			p.Mode = ModeFit
+		}
+		switch p.Mode {
+		case ModeFit:
+			hasFit = true
+			if p.Crop != "" {
+				return nil, fmt.Errorf("profile %q can only crop in %s mode", p.Name, ModeFill)
+			}
+		case ModeFill:
+			if p.Crop != "" && p.Crop != CropSmart {
+				return nil, fmt.Errorf("profile %q has unknown crop %q", p.Name, p.Crop)
+			}
+		default:
+			return nil, fmt.Errorf("profile %q has unknown mode %q", p.Name, p.Mode)
+		}
+
+		if p.Quality < 0 || p.Quality > 100 {
+			return nil, fmt.Errorf("profile %q quality must be between 1 and 100, or unset", p.Name)
+		}
+
+		parsed = append(parsed, p)
+	}
+
+	// pages are built from fit profiles, see Nearest and Srcset
+	if !hasFit {
+		return nil, fmt.Errorf("at least one profile must use %s mode", ModeFit)
+	}
+
+	slices.SortStableFunc(parsed, func(a, b Profile) int {
+		return cmp.Compare(b.Width, a.Width)
+	})
+
+	return parsed, nil
+}
+
+// ResizeString is the imageproxy option string for the profile when used on
+// an image of the given size. Old media with no dimensions use width only
+// thumbs rather than fit, as do icons.
+func (p Profile) ResizeString(width, height int) string {
+	var options []string
+	switch {
+	case p.Mode == ModeFill:
+		options = append(options, fmt.Sprintf("%dx%d", p.Width, p.Width))
+		if p.Crop == CropSmart {
+			options = append(options, "sc")
+		}
+	case width == 0 || height == 0:
+		options = append(options, fmt.Sprintf("%dx", p.Width))
+	default:
+		options = append(options, fmt.Sprintf("%d", p.Width), "fit")
+	}
+
+	if p.Quality != 0 {
+		options = append(options, fmt.Sprintf("q%d", p.Quality))
+	}
+
+	return strings.Join(options, ",")
+}
+
+// Find returns the profile with a name. Links from before profiles were named
+// used resize strings like 500,fit or 500x, these are also found.
+func Find(name string) (Profile, bool) {
+	for _, p := range Profiles {
+		if p.Name == name {
+			return p, true
+		}
+	}
+
+	for _, p := range Profiles {
+		if name == p.ResizeString(1, 1) || name == p.ResizeString(0, 0) {
+			return p, true
+		}
+	}
+
+	return Profile{}, false
+}
+
+// Nearest returns the smallest fit profile which is at least width wide, or
+// the largest if none are. Templates use this rather than profile names so
+// that they work with any configured profiles.
+func Nearest(width int) Profile {
+	var nearest Profile
+	for _, p := range Profiles {
+		if p.Mode != ModeFit {
+			continue
+		}
+
+		if nearest.Name == "" || p.Width >= width {
+			nearest = p
+		}
+	}
+
+	return nearest
+}
+
+// URL is the path where the profile's thumbnail of a media is served.
+func URL(mediaID int, p Profile) string {
+	return fmt.Sprintf("/medias/%d/image.jpg?o=%s", mediaID, p.Name)
+}
+
+// Srcset lists the fit profile thumbnails of a media with their widths, for
+// use in the srcset attribute of an img.
+func Srcset(mediaID int) string {
+	var candidates []string
+	for _, p := range slices.Backward(Profiles) {
+		if p.Mode != ModeFit {
+			continue
+		}
+
+		candidates = append(candidates, fmt.Sprintf("%s %dw", URL(mediaID, p), p.Width))
+	}
+
+	return strings.Join(candidates, ", ")
+}
+
+// Names returns the names of profiles, used when logging.
+func Names(profiles []Profile) []string {
+	names := make([]string, len(profiles))
+	for i, p := range profiles {
+		names[i] = p.Name
+	}
+
+	return names
+}
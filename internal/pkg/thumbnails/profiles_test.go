@@ -0,0 +1,98 @@
+package thumbnails
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseProfiles(t *testing.T) {
+	t.Parallel()
+
+	profiles, err := ParseProfiles([]Profile{
+		{Name: "small", Width: 200},
+		{Name: "square", Width: 300, Mode: ModeFill, Crop: CropSmart, Quality: 80},
+		{Name: "large", Width: 1000, Mode: ModeFit},
+	})
+	require.NoError(t, err)
+	require.Equal(t, []Profile{
+		{Name: "large", Width: 1000, Mode: ModeFit},
+		{Name: "square", Width: 300, Mode: ModeFill, Crop: CropSmart, Quality: 80},
+		{Name: "small", Width: 200, Mode: ModeFit},
+	}, profiles)
+
+	testCases := map[string][]Profile{
+		"no profiles":     {},
+		"invalid name":    {{Name: "Large Image", Width: 1000}},
+		"duplicate name":  {{Name: "large", Width: 1000}, {Name: "large", Width: 2000}},
+		"no width":        {{Name: "large"}},
+		"unknown mode":    {{Name: "large", Width: 1000, Mode: "stretch"}},
+		"crop with fit":   {{Name: "large", Width: 1000, Crop: CropSmart}},
+		"unknown crop":    {{Name: "large", Width: 1000}, {Name: "square", Width: 300, Mode: ModeFill, Crop: "top"}},
+		"invalid quality": {{Name: "large", Width: 1000, Quality: 101}},
+		"only fill":       {{Name: "square", Width: 300, Mode: ModeFill}},
+	}
+
+	for description, profiles := range testCases {
+		t.Run(description, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := ParseProfiles(profiles)
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestProfileResizeString(t *testing.T) {
+	t.Parallel()
+
+	fit := Profile{Name: "medium", Width: 500, Mode: ModeFit}
+	require.Equal(t, "500,fit", fit.ResizeString(300, 200))
+	require.Equal(t, "500x", fit.ResizeString(0, 0))
+
+	fit.Quality = 70
+	require.Equal(t, "500,fit,q70", fit.ResizeString(300, 200))
+
+	fill := Profile{Name: "square", Width: 300, Mode: ModeFill}
+	require.Equal(t, "300x300", fill.ResizeString(300, 200))
+	require.Equal(t, "300x300", fill.ResizeString(0, 0))
+
+	fill.Crop = CropSmart
+	require.Equal(t, "300x300,sc", fill.ResizeString(300, 200))
+}
+
+func TestFind(t *testing.T) {
+	t.Parallel()
+
+	for _, name := range []string{"medium", "500,fit", "500x"} {
+		profile, ok := Find(name)
+		require.True(t, ok, name)
+		require.Equal(t, "medium", profile.Name)
+	}
+
+	_, ok := Find("../../media/1.jpg")
+	require.False(t, ok)
+
+	_, ok = Find("300,fit")
+	require.False(t, ok)
+}
+
+func TestNearest(t *testing.T) {
+	t.Parallel()
+
+	require.Equal(t, "thumb", Nearest(50).Name)
+	require.Equal(t, "medium", Nearest(500).Name)
+	require.Equal(t, "large", Nearest(501).Name)
+	require.Equal(t, "xlarge", Nearest(4000).Name)
+}
+
+func TestSrcset(t *testing.T) {
+	t.Parallel()
+
+	require.Equal(
+		t,
+		"/medias/1/image.jpg?o=thumb 100w, /medias/1/image.jpg?o=small 200w, /medias/1/image.jpg?o=medium 500w, "+
+			"/medias/1/image.jpg?o=large 1000w, /medias/1/image.jpg?o=xlarge 2000w",
+		Srcset(1),
+	)
+}
@@ -0,0 +1,281 @@
+package thumbnails
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"io"
+	"strings"
+
+	"gocloud.dev/blob"
+	"gocloud.dev/gcerrors"
+
+	"github.com/charlieegan3/photos/internal/pkg/imageproxy"
+	"github.com/charlieegan3/photos/internal/pkg/mediakind"
+	"github.com/charlieegan3/photos/internal/pkg/mediametadata"
+	"github.com/charlieegan3/photos/internal/pkg/models"
+	"github.com/charlieegan3/photos/internal/pkg/placeholder"
+	"github.com/charlieegan3/photos/internal/pkg/similarity"
+)
+
+// OriginalPath is the bucket key of the uploaded media file.
+func OriginalPath(media models.Media) string {
+	return fmt.Sprintf("media/%d.%s", media.ID, media.Kind)
+}
+
+// PosterPath is the bucket key of the still image shown for a video before
+// it is played.
+func PosterPath(media models.Media) string {
+	return fmt.Sprintf("media/%d.poster.jpg", media.ID)
+}
+
+// PublicPath is the bucket key of the copy of the original that is served
+// publicly, it has the GPS location removed from its metadata. The public
+// copy of a RAW file is its JPEG preview.
+func PublicPath(media models.Media) string {
+	return fmt.Sprintf("media/%d.public.%s", media.ID, PublicKind(media))
+}
+
+// PublicKind is the kind of the public copy of the original, RAW files can't
+// be shown by browsers so their preview is used.
+func PublicKind(media models.Media) string {
+	if mediakind.IsRAW(media.Kind) {
+		return mediakind.JPG
+	}
+
+	return media.Kind
+}
+
+// SourcePath is the bucket key of the image that thumbnails are made from,
+// the poster for videos and the original for everything else.
+func SourcePath(media models.Media) string {
+	if mediakind.IsVideo(media.Kind) {
+		return PosterPath(media)
+	}
+
+	return OriginalPath(media)
+}
+
+// Options is the imageproxy option string used to create a thumbnail from a
+// resize string. Thumbnails are converted to jpeg so that they can be shown
+// by all browsers.
+func Options(resizeString string) string {
+	return resizeString + ",jpeg"
+}
+
+// Edits returns the crop and rotation edits of media which are applied to its
+// thumbnails.
+func Edits(media models.Media) imageproxy.Edits {
+	return imageproxy.Edits{
+		CropX:      media.CropX,
+		CropY:      media.CropY,
+		CropWidth:  media.CropWidth,
+		CropHeight: media.CropHeight,
+		Rotation:   media.Rotation,
+		Straighten: media.Straighten,
+	}
+}
+
+// EffectiveDimensions returns the size media is displayed at, after its EXIF
+// orientation and edits are applied. For orientations 6 and 8 (90° rotations),
+// width and height are swapped.
+func EffectiveDimensions(media models.Media) (int, int) {
+	width, height := media.Width, media.Height
+	switch media.Orientation {
+	case 6, 8: // 90° rotations - swap dimensions
+		width, height = height, width
+	}
+
+	return Edits(media).Size(width, height)
+}
+
+// Path is the bucket key of the thumbnail of a profile for media.
+// Thumbnails are always jpeg, whatever the kind of the original. Keys are
+// made from the resize options rather than the profile name so that changing
+// a profile's options makes new thumbnails, the same goes for the media's
+// edits and the watermark.
+func Path(media models.Media, profile Profile) string {
+	options := strings.ReplaceAll(profile.ResizeString(media.Width, media.Height), ",", "-")
+	if edits := Edits(media); !edits.IsZero() {
+		options += "-e" + edits.CacheKey()
+	}
+	if imageproxy.DefaultWatermark != nil {
+		options += "-w" + imageproxy.DefaultWatermark.CacheKey()
+	}
+
+	return fmt.Sprintf("thumbs/media/%d-%s.jpg", media.ID, options)
+}
+
+// Stale returns the profiles where the thumbnail is missing, empty or older
+// than the file it is made from.
+func Stale(ctx context.Context, bucket *blob.Bucket, media models.Media) ([]Profile, error) {
+	originalAttrs, err := bucket.Attributes(ctx, SourcePath(media))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get source image attributes: %w", err)
+	}
+
+	var stale []Profile
+	for _, profile := range Profiles {
+		attrs, err := bucket.Attributes(ctx, Path(media, profile))
+		if err != nil {
+			if gcerrors.Code(err) == gcerrors.NotFound {
+				stale = append(stale, profile)
+				continue
+			}
+
+			return nil, fmt.Errorf("failed to get thumb attributes: %w", err)
+		}
+
+		if attrs.Size == 0 || attrs.ModTime.Before(originalAttrs.ModTime) {
+			stale = append(stale, profile)
+		}
+	}
+
+	return stale, nil
+}
+
+// EnsurePublic makes the public copy of the original if it is missing or
+// older than the original.
+func EnsurePublic(ctx context.Context, bucket *blob.Bucket, media models.Media) error {
+	originalAttrs, err := bucket.Attributes(ctx, OriginalPath(media))
+	if err != nil {
+		return fmt.Errorf("failed to get original attributes: %w", err)
+	}
+
+	attrs, err := bucket.Attributes(ctx, PublicPath(media))
+	if err == nil && attrs.Size > 0 && !attrs.ModTime.Before(originalAttrs.ModTime) {
+		return nil
+	}
+	if err != nil && gcerrors.Code(err) != gcerrors.NotFound {
+		return fmt.Errorf("failed to get public copy attributes: %w", err)
+	}
+
+	original, err := bucket.ReadAll(ctx, OriginalPath(media))
+	if err != nil {
+		return fmt.Errorf("failed to read original: %w", err)
+	}
+
+	if mediakind.IsRAW(media.Kind) {
+		original, err = mediametadata.RAWPreview(original)
+		if err != nil {
+			return fmt.Errorf("failed to get raw preview: %w", err)
+		}
+	}
+
+	public, err := mediametadata.StripGPS(original)
+	if err != nil {
+		return fmt.Errorf("failed to strip location from original: %w", err)
+	}
+
+	err = bucket.WriteAll(ctx, PublicPath(media), public, nil)
+	if err != nil {
+		return fmt.Errorf("failed to write public copy: %w", err)
+	}
+
+	return nil
+}
+
+// Generate creates the thumbnails of the given profiles from the bytes of the
+// file at SourcePath.
+func Generate(
+	ctx context.Context,
+	bucket *blob.Bucket,
+	ir *imageproxy.Resizer,
+	media models.Media,
+	original []byte,
+	profiles []Profile,
+) error {
+	for _, profile := range profiles {
+		_, err := ir.CreateThumbInBucket(
+			ctx,
+			bytes.NewReader(original),
+			bucket,
+			Edits(media),
+			Options(profile.ResizeString(media.Width, media.Height)),
+			Path(media, profile),
+		)
+		if err != nil {
+			return fmt.Errorf("failed to create %s thumbnail: %w", profile.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// DeleteUnused removes the thumbnails of media which are not at the Path of
+// any profile, such as those made before its edits were changed.
+func DeleteUnused(ctx context.Context, bucket *blob.Bucket, media models.Media) error {
+	current := make(map[string]bool, len(Profiles))
+	for _, profile := range Profiles {
+		current[Path(media, profile)] = true
+	}
+
+	iter := bucket.List(&blob.ListOptions{Prefix: fmt.Sprintf("thumbs/media/%d-", media.ID)})
+	for {
+		obj, err := iter.Next(ctx)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to list thumbnails: %w", err)
+		}
+
+		if current[obj.Key] {
+			continue
+		}
+
+		err = bucket.Delete(ctx, obj.Key)
+		if err != nil && gcerrors.Code(err) != gcerrors.NotFound {
+			return fmt.Errorf("failed to delete thumbnail: %w", err)
+		}
+	}
+}
+
+// Placeholder returns the BlurHash and dominant colour of media, they are made
+// from a Small render so that they match the thumbnails shown.
+func Placeholder(ctx context.Context, bucket *blob.Bucket, media models.Media) (string, string, error) {
+	img, err := Small(ctx, bucket, media)
+	if err != nil {
+		return "", "", err
+	}
+
+	return placeholder.Make(img)
+}
+
+// PerceptualHash returns the perceptual hash of media, made from a Small
+// render.
+func PerceptualHash(ctx context.Context, bucket *blob.Bucket, media models.Media) (string, error) {
+	img, err := Small(ctx, bucket, media)
+	if err != nil {
+		return "", err
+	}
+
+	return similarity.Hash(img), nil
+}
+
+// Small renders media at the size of the smallest fit profile with its edits.
+// It is rendered from the source rather than read from the thumbnail since
+// thumbnails can be watermarked, and the mark would change the placeholder
+// and make marked media look similar.
+func Small(ctx context.Context, bucket *blob.Bucket, media models.Media) (image.Image, error) {
+	source, err := bucket.ReadAll(ctx, SourcePath(media))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read source image: %w", err)
+	}
+
+	var ir imageproxy.Resizer
+	rendered, err := ir.Preview(ctx, source, Edits(media), Options(Nearest(0).ResizeString(media.Width, media.Height)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to render small image: %w", err)
+	}
+
+	img, err := jpeg.Decode(bytes.NewReader(rendered))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode small image: %w", err)
+	}
+
+	return img, nil
+}
@@ -0,0 +1,256 @@
+package thumbnails
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gocloud.dev/blob/memblob"
+
+	"github.com/charlieegan3/photos/internal/pkg/imageproxy"
+	"github.com/charlieegan3/photos/internal/pkg/models"
+)
+
+func TestPath(t *testing.T) {
+	t.Parallel()
+
+	medium := Profile{Name: "medium", Width: 500, Mode: ModeFit}
+
+	require.Equal(
+		t,
+		"thumbs/media/1-500-fit.jpg",
+		Path(models.Media{ID: 1, Kind: "jpg", Width: 100, Height: 100}, medium),
+	)
+	require.Equal(
+		t,
+		"thumbs/media/1-500x.jpg",
+		Path(models.Media{ID: 1, Kind: "jpg"}, medium),
+	)
+	require.Equal(
+		t,
+		"thumbs/media/1-500-fit.jpg",
+		Path(models.Media{ID: 1, Kind: "heic", Width: 100, Height: 100}, medium),
+	)
+	require.Equal(
+		t,
+		"thumbs/media/1-500x.jpg",
+		Path(models.Media{ID: 1, Kind: "mp4"}, medium),
+	)
+	require.Equal(
+		t,
+		"thumbs/media/1-300x300-sc-q80.jpg",
+		Path(
+			models.Media{ID: 1, Kind: "jpg", Width: 100, Height: 100},
+			Profile{Name: "square", Width: 300, Mode: ModeFill, Crop: CropSmart, Quality: 80},
+		),
+	)
+
+	edited := models.Media{ID: 1, Kind: "jpg", Width: 100, Height: 100, Rotation: 90}
+	require.Equal(
+		t,
+		"thumbs/media/1-500-fit-e"+Edits(edited).CacheKey()+".jpg",
+		Path(edited, medium),
+	)
+	edited.Rotation = 180
+	require.NotEqual(t, "thumbs/media/1-500-fit-e"+imageproxy.Edits{Rotation: 90}.CacheKey()+".jpg", Path(edited, medium))
+}
+
+func TestSourcePath(t *testing.T) {
+	t.Parallel()
+
+	require.Equal(t, "media/1.heic", SourcePath(models.Media{ID: 1, Kind: "heic"}))
+	require.Equal(t, "media/1.poster.jpg", SourcePath(models.Media{ID: 1, Kind: "mov"}))
+}
+
+func TestPublicPath(t *testing.T) {
+	t.Parallel()
+
+	require.Equal(t, "media/1.public.heic", PublicPath(models.Media{ID: 1, Kind: "heic"}))
+	require.Equal(t, "media/1.public.jpg", PublicPath(models.Media{ID: 1, Kind: "cr3"}))
+}
+
+// testRAW returns a TIFF based RAW file with a JPEG preview in IFD0.
+func testRAW(t *testing.T, width, height int) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	err := jpeg.Encode(&buf, image.NewRGBA(image.Rect(0, 0, width, height)), nil)
+	require.NoError(t, err)
+
+	// the preview follows the header and an IFD with two entries
+	const previewOffset = 8 + 2 + 2*12 + 4
+	raw := []byte("II*\x00\x08\x00\x00\x00")
+	raw = binary.LittleEndian.AppendUint16(raw, 2)
+	for _, entry := range [][2]uint32{{0x0201, previewOffset}, {0x0202, uint32(buf.Len())}} {
+		raw = binary.LittleEndian.AppendUint16(raw, uint16(entry[0]))
+		raw = binary.LittleEndian.AppendUint16(raw, 4)
+		raw = binary.LittleEndian.AppendUint32(raw, 1)
+		raw = binary.LittleEndian.AppendUint32(raw, entry[1])
+	}
+	raw = binary.LittleEndian.AppendUint32(raw, 0)
+
+	return append(raw, buf.Bytes()...)
+}
+
+func TestGenerateAndEnsurePublicFromRAW(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	bucket := memblob.OpenBucket(nil)
+	defer bucket.Close()
+
+	raw := testRAW(t, 300, 200)
+	media := models.Media{ID: 1, Kind: "dng", Width: 300, Height: 200}
+
+	err := bucket.WriteAll(ctx, OriginalPath(media), raw, nil)
+	require.NoError(t, err)
+
+	err = Generate(ctx, bucket, &imageproxy.Resizer{}, media, raw, []Profile{{Name: "small", Width: 200}})
+	require.NoError(t, err)
+
+	thumb, err := bucket.ReadAll(ctx, "thumbs/media/1-200-fit.jpg")
+	require.NoError(t, err)
+
+	config, format, err := image.DecodeConfig(bytes.NewReader(thumb))
+	require.NoError(t, err)
+	require.Equal(t, "jpeg", format)
+	require.Equal(t, 200, config.Width)
+
+	err = EnsurePublic(ctx, bucket, media)
+	require.NoError(t, err)
+
+	public, err := bucket.ReadAll(ctx, "media/1.public.jpg")
+	require.NoError(t, err)
+
+	config, format, err = image.DecodeConfig(bytes.NewReader(public))
+	require.NoError(t, err)
+	require.Equal(t, "jpeg", format)
+	require.Equal(t, 300, config.Width)
+}
+
+func TestStaleAndGenerate(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	bucket := memblob.OpenBucket(nil)
+	defer bucket.Close()
+
+	var buf bytes.Buffer
+	err := jpeg.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 300, 200)), nil)
+	require.NoError(t, err)
+
+	media := models.Media{ID: 1, Kind: "jpg", Width: 300, Height: 200}
+
+	err = bucket.WriteAll(ctx, OriginalPath(media), buf.Bytes(), nil)
+	require.NoError(t, err)
+
+	stale, err := Stale(ctx, bucket, media)
+	require.NoError(t, err)
+	require.Equal(t, Profiles, stale)
+
+	err = Generate(ctx, bucket, &imageproxy.Resizer{}, media, buf.Bytes(), Profiles[2:4])
+	require.NoError(t, err)
+
+	stale, err = Stale(ctx, bucket, media)
+	require.NoError(t, err)
+	require.Equal(t, []string{"xlarge", "large", "thumb"}, Names(stale))
+}
+
+func TestDeleteUnused(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	bucket := memblob.OpenBucket(nil)
+	defer bucket.Close()
+
+	media := models.Media{ID: 1, Kind: "jpg", Width: 300, Height: 200}
+	edited := media
+	edited.Rotation = 90
+
+	for _, profile := range Profiles {
+		require.NoError(t, bucket.WriteAll(ctx, Path(media, profile), []byte("thumb"), nil))
+		require.NoError(t, bucket.WriteAll(ctx, Path(edited, profile), []byte("edited"), nil))
+	}
+	// thumbnails of other media are kept
+	other := models.Media{ID: 10, Kind: "jpg", Width: 300, Height: 200}
+	require.NoError(t, bucket.WriteAll(ctx, Path(other, Profiles[0]), []byte("other"), nil))
+
+	err := DeleteUnused(ctx, bucket, edited)
+	require.NoError(t, err)
+
+	for _, profile := range Profiles {
+		exists, err := bucket.Exists(ctx, Path(media, profile))
+		require.NoError(t, err)
+		require.False(t, exists, profile.Name)
+
+		exists, err = bucket.Exists(ctx, Path(edited, profile))
+		require.NoError(t, err)
+		require.True(t, exists, profile.Name)
+	}
+
+	exists, err := bucket.Exists(ctx, Path(other, Profiles[0]))
+	require.NoError(t, err)
+	require.True(t, exists)
+}
+
+func TestSmall(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	bucket := memblob.OpenBucket(nil)
+	defer bucket.Close()
+
+	var buf bytes.Buffer
+	err := jpeg.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 300, 200)), nil)
+	require.NoError(t, err)
+
+	media := models.Media{ID: 1, Kind: "jpg", Width: 300, Height: 200, Rotation: 90}
+	require.NoError(t, bucket.WriteAll(ctx, OriginalPath(media), buf.Bytes(), nil))
+
+	// a marked thumbnail is not used
+	white := image.NewGray(image.Rect(0, 0, 100, 150))
+	for i := range white.Pix {
+		white.Pix[i] = 255
+	}
+	var thumb bytes.Buffer
+	require.NoError(t, jpeg.Encode(&thumb, white, nil))
+	require.NoError(t, bucket.WriteAll(ctx, Path(media, Nearest(0)), thumb.Bytes(), nil))
+
+	img, err := Small(ctx, bucket, media)
+	require.NoError(t, err)
+	// the edits are applied
+	require.Equal(t, 100, img.Bounds().Dy())
+	require.Less(t, img.Bounds().Dx(), 100)
+
+	r, g, b, _ := img.At(30, 50).RGBA()
+	require.Less(t, r+g+b, uint32(3*0x1000))
+}
+
+func TestGenerateConvertsToJPEG(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	bucket := memblob.OpenBucket(nil)
+	defer bucket.Close()
+
+	var buf bytes.Buffer
+	err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 300, 200)))
+	require.NoError(t, err)
+
+	media := models.Media{ID: 1, Kind: "png", Width: 300, Height: 200}
+
+	err = Generate(ctx, bucket, &imageproxy.Resizer{}, media, buf.Bytes(), []Profile{{Name: "small", Width: 200}})
+	require.NoError(t, err)
+
+	thumb, err := bucket.ReadAll(ctx, "thumbs/media/1-200-fit.jpg")
+	require.NoError(t, err)
+
+	config, format, err := image.DecodeConfig(bytes.NewReader(thumb))
+	require.NoError(t, err)
+	require.Equal(t, "jpeg", format)
+	require.Equal(t, 200, config.Width)
+}
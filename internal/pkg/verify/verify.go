@@ -0,0 +1,452 @@
+package verify
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gocloud.dev/blob"
+
+	"github.com/charlieegan3/photos/internal/pkg/imageproxy"
+	"github.com/charlieegan3/photos/internal/pkg/models"
+	"github.com/charlieegan3/photos/internal/pkg/server/handlers/shared"
+	"github.com/charlieegan3/photos/internal/pkg/thumbnails"
+)
+
+// Kind describes the type of a Problem.
+type Kind string
+
+const (
+	KindMissingOriginal  Kind = "missing original"
+	KindMissingThumbnail Kind = "missing thumbnail"
+	KindMissingIcon      Kind = "missing icon"
+	KindOrphanedObject   Kind = "orphaned object"
+	KindBrokenPost       Kind = "post with missing references"
+	KindChecksumMismatch Kind = "checksum mismatch"
+)
+
+// originalPrefixes are the bucket prefixes holding uploaded files, these are
+// the files which are checksummed since they cannot be regenerated.
+var originalPrefixes = []string{"media/", "device_icons/", "lens_icons/"}
+
+var (
+	mediaKeyPattern       = regexp.MustCompile(`^media/(\d+)\.`)
+	mediaThumbKeyPattern  = regexp.MustCompile(`^thumbs/media/(\d+)-`)
+	lensIconKeyPattern    = regexp.MustCompile(`^lens_icons/(\d+)\.`)
+	lensThumbKeyPattern   = regexp.MustCompile(`^thumbs/lens_icons/(\d+)-`)
+	deviceThumbKeyPattern = regexp.MustCompile(`^thumbs/device_icons/(\d+)-`)
+)
+
+// State is the database records to check against the bucket.
+type State struct {
+	Medias      []models.Media
+	Devices     []models.Device
+	Lenses      []models.Lens
+	BrokenPosts []models.Post
+
+	// Checksums are the recorded checksums keyed by object key.
+	Checksums map[string]models.ObjectChecksum
+}
+
+// Options controls what Check does.
+type Options struct {
+	// Repair regenerates missing thumbnails and deletes orphaned objects.
+	Repair bool
+	// Checksums hashes all original files and compares them to the recorded
+	// checksums.
+	Checksums bool
+}
+
+// Problem is an inconsistency found between the database and bucket.
+type Problem struct {
+	Kind     Kind
+	Key      string
+	Detail   string
+	Repaired bool
+}
+
+func (p Problem) String() string {
+	s := fmt.Sprintf("%s: %s", p.Kind, p.Key)
+	if p.Detail != "" {
+		s += " (" + p.Detail + ")"
+	}
+	if p.Repaired {
+		s += " [repaired]"
+	}
+
+	return s
+}
+
+// Result is the outcome of a Check.
+type Result struct {
+	Problems []Problem
+
+	// Checksums are new or updated checksums to be recorded.
+	Checksums []models.ObjectChecksum
+	// RemovedChecksums are the keys of recorded checksums for objects which
+	// no longer exist.
+	RemovedChecksums []string
+}
+
+// Unrepaired returns the number of problems which were not repaired.
+func (r Result) Unrepaired() int {
+	var count int
+	for _, p := range r.Problems {
+		if !p.Repaired {
+			count++
+		}
+	}
+
+	return count
+}
+
+// Check compares the state of the database with the objects in the bucket.
+// Nothing is changed in the bucket unless opts.Repair is set, and checksum
+// changes are returned rather than saved.
+func Check(
+	ctx context.Context,
+	bucket *blob.Bucket,
+	ir *imageproxy.Resizer,
+	state State,
+	opts Options,
+) (Result, error) {
+	var result Result
+
+	objects, err := listObjects(ctx, bucket)
+	if err != nil {
+		return result, err
+	}
+
+	for _, post := range state.BrokenPosts {
+		result.Problems = append(result.Problems, Problem{
+			Kind:   KindBrokenPost,
+			Key:    fmt.Sprintf("post %d", post.ID),
+			Detail: fmt.Sprintf("media %d, location %d", post.MediaID, post.LocationID),
+		})
+	}
+
+	problems, err := checkMedias(ctx, bucket, ir, state.Medias, objects, opts.Repair)
+	if err != nil {
+		return result, err
+	}
+	result.Problems = append(result.Problems, problems...)
+
+	result.Problems = append(result.Problems, checkIcons(state.Devices, state.Lenses, objects)...)
+
+	problems, err = checkOrphans(ctx, bucket, state, objects, opts.Repair)
+	if err != nil {
+		return result, err
+	}
+	result.Problems = append(result.Problems, problems...)
+
+	if opts.Checksums {
+		err = checkChecksums(ctx, bucket, state.Checksums, objects, &result)
+		if err != nil {
+			return result, err
+		}
+	}
+
+	return result, nil
+}
+
+// listObjects returns all the objects in the bucket keyed by key.
+func listObjects(ctx context.Context, bucket *blob.Bucket) (map[string]*blob.ListObject, error) {
+	objects := make(map[string]*blob.ListObject)
+
+	iter := bucket.List(nil)
+	for {
+		obj, err := iter.Next(ctx)
+		if errors.Is(err, io.EOF) {
+			return objects, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to list bucket: %w", err)
+		}
+
+		if obj.IsDir {
+			continue
+		}
+
+		objects[obj.Key] = obj
+	}
+}
+
+func checkMedias(
+	ctx context.Context,
+	bucket *blob.Bucket,
+	ir *imageproxy.Resizer,
+	medias []models.Media,
+	objects map[string]*blob.ListObject,
+	repair bool,
+) ([]Problem, error) {
+	var problems []Problem
+
+	for _, media := range medias {
+		originalKey := thumbnails.OriginalPath(media)
+		if _, ok := objects[originalKey]; !ok {
+			problems = append(problems, Problem{
+				Kind:   KindMissingOriginal,
+				Key:    originalKey,
+				Detail: fmt.Sprintf("media %d", media.ID),
+			})
+			continue
+		}
+
+		// video thumbnails are made from the poster, older videos were
+		// uploaded without one and have no thumbnails
+		sourceKey := thumbnails.SourcePath(media)
+		source, ok := objects[sourceKey]
+		if !ok {
+			continue
+		}
+
+		var stale []thumbnails.Profile
+		for _, profile := range thumbnails.Profiles {
+			thumb, ok := objects[thumbnails.Path(media, profile)]
+			if !ok || thumb.Size == 0 || thumb.ModTime.Before(source.ModTime) {
+				stale = append(stale, profile)
+			}
+		}
+
+		if len(stale) == 0 {
+			continue
+		}
+
+		problem := Problem{
+			Kind:   KindMissingThumbnail,
+			Key:    sourceKey,
+			Detail: fmt.Sprintf("profiles %s", strings.Join(thumbnails.Names(stale), ", ")),
+		}
+
+		if repair {
+			sourceBytes, err := bucket.ReadAll(ctx, sourceKey)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read %s: %w", sourceKey, err)
+			}
+
+			err = thumbnails.Generate(ctx, bucket, ir, media, sourceBytes, stale)
+			if err != nil {
+				return nil, fmt.Errorf("failed to generate thumbnails for %s: %w", sourceKey, err)
+			}
+
+			problem.Repaired = true
+		}
+
+		problems = append(problems, problem)
+	}
+
+	return problems, nil
+}
+
+// checkIcons reports devices and lenses without icons. Icons cannot be
+// regenerated so these are never repaired. Devices created during imports
+// have no icon kind and are not expected to have icons.
+func checkIcons(devices []models.Device, lenses []models.Lens, objects map[string]*blob.ListObject) []Problem {
+	var problems []Problem
+
+	for _, device := range devices {
+		if device.IconKind == "" {
+			continue
+		}
+
+		key := shared.DeviceIcon{ID: device.ID, Slug: device.Slug, IconKind: device.IconKind}.GetIconPath()
+		if _, ok := objects[key]; !ok {
+			problems = append(problems, Problem{
+				Kind:   KindMissingIcon,
+				Key:    key,
+				Detail: fmt.Sprintf("device %d", device.ID),
+			})
+		}
+	}
+
+	for _, lens := range lenses {
+		key := shared.LensIcon{ID: lens.ID}.GetIconPath()
+		if _, ok := objects[key]; !ok {
+			problems = append(problems, Problem{
+				Kind:   KindMissingIcon,
+				Key:    key,
+				Detail: fmt.Sprintf("lens %d", lens.ID),
+			})
+		}
+	}
+
+	return problems
+}
+
+// checkOrphans reports objects which belong to records that do not exist.
+// Deleted objects are removed from objects so they are not checksummed.
+func checkOrphans(
+	ctx context.Context,
+	bucket *blob.Bucket,
+	state State,
+	objects map[string]*blob.ListObject,
+	repair bool,
+) ([]Problem, error) {
+	mediaIDs := make(map[int64]bool, len(state.Medias))
+	for _, media := range state.Medias {
+		mediaIDs[int64(media.ID)] = true
+	}
+
+	deviceIDs := make(map[int64]bool, len(state.Devices))
+	deviceIconKeys := make(map[string]bool, len(state.Devices))
+	for _, device := range state.Devices {
+		deviceIDs[device.ID] = true
+		if device.IconKind != "" {
+			deviceIconKeys[shared.DeviceIcon{Slug: device.Slug, IconKind: device.IconKind}.GetIconPath()] = true
+		}
+	}
+
+	lensIDs := make(map[int64]bool, len(state.Lenses))
+	for _, lens := range state.Lenses {
+		lensIDs[lens.ID] = true
+	}
+
+	keys := make([]string, 0, len(objects))
+	for key := range objects {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var problems []Problem
+	for _, key := range keys {
+		var orphaned bool
+		switch {
+		case strings.HasPrefix(key, "device_icons/"):
+			orphaned = !deviceIconKeys[key]
+		default:
+			orphaned = orphanedByID(key, mediaKeyPattern, mediaIDs) ||
+				orphanedByID(key, mediaThumbKeyPattern, mediaIDs) ||
+				orphanedByID(key, lensIconKeyPattern, lensIDs) ||
+				orphanedByID(key, lensThumbKeyPattern, lensIDs) ||
+				orphanedByID(key, deviceThumbKeyPattern, deviceIDs)
+		}
+
+		if !orphaned {
+			continue
+		}
+
+		problem := Problem{Kind: KindOrphanedObject, Key: key}
+
+		if repair {
+			err := bucket.Delete(ctx, key)
+			if err != nil {
+				return nil, fmt.Errorf("failed to delete %s: %w", key, err)
+			}
+
+			delete(objects, key)
+			problem.Repaired = true
+		}
+
+		problems = append(problems, problem)
+	}
+
+	return problems, nil
+}
+
+// orphanedByID returns true when the key matches pattern and the ID in the
+// key is not one of ids.
+func orphanedByID(key string, pattern *regexp.Regexp, ids map[int64]bool) bool {
+	match := pattern.FindStringSubmatch(key)
+	if match == nil {
+		return false
+	}
+
+	id, err := strconv.ParseInt(match[1], 10, 64)
+	if err != nil {
+		return false
+	}
+
+	return !ids[id]
+}
+
+// checkChecksums hashes the original files and compares them to the recorded
+// checksums. A changed checksum is only a problem when the object has not
+// been written since it was recorded, otherwise the file was replaced and the
+// record is updated.
+func checkChecksums(
+	ctx context.Context,
+	bucket *blob.Bucket,
+	recorded map[string]models.ObjectChecksum,
+	objects map[string]*blob.ListObject,
+	result *Result,
+) error {
+	keys := make([]string, 0, len(objects))
+	for key := range objects {
+		for _, prefix := range originalPrefixes {
+			if strings.HasPrefix(key, prefix) {
+				keys = append(keys, key)
+				break
+			}
+		}
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		obj := objects[key]
+
+		sum, err := hashObject(ctx, bucket, key)
+		if err != nil {
+			return err
+		}
+
+		checksum := models.ObjectChecksum{
+			Key:     key,
+			SHA256:  sum,
+			Size:    obj.Size,
+			ModTime: obj.ModTime.UTC(),
+		}
+
+		existing, ok := recorded[key]
+		switch {
+		case !ok:
+			result.Checksums = append(result.Checksums, checksum)
+		case existing.SHA256 == sum:
+			continue
+		case obj.ModTime.After(existing.ModTime):
+			result.Checksums = append(result.Checksums, checksum)
+		default:
+			result.Problems = append(result.Problems, Problem{
+				Kind: KindChecksumMismatch,
+				Key:  key,
+				Detail: fmt.Sprintf(
+					"changed since %s without being replaced, recorded %s, got %s",
+					existing.ModTime.Format("2006-01-02 15:04:05"),
+					existing.SHA256,
+					sum,
+				),
+			})
+		}
+	}
+
+	for key := range recorded {
+		if _, ok := objects[key]; !ok {
+			result.RemovedChecksums = append(result.RemovedChecksums, key)
+		}
+	}
+	sort.Strings(result.RemovedChecksums)
+
+	return nil
+}
+
+func hashObject(ctx context.Context, bucket *blob.Bucket, key string) (string, error) {
+	br, err := bucket.NewReader(ctx, key, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to open %s: %w", key, err)
+	}
+	defer br.Close()
+
+	h := sha256.New()
+	_, err = io.Copy(h, br)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", key, err)
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
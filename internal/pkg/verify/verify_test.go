@@ -0,0 +1,138 @@
+package verify
+
+import (
+	"bytes"
+	"image"
+	"image/jpeg"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"gocloud.dev/blob/memblob"
+
+	"github.com/charlieegan3/photos/internal/pkg/imageproxy"
+	"github.com/charlieegan3/photos/internal/pkg/models"
+	"github.com/charlieegan3/photos/internal/pkg/thumbnails"
+)
+
+func TestCheck(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	bucket := memblob.OpenBucket(nil)
+	defer bucket.Close()
+
+	var buf bytes.Buffer
+	err := jpeg.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 300, 200)), nil)
+	require.NoError(t, err)
+
+	media := models.Media{ID: 1, Kind: "jpg", Width: 300, Height: 200}
+	missingMedia := models.Media{ID: 2, Kind: "jpg"}
+
+	for key, data := range map[string][]byte{
+		thumbnails.OriginalPath(media):   buf.Bytes(),
+		"media/3.jpg":                    buf.Bytes(),
+		"thumbs/media/3-500x.jpg":        buf.Bytes(),
+		"device_icons/x100f.png":         []byte("icon"),
+		"device_icons/old.png":           []byte("icon"),
+		"thumbs/device_icons/9-100x.png": []byte("icon"),
+	} {
+		err = bucket.WriteAll(ctx, key, data, nil)
+		require.NoError(t, err)
+	}
+
+	state := State{
+		Medias:  []models.Media{media, missingMedia},
+		Devices: []models.Device{{ID: 1, Slug: "x100f", IconKind: "png"}, {ID: 2, Slug: "instagram"}},
+		Lenses:  []models.Lens{{ID: 1}},
+		BrokenPosts: []models.Post{
+			{ID: 1, MediaID: 5, LocationID: 1},
+		},
+		Checksums: map[string]models.ObjectChecksum{
+			"media/4.jpg": {Key: "media/4.jpg", SHA256: "deleted"},
+		},
+	}
+
+	result, err := Check(ctx, bucket, &imageproxy.Resizer{}, state, Options{Checksums: true})
+	require.NoError(t, err)
+
+	require.Equal(t, []Problem{
+		{Kind: KindBrokenPost, Key: "post 1", Detail: "media 5, location 1"},
+		{Kind: KindMissingThumbnail, Key: "media/1.jpg", Detail: "profiles xlarge, large, medium, small, thumb"},
+		{Kind: KindMissingOriginal, Key: "media/2.jpg", Detail: "media 2"},
+		{Kind: KindMissingIcon, Key: "lens_icons/1.png", Detail: "lens 1"},
+		{Kind: KindOrphanedObject, Key: "device_icons/old.png"},
+		{Kind: KindOrphanedObject, Key: "media/3.jpg"},
+		{Kind: KindOrphanedObject, Key: "thumbs/device_icons/9-100x.png"},
+		{Kind: KindOrphanedObject, Key: "thumbs/media/3-500x.jpg"},
+	}, result.Problems)
+	require.Equal(t, 8, result.Unrepaired())
+	require.Len(t, result.Checksums, 4)
+	require.Equal(t, []string{"media/4.jpg"}, result.RemovedChecksums)
+
+	result, err = Check(ctx, bucket, &imageproxy.Resizer{}, state, Options{Repair: true})
+	require.NoError(t, err)
+	require.Equal(t, 3, result.Unrepaired())
+	require.Empty(t, result.Checksums)
+
+	stale, err := thumbnails.Stale(ctx, bucket, media)
+	require.NoError(t, err)
+	require.Empty(t, stale)
+
+	exists, err := bucket.Exists(ctx, "media/3.jpg")
+	require.NoError(t, err)
+	require.False(t, exists)
+}
+
+func TestCheckChecksums(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	bucket := memblob.OpenBucket(nil)
+	defer bucket.Close()
+
+	err := bucket.WriteAll(ctx, "device_icons/x100f.png", []byte("icon"), nil)
+	require.NoError(t, err)
+
+	state := State{
+		Devices: []models.Device{{ID: 1, Slug: "x100f", IconKind: "png"}},
+	}
+
+	result, err := Check(ctx, bucket, nil, state, Options{Checksums: true})
+	require.NoError(t, err)
+	require.Empty(t, result.Problems)
+	require.Len(t, result.Checksums, 1)
+
+	recorded := result.Checksums[0]
+	state.Checksums = map[string]models.ObjectChecksum{recorded.Key: recorded}
+
+	result, err = Check(ctx, bucket, nil, state, Options{Checksums: true})
+	require.NoError(t, err)
+	require.Empty(t, result.Problems)
+	require.Empty(t, result.Checksums)
+
+	// a different checksum recorded after the object was last written means
+	// the content changed in place
+	corrupted := recorded
+	corrupted.SHA256 = "other"
+	corrupted.ModTime = recorded.ModTime.Add(time.Hour)
+	state.Checksums = map[string]models.ObjectChecksum{recorded.Key: corrupted}
+
+	result, err = Check(ctx, bucket, nil, state, Options{Checksums: true})
+	require.NoError(t, err)
+	require.Len(t, result.Problems, 1)
+	require.Equal(t, KindChecksumMismatch, result.Problems[0].Kind)
+	require.Empty(t, result.Checksums)
+
+	// when the object was replaced after the checksum was recorded the
+	// record is updated instead
+	replaced := recorded
+	replaced.SHA256 = "other"
+	replaced.ModTime = recorded.ModTime.Add(-time.Hour)
+	state.Checksums = map[string]models.ObjectChecksum{recorded.Key: replaced}
+
+	result, err = Check(ctx, bucket, nil, state, Options{Checksums: true})
+	require.NoError(t, err)
+	require.Empty(t, result.Problems)
+	require.Equal(t, []models.ObjectChecksum{recorded}, result.Checksums)
+}